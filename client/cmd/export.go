@@ -163,7 +163,9 @@ Currently, the only supported output format is %s.`,
 			transmitter.LoadOnly(),
 		}
 
-		transmitter := transmitter.OSCTransmitter{Port: player.Port}
+		transmitter := transmitter.OSCTransmitter{
+			Host: player.Host, Port: player.Port,
+		}
 
 		if err := transmitter.TransmitScore(score, transmitOpts...); err != nil {
 			return err
@@ -204,20 +204,14 @@ Currently, the only supported output format is %s.`,
 		default:
 			system.StartingPlayerProcesses()
 
-			if err := util.Await(
-				func() error {
-					player, err := system.FindAvailablePlayer()
-					if err != nil {
-						return err
-					}
-
-					players = []system.PlayerState{player}
-					return nil
-				},
-				reasonableTimeout,
-			); err != nil {
+			player, err := util.AwaitResult(
+				system.FindAvailablePlayer, reasonableTimeout,
+			)
+			if err != nil {
 				return err
 			}
+
+			players = []system.PlayerState{player}
 		}
 
 		transmitOpts := []transmitter.TransmissionOption{
@@ -230,7 +224,9 @@ Currently, the only supported output format is %s.`,
 		}
 
 		for _, player := range players {
-			transmitter := transmitter.OSCTransmitter{Port: player.Port}
+			transmitter := transmitter.OSCTransmitter{
+				Host: player.Host, Port: player.Port,
+			}
 
 			var transmissionError error
 			if action == "unpause" {
@@ -133,7 +133,7 @@ Please choose one of:
 		// number and display the error message at the relevant position in the
 		// file.
 		switch err.(type) {
-		case *model.AldaSourceError:
+		case *model.AldaSourceError, parser.ParseErrors:
 			err = &help.UserFacingError{Err: err}
 		}
 
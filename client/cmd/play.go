@@ -22,6 +22,7 @@ var file string
 var code string
 var optionFrom string
 var optionTo string
+var streamWindow time.Duration
 
 func init() {
 	playCmd.Flags().StringVarP(
@@ -55,6 +56,15 @@ func init() {
 		"",
 		"A time marking (e.g. 1:00) or marker at which to end playback",
 	)
+
+	playCmd.Flags().DurationVar(
+		&streamWindow,
+		"stream-window",
+		0,
+		"Stream the score to the player process in chunks of this length (e.g. "+
+			"30s), shortly before each chunk is played (default: send the whole "+
+			"score at once)",
+	)
 }
 
 // Parses Alda source code piped into stdin and returns the parsed AST.
@@ -175,7 +185,7 @@ var playCmd = &cobra.Command{
 		// TODO: See TODO comment in cmd/parse.go about writing better user-facing
 		// error messages.
 		switch err.(type) {
-		case *model.AldaSourceError:
+		case *model.AldaSourceError, parser.ParseErrors:
 			err = &help.UserFacingError{Err: err}
 		}
 
@@ -262,20 +272,14 @@ var playCmd = &cobra.Command{
 		default:
 			system.StartingPlayerProcesses()
 
-			if err := util.Await(
-				func() error {
-					player, err := system.FindAvailablePlayer()
-					if err != nil {
-						return err
-					}
-
-					players = []system.PlayerState{player}
-					return nil
-				},
-				reasonableTimeout,
-			); err != nil {
+			player, err := util.AwaitResult(
+				system.FindAvailablePlayer, reasonableTimeout,
+			)
+			if err != nil {
 				return err
 			}
+
+			players = []system.PlayerState{player}
 		}
 
 		log.Info().
@@ -284,7 +288,9 @@ var playCmd = &cobra.Command{
 			Msg("Sending messages to players.")
 
 		for _, player := range players {
-			xmitter := transmitter.OSCTransmitter{Port: player.Port}
+			xmitter := transmitter.OSCTransmitter{
+				Host: player.Host, Port: player.Port,
+			}
 
 			var transmissionError error
 			if action == "unpause" {
@@ -295,6 +301,7 @@ var playCmd = &cobra.Command{
 					transmitter.TransmitFrom(optionFrom),
 					transmitter.TransmitTo(optionTo),
 					transmitter.OneOff(),
+					transmitter.StreamInWindows(streamWindow),
 				)
 			}
 			if transmissionError != nil {
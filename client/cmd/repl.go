@@ -19,6 +19,12 @@ var replPort int
 var startREPLClient bool
 var startREPLServer bool
 var replMessage string
+var spawnPlayerOnDemand bool
+var playerPath string
+var playerArgs []string
+var playerEnv []string
+var healthCheckPort int
+var allowRawOSC bool
 
 func init() {
 	replCmd.Flags().StringVarP(
@@ -37,6 +43,53 @@ func init() {
 		&startREPLServer, "server", "s", false, "Start an Alda REPL server",
 	)
 
+	replCmd.Flags().BoolVar(
+		&spawnPlayerOnDemand,
+		"spawn-player-on-demand",
+		false,
+		"Spawn a player process if none is available when the REPL server needs one",
+	)
+
+	replCmd.Flags().StringVar(
+		&playerPath,
+		"player-path",
+		"",
+		"The path to the alda-player executable to use when spawning player "+
+			"processes (default: alda-player on the PATH)",
+	)
+
+	replCmd.Flags().StringArrayVar(
+		&playerArgs,
+		"player-arg",
+		nil,
+		"An additional argument for \"alda-player run\" when spawning player "+
+			"processes (can be repeated)",
+	)
+
+	replCmd.Flags().StringArrayVar(
+		&playerEnv,
+		"player-env",
+		nil,
+		"An additional KEY=value environment variable for spawned player "+
+			"processes (can be repeated)",
+	)
+
+	replCmd.Flags().IntVar(
+		&healthCheckPort,
+		"health-check-port",
+		0,
+		"A port on which the REPL server answers HTTP health checks at /healthz "+
+			"and /readyz and exports metrics at /metrics (default: none)",
+	)
+
+	replCmd.Flags().BoolVar(
+		&allowRawOSC,
+		"allow-raw-osc",
+		false,
+		"Allow REPL clients to send arbitrary OSC messages to the player process "+
+			"(unsafe; for debugging)",
+	)
+
 	replCmd.Flags().StringVarP(
 		&replMessage,
 		"message",
@@ -135,6 +188,10 @@ Examples:
     Starts an Alda REPL server without an interactive prompt. Clients can then
     connect by running ` + "`alda repl --client --port 12345`" + `.
 
+  cat song.alda | alda repl --server --port 12345
+    Starts an Alda REPL server without an interactive prompt, and plays the
+    Alda code piped into it. The code becomes the server's current score.
+
   alda repl --port 12345 --message '{"op": "eval-and-play", "code": "banjo: c"}'
     Sends an nREPL message to the Alda REPL server running on port 12345.
     This is mainly useful for writing scripts and tools for working with Alda.
@@ -165,7 +222,23 @@ Examples:
 				replPort = port
 			}
 
-			server, err := repl.RunServer(replPort)
+			serverOpts := []repl.ServerOption{
+				repl.WithPlayerManagementConfig(repl.PlayerManagementConfig{
+					SpawnPlayerOnDemand: spawnPlayerOnDemand,
+				}),
+				repl.WithPlayerLaunchConfig(system.PlayerLaunchConfig{
+					BinaryPath: playerPath,
+					ExtraArgs:  playerArgs,
+					Env:        playerEnv,
+				}),
+				repl.WithHealthCheckPort(healthCheckPort),
+			}
+
+			if allowRawOSC {
+				serverOpts = append(serverOpts, repl.WithRawOSC())
+			}
+
+			server, err := repl.RunServer(replPort, serverOpts...)
 			if err != nil {
 				return err
 			}
@@ -188,6 +261,19 @@ Examples:
 					server.Close()
 				}()
 			} else {
+				// Alda code piped into a server that has no interactive prompt is
+				// played right away, e.g. `cat song.alda | alda repl --server`.
+				isInputPipedIn, err := system.IsInputBeingPipedIn()
+				if err != nil {
+					return err
+				}
+
+				if isInputPipedIn {
+					if err := server.PlayReader(os.Stdin); err != nil {
+						return err
+					}
+				}
+
 				<-signals
 				server.Close()
 			}
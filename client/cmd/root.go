@@ -12,6 +12,7 @@ import (
 )
 
 var verbosity int
+var logFormat string
 
 // There are certain activities that the Alda CLI performs in the background,
 // like sending telemetry and filling the player pool.
@@ -164,6 +165,7 @@ func init() {
 		// When an invalid verbosity level is supplied, we fallback to the default
 		// log level, 1 (warn).
 		_ = handleVerbosity(cmd)
+		_ = handleLogFormat(cmd)
 
 		informUserOfTelemetryIfNeeded()
 
@@ -189,6 +191,7 @@ func init() {
 	rootCmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
 		// See the line that looks like this above in SetUsageFunc(...)
 		_ = handleVerbosity(cmd)
+		_ = handleLogFormat(cmd)
 
 		informUserOfTelemetryIfNeeded()
 
@@ -237,6 +240,10 @@ Use "{{.CommandPath}} [command] --help" for more information about a command.{{e
 		&verbosity, "verbosity", "v", 1, "verbosity level (0-3)",
 	)
 
+	rootCmd.PersistentFlags().StringVar(
+		&logFormat, "log-format", "text", "log format (text or json)",
+	)
+
 	for _, cmd := range []*cobra.Command{
 		doctorCmd,
 		exportCmd,
@@ -256,6 +263,24 @@ Use "{{.CommandPath}} [command] --help" for more information about a command.{{e
 	}
 }
 
+func handleLogFormat(cmd *cobra.Command) error {
+	switch logFormat {
+	case "text":
+		// This is the default, so there's nothing to do.
+	case "json":
+		log.ConfigureJSON(os.Stderr)
+	default:
+		return &help.UsageError{
+			Cmd: cmd,
+			Err: fmt.Errorf(
+				"invalid log format (%s). Valid formats are text and json", logFormat,
+			),
+		}
+	}
+
+	return nil
+}
+
 func handleVerbosity(cmd *cobra.Command) error {
 	switch verbosity {
 	case 0:
@@ -294,6 +319,10 @@ Slack: https://slack.alda.io`,
 			return err
 		}
 
+		if err := handleLogFormat(cmd); err != nil {
+			return err
+		}
+
 		cleanUpRenamedExecutables()
 
 		informUserOfTelemetryIfNeeded()
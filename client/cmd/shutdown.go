@@ -56,7 +56,9 @@ var shutdownCmd = &cobra.Command{
 		}
 
 		for _, player := range players {
-			transmitter := transmitter.OSCTransmitter{Port: player.Port}
+			transmitter := transmitter.OSCTransmitter{
+				Host: player.Host, Port: player.Port,
+			}
 			if err := transmitter.TransmitShutdownMessage(0); err != nil {
 				log.Warn().
 					Interface("player", player).
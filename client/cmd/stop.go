@@ -64,7 +64,9 @@ var stopCmd = &cobra.Command{
 		}
 
 		for _, player := range players {
-			transmitter := transmitter.OSCTransmitter{Port: player.Port}
+			transmitter := transmitter.OSCTransmitter{
+				Host: player.Host, Port: player.Port,
+			}
 			if err := transmitter.TransmitStopMessage(); err != nil {
 				log.Warn().
 					Interface("player", player).
@@ -1,6 +1,6 @@
 module alda.io/client
 
-go 1.17
+go 1.18
 
 require (
 	github.com/AlecAivazis/survey/v2 v2.2.9
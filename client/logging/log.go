@@ -10,7 +10,15 @@ import (
 	"github.com/rs/zerolog"
 )
 
+// When true, we log newline-delimited JSON instead of human-readable text. (See
+// ConfigureJSON.)
+var jsonFormat = false
+
 func logger(writer io.Writer) zerolog.Logger {
+	if jsonFormat {
+		return zerolog.New(writer).With().Timestamp().Caller().Logger()
+	}
+
 	output := zerolog.ConsoleWriter{
 		Out:        writer,
 		TimeFormat: time.Stamp,
@@ -25,7 +33,7 @@ func logger(writer io.Writer) zerolog.Logger {
 
 var log = logger(os.Stderr)
 
-// SetOutput sets the writer that we log to.
+// SetOutput sets the writer that we log to, keeping the current format.
 //
 // ...OK, technically, we create a NEW logger that is logging to the new writer,
 // because as far as I can tell, zerolog won't let you change the writer of a
@@ -34,26 +42,49 @@ func SetOutput(writer io.Writer) {
 	log = logger(writer)
 }
 
+// ConfigureJSON makes us log to the provided writer as newline-delimited JSON,
+// which is easier for log aggregators to ingest than the default,
+// human-readable format.
+//
+// Each line is a JSON object with `level`, `time`, `caller` and `message`
+// fields, plus any fields specific to that log line, e.g. `player-id` and
+// `port`.
+func ConfigureJSON(writer io.Writer) {
+	jsonFormat = true
+	log = logger(writer)
+}
+
+// ConfigureText makes us log to the provided writer in the default,
+// human-readable format.
+func ConfigureText(writer io.Writer) {
+	jsonFormat = false
+	log = logger(writer)
+}
+
+// NB: These are functions rather than references to the logger's methods so
+// that they use whichever logger is current. (See SetOutput.)
+
 // Debug logs at the DEBUG level.
-var Debug = log.Debug
+func Debug() *zerolog.Event { return log.Debug() }
 
 // Info logs at the INFO level.
-var Info = log.Info
+func Info() *zerolog.Event { return log.Info() }
 
 // Warn logs at the WARN level.
-var Warn = log.Warn
+func Warn() *zerolog.Event { return log.Warn() }
 
 // Error logs at the ERROR level.
-var Error = log.Error
+func Error() *zerolog.Event { return log.Error() }
 
 // Fatal logs at the FATAL level.
-var Fatal = log.Fatal
+func Fatal() *zerolog.Event { return log.Fatal() }
 
 // Panic logs at the PANIC level.
-var Panic = log.Panic
+func Panic() *zerolog.Event { return log.Panic() }
 
-// SetGlobalLevel sets the global logging level.
-func SetGlobalLevel(level string) {
+// SetLevel sets the global logging level, e.g. "debug" or "warn". This can be
+// done at any time, and it affects all subsequent log lines.
+func SetLevel(level string) error {
 	switch level {
 	case "debug":
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
@@ -68,6 +99,16 @@ func SetGlobalLevel(level string) {
 	case "panic":
 		zerolog.SetGlobalLevel(zerolog.PanicLevel)
 	default:
-		panic(fmt.Sprintf("Unrecognized log level: %s", level))
+		return fmt.Errorf("unrecognized log level: %s", level)
+	}
+
+	return nil
+}
+
+// SetGlobalLevel sets the global logging level, panicking if the level is
+// unrecognized.
+func SetGlobalLevel(level string) {
+	if err := SetLevel(level); err != nil {
+		panic(err)
 	}
 }
@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestSetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	ConfigureJSON(&buf)
+
+	initialLevel := zerolog.GlobalLevel()
+	t.Cleanup(func() {
+		zerolog.SetGlobalLevel(initialLevel)
+		ConfigureText(os.Stderr)
+	})
+
+	if err := SetLevel("warn"); err != nil {
+		t.Fatal(err)
+	}
+
+	Debug().Msg("suppressed")
+
+	if strings.Contains(buf.String(), "suppressed") {
+		t.Fatalf("expected the debug message to be suppressed, got %q", buf.String())
+	}
+
+	if err := SetLevel("debug"); err != nil {
+		t.Fatal(err)
+	}
+
+	Debug().Msg("not suppressed")
+
+	if !strings.Contains(buf.String(), "not suppressed") {
+		t.Errorf("expected the debug message to be logged, got %q", buf.String())
+	}
+
+	if err := SetLevel("loud"); err == nil {
+		t.Error("expected an error for an unrecognized log level")
+	}
+}
@@ -373,6 +373,111 @@ func (dm DynamicMarking) updatePart(part *Part, globalUpdate bool) {
 	part.Volume = DynamicVolumes[dm.Marking]
 }
 
+// A dynamicRamp is a crescendo or diminuendo that is in progress.
+type dynamicRamp struct {
+	active bool
+	// The volumes at the beginning and end of the ramp.
+	from float64
+	to   float64
+	// The index in the score's events where the ramp started. Only the notes
+	// added after that point are affected by the ramp.
+	firstEvent int
+}
+
+// DynamicRampStart starts a crescendo or diminuendo in all active parts. Notes
+// are added at the starting volume until the end of the ramp (see
+// DynamicRampEnd), at which point the volumes of the notes within the ramp are
+// adjusted so that they change gradually from the starting volume to the
+// ending volume.
+type DynamicRampStart struct {
+	// When true, the ramp starts at the part's current volume and From is
+	// ignored.
+	FromCurrentVolume bool
+	From              float64
+	To                float64
+}
+
+// JSON implements RepresentableAsJSON.JSON.
+func (drs DynamicRampStart) JSON() *json.Container {
+	value := json.Object("to", drs.To)
+	if !drs.FromCurrentVolume {
+		value.Set(drs.From, "from")
+	}
+
+	return json.Object("attribute", "dynamic-ramp-start", "value", value)
+}
+
+func (drs DynamicRampStart) updatePart(part *Part, globalUpdate bool) {
+	from := drs.From
+	if drs.FromCurrentVolume {
+		from = part.Volume
+	}
+
+	part.Volume = from
+	part.dynamicRamp = dynamicRamp{
+		active:     true,
+		from:       from,
+		to:         drs.To,
+		firstEvent: len(part.score.Events),
+	}
+}
+
+// DynamicRampEnd ends the crescendo or diminuendo in progress in all active
+// parts.
+type DynamicRampEnd struct{}
+
+// JSON implements RepresentableAsJSON.JSON.
+func (DynamicRampEnd) JSON() *json.Container {
+	return json.Object("attribute", "dynamic-ramp-end")
+}
+
+func (DynamicRampEnd) updatePart(part *Part, globalUpdate bool) {
+	ramp := part.dynamicRamp
+	if !ramp.active {
+		log.Warn().
+			Str("part", part.Name).
+			Msg("Ignoring the end of a crescendo/diminuendo that wasn't started.")
+		return
+	}
+
+	// The volume changes linearly over time, from the first note in the ramp to
+	// the last one. Notes that start at the same time (e.g. the notes of a chord)
+	// have the same volume.
+	events := part.score.Events
+	indices := []int{}
+	firstOffset, lastOffset := 0.0, 0.0
+	for i := ramp.firstEvent; i < len(events); i++ {
+		note, ok := events[i].(NoteEvent)
+		if !ok || note.Part != part.origin {
+			continue
+		}
+
+		if len(indices) == 0 || note.Offset < firstOffset {
+			firstOffset = note.Offset
+		}
+		if len(indices) == 0 || note.Offset > lastOffset {
+			lastOffset = note.Offset
+		}
+
+		indices = append(indices, i)
+	}
+
+	for _, i := range indices {
+		note := events[i].(NoteEvent)
+
+		progress := 1.0
+		if lastOffset > firstOffset {
+			progress = (note.Offset - firstOffset) / (lastOffset - firstOffset)
+		}
+
+		note.Volume = ramp.from + (ramp.to-ramp.from)*progress
+		events[i] = note
+	}
+
+	part.Volume = ramp.to
+	part.dynamicRamp = dynamicRamp{}
+}
+
 // PanningSet sets the panning of all active parts.
 type PanningSet struct {
 	Panning float64
@@ -387,6 +492,26 @@ func (ps PanningSet) updatePart(part *Part, globalUpdate bool) {
 	part.Panning = ps.Panning
 }
 
+// DetuneSet detunes the notes of all active parts by a number of cents
+// (hundredths of a semitone), e.g. for microtonal music. A positive number
+// raises the pitch, and a negative number lowers it.
+//
+// MIDI notes can't be detuned individually, so the player process detunes them
+// by bending the pitch of the part's MIDI channel. Parts that share a channel
+// (see `MidiChannel`) share the detuning.
+type DetuneSet struct {
+	Cents float64
+}
+
+// JSON implements RepresentableAsJSON.JSON.
+func (ds DetuneSet) JSON() *json.Container {
+	return json.Object("attribute", "detune", "value", ds.Cents)
+}
+
+func (ds DetuneSet) updatePart(part *Part, globalUpdate bool) {
+	part.Detune = ds.Cents
+}
+
 // QuantizationSet sets the quantization of all active parts.
 type QuantizationSet struct {
 	Quantization float64
@@ -417,6 +542,35 @@ func (ds DurationSet) updatePart(part *Part, globalUpdate bool) {
 	part.Duration = ds.Duration
 }
 
+// SwingSet sets the swing ratio of all active parts.
+type SwingSet struct {
+	Swing float64
+}
+
+// JSON implements RepresentableAsJSON.JSON.
+func (ss SwingSet) JSON() *json.Container {
+	return json.Object("attribute", "swing", "value", ss.Swing)
+}
+
+func (ss SwingSet) updatePart(part *Part, globalUpdate bool) {
+	part.Swing = ss.Swing
+}
+
+// MidiChannelSet pins all active parts to a MIDI channel.
+type MidiChannelSet struct {
+	// The MIDI channel, from 1 to 16.
+	Channel int32
+}
+
+// JSON implements RepresentableAsJSON.JSON.
+func (mcs MidiChannelSet) JSON() *json.Container {
+	return json.Object("attribute", "midi-channel", "value", mcs.Channel)
+}
+
+func (mcs MidiChannelSet) updatePart(part *Part, globalUpdate bool) {
+	part.MidiChannel = mcs.Channel
+}
+
 // KeySignatureSet sets the key signature of all active parts.
 type KeySignatureSet struct {
 	KeySignature KeySignature
@@ -434,6 +588,26 @@ func (kss KeySignatureSet) updatePart(part *Part, globalUpdate bool) {
 	part.KeySignature = kss.KeySignature
 }
 
+// TimeSignatureSet sets the time signature of all active parts. The current
+// offset of each part is considered to be the beginning of a measure.
+type TimeSignatureSet struct {
+	TimeSignature TimeSignature
+}
+
+// JSON implements RepresentableAsJSON.JSON.
+func (tss TimeSignatureSet) JSON() *json.Container {
+	return json.Object(
+		"attribute", "time-signature",
+		"value", tss.TimeSignature.JSON(),
+	)
+}
+
+func (tss TimeSignatureSet) updatePart(part *Part, globalUpdate bool) {
+	part.TimeSignature = tss.TimeSignature
+	part.measureStartOffset = part.CurrentOffset
+	part.measureNumber = 1
+}
+
 // TranspositionSet sets the transposition of all active parts.
 type TranspositionSet struct {
 	Semitones int32
@@ -104,6 +104,15 @@ func expectPartPanning(
 	)
 }
 
+func expectPartDetune(
+	instrument string, detune float64,
+) func(s *Score) error {
+	return expectPartFloatValue(
+		instrument, "detune", func(part *Part) float64 { return part.Detune },
+		detune,
+	)
+}
+
 func expectPartQuantization(
 	instrument string, quantization float64,
 ) func(s *Score) error {
@@ -431,6 +440,47 @@ func TestAttributes(t *testing.T) {
 				expectPartPanning("piano", 0.82),
 			},
 		},
+		scoreUpdateTestCase{
+			label: "initial detune",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectPartDetune("piano", 0),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "set detune using lisp",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				LispList{Elements: []LispForm{
+					LispSymbol{Name: "detune"},
+					LispNumber{Value: -25},
+				}},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectPartDetune("piano", -25),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "set detune globally using lisp",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				LispList{Elements: []LispForm{
+					LispSymbol{Name: "detune!"},
+					LispNumber{Value: 25},
+				}},
+				Note{Pitch: LetterAndAccidentals{NoteLetter: C}},
+				// Global updates are applied to other parts when they reach the offset
+				// of the update.
+				PartDeclaration{Names: []string{"viola"}},
+				Note{Pitch: LetterAndAccidentals{NoteLetter: C}},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectPartDetune("piano", 25),
+				expectPartDetune("viola", 25),
+			},
+		},
 		scoreUpdateTestCase{
 			label: "initial quantization",
 			updates: []ScoreUpdate{
@@ -811,6 +861,37 @@ func TestAttributes(t *testing.T) {
 				),
 			},
 		},
+		scoreUpdateTestCase{
+			label: "set key signature via lisp (string name of scale)",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				LispList{Elements: []LispForm{
+					LispSymbol{Name: "key-signature"},
+					LispString{Value: "g major"},
+				}},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectPartKeySignature("piano", KeySignature{F: {Sharp}}),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "set key signature via lisp (string name of scale with flat)",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				LispList{Elements: []LispForm{
+					LispSymbol{Name: "key-signature"},
+					LispString{Value: "e flat minor"},
+				}},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectPartKeySignature(
+					"piano",
+					KeySignature{
+						B: {Flat}, E: {Flat}, A: {Flat}, D: {Flat}, G: {Flat}, C: {Flat},
+					},
+				),
+			},
+		},
 		scoreUpdateTestCase{
 			// (key-signature '(g major))
 			label: "set key signature via lisp (name of scale 1a)",
@@ -1302,3 +1383,81 @@ func TestAttributes(t *testing.T) {
 		},
 	)
 }
+
+func expectNoteVolumes(expectedVolumes ...float64) func(*Score) error {
+	return expectNoteFloatValues(
+		"volume",
+		func(note NoteEvent) float64 { return note.Volume },
+		expectedVolumes,
+	)
+}
+
+func TestDynamicRamps(t *testing.T) {
+	executeScoreUpdateTestCases(
+		t,
+		scoreUpdateTestCase{
+			label: "crescendo from 50 to 100 over four notes",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				LispList{Elements: []LispForm{
+					LispSymbol{Name: "cresc"},
+					LispNumber{Value: 50},
+					LispNumber{Value: 100},
+				}},
+				quarterNote(C), quarterNote(D), quarterNote(E), quarterNote(F),
+				LispList{Elements: []LispForm{LispSymbol{Name: "end-cresc"}}},
+				quarterNote(G),
+			},
+			expectations: []scoreUpdateExpectation{
+				expectNoteVolumes(0.5, 0.5+0.5/3, 0.5+1.0/3, 1.0, 1.0),
+				expectPartVolume("piano", 1.0),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "diminuendo from the current volume",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				AttributeUpdate{PartUpdate: VolumeSet{Volume: 0.8}},
+				AttributeUpdate{PartUpdate: DynamicRampStart{
+					FromCurrentVolume: true, To: 0.2,
+				}},
+				quarterNote(C), quarterNote(D), quarterNote(E),
+				AttributeUpdate{PartUpdate: DynamicRampEnd{}},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectNoteVolumes(0.8, 0.5, 0.2),
+				expectPartVolume("piano", 0.2),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "notes of a chord within a crescendo have the same volume",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				AttributeUpdate{PartUpdate: DynamicRampStart{From: 0.4, To: 0.6}},
+				Chord{Events: []ScoreUpdate{quarterNote(C), quarterNote(E)}},
+				quarterNote(D),
+				AttributeUpdate{PartUpdate: DynamicRampEnd{}},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectNoteVolumes(0.4, 0.4, 0.6),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "crescendo in one part doesn't affect another part",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				AttributeUpdate{PartUpdate: DynamicRampStart{From: 0.4, To: 0.6}},
+				quarterNote(C),
+				PartDeclaration{Names: []string{"violin"}},
+				AttributeUpdate{PartUpdate: VolumeSet{Volume: 0.9}},
+				quarterNote(C),
+				PartDeclaration{Names: []string{"piano"}},
+				quarterNote(D),
+				AttributeUpdate{PartUpdate: DynamicRampEnd{}},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectNoteVolumes(0.4, 0.9, 0.6),
+			},
+		},
+	)
+}
@@ -40,9 +40,15 @@ func (Barline) Ms(tempo float64) float64 {
 	return 0
 }
 
-// UpdateScore implements ScoreUpdate.UpdateScore by doing nothing. The purpose
-// of a barline is to visually separate elements in an Alda source file.
-func (Barline) UpdateScore(score *Score) error {
+// UpdateScore implements ScoreUpdate.UpdateScore. A barline has no audible
+// effect, but for parts that have a time signature, it marks the end of a
+// measure, which allows us to check the length of the measure. (See
+// Score.CheckBars.)
+func (barline Barline) UpdateScore(score *Score) error {
+	for _, part := range score.CurrentParts {
+		score.recordBar(part, part.CurrentOffset, barline.SourceContext)
+	}
+
 	return nil
 }
 
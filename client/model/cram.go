@@ -1,6 +1,8 @@
 package model
 
 import (
+	"math"
+
 	"alda.io/client/json"
 	"github.com/mohae/deepcopy"
 )
@@ -55,6 +57,10 @@ func innerDurationMs(cram Cram, part *Part) (float64, error) {
 	return totalDurationMs, nil
 }
 
+// The amount of floating point rounding error that we correct for at the end of
+// a Cram expression, in milliseconds.
+const cramRoundingTolerance = 1e-6
+
 func timeScale(cram Cram, part *Part) (float64, error) {
 	duration := effectiveDuration(cram.Duration, part)
 
@@ -96,9 +102,14 @@ func timeScale(cram Cram, part *Part) (float64, error) {
 func (cram Cram) UpdateScore(score *Score) error {
 	previousDurations := map[*Part]Duration{}
 	previousTimeScales := map[*Part]float64{}
+	expectedEndOffsets := map[*Part]float64{}
 	for _, part := range score.CurrentParts {
 		previousDurations[part] = part.Duration
 		previousTimeScales[part] = part.TimeScale
+
+		outerDurationMs := effectiveDuration(cram.Duration, part).Ms(part.Tempo)
+		expectedEndOffsets[part] =
+			part.CurrentOffset + outerDurationMs*part.TimeScale
 	}
 
 	for _, part := range score.CurrentParts {
@@ -127,6 +138,15 @@ func (cram Cram) UpdateScore(score *Score) error {
 			part.Duration = previousDurations[part]
 		}
 		part.TimeScale = previousTimeScales[part]
+
+		// Adding up the time-scaled durations of the events can leave the part a
+		// tiny fraction of a millisecond away from where the Cram expression should
+		// end, due to floating point rounding error. We correct for that here, so
+		// that the error doesn't accumulate over the course of the score.
+		if math.Abs(part.CurrentOffset-expectedEndOffsets[part]) <
+			cramRoundingTolerance {
+			part.CurrentOffset = expectedEndOffsets[part]
+		}
 	}
 
 	return nil
@@ -135,8 +155,13 @@ func (cram Cram) UpdateScore(score *Score) error {
 // DurationMs implements ScoreUpdate.DurationMs by returning the effective
 // duration of the Cram expression, i.e. either the specified duration of the
 // Cram expression or the part's default duration.
+//
+// Like a note, a Cram expression with a specified duration updates the part's
+// default duration. (See UpdateScore.)
 func (cram Cram) DurationMs(part *Part) float64 {
-	return effectiveDuration(cram.Duration, part).Ms(part.Tempo)
+	durationMs := effectiveDuration(cram.Duration, part).Ms(part.Tempo)
+	updateDefaultDuration(part, cram.Duration)
+	return durationMs
 }
 
 // VariableValue implements ScoreUpdate.VariableValue by returning a version of
@@ -322,5 +322,104 @@ func TestCram(t *testing.T) {
 				expectPartDurationBeats("piano", 0.25),
 			},
 		},
+		scoreUpdateTestCase{
+			label: "cram 3 notes into the span of a quarter note (a triplet)",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				Cram{
+					Events: []ScoreUpdate{
+						Note{Pitch: LetterAndAccidentals{NoteLetter: C}},
+						Note{Pitch: LetterAndAccidentals{NoteLetter: D}},
+						Note{Pitch: LetterAndAccidentals{NoteLetter: E}},
+					},
+					Duration: Duration{
+						Components: []DurationComponent{
+							// A quarter note at 120 BPM = 500 ms
+							NoteLength{Denominator: 4},
+						},
+					},
+				},
+			},
+			expectations: []scoreUpdateExpectation{
+				// Each note is a twelfth note.
+				expectNoteDurations(500/3.0, 500/3.0, 500/3.0),
+				expectNoteOffsets(0, 500/3.0, (500/3.0)*2),
+				expectPartCurrentOffset("piano", 500),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "nested cram expression with a specified duration",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				Cram{
+					Events: []ScoreUpdate{
+						// 1 beat
+						Note{Pitch: LetterAndAccidentals{NoteLetter: C}},
+						// 1/2 beat
+						Cram{
+							Events: []ScoreUpdate{
+								Note{Pitch: LetterAndAccidentals{NoteLetter: D}},
+								Note{Pitch: LetterAndAccidentals{NoteLetter: E}},
+							},
+							Duration: Duration{
+								Components: []DurationComponent{
+									NoteLength{Denominator: 8},
+								},
+							},
+						},
+						// 1/2 beat, because the nested cram expression changed the
+						// default duration to an eighth note
+						Note{Pitch: LetterAndAccidentals{NoteLetter: F}},
+					},
+					Duration: Duration{
+						Components: []DurationComponent{
+							// A half note at 120 BPM = 1000 ms
+							NoteLength{Denominator: 2},
+						},
+					},
+				},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectNoteDurations(500, 125, 125, 250),
+				expectNoteOffsets(0, 500, 625, 750),
+				expectPartCurrentOffset("piano", 1000),
+			},
+		},
 	)
 }
+
+// The offsets of the notes within a Cram expression are subject to floating
+// point rounding error, but the Cram expression as a whole should end exactly
+// where it's supposed to.
+func TestCramTotalDurationIsExact(t *testing.T) {
+	for _, noteCount := range []int{3, 5, 6, 7, 9, 11, 13} {
+		events := []ScoreUpdate{}
+		for i := 0; i < noteCount; i++ {
+			events = append(
+				events, Note{Pitch: LetterAndAccidentals{NoteLetter: C}},
+			)
+		}
+
+		score := NewScore()
+		if err := score.Update(
+			PartDeclaration{Names: []string{"piano"}},
+			Cram{
+				Events: events,
+				Duration: Duration{
+					Components: []DurationComponent{NoteLength{Denominator: 4}},
+				},
+			},
+		); err != nil {
+			t.Fatal(err)
+		}
+
+		if offset := score.Parts[0].CurrentOffset; offset != 500 {
+			t.Errorf(
+				"%d notes: expected the cram expression to end at exactly 500 ms, "+
+					"got %.17g",
+				noteCount,
+				offset,
+			)
+		}
+	}
+}
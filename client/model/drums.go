@@ -0,0 +1,90 @@
+package model
+
+import (
+	"fmt"
+
+	"alda.io/client/json"
+)
+
+// The General MIDI percussion key map, i.e. the MIDI note number of each drum
+// sound on the percussion channel.
+var drumNotes = map[string]int32{
+	"acoustic-bass-drum": 35,
+	"bass-drum":          36,
+	"side-stick":         37,
+	"snare":              38,
+	"hand-clap":          39,
+	"electric-snare":     40,
+	"low-floor-tom":      41,
+	"hihat-closed":       42,
+	"high-floor-tom":     43,
+	"hihat-pedal":        44,
+	"low-tom":            45,
+	"hihat-open":         46,
+	"low-mid-tom":        47,
+	"high-mid-tom":       48,
+	"crash-cymbal":       49,
+	"high-tom":           50,
+	"ride-cymbal":        51,
+	"chinese-cymbal":     52,
+	"ride-bell":          53,
+	"tambourine":         54,
+	"splash-cymbal":      55,
+	"cowbell":            56,
+	"crash-cymbal-2":     57,
+	"vibraslap":          58,
+	"ride-cymbal-2":      59,
+	"high-bongo":         60,
+	"low-bongo":          61,
+	"mute-high-conga":    62,
+	"open-high-conga":    63,
+	"low-conga":          64,
+	"high-timbale":       65,
+	"low-timbale":        66,
+	"high-agogo":         67,
+	"low-agogo":          68,
+	"cabasa":             69,
+	"maracas":            70,
+	"short-whistle":      71,
+	"long-whistle":       72,
+	"short-guiro":        73,
+	"long-guiro":         74,
+	"claves":             75,
+	"high-wood-block":    76,
+	"low-wood-block":     77,
+	"mute-cuica":         78,
+	"open-cuica":         79,
+	"mute-triangle":      80,
+	"open-triangle":      81,
+}
+
+// DrumSound specifies a pitch as the name of a General MIDI drum sound, e.g.
+// "snare". This is meant to be used with a percussion instrument, which plays
+// each drum sound at a particular MIDI note number.
+type DrumSound struct {
+	Name string
+}
+
+// NewDrumSound returns the DrumSound with the provided name, or an error if
+// there is no General MIDI drum sound with that name.
+func NewDrumSound(name string) (DrumSound, error) {
+	if _, ok := drumNotes[name]; !ok {
+		return DrumSound{}, fmt.Errorf("unrecognized drum sound: %s", name)
+	}
+
+	return DrumSound{Name: name}, nil
+}
+
+// JSON implements RepresentableAsJSON.JSON.
+func (ds DrumSound) JSON() *json.Container {
+	return json.Object("drum", ds.Name)
+}
+
+// CalculateMidiNote implements PitchIdentifier.CalculateMidiNote by returning
+// the MIDI note number of the drum sound. Unlike other pitches, drum sounds are
+// not affected by the octave or transposition.
+func (ds DrumSound) CalculateMidiNote(
+	octave int32, keySignature KeySignature, transposition int32,
+) int32 {
+	return drumNotes[ds.Name]
+}
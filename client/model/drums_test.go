@@ -0,0 +1,35 @@
+package model
+
+import (
+	"testing"
+
+	_ "alda.io/client/testing"
+)
+
+func TestDrumSounds(t *testing.T) {
+	score := NewScore()
+	if err := score.Update(
+		PartDeclaration{Names: []string{"percussion"}},
+		Note{Pitch: DrumSound{Name: "snare"}},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	notes := score.NoteEvents()
+	if len(notes) != 1 || notes[0].MidiNote != 38 {
+		t.Fatalf("expected a snare (MIDI note 38), got %#v", notes)
+	}
+
+	channels, err := midiChannels(score, []*Part{notes[0].Part})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if channel := channels[notes[0].Part] + 1; channel != 10 {
+		t.Errorf("expected the snare to be on channel 10, got %d", channel)
+	}
+
+	if _, err := NewDrumSound("kazoo"); err == nil {
+		t.Error("expected an error for an unrecognized drum sound")
+	}
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	"testing"
 
 	_ "alda.io/client/testing"
@@ -235,3 +236,61 @@ func TestDuration(t *testing.T) {
 		}
 	}
 }
+
+// noteWithDuration returns a C note with the provided duration components.
+func noteWithDuration(components ...DurationComponent) Note {
+	return Note{
+		Pitch:    LetterAndAccidentals{NoteLetter: C},
+		Duration: Duration{Components: components},
+	}
+}
+
+// setTempo returns a score update that sets the tempo of the current parts.
+func setTempo(bpm float64) LispList {
+	return LispList{Elements: []LispForm{
+		LispSymbol{Name: "tempo"},
+		LispNumber{Value: bpm},
+	}}
+}
+
+func TestFractionalAndMsDurations(t *testing.T) {
+	executeScoreUpdateTestCases(
+		t,
+		scoreUpdateTestCase{
+			label: "three notes of 1/3 of a beat",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				noteWithDuration(NoteLengthBeats{Quantity: 1.0 / 3}),
+				noteWithDuration(),
+				noteWithDuration(),
+				noteWithDuration(NoteLength{Denominator: 4}),
+			},
+			expectations: []scoreUpdateExpectation{
+				expectNoteOffsets(0, 500.0/3, 1000.0/3, 500),
+				// The three notes add up to exactly one beat.
+				func(s *Score) error {
+					if offset := s.Events[3].(NoteEvent).Offset; offset != 500 {
+						return fmt.Errorf("expected offset 500, got %v", offset)
+					}
+
+					return nil
+				},
+			},
+		},
+		scoreUpdateTestCase{
+			label: "millisecond durations don't depend on the tempo",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				setTempo(60),
+				noteWithDuration(NoteLengthMs{Quantity: 250}),
+				setTempo(240),
+				noteWithDuration(NoteLengthMs{Quantity: 250}),
+				noteWithDuration(NoteLengthBeats{Quantity: 1}),
+			},
+			expectations: []scoreUpdateExpectation{
+				expectNoteOffsets(0, 250, 500),
+				expectNoteDurations(250, 250, 250),
+			},
+		},
+	)
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"math"
+
+	"alda.io/client/json"
+)
+
+// A GraceNote marks the next note in each current part as a grace note.
+//
+// A grace note is played just before the beat, such that it ends where it
+// would have started if it were an ordinary note. The note that follows it
+// lands on the beat. Grace notes take up no time in the part, so they can be
+// used for pickups and ornaments without shifting everything after them.
+type GraceNote struct {
+	SourceContext AldaSourceContext
+}
+
+// GetSourceContext implements HasSourceContext.GetSourceContext.
+func (gn GraceNote) GetSourceContext() AldaSourceContext {
+	return gn.SourceContext
+}
+
+// JSON implements RepresentableAsJSON.JSON.
+func (GraceNote) JSON() *json.Container {
+	return json.Object("type", "grace-note")
+}
+
+// UpdateScore implements ScoreUpdate.UpdateScore by marking the next note in
+// each current part as a grace note.
+func (GraceNote) UpdateScore(score *Score) error {
+	for _, part := range score.CurrentParts {
+		part.graceNote = true
+	}
+
+	return nil
+}
+
+// DurationMs implements ScoreUpdate.DurationMs by returning 0, since a grace
+// note takes up no time in the part.
+func (GraceNote) DurationMs(part *Part) float64 {
+	return 0
+}
+
+// VariableValue implements ScoreUpdate.VariableValue.
+func (gn GraceNote) VariableValue(score *Score) (ScoreUpdate, error) {
+	return gn, nil
+}
+
+// graceNoteOffset returns the offset at which a grace note of the given
+// duration starts, i.e. `durationMs` before the part's current offset.
+//
+// A grace note at the very beginning of the score would start before the score
+// does, so we clamp the offset to 0. (In that case, the grace note overlaps the
+// note that follows it.)
+func graceNoteOffset(part *Part, durationMs float64) float64 {
+	return math.Max(0, part.CurrentOffset-durationMs)
+}
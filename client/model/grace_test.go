@@ -0,0 +1,60 @@
+package model
+
+import (
+	"testing"
+
+	_ "alda.io/client/testing"
+)
+
+func TestGraceNotes(t *testing.T) {
+	executeScoreUpdateTestCases(
+		t,
+		scoreUpdateTestCase{
+			label: "grace note before a downbeat",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				quarterNote(C),
+				Marker{Name: "downbeat"},
+				GraceNote{},
+				eighthNote(D),
+				quarterNote(E),
+			},
+			expectations: []scoreUpdateExpectation{
+				expectMarker("downbeat", 500),
+				// The grace note ends right where the main note starts, on the
+				// downbeat.
+				expectNoteOffsets(0, 250, 500),
+				expectNoteDurations(500, 250, 500),
+				expectPartCurrentOffset("piano", 1000),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "grace note doesn't change the default duration",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				quarterNote(C),
+				GraceNote{},
+				eighthNote(D),
+				Note{Pitch: LetterAndAccidentals{NoteLetter: E}},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectNoteOffsets(0, 250, 500),
+				expectPartCurrentOffset("piano", 1000),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "grace note at the beginning of the score",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				GraceNote{},
+				eighthNote(D),
+				quarterNote(E),
+			},
+			expectations: []scoreUpdateExpectation{
+				// The grace note can't start before the score does.
+				expectNoteOffsets(0, 0),
+				expectPartCurrentOffset("piano", 500),
+			},
+		},
+	)
+}
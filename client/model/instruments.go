@@ -1,7 +1,14 @@
 package model
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	log "alda.io/client/logging"
 )
 
 // An Instrument is a template for a Part.
@@ -228,11 +235,45 @@ func InstrumentsList() []string {
 		list = append(list, instrument.name)
 	}
 
-	return list
+	instrumentsLock.RLock()
+	defer instrumentsLock.RUnlock()
+
+	return append(list, customInstruments...)
+}
+
+// MatchInstruments returns the names and aliases of the instruments available
+// to use in an Alda score that start with the provided prefix, ignoring case.
+// The results are sorted alphabetically.
+//
+// This is useful for suggesting instrument names as the user types.
+func MatchInstruments(prefix string) []string {
+	prefix = strings.ToLower(prefix)
+
+	instrumentsLock.RLock()
+	defer instrumentsLock.RUnlock()
+
+	matches := []string{}
+	for identifier := range stockInstruments {
+		if strings.HasPrefix(identifier, prefix) {
+			matches = append(matches, identifier)
+		}
+	}
+
+	sort.Strings(matches)
+
+	return matches
 }
 
 var stockInstruments = map[string]Instrument{}
 
+// The names of the instruments defined via LoadInstruments, in the order in
+// which they were defined.
+var customInstruments = []string{}
+
+// Guards `stockInstruments` and `customInstruments`, which LoadInstruments can
+// change while scores are being parsed.
+var instrumentsLock sync.RWMutex
+
 func init() {
 	for i, instrumentNames := range midiNonPercussionInstruments {
 		instrument := MidiInstrument{
@@ -255,12 +296,37 @@ func init() {
 	}
 }
 
+// SaveInstruments takes note of the instruments that are available to use in a
+// score, and returns a function that restores them, undoing any changes made by
+// LoadInstruments in the meantime. This is mostly useful for testing.
+func SaveInstruments() (restore func()) {
+	instrumentsLock.RLock()
+	defer instrumentsLock.RUnlock()
+
+	savedInstruments := map[string]Instrument{}
+	for identifier, instrument := range stockInstruments {
+		savedInstruments[identifier] = instrument
+	}
+	savedCustomInstruments := append([]string{}, customInstruments...)
+
+	return func() {
+		instrumentsLock.Lock()
+		defer instrumentsLock.Unlock()
+
+		stockInstruments = savedInstruments
+		customInstruments = savedCustomInstruments
+	}
+}
+
 // stockInstrument returns a stock instrument, given an identifier which is the
 // name or alias of a stock instrument.
 //
 // Returns an error if the identifier is not recognized as the name or alias of
 // a stock instrument.
 func stockInstrument(identifier string) (Instrument, error) {
+	instrumentsLock.RLock()
+	defer instrumentsLock.RUnlock()
+
 	instrument, hit := stockInstruments[identifier]
 
 	if !hit {
@@ -283,3 +349,125 @@ func stockInstrumentName(identifier string) (string, error) {
 
 	return instrument.Name(), nil
 }
+
+// An instrumentTable is the format of the instrument definitions that
+// LoadInstruments reads, e.g.:
+//
+//   {
+//     "instruments": {
+//       "lead": {"patch": 82, "aliases": ["synth-lead"]}
+//     },
+//     "aliases": {
+//       "fiddle": "violin"
+//     }
+//   }
+//
+// Patch numbers are 1-128, as in the General MIDI spec.
+type instrumentTable struct {
+	Instruments map[string]struct {
+		Patch      int32    `json:"patch"`
+		Percussion bool     `json:"percussion"`
+		Aliases    []string `json:"aliases"`
+	} `json:"instruments"`
+	Aliases map[string]string `json:"aliases"`
+}
+
+// defineInstrument makes `identifier` refer to `instrument`, warning if it
+// already referred to a different instrument.
+//
+// NB: The caller must hold `instrumentsLock`.
+func defineInstrument(identifier string, instrument Instrument) {
+	if existing, hit := stockInstruments[identifier]; hit &&
+		existing != instrument {
+		log.Warn().
+			Str("identifier", identifier).
+			Str("oldInstrument", existing.Name()).
+			Str("newInstrument", instrument.Name()).
+			Msg("Redefining instrument.")
+	}
+
+	stockInstruments[identifier] = instrument
+}
+
+// LoadInstruments reads a JSON table of instrument definitions and aliases
+// (see `instrumentTable`) and merges it into the instruments available to use
+// in an Alda score. This allows users to define instruments with their own
+// names, and aliases for existing instruments.
+//
+// A name or alias that is already defined is redefined, with a warning.
+//
+// Returns an error if the table can't be parsed, or if it refers to an
+// instrument that doesn't exist or to a patch number outside of 1-128. In that
+// case, none of the table is loaded.
+func LoadInstruments(r io.Reader) error {
+	var table instrumentTable
+	if err := json.NewDecoder(r).Decode(&table); err != nil {
+		return fmt.Errorf("unable to parse instrument definitions: %w", err)
+	}
+
+	names := []string{}
+	for name := range table.Instruments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	instruments := map[string]MidiInstrument{}
+	for _, name := range names {
+		definition := table.Instruments[name]
+		if !definition.Percussion &&
+			(definition.Patch < 1 || definition.Patch > 128) {
+			return fmt.Errorf(
+				"instrument %s: patch number must be 1-128, got %d",
+				name, definition.Patch,
+			)
+		}
+
+		instrument := MidiInstrument{
+			NameImpl: name, IsPercussion: definition.Percussion,
+		}
+		if !definition.Percussion {
+			instrument.PatchNumber = definition.Patch - 1
+		}
+
+		instruments[name] = instrument
+	}
+
+	instrumentsLock.Lock()
+	defer instrumentsLock.Unlock()
+
+	aliases := map[string]Instrument{}
+	for alias, target := range table.Aliases {
+		if instrument, hit := instruments[target]; hit {
+			aliases[alias] = instrument
+			continue
+		}
+
+		instrument, hit := stockInstruments[target]
+		if !hit {
+			return fmt.Errorf(
+				"alias %s refers to an unrecognized instrument: %s", alias, target,
+			)
+		}
+
+		aliases[alias] = instrument
+	}
+
+	for _, name := range names {
+		instrument := instruments[name]
+
+		if _, hit := stockInstruments[name]; !hit {
+			customInstruments = append(customInstruments, name)
+		}
+
+		defineInstrument(name, instrument)
+		for _, alias := range table.Instruments[name].Aliases {
+			defineInstrument(alias, instrument)
+		}
+	}
+
+	for alias, instrument := range aliases {
+		defineInstrument(alias, instrument)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	_ "alda.io/client/testing"
+)
+
+func TestMatchInstruments(t *testing.T) {
+	for _, testCase := range []struct {
+		prefix   string
+		expected []string
+	}{
+		{"vio", []string{"viola", "violin"}},
+		{"VIO", []string{"viola", "violin"}},
+		{"midi-vio", []string{"midi-viola", "midi-violin"}},
+		{"pian", []string{"piano"}},
+		{"midi-acoustic-gr", []string{"midi-acoustic-grand-piano"}},
+		{"kazoo", []string{}},
+	} {
+		actual := MatchInstruments(testCase.prefix)
+		if fmt.Sprint(actual) != fmt.Sprint(testCase.expected) {
+			t.Errorf(
+				"expected %q to match %v, got %v",
+				testCase.prefix, testCase.expected, actual,
+			)
+		}
+	}
+}
+
+// restoreInstrumentsAfterTest undoes any changes that a test makes to the
+// instruments available to use in a score via LoadInstruments.
+func restoreInstrumentsAfterTest(t *testing.T) {
+	t.Cleanup(SaveInstruments())
+}
+
+func TestLoadInstruments(t *testing.T) {
+	restoreInstrumentsAfterTest(t)
+
+	err := LoadInstruments(strings.NewReader(`{
+		"instruments": {
+			"lead": {"patch": 82, "aliases": ["synth-lead"]}
+		},
+		"aliases": {
+			"fiddle": "violin",
+			"piano": "lead"
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for identifier, expected := range map[string]string{
+		"lead":       "lead",
+		"synth-lead": "lead",
+		"fiddle":     "midi-violin",
+		// Redefined, with a warning.
+		"piano": "lead",
+	} {
+		score := NewScore()
+		if err := score.Update(
+			PartDeclaration{Names: []string{identifier}},
+		); err != nil {
+			t.Fatal(err)
+		}
+
+		instrument := score.Parts[0].StockInstrument.(MidiInstrument)
+		if instrument.Name() != expected {
+			t.Errorf(
+				"expected %s to be %s, got %s", identifier, expected, instrument.Name(),
+			)
+		}
+	}
+
+	lead, _ := stockInstrument("lead")
+	if patch := lead.(MidiInstrument).PatchNumber; patch != 81 {
+		t.Errorf("expected lead to use patch 81 (0-based), got %d", patch)
+	}
+
+	list := InstrumentsList()
+	if list[len(list)-1] != "lead" {
+		t.Errorf("expected lead to be listed as an instrument, got %v", list)
+	}
+}
+
+func TestLoadInstrumentsErrors(t *testing.T) {
+	restoreInstrumentsAfterTest(t)
+
+	for _, table := range []string{
+		`not json`,
+		`{"instruments": {"lead": {"patch": 0}}}`,
+		`{"instruments": {"lead": {"patch": 129}}}`,
+		`{"aliases": {"fiddle": "kazoo"}}`,
+		// Nothing is loaded if any part of the table is invalid.
+		`{"instruments": {"lead": {"patch": 82}}, "aliases": {"x": "kazoo"}}`,
+	} {
+		if err := LoadInstruments(strings.NewReader(table)); err == nil {
+			t.Errorf("expected an error loading %s", table)
+		}
+	}
+
+	if _, err := stockInstrument("lead"); err == nil {
+		t.Error("expected lead not to be defined")
+	}
+}
@@ -454,6 +454,22 @@ func integer(form LispForm) (int32, error) {
 	return int32(number.Value), nil
 }
 
+func positiveInteger(form LispForm) (int32, error) {
+	n, err := integer(form)
+	if err != nil {
+		return 0, err
+	}
+
+	if n < 1 {
+		return 0, &AldaSourceError{
+			Context: form.(LispNumber).SourceContext,
+			Err:     fmt.Errorf("expected positive integer, got %d", n),
+		}
+	}
+
+	return n, nil
+}
+
 func percentage(form LispForm) (float64, error) {
 	number := form.(LispNumber)
 
@@ -583,6 +599,19 @@ func keySignatureFromString(form LispForm) (KeySignature, error) {
 
 	strs := strings.Fields(stringLiteral.Value)
 
+	// The string can also be the name of a scale, e.g. "g major" or "e flat
+	// minor", which is equivalent to '(g major) or '(e flat minor).
+	if len(strs) > 1 {
+		symbols := []LispForm{}
+		for _, str := range strs {
+			symbols = append(symbols, LispSymbol{Name: str})
+		}
+
+		if keySig, err := keySignatureFromScaleName(symbols); err == nil {
+			return keySig, nil
+		}
+	}
+
 	keySig := KeySignature{}
 
 	for _, str := range strs {
@@ -1013,6 +1042,91 @@ func init() {
 		)
 	}
 
+	// The swing ratio, i.e. how far into each beat the off-beat falls. 0.5 is
+	// straight time, and 2/3 (~0.66) is a typical triplet swing.
+	defattribute([]string{"swing"},
+		attributeFunctionSignature{
+			argumentTypes: []LispForm{LispNumber{}},
+			implementation: func(args ...LispForm) (PartUpdate, error) {
+				ratio := args[0].(LispNumber)
+				if ratio.Value <= 0 || ratio.Value >= 1 {
+					return nil, &AldaSourceError{
+						Context: ratio.SourceContext,
+						Err: fmt.Errorf(
+							"expected a number between 0 and 1, got %f", ratio.Value,
+						),
+					}
+				}
+				return SwingSet{Swing: ratio.Value}, nil
+			},
+		},
+	)
+
+	// The MIDI channel (1-16) to pin the part to, instead of having one assigned
+	// automatically.
+	defattribute([]string{"midi-channel"},
+		attributeFunctionSignature{
+			argumentTypes: []LispForm{LispNumber{}},
+			implementation: func(args ...LispForm) (PartUpdate, error) {
+				channel, err := integer(args[0])
+				if err != nil {
+					return nil, err
+				}
+				if channel < 1 || channel > 16 {
+					return nil, &AldaSourceError{
+						Context: args[0].(LispNumber).SourceContext,
+						Err: fmt.Errorf(
+							"expected a MIDI channel from 1 to 16, got %d", channel,
+						),
+					}
+				}
+				return MidiChannelSet{Channel: channel}, nil
+			},
+		},
+	)
+
+	// A gradual change in volume over the notes that follow, ending at
+	// (end-cresc), e.g. (cresc 50 100) c d e f (end-cresc).
+	//
+	// When only one value is provided, the ramp starts at the current volume,
+	// e.g. (p) (cresc 80) c d e f (end-cresc).
+	defattribute([]string{"cresc", "crescendo", "dim", "diminuendo"},
+		attributeFunctionSignature{
+			argumentTypes: []LispForm{LispNumber{}},
+			implementation: func(args ...LispForm) (PartUpdate, error) {
+				to, err := percentage(args[0])
+				if err != nil {
+					return nil, err
+				}
+				return DynamicRampStart{FromCurrentVolume: true, To: to}, nil
+			},
+		},
+		attributeFunctionSignature{
+			argumentTypes: []LispForm{LispNumber{}, LispNumber{}},
+			implementation: func(args ...LispForm) (PartUpdate, error) {
+				from, err := percentage(args[0])
+				if err != nil {
+					return nil, err
+				}
+				to, err := percentage(args[1])
+				if err != nil {
+					return nil, err
+				}
+				return DynamicRampStart{From: from, To: to}, nil
+			},
+		},
+	)
+
+	defattribute(
+		[]string{"end-cresc", "end-crescendo", "end-dim", "end-diminuendo"},
+		attributeFunctionSignature{
+			argumentTypes: []LispForm{},
+			implementation: func(args ...LispForm) (PartUpdate, error) {
+				return DynamicRampEnd{}, nil
+			},
+		},
+	)
+
 	// Current panning. 0 = hard left, 100 = hard right.
 	defattribute([]string{"panning", "pan"},
 		attributeFunctionSignature{
@@ -1027,6 +1141,16 @@ func init() {
 		},
 	)
 
+	// Detuning in cents, e.g. 50 = a quarter tone higher.
+	defattribute([]string{"detune"},
+		attributeFunctionSignature{
+			argumentTypes: []LispForm{LispNumber{}},
+			implementation: func(args ...LispForm) (PartUpdate, error) {
+				return DetuneSet{Cents: args[0].(LispNumber).Value}, nil
+			},
+		},
+	)
+
 	// Default note duration in beats.
 	defattribute([]string{"set-duration"},
 		attributeFunctionSignature{
@@ -1112,6 +1236,28 @@ func init() {
 		},
 	)
 
+	// The time signature, e.g. (time-signature 3 4) for 3/4 time. Setting a time
+	// signature opts the part into having its measures checked. (See
+	// Score.CheckBars.)
+	defattribute([]string{"time-signature", "time-sig"},
+		attributeFunctionSignature{
+			argumentTypes: []LispForm{LispNumber{}, LispNumber{}},
+			implementation: func(args ...LispForm) (PartUpdate, error) {
+				beats, err := positiveInteger(args[0])
+				if err != nil {
+					return nil, err
+				}
+				beatUnit, err := positiveInteger(args[1])
+				if err != nil {
+					return nil, err
+				}
+				return TimeSignatureSet{
+					TimeSignature: TimeSignature{Beats: beats, BeatUnit: beatUnit},
+				}, nil
+			},
+		},
+	)
+
 	// The number of semitones to transpose. A negative number means transpose
 	// down, a positive number means transpose up.
 	defattribute([]string{"transposition", "transpose"},
@@ -1205,6 +1351,44 @@ func init() {
 		},
 	)
 
+	// A note that plays a General MIDI drum sound, e.g. (drum 'snare), for use in
+	// a percussion part.
+	drumNote := func(form LispForm) (Note, error) {
+		symbol := form.(LispSymbol)
+
+		drum, err := NewDrumSound(symbol.Name)
+		if err != nil {
+			return Note{}, &AldaSourceError{Context: symbol.SourceContext, Err: err}
+		}
+
+		return Note{Pitch: drum}, nil
+	}
+
+	defn("drum",
+		FunctionSignature{
+			ArgumentTypes: []LispForm{LispSymbol{}},
+			Implementation: func(args ...LispForm) (LispForm, error) {
+				note, err := drumNote(args[0])
+				if err != nil {
+					return nil, err
+				}
+				return LispScoreUpdate{ScoreUpdate: note}, nil
+			},
+		},
+		FunctionSignature{
+			ArgumentTypes: []LispForm{LispSymbol{}, LispDuration{}},
+			Implementation: func(args ...LispForm) (LispForm, error) {
+				note, err := drumNote(args[0])
+				if err != nil {
+					return nil, err
+				}
+				duration := args[1].(LispDuration).DurationComponent
+				note.Duration = Duration{Components: []DurationComponent{duration}}
+				return LispScoreUpdate{ScoreUpdate: note}, nil
+			},
+		},
+	)
+
 	defn("midi-note",
 		FunctionSignature{
 			ArgumentTypes: []LispForm{LispNumber{}},
@@ -1298,6 +1482,38 @@ func init() {
 		},
 	)
 
+	// Marks the next note as a grace note, e.g. (grace) d32 e4.
+	defn("grace",
+		FunctionSignature{
+			ArgumentTypes: []LispForm{},
+			Implementation: func(args ...LispForm) (LispForm, error) {
+				return LispScoreUpdate{ScoreUpdate: GraceNote{}}, nil
+			},
+		},
+	)
+
+	// Selects the note names used in the rest of the input: English letters
+	// (the default), or solfège syllables, e.g. (note-names! :solfege) do re mi.
+	//
+	// The parser switches note names when it encounters this S-expression, so
+	// there is nothing left to do when it's evaluated.
+	defn("note-names!",
+		FunctionSignature{
+			ArgumentTypes: []LispForm{LispSymbol{}},
+			Implementation: func(args ...LispForm) (LispForm, error) {
+				switch name := args[0].(LispSymbol).Name; name {
+				case ":english", ":solfege":
+					return LispScoreUpdate{ScoreUpdate: LispNil{}}, nil
+				default:
+					return nil, fmt.Errorf(
+						"unrecognized note names: %s (expected :english or :solfege)",
+						name,
+					)
+				}
+			},
+		},
+	)
+
 	defn("pause",
 		FunctionSignature{
 			ArgumentTypes: []LispForm{},
@@ -1430,10 +1646,15 @@ func (sym LispSymbol) String() string {
 }
 
 // Eval implements LispForm.Eval by resolving the symbol and returning the
-// corresponding value.
+// corresponding value. Symbols that start with a colon, like `:solfege`, are
+// keywords, which evaluate to themselves.
 //
 // Returns an error if the symbol cannot be resolved.
 func (sym LispSymbol) Eval() (LispForm, error) {
+	if strings.HasPrefix(sym.Name, ":") {
+		return sym, nil
+	}
+
 	specialForm, hit := specialForms[sym.Name]
 	if hit {
 		return specialForm, nil
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MIDI channel 10 (9, counting from 0) is reserved for percussion.
+const midiPercussionChannel = 9
+
+// The time span during which a part is playing notes.
+type partSpan struct {
+	start, end float64
+}
+
+// partSpans returns the time span of the notes of each part in the score that
+// has notes.
+func partSpans(score *Score) map[*Part]partSpan {
+	spans := map[*Part]partSpan{}
+
+	for _, event := range score.Events {
+		note, ok := event.(NoteEvent)
+		if !ok {
+			continue
+		}
+
+		start, end := note.Offset, note.Offset+note.AudibleDuration
+
+		span, ok := spans[note.Part]
+		if !ok {
+			spans[note.Part] = partSpan{start: start, end: end}
+			continue
+		}
+
+		if start < span.start {
+			span.start = start
+		}
+
+		if end > span.end {
+			span.end = end
+		}
+
+		spans[note.Part] = span
+	}
+
+	return spans
+}
+
+// midiChannels assigns a MIDI channel (counting from 0) to each of the
+// provided parts of the score:
+//
+// A part that is pinned to a channel via the `midi-channel` attribute always
+// uses that channel. Otherwise, percussion parts use the percussion channel,
+// and every other part gets a channel of its own from the remaining channels.
+//
+// There are only 16 MIDI channels, so when there are more parts than channels,
+// parts that don't play at the same time share a channel. If there are more
+// parts playing at the same time than there are channels for them, an error is
+// returned.
+func midiChannels(score *Score, parts []*Part) (map[*Part]uint8, error) {
+	channels := map[*Part]uint8{}
+	reserved := map[uint8]bool{midiPercussionChannel: true}
+
+	autoParts := []*Part{}
+
+	for _, part := range parts {
+		if part.MidiChannel != 0 {
+			channel := uint8(part.MidiChannel - 1)
+			channels[part] = channel
+			reserved[channel] = true
+			continue
+		}
+
+		if instrument, ok := part.StockInstrument.(MidiInstrument); ok &&
+			instrument.IsPercussion {
+			channels[part] = midiPercussionChannel
+			continue
+		}
+
+		autoParts = append(autoParts, part)
+	}
+
+	available := []uint8{}
+	for channel := uint8(0); channel < 16; channel++ {
+		if !reserved[channel] {
+			available = append(available, channel)
+		}
+	}
+
+	spans := partSpans(score)
+
+	// We assign channels in the order in which the parts start playing, so that
+	// a channel can be reused by a part that starts after the part that was using
+	// it is finished.
+	sort.SliceStable(autoParts, func(i, j int) bool {
+		return spans[autoParts[i]].start < spans[autoParts[j]].start
+	})
+
+	// The offset at which each channel is no longer in use.
+	busyUntil := map[uint8]float64{}
+
+	for _, part := range autoParts {
+		span, hasNotes := spans[part]
+
+		if !hasNotes {
+			// A part without notes doesn't need a channel of its own.
+			if len(available) > 0 {
+				channels[part] = available[0]
+			}
+
+			continue
+		}
+
+		assigned := false
+
+		for _, channel := range available {
+			if until, inUse := busyUntil[channel]; inUse && until > span.start {
+				continue
+			}
+
+			channels[part] = channel
+			busyUntil[channel] = span.end
+			assigned = true
+			break
+		}
+
+		if !assigned {
+			return nil, fmt.Errorf(
+				"too many parts are playing at once: %s starts at %.0fms, when all "+
+					"%d available MIDI channels are in use",
+				part.Name, span.start, len(available),
+			)
+		}
+	}
+
+	return channels, nil
+}
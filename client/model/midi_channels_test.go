@@ -0,0 +1,136 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	_ "alda.io/client/testing"
+	"gitlab.com/gomidi/midi/midimessage/channel"
+	"gitlab.com/gomidi/midi/smf"
+	"gitlab.com/gomidi/midi/smf/smfreader"
+)
+
+func TestMidiChannels(t *testing.T) {
+	score := NewScore()
+	if err := score.Update(
+		PartDeclaration{Names: []string{"piano"}},
+		quarterNote(C),
+		PartDeclaration{Names: []string{"percussion"}},
+		quarterNote(C),
+		PartDeclaration{Names: []string{"violin"}},
+		AttributeUpdate{PartUpdate: MidiChannelSet{Channel: 3}},
+		quarterNote(C),
+		PartDeclaration{Names: []string{"cello"}},
+		quarterNote(C),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := ExportMIDI(score, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	rd := smfreader.New(bytes.NewReader(buf.Bytes()))
+
+	// The MIDI channel of each track's notes, counting from 1.
+	channels := map[int16]uint8{}
+
+	var err error
+	for {
+		var msg interface{}
+		msg, err = rd.Read()
+		if err != nil {
+			break
+		}
+
+		if noteOn, ok := msg.(channel.NoteOn); ok {
+			channels[rd.Track()] = noteOn.Channel() + 1
+		}
+	}
+
+	if err != smf.ErrFinished {
+		t.Fatal(err)
+	}
+
+	// The piano and cello get the first channels that are available. The
+	// percussion part is on channel 10, and the violin is on the channel that it's
+	// pinned to.
+	expected := map[int16]uint8{1: 1, 2: 10, 3: 3, 4: 2}
+	for track, channel := range expected {
+		if channels[track] != channel {
+			t.Errorf("expected channels %v, got %v", expected, channels)
+			break
+		}
+	}
+}
+
+// consecutiveParts returns score updates for n parts, each of which plays a
+// note after the previous part's note has ended.
+func consecutiveParts(n int) []ScoreUpdate {
+	updates := []ScoreUpdate{}
+
+	for i := 0; i < n; i++ {
+		updates = append(updates,
+			PartDeclaration{Names: []string{"piano"}, Alias: fmt.Sprintf("p%d", i)},
+		)
+
+		if i > 0 {
+			updates = append(updates, Rest{Duration: Duration{
+				Components: []DurationComponent{NoteLengthBeats{Quantity: float64(i)}},
+			}})
+		}
+
+		updates = append(updates, quarterNote(C))
+	}
+
+	return updates
+}
+
+func TestMidiChannelsAreSharedByConsecutiveParts(t *testing.T) {
+	score := NewScore()
+	if err := score.Update(consecutiveParts(20)...); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := []*Part{}
+	for _, part := range score.Parts {
+		parts = append(parts, part)
+	}
+
+	channels, err := midiChannels(score, parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No two parts are playing at the same time, so they can all use the first
+	// channel.
+	for _, part := range parts {
+		if channels[part] != 0 {
+			t.Errorf("expected %s to use the first channel", part.Name)
+		}
+	}
+}
+
+func TestTooManySimultaneousParts(t *testing.T) {
+	updates := []ScoreUpdate{}
+	for i := 0; i < 16; i++ {
+		updates = append(updates,
+			PartDeclaration{Names: []string{"piano"}, Alias: fmt.Sprintf("p%d", i)},
+			quarterNote(C),
+		)
+	}
+
+	score := NewScore()
+	if err := score.Update(updates...); err != nil {
+		t.Fatal(err)
+	}
+
+	// 15 channels are available, since channel 10 is for percussion.
+	err := ExportMIDI(score, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "too many parts") {
+		t.Errorf("expected an error about too many parts, got %v", err)
+	}
+}
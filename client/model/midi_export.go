@@ -0,0 +1,162 @@
+package model
+
+import (
+	"io"
+	"math"
+	"sort"
+
+	"gitlab.com/gomidi/midi"
+	"gitlab.com/gomidi/midi/midimessage/channel"
+	"gitlab.com/gomidi/midi/midimessage/meta"
+	"gitlab.com/gomidi/midi/smf"
+	"gitlab.com/gomidi/midi/smf/smfwriter"
+)
+
+// The resolution of the MIDI files that we export, in ticks per quarter note.
+const midiExportResolution = 960
+
+// midiTicks converts an offset in milliseconds to MIDI ticks.
+func midiTicks(tm tempoMap, offsetMs float64) uint32 {
+	return uint32(math.Round(tm.beats(offsetMs) * midiExportResolution))
+}
+
+// A midiExportEvent is a MIDI message at a particular point in time.
+type midiExportEvent struct {
+	ticks uint32
+	// When two messages happen at the same time, the one with the lower priority
+	// number is written first. This ensures that e.g. a note ends before the next
+	// note with the same MIDI note number starts.
+	priority int
+	message  midi.Message
+}
+
+func writeMidiTrack(wr smf.Writer, events []midiExportEvent) error {
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].ticks != events[j].ticks {
+			return events[i].ticks < events[j].ticks
+		}
+
+		return events[i].priority < events[j].priority
+	})
+
+	var lastTicks uint32
+
+	for _, event := range events {
+		wr.SetDelta(event.ticks - lastTicks)
+		lastTicks = event.ticks
+
+		if err := wr.Write(event.message); err != nil {
+			return err
+		}
+	}
+
+	return wr.Write(meta.EndOfTrack)
+}
+
+// ExportMIDI writes the score to w as a Standard MIDI File, without involving a
+// player process.
+//
+// The file is in format 1. The first track contains the tempo changes in the
+// score (see `TempoItinerary`), and each part has a track of its own after
+// that.
+func ExportMIDI(score *Score, w io.Writer) error {
+	if err := score.ValidateNoteBalance(); err != nil {
+		return err
+	}
+
+	parts := score.originParts()
+
+	tempos := newTempoMap(score.TempoItinerary())
+	channels, err := midiChannels(score, parts)
+	if err != nil {
+		return err
+	}
+
+	spans := partSpans(score)
+
+	tracks := make([][]midiExportEvent, len(parts)+1)
+	partTracks := map[*Part]int{}
+
+	for i, offset := range tempos.offsets {
+		tracks[0] = append(tracks[0], midiExportEvent{
+			ticks:   midiTicks(tempos, offset),
+			message: meta.FractionalBPM(tempos.tempos[i]),
+		})
+	}
+
+	for i, part := range parts {
+		track := i + 1
+		partTracks[part] = track
+
+		tracks[track] = append(
+			tracks[track], midiExportEvent{message: meta.TrackSequenceName(part.Name)},
+		)
+
+		span, hasNotes := spans[part]
+
+		// We currently only have MIDI instruments. (See `Instrument`.)
+		//
+		// A channel can be shared by parts that play at different times (see
+		// `midiChannels`), so each part's program change happens right before its
+		// first note.
+		if instrument, ok := part.StockInstrument.(MidiInstrument); ok && hasNotes {
+			tracks[track] = append(tracks[track], midiExportEvent{
+				ticks: midiTicks(tempos, span.start),
+				message: channel.Channel(channels[part]).ProgramChange(
+					uint8(instrument.PatchNumber),
+				),
+			})
+		}
+	}
+
+	for _, event := range score.Events {
+		note, ok := event.(NoteEvent)
+		if !ok {
+			continue
+		}
+
+		track, ok := partTracks[note.Part]
+		if !ok {
+			continue
+		}
+
+		velocity := uint8(note.Velocity())
+		// A note-on message with a velocity of 0 is treated as a note-off message,
+		// so there is nothing to write for a note that can't be heard.
+		if velocity == 0 {
+			continue
+		}
+
+		ch := channel.Channel(channels[note.Part])
+		key := uint8(note.MidiNote)
+
+		tracks[track] = append(
+			tracks[track],
+			midiExportEvent{
+				ticks:    midiTicks(tempos, note.Offset),
+				priority: 1,
+				message:  ch.NoteOn(key, velocity),
+			},
+			midiExportEvent{
+				ticks:   midiTicks(tempos, note.Offset+note.AudibleDuration),
+				message: ch.NoteOff(key),
+			},
+		)
+	}
+
+	wr := smfwriter.New(
+		w,
+		smfwriter.Format(smf.SMF1),
+		smfwriter.NumTracks(uint16(len(tracks))),
+		smfwriter.TimeFormat(smf.MetricTicks(midiExportResolution)),
+	)
+
+	for _, events := range tracks {
+		// The writer returns smf.ErrFinished once the last track is written.
+		if err := writeMidiTrack(wr, events); err != nil && err != smf.ErrFinished {
+			return err
+		}
+	}
+
+	return nil
+}
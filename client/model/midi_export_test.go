@@ -0,0 +1,127 @@
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	_ "alda.io/client/testing"
+	"gitlab.com/gomidi/midi/midimessage/channel"
+	"gitlab.com/gomidi/midi/midimessage/meta"
+	"gitlab.com/gomidi/midi/smf"
+	"gitlab.com/gomidi/midi/smf/smfreader"
+)
+
+func quarterNote(letter NoteLetter) Note {
+	return Note{
+		Pitch: LetterAndAccidentals{NoteLetter: letter},
+		Duration: Duration{
+			Components: []DurationComponent{NoteLength{Denominator: 4}},
+		},
+	}
+}
+
+func TestExportMIDI(t *testing.T) {
+	score := NewScore()
+	if err := score.Update(
+		PartDeclaration{Names: []string{"piano"}},
+		AttributeUpdate{PartUpdate: TempoSet{Tempo: 60}},
+		Chord{Events: []ScoreUpdate{
+			quarterNote(C), quarterNote(E), quarterNote(G),
+		}},
+		Rest{Duration: Duration{
+			Components: []DurationComponent{NoteLength{Denominator: 4}},
+		}},
+		quarterNote(D),
+		PartDeclaration{Names: []string{"violin"}},
+		quarterNote(E),
+		quarterNote(F),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := ExportMIDI(score, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	rd := smfreader.New(bytes.NewReader(buf.Bytes()))
+
+	noteOns := map[int16][]uint32{}
+	tempos := []float64{}
+	programs := map[int16]uint8{}
+
+	// The delta time of each message is relative to the previous message in the
+	// same track.
+	ticks := map[int16]uint32{}
+
+	var err error
+	for {
+		var msg interface{}
+		msg, err = rd.Read()
+		if err != nil {
+			break
+		}
+
+		track := rd.Track()
+		ticks[track] += rd.Delta()
+
+		switch msg := msg.(type) {
+		case channel.NoteOn:
+			noteOns[track] = append(noteOns[track], ticks[track])
+		case channel.ProgramChange:
+			programs[track] = msg.Program()
+		case meta.Tempo:
+			tempos = append(tempos, msg.FractionalBPM())
+		}
+	}
+
+	if err != smf.ErrFinished {
+		t.Fatal(err)
+	}
+
+	header := rd.Header()
+	if header.Format != smf.SMF1 || header.NumTracks != 3 {
+		t.Errorf("expected a format 1 file with 3 tracks, got %v", header)
+	}
+
+	if len(tempos) != 1 || tempos[0] != 60 {
+		t.Errorf("expected a tempo of 60 bpm, got %v", tempos)
+	}
+
+	// At 60 bpm, the D comes after the chord and the rest, i.e. 2 beats in.
+	expectedPianoTicks := []uint32{0, 0, 0, 2 * midiExportResolution}
+	if len(noteOns[1]) != len(expectedPianoTicks) {
+		t.Fatalf("expected 4 piano notes, got %v", noteOns[1])
+	}
+	for i, expected := range expectedPianoTicks {
+		if noteOns[1][i] != expected {
+			t.Errorf(
+				"expected piano notes at %v, got %v", expectedPianoTicks, noteOns[1],
+			)
+			break
+		}
+	}
+
+	if len(noteOns[2]) != 2 {
+		t.Errorf("expected 2 violin notes, got %v", noteOns[2])
+	}
+
+	if programs[1] != 0 || programs[2] != 40 {
+		t.Errorf("expected programs 0 and 40, got %v", programs)
+	}
+}
+
+func TestMidiTicksWithTempoChanges(t *testing.T) {
+	tempos := newTempoMap(map[float64]float64{0: 120, 1000: 60})
+
+	for offset, expected := range map[float64]uint32{
+		0:    0,
+		500:  midiExportResolution,
+		1000: 2 * midiExportResolution,
+		2000: 3 * midiExportResolution,
+	} {
+		if actual := midiTicks(tempos, offset); actual != expected {
+			t.Errorf("expected %d ticks at %vms, got %d", expected, offset, actual)
+		}
+	}
+}
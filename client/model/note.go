@@ -1,6 +1,8 @@
 package model
 
 import (
+	"math"
+
 	"alda.io/client/json"
 	log "alda.io/client/logging"
 )
@@ -46,6 +48,7 @@ type NoteEvent struct {
 	Volume          float64
 	TrackVolume     float64
 	Panning         float64
+	Detune          float64
 }
 
 // JSON implements RepresentableAsJSON.JSON.
@@ -59,6 +62,7 @@ func (note NoteEvent) JSON() *json.Container {
 		"volume", note.Volume,
 		"track-volume", note.TrackVolume,
 		"panning", note.Panning,
+		"detune", note.Detune,
 	)
 }
 
@@ -68,6 +72,12 @@ func (note NoteEvent) EventOffset() float64 {
 	return note.Offset
 }
 
+// Velocity returns the MIDI velocity (0-127) corresponding to the volume of the
+// note.
+func (note NoteEvent) Velocity() int32 {
+	return int32(math.Round(math.Max(0, math.Min(note.Volume, 1)) * 127))
+}
+
 func effectiveDuration(specifiedDuration Duration, part *Part) Duration {
 	// If no duration is specified, use the part's default duration.
 	if specifiedDuration.Components == nil {
@@ -85,6 +95,22 @@ func updateDefaultDuration(part *Part, duration Duration) {
 	}
 }
 
+func recordBarsWithinDuration(score *Score, part *Part, duration Duration) {
+	elapsedMs := 0.0
+
+	for _, component := range duration.Components {
+		if barline, ok := component.(Barline); ok {
+			score.recordBar(
+				part,
+				part.CurrentOffset+elapsedMs*part.TimeScale,
+				barline.SourceContext,
+			)
+		}
+
+		elapsedMs += component.Ms(part.Tempo)
+	}
+}
+
 func addNoteOrRest(score *Score, noteOrRest ScoreUpdate) error {
 	// Avoid applying the same global attribute change multiple times if we're in
 	// a chord.
@@ -111,9 +137,25 @@ func addNoteOrRest(score *Score, noteOrRest ScoreUpdate) error {
 		duration := effectiveDuration(specifiedDuration, part)
 		durationMs := duration.Ms(part.Tempo) * part.TimeScale
 
+		// A grace note only applies to the next note, not to a rest.
+		graceNote := part.graceNote
+		part.graceNote = false
+
 		switch noteOrRest := noteOrRest.(type) {
 		case Note:
-			audibleDurationMs := durationMs
+			// Swing changes when the note is heard, but not the timing of the notes
+			// that follow, which is why we leave `durationMs` alone.
+			noteOffset := part.swungOffset(part.CurrentOffset)
+			noteDurationMs :=
+				part.swungOffset(part.CurrentOffset+durationMs) - noteOffset
+
+			// A grace note ends where an ordinary note would have started.
+			if graceNote {
+				noteOffset = graceNoteOffset(part, durationMs)
+				noteDurationMs = durationMs
+			}
+
+			audibleDurationMs := noteDurationMs
 			if !noteOrRest.Slurred {
 				audibleDurationMs *= part.Quantization
 			}
@@ -126,12 +168,13 @@ func addNoteOrRest(score *Score, noteOrRest ScoreUpdate) error {
 				noteEvent := NoteEvent{
 					Part:            part.origin,
 					MidiNote:        midiNote,
-					Offset:          part.CurrentOffset,
-					Duration:        durationMs,
+					Offset:          noteOffset,
+					Duration:        noteDurationMs,
 					AudibleDuration: audibleDurationMs,
 					Volume:          part.Volume,
 					TrackVolume:     part.TrackVolume,
 					Panning:         part.Panning,
+					Detune:          part.Detune,
 				}
 
 				log.Debug().
@@ -143,9 +186,22 @@ func addNoteOrRest(score *Score, noteOrRest ScoreUpdate) error {
 
 				score.Events = append(score.Events, noteEvent)
 			}
+
+			// A grace note doesn't advance the part or change its default duration.
+			if graceNote {
+				continue
+			}
 		}
 
 		if !score.chordMode {
+			// A note can be tied across a barline, e.g. `c1~|c`, in which case the
+			// barline ends a measure partway through the note.
+			//
+			// NB: We only consider the specified duration, as opposed to a default
+			// duration carried over from a previous note, so that a barline in the
+			// source is only counted once.
+			recordBarsWithinDuration(score, part, specifiedDuration)
+
 			part.LastOffset = part.CurrentOffset
 			part.CurrentOffset += durationMs
 		}
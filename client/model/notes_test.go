@@ -599,6 +599,31 @@ func TestNotes(t *testing.T) {
 				expectNoteDurations(1500),
 			},
 		},
+		scoreUpdateTestCase{
+			label: "notes in G major",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				LispList{Elements: []LispForm{
+					LispSymbol{Name: "key-signature"},
+					LispString{Value: "g major"},
+				}},
+				// F is sharp in G major.
+				Note{Pitch: LetterAndAccidentals{NoteLetter: F}},
+				// An explicit natural overrides the key signature.
+				Note{Pitch: LetterAndAccidentals{
+					NoteLetter: F, Accidentals: []Accidental{Natural},
+				}},
+				// An explicit flat overrides the key signature.
+				Note{Pitch: LetterAndAccidentals{
+					NoteLetter: F, Accidentals: []Accidental{Flat},
+				}},
+				// Other notes are unaffected.
+				Note{Pitch: LetterAndAccidentals{NoteLetter: G}},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectMidiNoteNumbers(66, 65, 64, 67),
+			},
+		},
 	)
 }
 
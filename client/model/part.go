@@ -41,6 +41,7 @@ type Part struct {
 	TempoRole       TempoRole
 	Tempo           float64
 	KeySignature    KeySignature
+	TimeSignature   TimeSignature
 	Transposition   int32
 	ReferencePitch  float64
 	CurrentOffset   float64
@@ -49,7 +50,10 @@ type Part struct {
 	Volume          float64
 	TrackVolume     float64
 	Panning         float64
+	Detune          float64
 	Quantization    float64
+	Swing           float64
+	MidiChannel     int32
 	Duration        Duration
 	TimeScale       float64
 	// A map of offset to the tempo value that should be applied at that offset.
@@ -59,11 +63,25 @@ type Part struct {
 	// change with a local attribute change just for that part, at the exact same
 	// offset.
 	localAttributeOverride PartUpdate
+	// The offset where the current measure started, and its index, counting
+	// from 1. These are only used when the part has a time signature.
+	//
+	// See time_signature.go.
+	measureStartOffset float64
+	measureNumber      int
+	// A crescendo or diminuendo in progress, if any.
+	//
+	// See DynamicRampStart and DynamicRampEnd.
+	dynamicRamp dynamicRamp
 	// Used for conditionally playing or not playing an event based on how many
 	// times through a repeated sequence the part has played so far.
 	//
 	// See repetitions.go.
 	currentRepetition int32
+	// When true, the next note is a grace note.
+	//
+	// See grace.go.
+	graceNote bool
 	// A snapshot copy of the part at the point in time when a voice group starts.
 	// This is used as a template for each new voice.
 	voiceTemplate *Part
@@ -102,6 +120,7 @@ func (part *Part) JSON() *json.Container {
 		"tempo-role", part.TempoRole.String(),
 		"tempo", part.Tempo,
 		"key-signature", part.KeySignature.JSON(),
+		"time-signature", part.TimeSignature.JSON(),
 		"transposition", part.Transposition,
 		"reference-pitch", part.ReferencePitch,
 		"current-offset", part.CurrentOffset,
@@ -110,7 +129,10 @@ func (part *Part) JSON() *json.Container {
 		"volume", part.Volume,
 		"track-volume", part.TrackVolume,
 		"panning", part.Panning,
+		"detune", part.Detune,
 		"quantization", part.Quantization,
+		"swing", part.Swing,
+		"midi-channel", part.MidiChannel,
 		"duration", part.Duration.JSON(),
 		"time-scale", part.TimeScale,
 		"tempo-values", tempoValues,
@@ -129,6 +151,10 @@ func (part *Part) Clone() *Part {
 
 	// Instead, we manually copy the fields here.
 	clone.currentRepetition = part.currentRepetition
+	clone.graceNote = part.graceNote
+	clone.measureStartOffset = part.measureStartOffset
+	clone.measureNumber = part.measureNumber
+	clone.dynamicRamp = part.dynamicRamp
 	clone.origin = part.origin
 	clone.voiceTemplate = part.voiceTemplate
 	clone.voices = part.voices
@@ -156,6 +182,7 @@ func (score *Score) NewPart(name string) (*Part, error) {
 		TrackVolume:     100.0 / 127,
 		Panning:         0.5,
 		Quantization:    0.9,
+		Swing:           0.5,
 		Duration: Duration{
 			Components: []DurationComponent{NoteLength{Denominator: 4}},
 		},
@@ -254,6 +281,28 @@ func (score *Score) AliasesFor(part *Part) []string {
 	return results
 }
 
+// PartsNamed returns the parts in the score that are identified by `name`,
+// which is either an alias (e.g. "foo" in 'piano "foo"') or the name of an
+// instrument as it was written in the score (e.g. "piano").
+//
+// An alias can refer to several parts, e.g. "strings" in
+// 'violin/viola/cello "strings"'.
+func (score *Score) PartsNamed(name string) []*Part {
+	if parts, ok := score.Aliases[name]; ok {
+		return parts
+	}
+
+	results := []*Part{}
+
+	for _, part := range score.Parts {
+		if part.Name == name {
+			results = append(results, part)
+		}
+	}
+
+	return results
+}
+
 // The PartUpdate interface defines how something updates a part.
 type PartUpdate interface {
 	json.RepresentableAsJSON
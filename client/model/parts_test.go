@@ -186,6 +186,44 @@ func expectAliasParts(
 	}
 }
 
+// expectPartNoteOffsets expects the notes of a particular part to have the
+// provided offsets.
+func expectPartNoteOffsets(
+	instrument string, expectedOffsets ...float64,
+) func(s *Score) error {
+	return func(s *Score) error {
+		part, err := getPart(s, instrument)
+		if err != nil {
+			return err
+		}
+
+		actualOffsets := []float64{}
+		for _, event := range s.Events {
+			if note, ok := event.(NoteEvent); ok && note.Part == part {
+				actualOffsets = append(actualOffsets, note.Offset)
+			}
+		}
+
+		if len(actualOffsets) != len(expectedOffsets) {
+			return fmt.Errorf(
+				"expected %d %s notes, got %d",
+				len(expectedOffsets), instrument, len(actualOffsets),
+			)
+		}
+
+		for i := range expectedOffsets {
+			if !equalish(expectedOffsets[i], actualOffsets[i]) {
+				return fmt.Errorf(
+					"expected %s note #%d to be at offset %f, but it was at offset %f",
+					instrument, i+1, expectedOffsets[i], actualOffsets[i],
+				)
+			}
+		}
+
+		return nil
+	}
+}
+
 func TestParts(t *testing.T) {
 	executeScoreUpdateTestCases(
 		t,
@@ -850,5 +888,23 @@ func TestParts(t *testing.T) {
 				),
 			},
 		},
+		scoreUpdateTestCase{
+			label: "group of parts followed by one of the parts",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano", "guitar"}},
+				Note{Pitch: LetterAndAccidentals{NoteLetter: C}},
+				Note{Pitch: LetterAndAccidentals{NoteLetter: D}},
+				PartDeclaration{Names: []string{"piano"}},
+				Note{Pitch: LetterAndAccidentals{NoteLetter: E}},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectParts("piano", "guitar"),
+				expectCurrentParts("piano"),
+				expectPartNoteOffsets("piano", 0, 500, 1000),
+				expectPartNoteOffsets("guitar", 0, 500),
+				expectPartCurrentOffset("piano", 1500),
+				expectPartCurrentOffset("guitar", 1000),
+			},
+		},
 	)
 }
@@ -0,0 +1,275 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Render returns Alda code that describes the score's note events.
+//
+// The code is written in absolute terms: each note has an explicit pitch and a
+// duration in milliseconds, so parsing the code produces a score with the same
+// parts and notes, regardless of how the original score arrived at them (e.g.
+// via tempo changes, repeats or variables). Attributes that affect individual
+// notes (volume, track volume, panning and quantization) are included where
+// they change.
+//
+// Notes that overlap without starting at the same time are placed in separate
+// voices.
+func Render(score *Score) string {
+	eventsByPart := map[*Part][]NoteEvent{}
+	for _, event := range score.Events {
+		if note, ok := event.(NoteEvent); ok {
+			eventsByPart[note.Part] = append(eventsByPart[note.Part], note)
+		}
+	}
+
+	partNames := renderedPartNames(score.Parts)
+
+	var sb strings.Builder
+	for i, part := range score.Parts {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+
+		sb.WriteString(partNames[part] + ":\n")
+
+		// After a voice group, the part in `score.Parts` is one of the voices, but
+		// each note event refers to the original part.
+		lanes := renderedLanes(part, eventsByPart[part.origin])
+		for j, lane := range lanes {
+			if len(lane.items) == 0 {
+				continue
+			}
+
+			sb.WriteString("  ")
+			if len(lanes) > 1 {
+				sb.WriteString(fmt.Sprintf("V%d: ", j+1))
+			}
+			sb.WriteString(strings.Join(lane.items, " ") + "\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// renderedPartNames returns the part declaration to use for each part. When a
+// score has more than one part with the same instrument, each of those parts is
+// given an alias, so that they can be told apart.
+func renderedPartNames(parts []*Part) map[*Part]string {
+	counts := map[string]int{}
+	for _, part := range parts {
+		counts[part.Name]++
+	}
+
+	names := map[*Part]string{}
+	seen := map[string]int{}
+	for _, part := range parts {
+		if counts[part.Name] == 1 {
+			names[part] = part.Name
+			continue
+		}
+
+		seen[part.Name]++
+		names[part] = fmt.Sprintf(
+			`%s "%s-%d"`, part.Name, part.Name, seen[part.Name],
+		)
+	}
+
+	return names
+}
+
+// Offsets and durations are floating point numbers, so we allow for a little
+// bit of rounding error when comparing them.
+const renderTolerance = 1e-6
+
+// renderedNumber formats a number the way we would write it by hand, e.g. 50
+// instead of 50.000000.
+func renderedNumber(n float64) string {
+	return strconv.FormatFloat(math.Round(n*1e9)/1e9, 'f', -1, 64)
+}
+
+// noteAttributes are the attributes of a part that affect individual notes.
+type noteAttributes struct {
+	volume       float64
+	trackVolume  float64
+	panning      float64
+	detune       float64
+	quantization float64
+}
+
+func attributesOf(note NoteEvent) noteAttributes {
+	return noteAttributes{
+		volume:       note.Volume,
+		trackVolume:  note.TrackVolume,
+		panning:      note.Panning,
+		detune:       note.Detune,
+		quantization: note.AudibleDuration / note.Duration,
+	}
+}
+
+func sameValue(a, b float64) bool {
+	return math.Abs(a-b) < renderTolerance
+}
+
+// changes returns the attribute changes needed to go from `from` to `to`.
+func (from noteAttributes) changes(to noteAttributes) []string {
+	changes := []string{}
+
+	for _, attribute := range []struct {
+		name     string
+		from, to float64
+		// The attribute is written as its value times this, e.g. 0.5 is written
+		// as 50 for attributes that are percentages.
+		scale float64
+	}{
+		{"vol", from.volume, to.volume, 100},
+		{"track-vol", from.trackVolume, to.trackVolume, 100},
+		{"panning", from.panning, to.panning, 100},
+		{"detune", from.detune, to.detune, 1},
+		{"quant", from.quantization, to.quantization, 100},
+	} {
+		if !sameValue(attribute.from, attribute.to) {
+			changes = append(changes, fmt.Sprintf(
+				"(%s %s)",
+				attribute.name,
+				renderedNumber(attribute.to*attribute.scale),
+			))
+		}
+	}
+
+	return changes
+}
+
+// renderedLane is a sequence of notes, chords and rests that don't overlap.
+type renderedLane struct {
+	items      []string
+	offset     float64
+	octave     int32
+	attributes noteAttributes
+}
+
+var renderedPitchClasses = []string{
+	"c", "c+", "d", "d+", "e", "f", "f+", "g", "g+", "a", "a+", "b",
+}
+
+// octaveChange returns the Alda code that changes the lane's octave to the
+// provided octave, or "" if the lane is already in that octave.
+func (lane *renderedLane) octaveChange(octave int32) string {
+	previous := lane.octave
+	lane.octave = octave
+
+	switch octave - previous {
+	case 0:
+		return ""
+	case 1:
+		return ">"
+	case -1:
+		return "<"
+	}
+
+	if octave < 0 {
+		return "o0 " + strings.Repeat("<", int(-octave))
+	}
+
+	return fmt.Sprintf("o%d", octave)
+}
+
+// addChord adds notes that start at the same offset and have the same
+// attributes to the lane, preceded by a rest if there is a gap between the end
+// of the lane and the start of the notes.
+func (lane *renderedLane) addChord(notes []NoteEvent) {
+	offset := notes[0].Offset
+
+	if gap := offset - lane.offset; gap > renderTolerance {
+		lane.items = append(lane.items, "r"+renderedNumber(gap)+"ms")
+	}
+
+	lane.items = append(
+		lane.items, lane.attributes.changes(attributesOf(notes[0]))...,
+	)
+	lane.attributes = attributesOf(notes[0])
+
+	chordNotes := []string{}
+	shortestDuration := math.MaxFloat64
+
+	for _, note := range notes {
+		octave := note.MidiNote/12 - 1
+		pitchClass := renderedPitchClasses[note.MidiNote%12]
+
+		chordNote := pitchClass + renderedNumber(note.Duration) + "ms"
+		if change := lane.octaveChange(octave); change != "" {
+			chordNote = change + " " + chordNote
+		}
+
+		chordNotes = append(chordNotes, chordNote)
+		shortestDuration = math.Min(shortestDuration, note.Duration)
+	}
+
+	lane.items = append(lane.items, strings.Join(chordNotes, "/"))
+	lane.offset = offset + shortestDuration
+}
+
+// renderedLanes arranges a part's notes into as few non-overlapping lanes as
+// possible. Each lane after the first becomes a voice.
+func renderedLanes(part *Part, notes []NoteEvent) []*renderedLane {
+	sort.SliceStable(notes, func(i, j int) bool {
+		return notes[i].Offset < notes[j].Offset
+	})
+
+	// Each lane starts out with the attributes of a new part, which is what the
+	// part will have when the rendered code is parsed.
+	initial, err := NewScore().NewPart(part.Name)
+	if err != nil {
+		initial = part
+	}
+
+	newLane := func() *renderedLane {
+		return &renderedLane{
+			octave: initial.Octave,
+			attributes: noteAttributes{
+				volume:       initial.Volume,
+				trackVolume:  initial.TrackVolume,
+				panning:      initial.Panning,
+				detune:       initial.Detune,
+				quantization: initial.Quantization,
+			},
+		}
+	}
+
+	lanes := []*renderedLane{newLane()}
+
+	for len(notes) > 0 {
+		// Notes that start at the same time and have the same attributes are
+		// rendered as a chord.
+		chordSize := 1
+		for chordSize < len(notes) &&
+			sameValue(notes[chordSize].Offset, notes[0].Offset) &&
+			attributesOf(notes[chordSize]) == attributesOf(notes[0]) {
+			chordSize++
+		}
+
+		chord := notes[:chordSize]
+		notes = notes[chordSize:]
+
+		var lane *renderedLane
+		for _, l := range lanes {
+			if l.offset <= chord[0].Offset+renderTolerance {
+				lane = l
+				break
+			}
+		}
+
+		if lane == nil {
+			lane = newLane()
+			lanes = append(lanes, lane)
+		}
+
+		lane.addChord(chord)
+	}
+
+	return lanes
+}
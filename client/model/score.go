@@ -1,8 +1,12 @@
 package model
 
 import (
+	"fmt"
+	"math"
 	"regexp"
+	"sort"
 	"strconv"
+	"time"
 
 	"alda.io/client/color"
 	"alda.io/client/help"
@@ -59,6 +63,9 @@ type Score struct {
 	Markers          map[string]float64
 	Variables        map[string][]ScoreUpdate
 	chordMode        bool
+	// Measures whose lengths don't agree with their parts' time signatures.
+	// See CheckBars.
+	barErrors []BarError
 }
 
 // JSON implements RepresentableAsJSON.JSON.
@@ -134,6 +141,204 @@ func (score *Score) Update(updates ...ScoreUpdate) error {
 	return nil
 }
 
+// NoteEvents returns the notes in the score in the order in which they are
+// scheduled to be played, i.e. sorted by offset. Notes with the same offset are
+// returned in the order in which they were added to the score.
+//
+// Unlike `score.Events`, which is in the order in which the events were added
+// (e.g. all of one part's notes, followed by all of another part's notes), this
+// is suitable for walking through the score from beginning to end.
+func (score *Score) NoteEvents() []NoteEvent {
+	notes := []NoteEvent{}
+	for _, event := range score.Events {
+		if note, ok := event.(NoteEvent); ok {
+			notes = append(notes, note)
+		}
+	}
+
+	sort.SliceStable(notes, func(i, j int) bool {
+		return notes[i].Offset < notes[j].Offset
+	})
+
+	return notes
+}
+
+// originParts returns the parts in the score, in the order in which they were
+// added.
+//
+// After a voice group, `score.Parts` contains the voices rather than the parts
+// that the voices belong to, so we need to find the original parts.
+func (score *Score) originParts() []*Part {
+	parts := []*Part{}
+	seen := map[*Part]bool{}
+	for _, part := range score.Parts {
+		if !seen[part.origin] {
+			seen[part.origin] = true
+			parts = append(parts, part.origin)
+		}
+	}
+
+	return parts
+}
+
+// Duration returns how long the score takes to play from beginning to end,
+// including any rests at the end. Tempo changes are taken into account.
+func (score *Score) Duration() time.Duration {
+	endMs := 0.0
+
+	for _, part := range score.originParts() {
+		endMs = math.Max(endMs, part.CurrentOffset)
+	}
+
+	// A note in a chord can be longer than the part's last note or rest, in which
+	// case it ends after the part's current offset.
+	for _, note := range score.NoteEvents() {
+		endMs = math.Max(endMs, note.Offset+note.Duration)
+	}
+
+	return time.Duration(endMs * float64(time.Millisecond))
+}
+
+// A PartSummary describes a part in a score. (See `Score.PartSummaries`.)
+type PartSummary struct {
+	// The part's alias, if it has one, or otherwise the name of the instrument
+	// as it was written in the score, e.g. "piano".
+	Name string
+	// The name of the part's stock instrument, e.g. "midi-acoustic-grand-piano".
+	Instrument string
+	// The number of notes that the part plays.
+	Notes int
+}
+
+// JSON implements RepresentableAsJSON.JSON.
+func (ps PartSummary) JSON() *json.Container {
+	return json.Object(
+		"name", ps.Name,
+		"instrument", ps.Instrument,
+		"notes", ps.Notes,
+	)
+}
+
+// PartSummaries returns a summary of each part in the score, in the order in
+// which the parts were added.
+func (score *Score) PartSummaries() []PartSummary {
+	notes := map[*Part]int{}
+	for _, note := range score.NoteEvents() {
+		notes[note.Part]++
+	}
+
+	summaries := []PartSummary{}
+
+	for _, part := range score.originParts() {
+		name := part.Name
+		if aliases := score.AliasesFor(part); len(aliases) > 0 {
+			sort.Strings(aliases)
+			name = aliases[0]
+		}
+
+		summaries = append(summaries, PartSummary{
+			Name:       name,
+			Instrument: part.StockInstrument.Name(),
+			Notes:      notes[part],
+		})
+	}
+
+	return summaries
+}
+
+// Transpose shifts the pitch of every note in the score by the provided number
+// of semitones. Offsets and durations are unchanged.
+//
+// Returns an error without changing any notes if a note would be shifted out of
+// the range of valid MIDI note numbers (0-127).
+func (score *Score) Transpose(semitones int) error {
+	return score.TransposeFrom(0, semitones)
+}
+
+// TransposeFrom is like Transpose, but it only shifts the notes from index
+// `from` of the score's events onward.
+func (score *Score) TransposeFrom(from int, semitones int) error {
+	for _, event := range score.Events[from:] {
+		note, ok := event.(NoteEvent)
+		if !ok {
+			continue
+		}
+
+		transposed := int(note.MidiNote) + semitones
+		if transposed < 0 || transposed > 127 {
+			return fmt.Errorf(
+				"unable to transpose by %d semitones: MIDI note %d would be out of "+
+					"range (%d)",
+				semitones,
+				note.MidiNote,
+				transposed,
+			)
+		}
+	}
+
+	for i := from; i < len(score.Events); i++ {
+		if note, ok := score.Events[i].(NoteEvent); ok {
+			note.MidiNote += int32(semitones)
+			score.Events[i] = note
+		}
+	}
+
+	return nil
+}
+
+// ValidateNoteBalance checks that every note in the score can be emitted as a
+// note-on followed by a matching note-off on the same track, i.e. that its
+// audible duration is a finite, positive number of milliseconds. A note that
+// fails this check would either never be turned off (a "hung" note) or be
+// turned off before it's turned on, which always points to a bug in the way
+// that we computed its duration.
+//
+// Returns an error describing the first unbalanced note and how many notes on
+// its track are unbalanced.
+func (score *Score) ValidateNoteBalance() error {
+	tracks := score.Tracks()
+	noteOns := map[int32]int{}
+	noteOffs := map[int32]int{}
+	var unbalanced *NoteEvent
+
+	for _, event := range score.Events {
+		note, ok := event.(NoteEvent)
+		if !ok {
+			continue
+		}
+
+		track := tracks[note.Part]
+		noteOns[track]++
+
+		noteOff := note.Offset + note.AudibleDuration
+		if math.IsNaN(noteOff) || math.IsInf(noteOff, 0) || noteOff <= note.Offset {
+			if unbalanced == nil {
+				unbalanced = &note
+			}
+			continue
+		}
+
+		noteOffs[track]++
+	}
+
+	if unbalanced == nil {
+		return nil
+	}
+
+	track := tracks[unbalanced.Part]
+
+	return fmt.Errorf(
+		"track %d has %d note-ons but %d note-offs: MIDI note %d at offset "+
+			"%.2f ms has no matching note-off (audible duration: %v ms)",
+		track,
+		noteOns[track],
+		noteOffs[track],
+		unbalanced.MidiNote,
+		unbalanced.Offset,
+		unbalanced.AudibleDuration,
+	)
+}
+
 // Tracks returns a map of Part instances to track numbers for the purposes of
 // transmitting score data.
 func (score *Score) Tracks() map[*Part]int32 {
@@ -0,0 +1,183 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	_ "alda.io/client/testing"
+)
+
+func TestTranspose(t *testing.T) {
+	score := NewScore()
+	if err := score.Update(
+		PartDeclaration{Names: []string{"piano"}},
+		Note{Pitch: LetterAndAccidentals{NoteLetter: C}},
+		Note{Pitch: LetterAndAccidentals{NoteLetter: D}},
+		Note{Pitch: LetterAndAccidentals{NoteLetter: E}},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := score.Transpose(12); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expectation := range []scoreUpdateExpectation{
+		expectMidiNoteNumbers(72, 74, 76),
+		expectNoteOffsets(0, 500, 1000),
+	} {
+		if err := expectation(score); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestTransposeFrom(t *testing.T) {
+	score := NewScore()
+	if err := score.Update(
+		PartDeclaration{Names: []string{"piano"}},
+		Note{Pitch: LetterAndAccidentals{NoteLetter: C}},
+		Note{Pitch: LetterAndAccidentals{NoteLetter: D}},
+		Note{Pitch: LetterAndAccidentals{NoteLetter: E}},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := score.TransposeFrom(1, -2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := expectMidiNoteNumbers(60, 60, 62)(score); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTransposeOutOfRange(t *testing.T) {
+	score := NewScore()
+	if err := score.Update(
+		PartDeclaration{Names: []string{"piano"}},
+		AttributeUpdate{PartUpdate: OctaveSet{OctaveNumber: 9}},
+		Note{Pitch: LetterAndAccidentals{NoteLetter: C}},
+		Note{Pitch: LetterAndAccidentals{NoteLetter: G}},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := score.Transpose(1); err == nil {
+		t.Fatal("expected an error when transposing G9 up")
+	}
+
+	// None of the notes are transposed if any of them would be out of range.
+	if err := expectMidiNoteNumbers(120, 127)(score); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNoteEvents(t *testing.T) {
+	score := NewScore()
+	if err := score.Update(
+		PartDeclaration{Names: []string{"piano"}},
+		quarterNote(C), quarterNote(D), quarterNote(E),
+		PartDeclaration{Names: []string{"violin"}},
+		quarterNote(F), quarterNote(G),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	notes := score.NoteEvents()
+
+	if len(notes) != 5 {
+		t.Fatalf("expected 5 notes, got %d", len(notes))
+	}
+
+	for i := 1; i < len(notes); i++ {
+		if notes[i].Offset < notes[i-1].Offset {
+			t.Errorf(
+				"note %d (offset %f) comes after note %d (offset %f)",
+				i, notes[i].Offset, i-1, notes[i-1].Offset,
+			)
+		}
+	}
+
+	expectedNotes := []int32{60, 65, 62, 67, 64}
+	for i, note := range notes {
+		if note.MidiNote != expectedNotes[i] {
+			t.Errorf(
+				"expected note %d to be %d, got %d", i, expectedNotes[i], note.MidiNote,
+			)
+		}
+	}
+
+	if velocity := notes[0].Velocity(); velocity != 69 {
+		t.Errorf("expected velocity 69, got %d", velocity)
+	}
+}
+
+func TestValidateNoteBalance(t *testing.T) {
+	score := NewScore()
+	if err := score.Update(
+		PartDeclaration{Names: []string{"piano"}},
+		quarterNote(C), quarterNote(D), quarterNote(E),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := score.ValidateNoteBalance(); err != nil {
+		t.Fatalf("expected the score's notes to be balanced, got %v", err)
+	}
+
+	// Deliberately break the second note, as if we had computed its duration
+	// incorrectly. Its note-off would come before its note-on.
+	note := score.Events[1].(NoteEvent)
+	note.AudibleDuration = -450
+	score.Events[1] = note
+
+	err := score.ValidateNoteBalance()
+	if err == nil {
+		t.Fatal("expected an error about an unbalanced note")
+	}
+
+	for _, expected := range []string{
+		"track 1 has 3 note-ons but 2 note-offs", "MIDI note 62",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q to contain %q", err, expected)
+		}
+	}
+}
+
+func TestDurationAndPartSummaries(t *testing.T) {
+	score := NewScore()
+	if err := score.Update(
+		PartDeclaration{Names: []string{"piano"}},
+		quarterNote(C), quarterNote(D),
+		// Halving the tempo makes the last note twice as long.
+		AttributeUpdate{PartUpdate: TempoSet{Tempo: 60}},
+		quarterNote(E),
+		PartDeclaration{Names: []string{"violin"}, Alias: "fiddle"},
+		quarterNote(F), quarterNote(G),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if duration := score.Duration(); duration != 2*time.Second {
+		t.Errorf("expected duration 2s, got %s", duration)
+	}
+
+	expected := []PartSummary{
+		{Name: "piano", Instrument: "midi-acoustic-grand-piano", Notes: 3},
+		{Name: "fiddle", Instrument: "midi-violin", Notes: 2},
+	}
+
+	summaries := score.PartSummaries()
+	if len(summaries) != len(expected) {
+		t.Fatalf("expected %d parts, got %#v", len(expected), summaries)
+	}
+
+	for i, summary := range summaries {
+		if summary != expected[i] {
+			t.Errorf("expected part %d to be %#v, got %#v", i, expected[i], summary)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package model
+
+import "math"
+
+// Swing is modeled as a distortion of time within each beat. The swing ratio is
+// how far into the beat the off-beat (the second eighth note) is heard, so at
+// a ratio of 0.5, there is no distortion at all.
+//
+// The first half of the beat is stretched (or squashed) to fill the part of the
+// beat before the swung off-beat, and the second half fills the rest of the
+// beat. This means that the on-beat eighth note becomes longer and the
+// off-beat eighth note becomes shorter, and finer subdivisions like sixteenth
+// notes are swung proportionally.
+
+// swungOffset returns the offset at which something that would happen at the
+// provided offset in straight time should be heard, given the part's swing
+// ratio.
+func (part *Part) swungOffset(offsetMs float64) float64 {
+	if part.Swing == 0.5 {
+		return offsetMs
+	}
+
+	beats := newTempoMap(part.TempoValues).beats(offsetMs)
+	position := beats - math.Floor(beats)
+
+	var swungPosition float64
+	if position <= 0.5 {
+		swungPosition = position * 2 * part.Swing
+	} else {
+		swungPosition = part.Swing + (position-0.5)*2*(1-part.Swing)
+	}
+
+	msPerBeat := 60000 / part.Tempo
+	return offsetMs + (swungPosition-position)*msPerBeat
+}
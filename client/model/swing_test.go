@@ -0,0 +1,74 @@
+package model
+
+import (
+	"testing"
+
+	_ "alda.io/client/testing"
+)
+
+func eighthNote(letter NoteLetter) Note {
+	return Note{
+		Pitch: LetterAndAccidentals{NoteLetter: letter},
+		Duration: Duration{
+			Components: []DurationComponent{NoteLength{Denominator: 8}},
+		},
+	}
+}
+
+func TestSwing(t *testing.T) {
+	executeScoreUpdateTestCases(
+		t,
+		scoreUpdateTestCase{
+			label: "swing 0.66",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				AttributeUpdate{PartUpdate: SwingSet{Swing: 0.66}},
+				eighthNote(C), eighthNote(D), eighthNote(E), eighthNote(F),
+			},
+			expectations: []scoreUpdateExpectation{
+				// The second of each pair of eighths starts later than the halfway
+				// point of the beat (250 ms).
+				expectNoteOffsets(0, 330, 500, 830),
+				expectNoteDurations(330, 170, 330, 170),
+				expectPartCurrentOffset("piano", 1000),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "swing 0.5 is straight",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				AttributeUpdate{PartUpdate: SwingSet{Swing: 0.5}},
+				eighthNote(C), eighthNote(D), eighthNote(E), eighthNote(F),
+			},
+			expectations: []scoreUpdateExpectation{
+				expectNoteOffsets(0, 250, 500, 750),
+				expectNoteDurations(250, 250, 250, 250),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "notes on the beat are unaffected",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				AttributeUpdate{PartUpdate: SwingSet{Swing: 0.66}},
+				quarterNote(C), quarterNote(D),
+			},
+			expectations: []scoreUpdateExpectation{
+				expectNoteOffsets(0, 500),
+				expectNoteDurations(500, 500),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "only notes within the swing's scope are swung",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				AttributeUpdate{PartUpdate: SwingSet{Swing: 0.66}},
+				eighthNote(C), eighthNote(D),
+				AttributeUpdate{PartUpdate: SwingSet{Swing: 0.5}},
+				eighthNote(E), eighthNote(F),
+			},
+			expectations: []scoreUpdateExpectation{
+				expectNoteOffsets(0, 330, 500, 750),
+			},
+		},
+	)
+}
@@ -0,0 +1,62 @@
+package model
+
+import "sort"
+
+// A tempoMap is a history of tempo changes, which can be used to convert an
+// offset in milliseconds to a number of beats.
+type tempoMap struct {
+	offsets []float64
+	tempos  []float64
+}
+
+// newTempoMap returns a tempoMap for a map of offsets to the tempo value that
+// starts at that offset, e.g. the result of Score.TempoItinerary or a part's
+// TempoValues.
+//
+// The tempo is assumed to be 120 bpm until the first tempo change.
+func newTempoMap(tempoValues map[float64]float64) tempoMap {
+	tm := tempoMap{}
+
+	if _, hit := tempoValues[0]; !hit {
+		tm.offsets = append(tm.offsets, 0)
+	}
+
+	for offset := range tempoValues {
+		tm.offsets = append(tm.offsets, offset)
+	}
+
+	sort.Float64s(tm.offsets)
+
+	for _, offset := range tm.offsets {
+		tempo, hit := tempoValues[offset]
+		if !hit {
+			tempo = 120
+		}
+
+		tm.tempos = append(tm.tempos, tempo)
+	}
+
+	return tm
+}
+
+// beats returns the number of beats from the beginning of the score to the
+// provided offset.
+func (tm tempoMap) beats(offsetMs float64) float64 {
+	beats := 0.0
+
+	for i, start := range tm.offsets {
+		if start >= offsetMs {
+			break
+		}
+
+		end := offsetMs
+		if i+1 < len(tm.offsets) && tm.offsets[i+1] < offsetMs {
+			end = tm.offsets[i+1]
+		}
+
+		msPerBeat := 60000 / tm.tempos[i]
+		beats += (end - start) / msPerBeat
+	}
+
+	return beats
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"fmt"
+	"math"
+
+	"alda.io/client/json"
+)
+
+// A TimeSignature is a time signature in Western standard musical notation,
+// e.g. 4/4 or 6/8.
+//
+// The zero value means that the part has no time signature, i.e. it is in free
+// time, in which case its measures are not checked.
+type TimeSignature struct {
+	// The number of beats in a measure, i.e. the top number.
+	Beats int32
+	// The note value that gets one beat, i.e. the bottom number.
+	BeatUnit int32
+}
+
+// JSON implements RepresentableAsJSON.JSON.
+func (ts TimeSignature) JSON() *json.Container {
+	return json.Object("beats", ts.Beats, "beat-unit", ts.BeatUnit)
+}
+
+// IsZero returns true if the time signature is unset.
+func (ts TimeSignature) IsZero() bool {
+	return ts.Beats == 0 && ts.BeatUnit == 0
+}
+
+// measureBeats returns the length of a full measure, in quarter notes (which is
+// the unit that Alda uses for beats, regardless of the time signature).
+func (ts TimeSignature) measureBeats() float64 {
+	return float64(ts.Beats) * 4 / float64(ts.BeatUnit)
+}
+
+// barTolerance is how far off the length of a measure can be, in beats, before
+// we consider it to be the wrong length. This allows for floating point
+// rounding errors in things like triplets.
+const barTolerance = 1e-6
+
+// A BarError describes a measure whose length doesn't agree with the part's
+// time signature.
+type BarError struct {
+	// The name of the part, e.g. "piano".
+	Part string
+	// The index of the measure within the part, counting from 1. Measures are
+	// counted from the point where the time signature was set.
+	Measure int
+	// The length of a full measure in the part's time signature, in quarter
+	// notes.
+	ExpectedBeats float64
+	// The length of the measure, in quarter notes.
+	ActualBeats float64
+	// The barline at the end of the measure.
+	SourceContext AldaSourceContext
+}
+
+// Error returns a human-readable description of the BarError.
+func (be BarError) Error() string {
+	msg := fmt.Sprintf(
+		"%s, measure %d: expected %g beats, got %g",
+		be.Part, be.Measure, be.ExpectedBeats, be.ActualBeats,
+	)
+
+	if be.SourceContext.Line == 0 {
+		return msg
+	}
+
+	return fmt.Sprintf(
+		"%d:%d: %s", be.SourceContext.Line, be.SourceContext.Column, msg,
+	)
+}
+
+// recordBar ends the current measure of a part at the provided offset, making
+// a note of it if its length doesn't agree with the part's time signature.
+//
+// Parts without a time signature are ignored.
+func (score *Score) recordBar(
+	part *Part, offset float64, context AldaSourceContext,
+) {
+	if part.TimeSignature.IsZero() {
+		return
+	}
+
+	tempos := newTempoMap(part.TempoValues)
+	actual := tempos.beats(offset) - tempos.beats(part.measureStartOffset)
+	expected := part.TimeSignature.measureBeats()
+
+	if math.Abs(actual-expected) > barTolerance {
+		score.barErrors = append(score.barErrors, BarError{
+			Part:          part.Name,
+			Measure:       part.measureNumber,
+			ExpectedBeats: expected,
+			ActualBeats:   actual,
+			SourceContext: context,
+		})
+	}
+
+	part.measureStartOffset = offset
+	part.measureNumber++
+}
+
+// CheckBars returns a BarError for each measure in the score whose length
+// doesn't agree with its part's time signature. A measure is the music between
+// two barlines (`|`), or between the point where the time signature was set and
+// the next barline.
+//
+// This check is opt-in: only parts that have a time signature (see the
+// `time-signature` attribute) are checked.
+func (score *Score) CheckBars() []BarError {
+	return score.barErrors
+}
@@ -0,0 +1,173 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	_ "alda.io/client/testing"
+)
+
+func expectBarErrors(expected ...BarError) func(s *Score) error {
+	return func(s *Score) error {
+		actual := s.CheckBars()
+
+		if len(actual) != len(expected) {
+			return fmt.Errorf(
+				"expected %d bar errors, got %d: %#v", len(expected), len(actual), actual,
+			)
+		}
+
+		for i := range expected {
+			if actual[i].Part != expected[i].Part ||
+				actual[i].Measure != expected[i].Measure ||
+				!equalish(actual[i].ExpectedBeats, expected[i].ExpectedBeats) ||
+				!equalish(actual[i].ActualBeats, expected[i].ActualBeats) {
+				return fmt.Errorf(
+					"expected bar error %#v, got %#v", expected[i], actual[i],
+				)
+			}
+		}
+
+		return nil
+	}
+}
+
+func fourFour() AttributeUpdate {
+	return AttributeUpdate{
+		PartUpdate: TimeSignatureSet{
+			TimeSignature: TimeSignature{Beats: 4, BeatUnit: 4},
+		},
+	}
+}
+
+func TestCheckBars(t *testing.T) {
+	executeScoreUpdateTestCases(
+		t,
+		scoreUpdateTestCase{
+			label: "full measures",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				fourFour(),
+				quarterNote(C), quarterNote(D), quarterNote(E), quarterNote(F),
+				Barline{},
+				quarterNote(G), quarterNote(A), quarterNote(B), quarterNote(C),
+				Barline{},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectBarErrors(),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "a short measure",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				fourFour(),
+				quarterNote(C), quarterNote(D), quarterNote(E), quarterNote(F),
+				Barline{},
+				quarterNote(G), quarterNote(A), quarterNote(B),
+				Barline{},
+				quarterNote(C), quarterNote(D), quarterNote(E), quarterNote(F),
+				Barline{},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectBarErrors(
+					BarError{
+						Part: "piano", Measure: 2, ExpectedBeats: 4, ActualBeats: 3,
+					},
+				),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "free time (no time signature)",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				quarterNote(C), quarterNote(D), quarterNote(E),
+				Barline{},
+				quarterNote(F),
+				Barline{},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectBarErrors(),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "note tied across a barline",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				fourFour(),
+				quarterNote(C), quarterNote(D),
+				// e2~|e2
+				Note{
+					Pitch: LetterAndAccidentals{NoteLetter: E},
+					Duration: Duration{
+						Components: []DurationComponent{
+							NoteLength{Denominator: 2},
+							Barline{},
+							NoteLength{Denominator: 2},
+						},
+					},
+				},
+				quarterNote(F), quarterNote(G),
+				Barline{},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectBarErrors(),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "default duration carried over from a note tied across a barline",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				fourFour(),
+				// c1~|c2 d
+				Note{
+					Pitch: LetterAndAccidentals{NoteLetter: C},
+					Duration: Duration{
+						Components: []DurationComponent{
+							NoteLength{Denominator: 1},
+							Barline{},
+							NoteLength{Denominator: 2},
+						},
+					},
+				},
+				Note{Pitch: LetterAndAccidentals{NoteLetter: D}},
+				Barline{},
+			},
+			expectations: []scoreUpdateExpectation{
+				// d takes on the duration of the tied note, which is 6 beats long, so
+				// the second measure is 8 beats long. If the barline in the
+				// carried-over duration were counted, it would end the second measure
+				// after 6 beats.
+				expectBarErrors(
+					BarError{
+						Part: "piano", Measure: 2, ExpectedBeats: 4, ActualBeats: 8,
+					},
+				),
+			},
+		},
+		scoreUpdateTestCase{
+			label: "tempo change in the middle of a measure",
+			updates: []ScoreUpdate{
+				PartDeclaration{Names: []string{"piano"}},
+				AttributeUpdate{
+					PartUpdate: TimeSignatureSet{
+						TimeSignature: TimeSignature{Beats: 3, BeatUnit: 4},
+					},
+				},
+				quarterNote(C),
+				AttributeUpdate{PartUpdate: TempoSet{Tempo: 60}},
+				quarterNote(D), quarterNote(E),
+				Barline{},
+				quarterNote(F), quarterNote(G),
+				Barline{},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectBarErrors(
+					BarError{
+						Part: "piano", Measure: 2, ExpectedBeats: 3, ActualBeats: 2,
+					},
+				),
+			},
+		},
+	)
+}
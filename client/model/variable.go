@@ -21,7 +21,14 @@ func (score *Score) GetVariable(name string) ([]ScoreUpdate, error) {
 }
 
 // SetVariable defines the value of a variable.
+//
+// Redefining a variable is allowed (and it's handy in the REPL), but because it
+// can also be a mistake, we log a warning when it happens.
 func (score *Score) SetVariable(name string, value []ScoreUpdate) {
+	if _, defined := score.Variables[name]; defined {
+		log.Warn().Str("name", name).Msg("Redefining variable.")
+	}
+
 	log.Debug().
 		Str("name", name).
 		Interface("value", value).
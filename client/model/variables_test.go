@@ -262,5 +262,33 @@ func TestVariables(t *testing.T) {
 				expectMidiNoteNumbers(60, 62, 60, 62, 60, 62, 60, 62),
 			},
 		},
+		scoreUpdateTestCase{
+			label: "referencing a variable twice",
+			updates: []ScoreUpdate{
+				VariableDefinition{
+					VariableName: "motif",
+					Events: []ScoreUpdate{
+						Note{
+							Pitch: LetterAndAccidentals{NoteLetter: C},
+							Duration: Duration{
+								Components: []DurationComponent{
+									NoteLength{Denominator: 8},
+								},
+							},
+						},
+						Note{Pitch: LetterAndAccidentals{NoteLetter: D}},
+						Note{Pitch: LetterAndAccidentals{NoteLetter: E}},
+						Note{Pitch: LetterAndAccidentals{NoteLetter: F}},
+					},
+				},
+				PartDeclaration{Names: []string{"piano"}},
+				VariableReference{VariableName: "motif"},
+				VariableReference{VariableName: "motif"},
+			},
+			expectations: []scoreUpdateExpectation{
+				expectMidiNoteNumbers(60, 62, 64, 65, 60, 62, 64, 65),
+				expectNoteOffsets(0, 250, 500, 750, 1000, 1250, 1500, 1750),
+			},
+		},
 	)
 }
@@ -34,6 +34,7 @@ const (
 	MarkerNode
 	NaturalNode
 	NoteAccidentalsNode
+	NoteLengthBeatsNode
 	NoteLengthMsNode
 	NoteLengthNode
 	NoteLetterAndAccidentalsNode
@@ -114,6 +115,8 @@ func (nt ASTNodeType) String() string {
 		return "NaturalNode"
 	case NoteAccidentalsNode:
 		return "NoteAccidentalsNode"
+	case NoteLengthBeatsNode:
+		return "NoteLengthBeatsNode"
 	case NoteLengthMsNode:
 		return "NoteLengthMsNode"
 	case NoteLengthNode:
@@ -348,6 +351,10 @@ func duration(node ASTNode) (model.Duration, error) {
 			}
 
 			duration.Components = append(duration.Components, noteLength)
+		case NoteLengthBeatsNode:
+			literal := componentNode.Literal.(float64)
+			noteLengthBeats := model.NoteLengthBeats{Quantity: literal}
+			duration.Components = append(duration.Components, noteLengthBeats)
 		case NoteLengthMsNode:
 			literal := componentNode.Literal.(float64)
 			noteLengthMs := model.NoteLengthMs{Quantity: literal}
@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"testing"
+
+	_ "alda.io/client/testing"
+)
+
+func TestDrumSounds(t *testing.T) {
+	score, err := scoreFromString(
+		"percussion: (drum 'bass-drum) (drum 'snare (note-length 8)) " +
+			"o6 (transpose 2) (drum 'hihat-closed)",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Drum sounds aren't affected by the octave or transposition.
+	expected := []int32{36, 38, 42}
+
+	notes := score.NoteEvents()
+	if len(notes) != len(expected) {
+		t.Fatalf("expected %d notes, got %d", len(expected), len(notes))
+	}
+
+	for i, note := range notes {
+		if note.MidiNote != expected[i] {
+			t.Errorf("expected MIDI notes %v, got %#v", expected, notes)
+			break
+		}
+	}
+
+	if notes[1].Duration != 250 {
+		t.Errorf("expected the snare to be an eighth note, got %f", notes[1].Duration)
+	}
+
+	if _, err := scoreFromString("percussion: (drum 'kazoo)"); err == nil {
+		t.Error("expected an error for an unrecognized drum sound")
+	}
+}
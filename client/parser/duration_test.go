@@ -81,6 +81,24 @@ func TestDurations(t *testing.T) {
 				),
 			},
 		},
+		parseTestCase{
+			label: "Note with a fraction of a beat",
+			given: "c1/3",
+			expect: []model.ScoreUpdate{
+				cNoteWithDuration(model.NoteLengthBeats{Quantity: 1.0 / 3}),
+			},
+		},
+		parseTestCase{
+			label: "Note with a fraction of a beat tied to other note lengths",
+			given: "c3/2~4~250ms",
+			expect: []model.ScoreUpdate{
+				cNoteWithDuration(
+					model.NoteLengthBeats{Quantity: 1.5},
+					model.NoteLength{Denominator: 4},
+					model.NoteLengthMs{Quantity: 250},
+				),
+			},
+		},
 		parseTestCase{
 			label: "Note with tied millisecond note lengths",
 			given: "c500ms~350ms",
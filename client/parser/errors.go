@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"alda.io/client/model"
+)
+
+// A ParseError is a syntax error encountered while scanning or parsing Alda
+// source code, along with the position in the source where it occurred.
+type ParseError struct {
+	// The file being parsed, or "" if the input didn't come from a file.
+	Filename string
+	Line     int
+	Column   int
+	Message  string
+}
+
+// Error returns a string representation of a ParseError in the form
+// `line:col: message`, prefixed with the filename if there is one.
+func (pe *ParseError) Error() string {
+	position := fmt.Sprintf("%d:%d", pe.Line, pe.Column)
+	if pe.Filename != "" {
+		position = pe.Filename + ":" + position
+	}
+
+	return fmt.Sprintf("%s: %s", position, pe.Message)
+}
+
+// GetSourceContext implements model.HasSourceContext.
+func (pe *ParseError) GetSourceContext() model.AldaSourceContext {
+	return model.AldaSourceContext{
+		Filename: pe.Filename,
+		Line:     pe.Line,
+		Column:   pe.Column,
+	}
+}
+
+func parseErrorAt(context model.AldaSourceContext, msg string) *ParseError {
+	return &ParseError{
+		Filename: context.Filename,
+		Line:     context.Line,
+		Column:   context.Column,
+		Message:  msg,
+	}
+}
+
+// ParseErrors are all of the syntax errors found while parsing Alda source
+// code. Rather than stopping at the first error, the parser skips ahead to the
+// next barline or part declaration and keeps going, so that several mistakes
+// can be reported at once.
+type ParseErrors []*ParseError
+
+// Error returns the errors, one per line.
+func (pes ParseErrors) Error() string {
+	messages := make([]string, len(pes))
+	for i, pe := range pes {
+		messages[i] = pe.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap returns the first error, so that `errors.As` can be used to get at the
+// first error's position.
+func (pes ParseErrors) Unwrap() error {
+	if len(pes) == 0 {
+		return nil
+	}
+
+	return pes[0]
+}
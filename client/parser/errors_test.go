@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	_ "alda.io/client/testing"
+)
+
+func TestParseErrorPositions(t *testing.T) {
+	for _, testCase := range []struct {
+		label   string
+		given   string
+		line    int
+		column  int
+		message string
+	}{
+		{
+			label:   "unclosed chord",
+			given:   "piano: c/e/",
+			line:    1,
+			column:  12,
+			message: "Unexpected EOF in chord",
+		},
+		{
+			label:   "unexpected character",
+			given:   "piano:\n  c d $ e",
+			line:    2,
+			column:  7,
+			message: "Unexpected '$' at the top level",
+		},
+		{
+			label:   "note length fraction with a denominator of 0",
+			given:   "piano: c1/0",
+			line:    1,
+			column:  10,
+			message: "Note length fraction with a denominator of 0",
+		},
+		{
+			label:   "unclosed event sequence",
+			given:   "piano: c d\n[e f",
+			line:    2,
+			column:  5,
+			message: "unterminated event sequence",
+		},
+	} {
+		_, err := ParseString(testCase.given)
+
+		var parseError *ParseError
+		if !errors.As(err, &parseError) {
+			t.Errorf("%s: expected a ParseError, got %#v", testCase.label, err)
+			continue
+		}
+
+		if parseError.Line != testCase.line ||
+			parseError.Column != testCase.column {
+			t.Errorf(
+				"%s: expected error at %d:%d, got %d:%d (%v)",
+				testCase.label,
+				testCase.line,
+				testCase.column,
+				parseError.Line,
+				parseError.Column,
+				err,
+			)
+		}
+
+		expected := fmt.Sprintf(
+			"%d:%d: %s", testCase.line, testCase.column, testCase.message,
+		)
+		if err.Error() != expected {
+			t.Errorf("%s: expected %q, got %q", testCase.label, expected, err)
+		}
+	}
+}
+
+func TestMultipleParseErrors(t *testing.T) {
+	input := `piano: c d ] e | f g
+violin: c/e/ | a b
+cello: c d } e f g`
+
+	_, err := ParseString(input)
+
+	parseErrors, ok := err.(ParseErrors)
+	if !ok {
+		t.Fatalf("expected ParseErrors, got %#v", err)
+	}
+
+	expected := []string{"1:12", "2:14", "3:12"}
+
+	actual := []string{}
+	for _, parseError := range parseErrors {
+		actual = append(
+			actual, fmt.Sprintf("%d:%d", parseError.Line, parseError.Column),
+		)
+	}
+
+	if fmt.Sprint(expected) != fmt.Sprint(actual) {
+		t.Errorf("expected errors at %v, got %v\n%v", expected, actual, err)
+	}
+}
@@ -0,0 +1,206 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// An import is an S-expression like `(import "motifs.alda")` that appears
+// either outside of any part or directly within a part. The import is replaced
+// with the contents of the imported file, as if the contents of that file were
+// written in its place.
+//
+// A relative path is resolved against the directory of the importing file, or
+// against the working directory if the input didn't come from a file.
+
+// importedBy customizes a parser to keep track of the chain of files being
+// imported, so that we can detect import cycles.
+func importedBy(chain []string) parseOption {
+	return func(parser *parser) {
+		parser.importChain = chain
+	}
+}
+
+// importPath returns the path of the file being imported, if the node is an
+// import.
+func importPath(node ASTNode) (string, bool, error) {
+	if node.Type != LispListNode ||
+		len(node.Children) == 0 ||
+		node.Children[0].Type != LispSymbolNode ||
+		node.Children[0].Literal != "import" {
+		return "", false, nil
+	}
+
+	if len(node.Children) != 2 || node.Children[1].Type != LispStringNode {
+		return "", true, parseErrorAt(
+			node.SourceContext,
+			"import expects a single argument, the path of an Alda file",
+		)
+	}
+
+	return node.Children[1].Literal.(string), true, nil
+}
+
+// checkNoNestedImports returns an error if there are any imports within the
+// node, e.g. inside of a voice or an event sequence.
+func checkNoNestedImports(node ASTNode) error {
+	if _, isImport, _ := importPath(node); isImport {
+		return parseErrorAt(
+			node.SourceContext,
+			"imports are only allowed outside of a part or directly within a part",
+		)
+	}
+
+	for _, child := range node.Children {
+		if err := checkNoNestedImports(child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importFile parses the file that an import refers to and returns the
+// resulting top-level nodes.
+func (p *parser) importFile(node ASTNode, path string) ([]ASTNode, error) {
+	dir := "."
+	if p.filename != "" {
+		dir = filepath.Dir(p.filename)
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, parseErrorAt(
+			node.SourceContext, fmt.Sprintf("unable to import %s: %v", path, err),
+		)
+	}
+
+	for _, importing := range p.importChain {
+		if importing == absPath {
+			return nil, parseErrorAt(
+				node.SourceContext,
+				fmt.Sprintf(
+					"import cycle: %s",
+					strings.Join(append(p.importChain, absPath), " -> "),
+				),
+			)
+		}
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, parseErrorAt(
+			node.SourceContext, fmt.Sprintf("unable to import %s: %v", path, err),
+		)
+	}
+
+	// NB: We make a copy of the chain so that sibling imports don't share it.
+	chain := append([]string{}, p.importChain...)
+	chain = append(chain, absPath)
+
+	opts := []parseOption{importedBy(chain)}
+	if p.suppressSourceContext {
+		opts = append(opts, SuppressSourceContext)
+	}
+
+	imported, err := Parse(path, string(contents), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return imported.Children, nil
+}
+
+// resolveImports returns a copy of the root node in which each import has been
+// replaced with the contents of the imported file.
+//
+// Because an imported file can contain part declarations, a part that includes
+// an import is split in two: the events before the import stay in the part, and
+// the events after the import become an implicit part, which continues
+// whichever part(s) the imported file left off on.
+func (p *parser) resolveImports(root ASTNode) (ASTNode, error) {
+	// The file being parsed is the start of the chain, unless it was itself
+	// imported.
+	if len(p.importChain) == 0 && p.filename != "" {
+		if absPath, err := filepath.Abs(p.filename); err == nil {
+			p.importChain = []string{absPath}
+		}
+	}
+
+	resolved := ASTNode{
+		Type:          RootNode,
+		SourceContext: root.SourceContext,
+	}
+
+	for _, node := range root.Children {
+		// Both part nodes and implicit part nodes have their events as the last
+		// child.
+		last := len(node.Children) - 1
+		events := node.Children[last]
+
+		current := ASTNode{
+			Type:          node.Type,
+			SourceContext: node.SourceContext,
+			Children:      append([]ASTNode{}, node.Children[:last]...),
+		}
+		currentEvents := ASTNode{
+			Type:          EventSequenceNode,
+			SourceContext: events.SourceContext,
+			Children:      []ASTNode{},
+		}
+
+		flush := func() {
+			if current.Type == ImplicitPartNode && len(currentEvents.Children) == 0 {
+				return
+			}
+
+			current.Children = append(current.Children, currentEvents)
+			resolved.Children = append(resolved.Children, current)
+		}
+
+		for _, event := range events.Children {
+			path, isImport, err := importPath(event)
+			if err != nil {
+				return ASTNode{}, err
+			}
+
+			if !isImport {
+				if err := checkNoNestedImports(event); err != nil {
+					return ASTNode{}, err
+				}
+
+				currentEvents.Children = append(currentEvents.Children, event)
+				continue
+			}
+
+			flush()
+
+			imported, err := p.importFile(event, path)
+			if err != nil {
+				return ASTNode{}, err
+			}
+
+			resolved.Children = append(resolved.Children, imported...)
+
+			current = ASTNode{
+				Type:          ImplicitPartNode,
+				SourceContext: event.SourceContext,
+			}
+			currentEvents = ASTNode{
+				Type:          EventSequenceNode,
+				SourceContext: event.SourceContext,
+				Children:      []ASTNode{},
+			}
+		}
+
+		flush()
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"alda.io/client/model"
+	_ "alda.io/client/testing"
+)
+
+func writeAldaFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestNestedImport(t *testing.T) {
+	dir := writeAldaFiles(t, map[string]string{
+		"main.alda": `(import "parts/strings.alda")
+piano: c (import "parts/coda.alda") d`,
+		// Paths are resolved relative to the importing file.
+		"parts/strings.alda": `(import "motif.alda")
+violin: o5 motif`,
+		"parts/motif.alda": `motif = c e g`,
+		"parts/coda.alda":  `cello: o2 c`,
+	})
+
+	ast, err := ParseFile(filepath.Join(dir, "main.alda"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scoreUpdates, err := ast.Updates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score := model.NewScore()
+	if err := score.Update(scoreUpdates...); err != nil {
+		t.Fatal(err)
+	}
+
+	partNotes := map[string][]int32{}
+	for _, event := range score.Events {
+		note := event.(model.NoteEvent)
+		partNotes[note.Part.Name] = append(partNotes[note.Part.Name], note.MidiNote)
+	}
+
+	expected := map[string][]int32{
+		"violin": {72, 76, 79},
+		"piano":  {60},
+		// After the import, `d` continues the last part declared in the imported
+		// file.
+		"cello": {36, 38},
+	}
+
+	for part, notes := range expected {
+		if len(partNotes[part]) != len(notes) {
+			t.Errorf("%s: expected %v, got %v", part, notes, partNotes[part])
+			continue
+		}
+
+		for i := range notes {
+			if partNotes[part][i] != notes[i] {
+				t.Errorf("%s: expected %v, got %v", part, notes, partNotes[part])
+				break
+			}
+		}
+	}
+}
+
+func TestImportCycle(t *testing.T) {
+	dir := writeAldaFiles(t, map[string]string{
+		"a.alda": `(import "b.alda")
+piano: c`,
+		"b.alda": `(import "a.alda")`,
+	})
+
+	_, err := ParseFile(filepath.Join(dir, "a.alda"))
+	if err == nil {
+		t.Fatal("expected an import cycle to be rejected")
+	}
+
+	var parseError *ParseError
+	if !errors.As(err, &parseError) ||
+		!strings.HasPrefix(parseError.Message, "import cycle") {
+		t.Fatalf("expected an import cycle error, got %v", err)
+	}
+
+	if !strings.HasSuffix(parseError.Filename, "b.alda") ||
+		parseError.Line != 1 ||
+		parseError.Column != 1 {
+		t.Errorf("expected the error to point to the import in b.alda, got %v", err)
+	}
+}
+
+func TestNestedImportIsRejected(t *testing.T) {
+	_, err := ParseString(`piano: V1: (import "motif.alda")`)
+
+	var parseError *ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatalf("expected a ParseError, got %#v", err)
+	}
+}
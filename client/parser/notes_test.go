@@ -101,3 +101,83 @@ func TestNotes(t *testing.T) {
 		},
 	)
 }
+
+func TestSolfegeNotes(t *testing.T) {
+	solfege := lispList(lispSymbol("note-names!"), lispSymbol(":solfege"))
+	english := lispList(lispSymbol("note-names!"), lispSymbol(":english"))
+
+	note := func(
+		letter model.NoteLetter, accidentals ...model.Accidental,
+	) model.Note {
+		return model.Note{
+			Pitch: model.LetterAndAccidentals{
+				NoteLetter: letter, Accidentals: accidentals,
+			},
+		}
+	}
+
+	executeParseTestCases(
+		t,
+		parseTestCase{
+			label: "solfège syllables",
+			given: "(note-names! :solfege) do re mi fa sol la ti",
+			expect: []model.ScoreUpdate{
+				solfege,
+				note(model.C), note(model.D), note(model.E), note(model.F),
+				note(model.G), note(model.A), note(model.B),
+			},
+		},
+		parseTestCase{
+			label: "solfège syllables with accidentals and durations",
+			given: "(note-names! :solfege) do+ si- so4",
+			expect: []model.ScoreUpdate{
+				solfege,
+				note(model.C, model.Sharp),
+				note(model.B, model.Flat),
+				model.Note{
+					Pitch: model.LetterAndAccidentals{NoteLetter: model.G},
+					Duration: model.Duration{
+						Components: []model.DurationComponent{
+							model.NoteLength{Denominator: 4},
+						},
+					},
+				},
+			},
+		},
+		parseTestCase{
+			label: "switching back to English note names",
+			given: "(note-names! :solfege) do (note-names! :english) c",
+			expect: []model.ScoreUpdate{
+				solfege, note(model.C), english, note(model.C),
+			},
+		},
+	)
+
+	ast, err := ParseString("piano: (note-names! :solfege) do re mi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates, err := ast.Updates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score := model.NewScore()
+	if err := score.Update(updates...); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []int32{60, 62, 64}
+	if len(score.Events) != len(expected) {
+		t.Fatalf("expected %d notes, got %#v", len(expected), score.Events)
+	}
+
+	for i, event := range score.Events {
+		if note := event.(model.NoteEvent); note.MidiNote != expected[i] {
+			t.Errorf(
+				"expected note %d to be %d, got %d", i, expected[i], note.MidiNote,
+			)
+		}
+	}
+}
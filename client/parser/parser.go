@@ -22,6 +22,9 @@ type parser struct {
 	// useful for testing, e.g. for checking the equality of a list of expected
 	// tokens, agnostic of source context like line and column numbers.
 	suppressSourceContext bool
+	// The absolute paths of the files being imported, starting with the file
+	// that contains the first import. (See import.go.)
+	importChain []string
 }
 
 // A parseOption is a function that customizes a parser instance.
@@ -99,16 +102,13 @@ func (p *parser) match(types ...TokenType) (Token, bool) {
 	return Token{}, false
 }
 
-func (p *parser) errorAtToken(token Token, msg string) *model.AldaSourceError {
-	return &model.AldaSourceError{
-		Context: token.sourceContext,
-		Err:     fmt.Errorf("%s", msg),
-	}
+func (p *parser) errorAtToken(token Token, msg string) *ParseError {
+	return parseErrorAt(token.sourceContext, msg)
 }
 
 func (p *parser) unexpectedTokenError(
 	token Token, context string,
-) *model.AldaSourceError {
+) *ParseError {
 	if context != "" {
 		context = " " + context
 	}
@@ -358,12 +358,10 @@ func (p *parser) variableDefinition() (ASTNode, error) {
 	}
 
 	if p.check(EOF) || p.peek().sourceContext.Line > definitionLine {
-		return ASTNode{}, &model.AldaSourceError{
-			Context: nameToken.sourceContext,
-			Err: fmt.Errorf(
-				"there must be at least one event on the same line as the '='",
-			),
-		}
+		return ASTNode{}, parseErrorAt(
+			nameToken.sourceContext,
+			"there must be at least one event on the same line as the '='",
+		)
 	}
 
 	eventsNode := ASTNode{
@@ -446,7 +444,7 @@ func (p *parser) octaveSet() (ASTNode, error) {
 }
 
 func (p *parser) matchDurationComponent() (Token, bool) {
-	return p.match(NoteLength, NoteLengthMs)
+	return p.match(NoteLength, NoteLengthBeats, NoteLengthMs)
 }
 
 func (p *parser) durationComponent() ASTNode {
@@ -476,6 +474,12 @@ func (p *parser) durationComponent() ASTNode {
 		}
 
 		return nlNode
+	case NoteLengthBeats:
+		return ASTNode{
+			Type:          NoteLengthBeatsNode,
+			SourceContext: p.sourceContext(token),
+			Literal:       token.literal,
+		}
 	case NoteLengthMs:
 		return ASTNode{
 			Type:          NoteLengthMsNode,
@@ -964,6 +968,16 @@ func (p *parser) voiceGroup() (ASTNode, error) {
 	// NB: This assumes the first VoiceMarker token was already consumed.
 	firstVoiceMarkerToken := p.previous()
 
+	// A voice group end marker that isn't preceded by a voice ends a voice group
+	// that was started earlier, e.g. in a previous line of input in a REPL
+	// session.
+	if firstVoiceMarkerToken.literal.(int32) == 0 {
+		return ASTNode{
+			Type:          VoiceGroupEndMarkerNode,
+			SourceContext: p.sourceContext(firstVoiceMarkerToken),
+		}, nil
+	}
+
 	voiceGroupNode := ASTNode{
 		Type:          VoiceGroupNode,
 		SourceContext: firstVoiceMarkerToken.sourceContext,
@@ -1074,19 +1088,52 @@ func (p *parser) topLevel() (ASTNode, error) {
 	return p.implicitPart()
 }
 
+// synchronize skips past the tokens following a parse error, up to the next
+// point where it's safe to resume parsing: just after a barline, or at the
+// start of a part declaration.
+func (p *parser) synchronize(errorPosition int) {
+	// Always move past the token where the error occurred, so that we don't get
+	// stuck reporting the same error forever.
+	if p.current == errorPosition {
+		p.advance()
+	}
+
+	for !p.check(EOF) && !p.looksLikePartDeclaration() {
+		if _, matched := p.match(Barline); matched {
+			return
+		}
+
+		p.advance()
+	}
+}
+
 func (p *parser) parseAST() (ASTNode, error) {
 	rootNode := ASTNode{Type: RootNode}
+	parseErrors := ParseErrors{}
 
 	for t := p.peek(); t.tokenType != EOF; t = p.peek() {
 		// fmt.Printf("t: %s\n", t.String())
+		start := p.current
+
 		node, err := p.topLevel()
 		if err != nil {
-			return ASTNode{}, err
+			parseError, ok := err.(*ParseError)
+			if !ok {
+				return ASTNode{}, err
+			}
+
+			parseErrors = append(parseErrors, parseError)
+			p.synchronize(start)
+			continue
 		}
 
 		rootNode.Children = append(rootNode.Children, node)
 	}
 
+	if len(parseErrors) > 0 {
+		return ASTNode{}, parseErrors
+	}
+
 	return rootNode, nil
 }
 
@@ -1106,13 +1153,31 @@ func Parse(
 	}(time.Now())
 
 	tokens, err := Scan(filepath, input)
+	if parseError, ok := err.(*ParseError); ok {
+		// The scanner stops at the first error, but for the sake of consistency,
+		// we return it the same way that we return errors from the parser.
+		return ASTNode{}, ParseErrors{parseError}
+	}
 	if err != nil {
 		return ASTNode{}, err
 	}
 
 	p := newParser(filepath, tokens, opts...)
 
-	return p.parseAST()
+	ast, err := p.parseAST()
+	if err != nil {
+		return ASTNode{}, err
+	}
+
+	ast, err = p.resolveImports(ast)
+	if parseError, ok := err.(*ParseError); ok {
+		return ASTNode{}, ParseErrors{parseError}
+	}
+	if err != nil {
+		return ASTNode{}, err
+	}
+
+	return ast, nil
 }
 
 // ParseString reads and parses a string of input.
@@ -0,0 +1,176 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"alda.io/client/model"
+	_ "alda.io/client/testing"
+)
+
+func scoreFromString(input string) (*model.Score, error) {
+	ast, err := ParseString(input)
+	if err != nil {
+		return nil, err
+	}
+
+	scoreUpdates, err := ast.Updates()
+	if err != nil {
+		return nil, err
+	}
+
+	score := model.NewScore()
+	if err := score.Update(scoreUpdates...); err != nil {
+		return nil, err
+	}
+
+	return score, nil
+}
+
+// sortedNoteEvents returns the note events of a score, in a predictable order.
+func sortedNoteEvents(score *model.Score) []model.NoteEvent {
+	notes := []model.NoteEvent{}
+	for _, event := range score.Events {
+		notes = append(notes, event.(model.NoteEvent))
+	}
+
+	partIndex := score.Tracks()
+
+	sort.SliceStable(notes, func(i, j int) bool {
+		a, b := notes[i], notes[j]
+		if partIndex[a.Part] != partIndex[b.Part] {
+			return partIndex[a.Part] < partIndex[b.Part]
+		}
+		if math.Abs(a.Offset-b.Offset) > 1e-6 {
+			return a.Offset < b.Offset
+		}
+		if a.MidiNote != b.MidiNote {
+			return a.MidiNote < b.MidiNote
+		}
+		return a.Duration < b.Duration
+	})
+
+	return notes
+}
+
+// equivalentScores returns an error describing the first difference between the
+// parts and note events of two scores, or nil if there are no differences.
+func equivalentScores(expected, actual *model.Score) error {
+	if len(expected.Parts) != len(actual.Parts) {
+		return fmt.Errorf(
+			"expected %d parts, got %d", len(expected.Parts), len(actual.Parts),
+		)
+	}
+
+	for i, part := range expected.Parts {
+		if part.StockInstrument != actual.Parts[i].StockInstrument {
+			return fmt.Errorf(
+				"expected part %d to be %s, got %s",
+				i,
+				part.StockInstrument.Name(),
+				actual.Parts[i].StockInstrument.Name(),
+			)
+		}
+	}
+
+	expectedNotes := sortedNoteEvents(expected)
+	actualNotes := sortedNoteEvents(actual)
+
+	if len(expectedNotes) != len(actualNotes) {
+		return fmt.Errorf(
+			"expected %d notes, got %d", len(expectedNotes), len(actualNotes),
+		)
+	}
+
+	close := func(a, b float64) bool { return math.Abs(a-b) < 1e-3 }
+
+	for i, e := range expectedNotes {
+		a := actualNotes[i]
+
+		if e.MidiNote != a.MidiNote ||
+			!close(e.Offset, a.Offset) ||
+			!close(e.Duration, a.Duration) ||
+			!close(e.AudibleDuration, a.AudibleDuration) ||
+			!close(e.Volume, a.Volume) ||
+			!close(e.TrackVolume, a.TrackVolume) ||
+			!close(e.Panning, a.Panning) {
+			return fmt.Errorf("expected note %d to be %#v, got %#v", i, e, a)
+		}
+	}
+
+	return nil
+}
+
+func testRenderRoundTrip(t *testing.T, label string, score *model.Score) {
+	rendered := model.Render(score)
+
+	reparsed, err := scoreFromString(rendered)
+	if err != nil {
+		t.Errorf("%s: failed to parse rendered score: %v\n%s", label, err, rendered)
+		return
+	}
+
+	if err := equivalentScores(score, reparsed); err != nil {
+		t.Errorf("%s: %v\n%s", label, err, rendered)
+	}
+}
+
+func TestRenderRoundTrip(t *testing.T) {
+	for _, input := range []string{
+		"piano: c d e",
+		"piano: (tempo 90) o5 c8. d16 e4~8 r4 f2",
+		"piano: c1/e/g > c4 < b-",
+		"violin: (vol 50) (pan 25) c4 d (quant 100) e f",
+		`piano "piano-1": c d e
+		 piano "piano-2": e f g`,
+		"piano: V1: c1 V2: r4 e2. V0: f",
+		"bassoon: o1 c c+ <b",
+		"midi-percussion: o2 c d e",
+		"piano: c d e\nflute: o6 g a b",
+		"piano: [c d e]*2",
+	} {
+		score, err := scoreFromString(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testRenderRoundTrip(t, input, score)
+	}
+}
+
+func TestRenderRoundTripExamples(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	examplesDir := filepath.Join(filepath.Dir(filepath.Dir(dir)), "examples")
+
+	paths, err := filepath.Glob(filepath.Join(examplesDir, "*.alda"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		ast, err := ParseFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		scoreUpdates, err := ast.Updates()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		score := model.NewScore()
+		if err := score.Update(scoreUpdates...); err != nil {
+			t.Fatal(err)
+		}
+
+		testRenderRoundTrip(t, filepath.Base(path), score)
+	}
+}
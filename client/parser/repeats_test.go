@@ -256,3 +256,28 @@ func TestRepeats(t *testing.T) {
 		},
 	)
 }
+
+// TestAlternateEndings checks that a repeat with first and second endings is
+// equivalent to writing out each time through the repeat.
+func TestAlternateEndings(t *testing.T) {
+	for given, expected := range map[string]string{
+		"piano: [c d [e f]'1 [g a]'2]*2":   "piano: c d e f c d g a",
+		"piano: [c8 d [e4]'1-2 [f g]'3]*3": "piano: c8 d e4 c8 d e4 c8 d f g",
+		"piano: [c [d e]'1 [f g]'2 | a]*2": "piano: c d e a c f g a",
+	} {
+		expectedScore, err := scoreFromString(expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actualScore, err := scoreFromString(given)
+		if err != nil {
+			t.Errorf("%s: %v", given, err)
+			continue
+		}
+
+		if err := equivalentScores(expectedScore, actualScore); err != nil {
+			t.Errorf("%s: %v", given, err)
+		}
+	}
+}
@@ -20,19 +20,17 @@ func isLetter(c rune) bool {
 
 func (s *scanner) errorAtPosition(
 	line int, column int, msg string,
-) *model.AldaSourceError {
-	return &model.AldaSourceError{
-		Context: model.AldaSourceContext{
-			Filename: s.filename,
-			Line:     line,
-			Column:   column,
-		},
-		Err: fmt.Errorf("%s", msg),
+) *ParseError {
+	return &ParseError{
+		Filename: s.filename,
+		Line:     line,
+		Column:   column,
+		Message:  msg,
 	}
 }
 
 func (s *scanner) unexpectedCharError(
-	c rune, context string, line int, column int) *model.AldaSourceError {
+	c rune, context string, line int, column int) *ParseError {
 	if context != "" {
 		context = " " + context
 	}
@@ -76,6 +74,7 @@ const (
 	Name
 	Natural
 	NoteLength
+	NoteLengthBeats
 	NoteLengthMs
 	NoteLetter
 	Number
@@ -139,6 +138,8 @@ func (tt TokenType) String() string {
 		return "natural"
 	case NoteLength:
 		return "note length"
+	case NoteLengthBeats:
+		return "note length (beats)"
 	case NoteLengthMs:
 		return "note length (ms)"
 	case NoteLetter:
@@ -178,6 +179,27 @@ func (tt TokenType) String() string {
 	}
 }
 
+// Type returns the type of the token.
+func (t Token) Type() TokenType {
+	return t.tokenType
+}
+
+// Text returns the text of the token, as it appears in the source code.
+func (t Token) Text() string {
+	return t.text
+}
+
+// Literal returns the value that the token represents, e.g. the number for an
+// Integer token, or nil for tokens that don't have a value.
+func (t Token) Literal() interface{} {
+	return t.literal
+}
+
+// GetSourceContext implements model.HasSourceContext.
+func (t Token) GetSourceContext() model.AldaSourceContext {
+	return t.sourceContext
+}
+
 func (t Token) String() string {
 	return fmt.Sprintf(
 		"[%d:%d] %s | %#q | %#v",
@@ -200,6 +222,12 @@ type scanner struct {
 	startLine   int
 	startColumn int
 	sexpLevel   int
+	// When true, solfège syllables (do, re, mi, ...) are scanned as note letters.
+	// (See `applyNoteNamesDirective`.)
+	solfege bool
+	// True if the input contains a `note-names!` directive. (See
+	// `ChangesLaterScanning`.)
+	sawNoteNamesDirective bool
 }
 
 func newScanner(filename string, input string) *scanner {
@@ -356,7 +384,7 @@ func terminatesNoteLength(c rune) bool {
 	return false
 }
 
-func (s *scanner) parseNoteLength() {
+func (s *scanner) parseNoteLength() error {
 	// NB: This assumes that the first digit has already been consumed.
 
 	// Consume the rest of the digits.
@@ -378,7 +406,7 @@ func (s *scanner) parseNoteLength() {
 		// consume 's'
 		s.advance()
 		s.addToken(NoteLengthMs, number*1000)
-		return
+		return nil
 	}
 
 	if c == 'm' && n == 's' {
@@ -386,7 +414,30 @@ func (s *scanner) parseNoteLength() {
 		s.advance()
 		s.advance()
 		s.addToken(NoteLengthMs, number)
-		return
+		return nil
+	}
+
+	// A fraction (e.g. `1/3`) is a number of beats. This doesn't clash with the
+	// `/` in a chord (e.g. `c1/e`), which is always followed by a note.
+	if c == '/' && isDigit(n) {
+		line, column := s.line, s.column
+
+		// consume '/'
+		s.advance()
+		denominatorStart := s.current
+		s.consumeDigits()
+
+		denominator, _ := strconv.ParseFloat(
+			string(s.input[denominatorStart:s.current]), 64,
+		)
+		if denominator == 0 {
+			return s.errorAtPosition(
+				line, column, "Note length fraction with a denominator of 0",
+			)
+		}
+
+		s.addToken(NoteLengthBeats, number/denominator)
+		return nil
 	}
 
 	dots := 0
@@ -397,6 +448,8 @@ func (s *scanner) parseNoteLength() {
 	}
 
 	s.addToken(NoteLength, noteLength{denominator: number, dots: int32(dots)})
+
+	return nil
 }
 
 // This assumes that the initial digit (or minus sign, if it's a negative
@@ -648,6 +701,81 @@ func followsRestLetter(c rune) bool {
 	return false
 }
 
+// Solfège syllables and the note letters that they stand for ("fixed do").
+// "sol" comes before "so" so that it isn't scanned as "so" followed by "l".
+var solfegeSyllables = []struct {
+	syllable string
+	letter   rune
+}{
+	{"do", 'c'},
+	{"re", 'd'},
+	{"mi", 'e'},
+	{"fa", 'f'},
+	{"sol", 'g'},
+	{"so", 'g'},
+	{"la", 'a'},
+	{"si", 'b'},
+	{"ti", 'b'},
+}
+
+// matchSolfegeSyllable checks whether the lexeme that starts with the character
+// that was just consumed is a solfège syllable used as a note, i.e. followed by
+// something that can follow a note letter, like an accidental or a note
+// length. If so, the rest of the syllable is consumed and the corresponding
+// note letter is returned.
+func (s *scanner) matchSolfegeSyllable() (rune, bool) {
+	for _, solfege := range solfegeSyllables {
+		end := s.start + len(solfege.syllable)
+		if end > len(s.input) ||
+			string(s.input[s.start:end]) != solfege.syllable {
+			continue
+		}
+
+		if end < len(s.input) && !followsNoteLetter(s.input[end]) {
+			continue
+		}
+
+		for s.current < end {
+			s.advance()
+		}
+
+		return solfege.letter, true
+	}
+
+	return 0, false
+}
+
+// applyNoteNamesDirective is called after the closing parenthesis of an
+// S-expression. If the S-expression was `(note-names! :solfege)`, solfège
+// syllables are scanned as note letters from then on, until the end of the
+// input or `(note-names! :english)`.
+//
+// NB: This happens when scanning, because whether `do` is a note or a name
+// affects how the rest of the input is parsed. The `note-names!` function
+// itself doesn't do anything.
+func (s *scanner) applyNoteNamesDirective() {
+	if s.sexpLevel != 0 || len(s.tokens) < 4 {
+		return
+	}
+
+	directive := s.tokens[len(s.tokens)-4:]
+	if directive[0].tokenType != LeftParen ||
+		directive[1].tokenType != Symbol ||
+		directive[1].text != "note-names!" ||
+		directive[2].tokenType != Symbol {
+		return
+	}
+
+	s.sawNoteNamesDirective = true
+
+	switch directive[2].text {
+	case ":solfege":
+		s.solfege = true
+	case ":english":
+		s.solfege = false
+	}
+}
+
 func (s *scanner) scanToken() error {
 	prevLine := s.line
 	prevColumn := s.column
@@ -668,6 +796,7 @@ func (s *scanner) scanToken() error {
 	case ')':
 		s.sexpLevel--
 		s.addToken(RightParen, nil)
+		s.applyNoteNamesDirective()
 		return nil
 	}
 
@@ -738,8 +867,15 @@ func (s *scanner) scanToken() error {
 	default:
 		switch {
 		case isDigit(c):
-			s.parseNoteLength()
+			err = s.parseNoteLength()
 		case isLetter(c):
+			if s.solfege {
+				if letter, matched := s.matchSolfegeSyllable(); matched {
+					s.addToken(NoteLetter, letter)
+					break
+				}
+			}
+
 			n := s.peek()
 			switch {
 			case isLetter(n):
@@ -763,6 +899,27 @@ func (s *scanner) scanToken() error {
 	return err
 }
 
+// beginLexeme marks the current position as the beginning of the next lexeme.
+func (s *scanner) beginLexeme() {
+	s.start = s.current
+	s.startLine = s.line
+	s.startColumn = s.column
+}
+
+// addEOFToken adds the token that marks the end of the input.
+func (s *scanner) addEOFToken() {
+	s.tokens = append(s.tokens, Token{
+		tokenType: EOF,
+		text:      "",
+		literal:   nil,
+		sourceContext: model.AldaSourceContext{
+			Filename: s.filename,
+			Line:     s.line,
+			Column:   s.column,
+		},
+	})
+}
+
 // Scan an input string and return a list of tokens.
 //
 // The `filename` argument is included in the error message in the event of a
@@ -771,9 +928,7 @@ func Scan(filename string, input string) ([]Token, error) {
 	s := newScanner(filename, input)
 	for !s.reachedEOF() {
 		// We are at the beginning of the next lexeme.
-		s.start = s.current
-		s.startLine = s.line
-		s.startColumn = s.column
+		s.beginLexeme()
 
 		// log.Debug().
 		// 	Int("line", s.line).
@@ -787,20 +942,60 @@ func Scan(filename string, input string) ([]Token, error) {
 		}
 	}
 
-	s.tokens = append(s.tokens, Token{
-		tokenType: EOF,
-		text:      "",
-		literal:   nil,
-		sourceContext: model.AldaSourceContext{
-			Filename: s.filename,
-			Line:     s.line,
-			Column:   s.column,
-		},
-	})
+	s.addEOFToken()
+
+	return s.tokens, nil
+}
+
+// Tokenize scans Alda source code and returns the tokens, along with their
+// positions in the source. This is useful for editor integrations that only
+// need tokens, e.g. for syntax highlighting, and not an AST.
+//
+// Unlike `Scan`, Tokenize doesn't stop at the first error. Scanning resumes
+// right after the point where the error occurred, so the tokens in the rest of
+// the input are still returned. Any errors are returned as ParseErrors.
+func Tokenize(src string) ([]Token, error) {
+	s := newScanner("", src)
+
+	if errs := s.scanAll(); len(errs) > 0 {
+		return s.tokens, errs
+	}
 
 	return s.tokens, nil
 }
 
+// scanAll scans the entire input, continuing after any errors, and returns the
+// errors. (See `Tokenize`.)
+func (s *scanner) scanAll() ParseErrors {
+	errs := ParseErrors{}
+
+	for !s.reachedEOF() {
+		s.beginLexeme()
+
+		if err := s.scanToken(); err != nil {
+			errs = append(errs, err.(*ParseError))
+		}
+	}
+
+	s.addEOFToken()
+
+	return errs
+}
+
+// ChangesLaterScanning returns true if the input contains a directive that
+// changes how the source code after it is scanned, like
+// `(note-names! :solfege)`.
+//
+// Source code that follows such a directive in a separate input (e.g. a later
+// line of input in a REPL session) can't be parsed correctly on its own; it
+// needs to be parsed along with the directive.
+func ChangesLaterScanning(input string) bool {
+	s := newScanner("", input)
+	s.scanAll()
+
+	return s.sawNoteNamesDirective
+}
+
 // ScanFile reads a file, scans it, and returns a list of tokens.
 func ScanFile(filepath string) ([]Token, error) {
 	contents, err := ioutil.ReadFile(filepath)
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+
+	_ "alda.io/client/testing"
+)
+
+type expectedToken struct {
+	tokenType TokenType
+	text      string
+	line      int
+	column    int
+}
+
+func expectTokens(t *testing.T, tokens []Token, expected []expectedToken) {
+	t.Helper()
+
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %d: %v", len(expected), len(tokens), tokens)
+	}
+
+	for i, token := range tokens {
+		actual := expectedToken{
+			tokenType: token.Type(),
+			text:      token.Text(),
+			line:      token.GetSourceContext().Line,
+			column:    token.GetSourceContext().Column,
+		}
+
+		if actual != expected[i] {
+			t.Errorf("token %d: expected %v, got %v", i, expected[i], actual)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tokens, err := Tokenize("piano: c4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectTokens(t, tokens, []expectedToken{
+		{Name, "piano", 1, 1},
+		{Colon, ":", 1, 6},
+		{NoteLetter, "c", 1, 8},
+		{NoteLength, "4", 1, 9},
+		{EOF, "", 1, 10},
+	})
+}
+
+func TestTokenizeInvalidInput(t *testing.T) {
+	tokens, err := Tokenize("piano: c $ d\nviolin: ^ e")
+
+	var parseErrors ParseErrors
+	if !errors.As(err, &parseErrors) {
+		t.Fatalf("expected ParseErrors, got %#v", err)
+	}
+
+	if len(parseErrors) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", len(parseErrors), parseErrors)
+	}
+
+	for i, position := range [][2]int{{1, 10}, {2, 9}} {
+		context := parseErrors[i].GetSourceContext()
+		if context.Line != position[0] || context.Column != position[1] {
+			t.Errorf(
+				"expected error %d at %d:%d, got %d:%d",
+				i, position[0], position[1], context.Line, context.Column,
+			)
+		}
+	}
+
+	// The tokens around the invalid characters are still returned.
+	expectTokens(t, tokens, []expectedToken{
+		{Name, "piano", 1, 1},
+		{Colon, ":", 1, 6},
+		{NoteLetter, "c", 1, 8},
+		{NoteLetter, "d", 1, 12},
+		{Name, "violin", 2, 1},
+		{Colon, ":", 2, 7},
+		{NoteLetter, "e", 2, 11},
+		{EOF, "", 2, 12},
+	})
+}
@@ -98,5 +98,13 @@ func TestVoices(t *testing.T) {
 				model.Note{Pitch: model.LetterAndAccidentals{NoteLetter: model.G}},
 			},
 		},
+		parseTestCase{
+			label: "voice group end marker without a voice group",
+			given: "V0: g",
+			expect: []model.ScoreUpdate{
+				model.VoiceGroupEndMarker{},
+				model.Note{Pitch: model.LetterAndAccidentals{NoteLetter: model.G}},
+			},
+		},
 	)
 }
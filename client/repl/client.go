@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -77,8 +78,95 @@ func invalidArgsError(args []string) error {
 	return fmt.Errorf("invalid arguments: %#v", args)
 }
 
+// fromToRequest returns a request for the provided op with the optional
+// `from` and `to` arguments (e.g. `from verse to 1:30`) that commands like
+// :play accept.
+func fromToRequest(op string, args []string) (map[string]interface{}, error) {
+	req := map[string]interface{}{"op": op}
+
+	for i := 0; i < len(args); i++ {
+		// If this is the last argument, that means there are an odd number of
+		// arguments, which is invalid because we are expecting an even number of
+		// arguments.
+		if i == len(args)-1 {
+			return nil, invalidArgsError(args)
+		}
+
+		switch args[i] {
+		case "from", "to":
+			if _, hit := req[args[i]]; hit {
+				return nil, invalidArgsError(args)
+			}
+			req[args[i]] = args[i+1]
+			i++
+		default:
+			return nil, invalidArgsError(args)
+		}
+	}
+
+	return req, nil
+}
+
+// partCommand returns a function that runs a command like :mute, which sends
+// the provided op for the part whose name or alias is the command's argument.
+func partCommand(op string) func(client *Client, argsString string) error {
+	return func(client *Client, argsString string) error {
+		args, err := shlex.Split(argsString)
+		if err != nil {
+			return err
+		}
+
+		if len(args) != 1 {
+			return invalidArgsError(args)
+		}
+
+		_, err = client.sendRequest(map[string]interface{}{
+			"op": op, "part": args[0],
+		})
+		return err
+	}
+}
+
 func init() {
 	replCommands = map[string]replCommand{
+		"capture": {
+			helpSummary: "Shows the OSC messages sent to the player process.",
+			helpDetails: `Usage:
+
+  :capture on
+  :capture on osc-messages.log
+  :capture off
+
+When capturing is on, the REPL server writes a description of every OSC message
+that it sends to the player process, which can be useful for debugging. By
+default, the messages are written to the REPL server's standard error, or you
+can provide the path of a file to write them to.`,
+			run: func(client *Client, argsString string) error {
+				args, err := shlex.Split(argsString)
+				if err != nil {
+					return err
+				}
+
+				var req map[string]interface{}
+
+				switch {
+				case len(args) == 1 && args[0] == "off":
+					req = map[string]interface{}{"op": "stop-osc-capture"}
+				case len(args) == 1 && args[0] == "on":
+					req = map[string]interface{}{"op": "start-osc-capture"}
+				case len(args) == 2 && args[0] == "on":
+					req = map[string]interface{}{
+						"op": "start-osc-capture", "file": args[1],
+					}
+				default:
+					return invalidArgsError(args)
+				}
+
+				_, err = client.sendRequest(req)
+				return err
+			},
+		},
+
 		"export": {
 			helpSummary: "Exports the current score as a MIDI file.",
 			helpDetails: `Example usage:
@@ -198,6 +286,28 @@ Available commands:
 			},
 		},
 
+		"latency": {
+			helpSummary: "Displays the audio output latency of the player process.",
+			run: func(client *Client, argsString string) error {
+				res, err := client.sendRequest(
+					map[string]interface{}{"op": "player-latency"},
+				)
+				if err != nil {
+					return err
+				}
+
+				micros, ok := res["latency"].(int64)
+				if !ok {
+					return fmt.Errorf(
+						"the response from the REPL server did not contain the latency",
+					)
+				}
+
+				fmt.Println(time.Duration(micros) * time.Microsecond)
+				return nil
+			},
+		},
+
 		"load": {
 			helpSummary: "Loads a score file (*.alda) into the current REPL session.",
 			helpDetails: `Usage:
@@ -243,6 +353,122 @@ file into the REPL server.`,
 			},
 		},
 
+		"load-instruments": {
+			helpSummary: "Loads custom instrument definitions and aliases.",
+			helpDetails: `Usage:
+
+  :load-instruments instruments.json
+
+The file is a JSON object that defines instruments and/or aliases, e.g.:
+
+  {
+    "instruments": {
+      "lead": {"patch": 82, "aliases": ["synth-lead"]}
+    },
+    "aliases": {
+      "fiddle": "violin"
+    }
+  }
+
+Patch numbers are 1-128, as in the General MIDI spec. Redefining an existing
+instrument name or alias logs a warning on the REPL server.
+
+The definitions can be used in the score right away, without restarting the
+REPL server. Run the command again after changing the file to reload it.`,
+			run: func(client *Client, argsString string) error {
+				args, err := shlex.Split(argsString)
+				if err != nil {
+					return err
+				}
+
+				if len(args) != 1 {
+					return invalidArgsError(args)
+				}
+
+				// The server doesn't necessarily have the same working directory as the
+				// client, so we give it an absolute path.
+				path, err := filepath.Abs(args[0])
+				if err != nil {
+					return err
+				}
+
+				_, err = client.sendRequest(map[string]interface{}{
+					"op": "load-instruments", "path": path,
+				})
+				return err
+			},
+		},
+
+		"loglevel": {
+			helpSummary: "Sets the log level of the REPL server.",
+			helpDetails: `Usage:
+
+  :loglevel debug
+  :loglevel warn
+
+Valid log levels are debug, info, warn, error, fatal and panic.
+
+The new log level takes effect immediately, without restarting the REPL server.`,
+			run: func(client *Client, argsString string) error {
+				args, err := shlex.Split(argsString)
+				if err != nil {
+					return err
+				}
+
+				if len(args) != 1 {
+					return invalidArgsError(args)
+				}
+
+				_, err = client.sendRequest(map[string]interface{}{
+					"op": "loglevel", "level": args[0],
+				})
+				return err
+			},
+		},
+
+		"loop": {
+			helpSummary: "Plays a section of the score over and over.",
+			helpDetails: `Takes optional ` + "`from`" + ` and ` + "`to`" +
+				` arguments, like :play, in the form of
+markers or mm:ss times.
+
+The section plays over and over until you use :stop.
+
+Example usage:
+
+  :loop
+  :loop from verse to chorus
+  :loop from 0:05 to 0:10`,
+			run: func(client *Client, argsString string) error {
+				args, err := shlex.Split(argsString)
+				if err != nil {
+					return err
+				}
+
+				req, err := fromToRequest("loop", args)
+				if err != nil {
+					return err
+				}
+
+				_, err = client.sendRequest(req)
+				return err
+			},
+		},
+
+		"mute": {
+			helpSummary: "Silences a part during playback.",
+			helpDetails: `Usage:
+
+  :mute piano
+  :mute "lead guitar"
+
+The part is identified by its instrument name or alias. It stays muted in
+everything that is played afterward, until you run :unmute.
+
+Soloing a part (see :solo) takes precedence over muting.`,
+			run: partCommand("mute"),
+		},
+
 		"new": {
 			helpSummary: "Resets the REPL server state and initializes a new score.",
 			run: func(client *Client, argsString string) error {
@@ -257,6 +483,66 @@ file into the REPL server.`,
 			},
 		},
 
+		"osc": {
+			helpSummary: "Sends a raw OSC message to the player process.",
+			helpDetails: `Usage:
+
+  :osc /ping
+  :osc /track/1/midi/note 0 60 500 500 100
+  :osc /system/soundfont "/path/to/soundfont.sf2"
+
+Each argument after the address is an int, a float (e.g. 0.5) or a string in
+double quotes.
+
+This is meant for debugging and for developing player processes. It is unsafe,
+so the REPL server only allows it when it was started with --allow-raw-osc.`,
+			run: func(client *Client, argsString string) error {
+				address, args, _ := strings.Cut(strings.TrimSpace(argsString), " ")
+				if address == "" {
+					return invalidArgsError([]string{})
+				}
+
+				_, err := client.sendRequest(map[string]interface{}{
+					"op": "osc", "address": address, "args": args,
+				})
+				return err
+			},
+		},
+
+		"pin": {
+			helpSummary: "Makes the REPL server use a specific player process.",
+			helpDetails: `Usage:
+
+  :pin abc
+
+While a player process is pinned, the REPL server will not automatically switch
+to another player process if the pinned one becomes unavailable. This can be
+useful for debugging playback issues.
+
+To list the current player processes, you can run ` + "`alda ps`" + `.
+
+Use :unpin to restore the default behavior.`,
+			run: func(client *Client, argsString string) error {
+				args, err := shlex.Split(argsString)
+				if err != nil {
+					return err
+				}
+
+				if len(args) != 1 {
+					return invalidArgsError(args)
+				}
+
+				_, err = client.sendRequest(
+					map[string]interface{}{"op": "pin-player", "player-id": args[0]},
+				)
+				if err != nil {
+					return err
+				}
+
+				return nil
+			},
+		},
+
 		"play": {
 			helpSummary: "Plays the current score.",
 			helpDetails: `Can take optional ` + "`from`" + `and ` + "`to`" +
@@ -280,41 +566,71 @@ Example usage:
 					return err
 				}
 
-				req := map[string]interface{}{"op": "replay"}
+				req, err := fromToRequest("replay", args)
+				if err != nil {
+					return err
+				}
 
-				for i := 0; i < len(args); i++ {
-					// If this is the last argument, that means there are an odd number of
-					// arguments, which is invalid because we are expecting an even number
-					// of arguments.
-					if i == len(args)-1 {
-						return invalidArgsError(args)
-					}
+				_, err = client.sendRequest(req)
+				if err != nil {
+					return err
+				}
 
-					switch args[i] {
-					case "from":
-						_, hit := req["from"]
-						if hit {
-							return invalidArgsError(args)
-						}
-						i++
-						req["from"] = args[i]
-					case "to":
-						_, hit := req["to"]
-						if hit {
-							return invalidArgsError(args)
-						}
-						i++
-						req["to"] = args[i]
-					default:
-						return invalidArgsError(args)
-					}
+				return nil
+			},
+		},
+
+		"player": {
+			helpSummary: "Displays information about the player process in use.",
+			run: func(client *Client, argsString string) error {
+				res, err := client.sendRequest(
+					map[string]interface{}{"op": "player-status"},
+				)
+				if err != nil {
+					return err
 				}
 
-				_, err = client.sendRequest(req)
+				switch res["data"].(type) {
+				case string: // OK to proceed
+				default:
+					return fmt.Errorf(
+						"the response from the REPL server did not contain the player status",
+					)
+				}
+
+				status, err := json.ParseJSON([]byte(res["data"].(string)))
 				if err != nil {
 					return err
 				}
 
+				return printPlayerStatus(status)
+			},
+		},
+
+		"players": {
+			helpSummary: "Lists the player processes in the player pool.",
+			run: func(client *Client, argsString string) error {
+				res, err := client.sendRequest(
+					map[string]interface{}{"op": "players"},
+				)
+				if err != nil {
+					return err
+				}
+
+				switch res["data"].(type) {
+				case string: // OK to proceed
+				default:
+					return fmt.Errorf(
+						"the response from the REPL server did not contain the players",
+					)
+				}
+
+				players, err := json.ParseJSON([]byte(res["data"].(string)))
+				if err != nil {
+					return err
+				}
+
+				printPlayers(players)
 				return nil
 			},
 		},
@@ -327,6 +643,27 @@ Example usage:
 			},
 		},
 
+		"restart": {
+			helpSummary: "Replaces the REPL server's player process with a new one.",
+			helpDetails: `Usage:
+
+  :restart
+
+The player process that the REPL server is using is shut down, and the REPL
+server switches to a different player process. This can be useful if the player
+process gets into a bad state.`,
+			run: func(client *Client, argsString string) error {
+				_, err := client.sendRequest(
+					map[string]interface{}{"op": "restart-player"},
+				)
+				if err != nil {
+					return err
+				}
+
+				return nil
+			},
+		},
+
 		"save": {
 			helpSummary: "Saves the current score into a file (*.alda).",
 			helpDetails: `Usage:
@@ -370,10 +707,14 @@ arguments will save the updated score to the same file.`,
     :score
     :score text
 
-  Print a summary of the score, including information about instruments and
-  markers:
+  Print a summary of the score, including how long it is, how many notes each
+  part has, and information about instruments and markers:
     :score info
 
+  Print the notes of the score as Alda code, with each note's pitch and
+  duration written out explicitly:
+    :score rendered
+
   Print a data representation of the score (This is the output that you get
   when you run ` + "`alda parse -o data ...`" + ` at the command line.):
     :score data
@@ -412,6 +753,14 @@ arguments will save the updated score to the same file.`,
 
 					fmt.Println(scoreText)
 
+				case "rendered":
+					scoreRendered, err := client.scoreRendered()
+					if err != nil {
+						return err
+					}
+
+					fmt.Print(scoreRendered)
+
 				case "data":
 					scoreData, err := client.scoreData()
 					if err != nil {
@@ -437,6 +786,15 @@ arguments will save the updated score to the same file.`,
 					fmt.Println(parser.HumanReadableAST(scoreAST))
 
 				case "info":
+					scoreInfo, err := client.scoreInfo()
+					if err != nil {
+						return err
+					}
+
+					if err := printScoreSummary(scoreInfo); err != nil {
+						return err
+					}
+
 					scoreData, err := client.scoreData()
 					if err != nil {
 						return err
@@ -449,8 +807,27 @@ arguments will save the updated score to the same file.`,
 			},
 		},
 
+		"solo": {
+			helpSummary: "Plays only the soloed parts during playback.",
+			helpDetails: `Usage:
+
+  :solo piano
+  :solo "lead guitar"
+
+The part is identified by its instrument name or alias. While any parts are
+soloed, only those parts are heard in everything that is played, and mutes are
+ignored. Run :unsolo to undo.`,
+			run: partCommand("solo"),
+		},
+
 		"stop": {
 			helpSummary: "Stops playback.",
+			helpDetails: `Stops whatever is currently playing, right away.
+
+The score is left as it is, so you can keep adding to it, and the player
+process keeps running, so the next thing you play starts without delay.
+
+To start over with a new score, use :new. To leave the REPL, use :quit.`,
 			run: func(client *Client, argsString string) error {
 				_, err := client.sendRequest(map[string]interface{}{"op": "stop"})
 				if err != nil {
@@ -473,6 +850,98 @@ arguments will save the updated score to the same file.`,
 			},
 		},
 
+		"tempo-scale": {
+			helpSummary: "Plays everything faster or slower than written.",
+			helpDetails: `Usage:
+
+  :tempo-scale 0.7
+  :tempo-scale 1
+
+Multiplies the tempo of everything played from now on by the provided factor,
+without changing the score. For example, 0.7 plays at 70% speed, and 1 plays at
+normal speed. The factor must be positive.`,
+			run: func(client *Client, argsString string) error {
+				args, err := shlex.Split(argsString)
+				if err != nil {
+					return err
+				}
+
+				if len(args) != 1 {
+					return invalidArgsError(args)
+				}
+
+				if _, err := strconv.ParseFloat(args[0], 64); err != nil {
+					return invalidArgsError(args)
+				}
+
+				_, err = client.sendRequest(map[string]interface{}{
+					"op": "tempo-scale", "factor": args[0],
+				})
+				return err
+			},
+		},
+
+		"transpose": {
+			helpSummary: "Transposes the notes of the current score.",
+			helpDetails: `Usage:
+
+  :transpose 12
+  :transpose -2
+
+Shifts the pitch of every note in the score by the provided number of
+semitones. Notes entered afterward are transposed as well.`,
+			run: func(client *Client, argsString string) error {
+				args, err := shlex.Split(argsString)
+				if err != nil {
+					return err
+				}
+
+				if len(args) != 1 {
+					return invalidArgsError(args)
+				}
+
+				semitones, err := strconv.Atoi(args[0])
+				if err != nil {
+					return invalidArgsError(args)
+				}
+
+				_, err = client.sendRequest(map[string]interface{}{
+					"op": "transpose", "semitones": int64(semitones),
+				})
+				return err
+			},
+		},
+
+		"unpin": {
+			helpSummary: "Lets the REPL server switch player processes as needed.",
+			run: func(client *Client, argsString string) error {
+				_, err := client.sendRequest(
+					map[string]interface{}{"op": "unpin-player"},
+				)
+				if err != nil {
+					return err
+				}
+
+				return nil
+			},
+		},
+
+		"unmute": {
+			helpSummary: "Undoes :mute for a part.",
+			helpDetails: `Usage:
+
+  :unmute piano`,
+			run: partCommand("unmute"),
+		},
+
+		"unsolo": {
+			helpSummary: "Undoes :solo for a part.",
+			helpDetails: `Usage:
+
+  :unsolo piano`,
+			run: partCommand("unsolo"),
+		},
+
 		"version": {
 			helpSummary: "Displays the version numbers of the Alda server and client.",
 			run: func(client *Client, argsString string) error {
@@ -554,18 +1023,13 @@ func (client *Client) connect() error {
 	// First, close any existing connection to avoid a memory leak.
 	client.Disconnect()
 
-	if err := util.Await(
-		func() error {
-			conn, err := net.DialTCP("tcp", nil, client.serverAddr)
-			if err != nil {
-				return err
-			}
-
-			client.serverConn = conn
-			return nil
+	conn, err := util.AwaitResult(
+		func() (*net.TCPConn, error) {
+			return net.DialTCP("tcp", nil, client.serverAddr)
 		},
 		serverConnectTimeout,
-	); err != nil {
+	)
+	if err != nil {
 		return help.UserFacingErrorf(
 			`Attempting to connect to %s resulted in this error:
 
@@ -583,6 +1047,7 @@ by running:
 		)
 	}
 
+	client.serverConn = conn
 	return nil
 }
 
@@ -622,6 +1087,31 @@ func suppressErrorPrinting() replClientRequestOption {
 // server and put them onto the channel until it encounters a response whose
 // "status" value includes "done". At the moment, we don't have that need, so
 // we're keeping it simple.
+// receiveResponse reads the next response from the server. Notifications that
+// the server sends in the meantime (e.g. when it switches to a different player
+// process) aren't responses to anything, so they are logged and skipped.
+func (client *Client) receiveResponse() (map[string]interface{}, error) {
+	for {
+		response, err := bencode.Decode(client.serverConn)
+		if err != nil {
+			return nil, err
+		}
+
+		res, ok := response.(map[string]interface{})
+		if !ok {
+			return nil,
+				fmt.Errorf("response could not be decoded into the expected type")
+		}
+
+		if _, isNotification := res["notification"]; isNotification {
+			log.Debug().Interface("notification", res).Msg("Received notification.")
+			continue
+		}
+
+		return res, nil
+	}
+}
+
 func (client *Client) sendRequest(
 	req map[string]interface{}, opts ...replClientRequestOption,
 ) (map[string]interface{}, error) {
@@ -646,19 +1136,11 @@ func (client *Client) sendRequest(
 	// Avoid hanging forever if the server doesn't respond.
 	client.serverConn.SetReadDeadline(time.Now().Add(30 * time.Second))
 
-	response, err := bencode.Decode(client.serverConn)
+	res, err := client.receiveResponse()
 	if err != nil {
 		return nil, err
 	}
 
-	switch response.(type) {
-	case map[string]interface{}: // OK to continue
-	default:
-		return nil,
-			fmt.Errorf("response could not be decoded into the expected type")
-	}
-	res := response.(map[string]interface{})
-
 	log.Debug().Interface("response", res).Msg("Received response.")
 
 	if res["id"] != messageID {
@@ -806,6 +1288,25 @@ func (client *Client) scoreText() (string, error) {
 	return res["text"].(string), nil
 }
 
+func (client *Client) scoreRendered() (string, error) {
+	res, err := client.sendRequest(
+		map[string]interface{}{"op": "score-rendered"},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	switch res["text"].(type) {
+	case string: // OK to proceed
+	default:
+		return "", fmt.Errorf(
+			"the response from the REPL server did not contain the rendered " +
+				"score",
+		)
+	}
+	return res["text"].(string), nil
+}
+
 func (client *Client) scoreData() (*json.Container, error) {
 	res, err := client.sendRequest(
 		map[string]interface{}{"op": "score-data"},
@@ -825,6 +1326,25 @@ func (client *Client) scoreData() (*json.Container, error) {
 	return json.ParseJSON([]byte(res["data"].(string)))
 }
 
+func (client *Client) scoreInfo() (*json.Container, error) {
+	res, err := client.sendRequest(
+		map[string]interface{}{"op": "score-info"},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	switch res["info"].(type) {
+	case string: // OK to proceed
+	default:
+		return nil, fmt.Errorf(
+			"the response from the REPL server did not contain the score info",
+		)
+	}
+
+	return json.ParseJSON([]byte(res["info"].(string)))
+}
+
 func (client *Client) scoreEvents() (*json.Container, error) {
 	res, err := client.sendRequest(
 		map[string]interface{}{"op": "score-events"},
@@ -863,6 +1383,107 @@ func (client *Client) scoreAST() (*json.Container, error) {
 	return json.ParseJSON([]byte(res["ast"].(string)))
 }
 
+func printPlayers(players *json.Container) {
+	if len(players.Children()) == 0 {
+		fmt.Println("(no player processes)")
+		return
+	}
+
+	fmt.Println("id\tport\tstate\tnotes")
+
+	for _, player := range players.Children() {
+		notes := []string{}
+
+		if bound, _ := player.Search("bound").Data().(bool); bound {
+			notes = append(notes, "in use")
+		}
+
+		if expired, _ := player.Search("expired").Data().(bool); expired {
+			notes = append(notes, "expired")
+		}
+
+		fmt.Printf(
+			"%v\t%v\t%v\t%s\n",
+			player.Search("id").Data(),
+			player.Search("port").Data(),
+			player.Search("state").Data(),
+			strings.Join(notes, ", "),
+		)
+	}
+}
+
+func printPlayerStatus(status *json.Container) error {
+	player := status.Search("player")
+	if player.Data() == nil {
+		return fmt.Errorf("server response missing information about the player")
+	}
+
+	if id, _ := player.Search("id").Data().(string); id == "" {
+		fmt.Println("Player: (none)")
+	} else {
+		fmt.Printf("Player: %s (port %v)\n", id, player.Search("port").Data())
+	}
+
+	if pinned, ok := status.Search("pinnedPlayerID").Data().(string); ok {
+		fmt.Printf("Pinned player: %s\n", pinned)
+	}
+
+	fmt.Printf("Player pool size: %v\n", status.Search("poolSize").Data())
+	if healthy, ok := status.Search("poolHealthy").Data().(bool); ok && !healthy {
+		fmt.Println(
+			"Player pool: unhealthy (unable to start new player processes)",
+		)
+	}
+	fmt.Printf(
+		"Last successful ping: %v\n", status.Search("lastSuccessfulPing").Data(),
+	)
+	fmt.Printf(
+		"Consecutive failed pings: %v\n", status.Search("failedPings").Data(),
+	)
+
+	if reason, ok := status.Search("unreachableReason").Data().(string); ok {
+		fmt.Printf("Last player lost because: %s\n", reason)
+	}
+
+	return nil
+}
+
+func printScoreSummary(scoreInfo *json.Container) error {
+	durationMs, ok := scoreInfo.Search("duration-ms").Data().(float64)
+	if !ok {
+		return fmt.Errorf("server response missing the duration of the score")
+	}
+
+	fmt.Printf(
+		"Duration:\n  %s\n\n",
+		time.Duration(durationMs*float64(time.Millisecond)).Round(time.Millisecond),
+	)
+
+	parts := scoreInfo.Search("parts")
+	if parts.Data() == nil {
+		return fmt.Errorf("server response missing information about parts")
+	}
+
+	fmt.Println("Notes:")
+
+	if len(parts.Children()) == 0 {
+		fmt.Println("  (none)")
+	} else {
+		for _, part := range parts.Children() {
+			fmt.Printf(
+				"  %s (%s): %v\n",
+				part.Search("name").Data(),
+				part.Search("instrument").Data(),
+				part.Search("notes").Data(),
+			)
+		}
+	}
+
+	fmt.Println()
+
+	return nil
+}
+
 func printScoreInfo(scoreData *json.Container) error {
 	parts := scoreData.Search("parts")
 	if parts.Data() == nil {
@@ -0,0 +1,140 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	log "alda.io/client/logging"
+)
+
+// WithHealthCheckPort makes the server listen for HTTP requests on the provided
+// port, where it answers health checks, e.g. liveness and readiness probes from
+// a container orchestrator. `/healthz` responds 200 as long as the server is
+// running. `/readyz` responds 200 when the server has a player process to use,
+// and 503 otherwise.
+//
+// `/metrics` exports the server's player management metrics (see `Metrics`) in
+// the Prometheus text format.
+//
+// Unlike the nREPL port, the health check port is reachable from other hosts,
+// since that's where the probes come from.
+func WithHealthCheckPort(port int) ServerOption {
+	return func(server *Server) {
+		server.healthCheckPort = port
+	}
+}
+
+func (server *Server) healthCheckHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !server.hasPlayer() {
+			http.Error(w, "no player process", http.StatusServiceUnavailable)
+			return
+		}
+
+		fmt.Fprintln(w, "ok")
+	})
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		server.writePrometheusMetrics(w)
+	})
+
+	return mux
+}
+
+type prometheusMetric struct {
+	name  string
+	kind  string
+	help  string
+	value float64
+}
+
+// writePrometheusMetrics writes the server's metrics in the Prometheus text
+// exposition format.
+//
+// NB: We write the format by hand rather than using the Prometheus client
+// library, which would be a large dependency for a handful of numbers.
+func (server *Server) writePrometheusMetrics(w io.Writer) {
+	metrics := server.Metrics()
+
+	exported := []prometheusMetric{
+		{
+			"alda_repl_pings_total", "counter",
+			"Pings sent to the player process that the server is using.",
+			float64(metrics.PingsSent),
+		},
+		{
+			"alda_repl_ping_failures_total", "counter",
+			"Pings to the player process that failed.",
+			float64(metrics.PingFailures),
+		},
+		{
+			"alda_repl_player_replacements_total", "counter",
+			"Times that the server switched to a different player process.",
+			float64(metrics.PlayerReplacements),
+		},
+		{
+			"alda_repl_pool_fills_total", "counter",
+			"Times that the player pool was filled.",
+			float64(metrics.PoolFills),
+		},
+	}
+
+	if poolSize, err := playerPoolSize(); err != nil {
+		log.Warn().Err(err).Msg("Failed to determine player pool size.")
+	} else {
+		exported = append(exported, prometheusMetric{
+			"alda_repl_player_pool_size", "gauge",
+			"Player processes that are available or starting up.",
+			float64(poolSize),
+		})
+	}
+
+	for _, metric := range exported {
+		fmt.Fprintf(w, "# HELP %s %s\n", metric.name, metric.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", metric.name, metric.kind)
+		fmt.Fprintf(w, "%s %v\n", metric.name, metric.value)
+	}
+}
+
+// serveHealthChecks starts answering health checks on the configured port, if
+// there is one. The listener is closed when the server is closed.
+func (server *Server) serveHealthChecks() error {
+	if server.healthCheckPort == 0 {
+		return nil
+	}
+
+	l, err := net.Listen("tcp", ":"+strconv.Itoa(server.healthCheckPort))
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-server.done
+		l.Close()
+	}()
+
+	go func() {
+		err := http.Serve(l, server.healthCheckHandler())
+
+		select {
+		case <-server.done:
+		default:
+			log.Warn().
+				Err(err).
+				Int("port", server.healthCheckPort).
+				Msg("Stopped answering health checks.")
+		}
+	}()
+
+	return nil
+}
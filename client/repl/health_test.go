@@ -0,0 +1,95 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"alda.io/client/system"
+)
+
+func TestHealthChecks(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	server := NewServer(0)
+	server.setPlayer(testPlayer())
+
+	httpServer := httptest.NewServer(server.healthCheckHandler())
+	defer httpServer.Close()
+
+	expectStatus := func(path string, expected int) {
+		t.Helper()
+
+		res, err := http.Get(httpServer.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != expected {
+			t.Errorf(
+				"expected %s to respond %d, got %d", path, expected, res.StatusCode,
+			)
+		}
+	}
+
+	expectStatus("/healthz", http.StatusOK)
+	expectStatus("/readyz", http.StatusOK)
+
+	server.unsetPlayer(ReasonNotFound)
+
+	expectStatus("/healthz", http.StatusOK)
+	expectStatus("/readyz", http.StatusServiceUnavailable)
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	players := []system.PlayerState{}
+	for i := 0; i < 2; i++ {
+		players = append(players, system.PlayerState{
+			State: "ready", Port: 27280 + i, ID: fmt.Sprintf("player%d", i),
+		})
+	}
+
+	fake := &fakePlayerSystem{players: players}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0)
+	server.refreshPlayer()
+
+	// Simulate the player process going offline, so that it's replaced.
+	fake.lock.Lock()
+	fake.players = fake.players[1:]
+	fake.lock.Unlock()
+
+	server.refreshPlayer()
+
+	httpServer := httptest.NewServer(server.healthCheckHandler())
+	defer httpServer.Close()
+
+	res, err := http.Get(httpServer.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"# TYPE alda_repl_player_replacements_total counter",
+		"alda_repl_player_replacements_total 1",
+		"alda_repl_ping_failures_total 0",
+		"alda_repl_pool_fills_total 0",
+		"# TYPE alda_repl_player_pool_size gauge",
+		"alda_repl_player_pool_size 1",
+	} {
+		if !strings.Contains(string(body), expected+"\n") {
+			t.Errorf("expected %q in metrics:\n%s", expected, body)
+		}
+	}
+}
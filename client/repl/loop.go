@@ -0,0 +1,195 @@
+package repl
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	log "alda.io/client/logging"
+	"alda.io/client/model"
+	"alda.io/client/transmitter"
+)
+
+// How often a loop checks whether it's time to play the section again.
+var loopPollInterval = 10 * time.Millisecond
+
+// A loop plays a section of the score over and over. (See `Loop`.)
+type loop struct {
+	// Closed to tell the loop to stop.
+	done chan struct{}
+}
+
+// stopped returns true if the loop has been told to stop.
+func (l *loop) stopped() bool {
+	select {
+	case <-l.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// sectionLength returns the length (in milliseconds) of the section of the
+// score between `from` and `to`, which are time markings or markers, like the
+// `--from` and `--to` options of `alda play`. Either one can be "", meaning the
+// beginning or the end of the score.
+//
+// When `to` is omitted, the section ends when the last note in it ends.
+func sectionLength(score *model.Score, from, to string) (float64, error) {
+	startOffset := 0.0
+	if from != "" {
+		offset, err := score.InterpretOffsetReference(from)
+		if err != nil {
+			return 0, err
+		}
+		startOffset = offset
+	}
+
+	if to != "" {
+		endOffset, err := score.InterpretOffsetReference(to)
+		if err != nil {
+			return 0, err
+		}
+		return endOffset - startOffset, nil
+	}
+
+	endOffset := startOffset
+	for _, note := range score.NoteEvents() {
+		if note.Offset >= startOffset {
+			endOffset = math.Max(endOffset, note.Offset+note.Duration)
+		}
+	}
+
+	return endOffset - startOffset, nil
+}
+
+// Loop plays the section of the score between `from` and `to` (see
+// `sectionLength`) over and over, until `Stop` is called. Each time the section
+// finishes playing, it is sent to the player process again.
+//
+// Starting a loop stops the loop that was already playing, if there was one.
+//
+// Each time around, the section is played from the score as it is at that
+// time, so changes to the score are heard the next time around. The routine
+// that plays the section holds the state lock while it does so, in case a
+// request is being handled at the same time.
+//
+// Returns an error if the section is empty or can't be found in the score, or
+// if it can't be played the first time around.
+func (server *Server) Loop(from, to string) error {
+	lengthMs, err := sectionLength(server.score, from, to)
+	if err != nil {
+		return err
+	}
+
+	if lengthMs <= 0 {
+		return fmt.Errorf("there are no notes to loop")
+	}
+
+	lengthMs /= server.tempoScale
+	length := time.Duration(lengthMs * float64(time.Millisecond))
+
+	server.stopLoop()
+
+	opts := append(
+		[]transmitter.TransmissionOption{
+			transmitter.TransmitFrom(from), transmitter.TransmitTo(to),
+		},
+		server.playbackOpts()...,
+	)
+
+	playSection := func() error {
+		return server.withTransmitter(
+			func(transmitter transmitter.PlayerTransmitter) error {
+				return transmitter.TransmitScore(server.score, opts...)
+			},
+		)
+	}
+
+	if err := playSection(); err != nil {
+		return err
+	}
+
+	next := server.now().Add(length)
+
+	l := &loop{done: make(chan struct{})}
+	server.setLoop(l)
+
+	go func() {
+		ticker := time.NewTicker(loopPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-l.done:
+				return
+			case <-ticker.C:
+				if server.now().Before(next) {
+					continue
+				}
+
+				if !server.playLoopIteration(l, playSection) {
+					return
+				}
+
+				next = next.Add(length)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// playLoopIteration plays the section of the score that `l` loops, unless the
+// loop has been stopped. Returns false if the loop has stopped, either because
+// it was told to or because playing the section failed.
+func (server *Server) playLoopIteration(
+	l *loop, playSection func() error,
+) bool {
+	server.stateLock.Lock()
+	defer server.stateLock.Unlock()
+
+	// `stopLoop` is called while holding the state lock, so if the loop hasn't
+	// been stopped by now, it won't be until we're done.
+	if l.stopped() {
+		return false
+	}
+
+	if err := playSection(); err != nil {
+		log.Warn().Err(err).Msg("Failed to play loop. Stopping the loop.")
+
+		close(l.done)
+		if server.loop == l {
+			server.setLoop(nil)
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// stopLoop stops the loop that is playing, if there is one. Nothing else from
+// the loop is sent to the player process afterward.
+//
+// This must be called while holding the state lock (see `playLoopIteration`),
+// which is the case when handling a request.
+func (server *Server) stopLoop() {
+	if server.loop == nil {
+		return
+	}
+
+	close(server.loop.done)
+	server.setLoop(nil)
+}
+
+// setLoop sets the section of the score that is playing over and over, or nil
+// if none is. (See `IsPlaying`.)
+//
+// This must be called while holding the state lock.
+func (server *Server) setLoop(l *loop) {
+	server.playbackLock.Lock()
+	defer server.playbackLock.Unlock()
+
+	server.loop = l
+}
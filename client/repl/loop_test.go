@@ -0,0 +1,278 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"alda.io/client/model"
+	"alda.io/client/system"
+	"alda.io/client/transmitter"
+)
+
+// lockedTransmitter is a mockTransmitter that can be used from more than one
+// routine at a time, and keeps count of the scores transmitted.
+type lockedTransmitter struct {
+	mockTransmitter
+	lock   *sync.Mutex
+	scores *int
+}
+
+func (lt lockedTransmitter) TransmitScore(
+	score *model.Score, opts ...transmitter.TransmissionOption,
+) error {
+	lt.lock.Lock()
+	defer lt.lock.Unlock()
+
+	*lt.scores++
+	return nil
+}
+
+func (lt lockedTransmitter) TransmitStopMessage() error {
+	lt.lock.Lock()
+	defer lt.lock.Unlock()
+
+	return lt.mockTransmitter.TransmitStopMessage()
+}
+
+func TestLoop(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	originalPollInterval := loopPollInterval
+	t.Cleanup(func() { loopPollInterval = originalPollInterval })
+	loopPollInterval = time.Millisecond
+
+	var clockLock sync.Mutex
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time {
+		clockLock.Lock()
+		defer clockLock.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		clockLock.Lock()
+		defer clockLock.Unlock()
+		now = now.Add(d)
+	}
+
+	var lock sync.Mutex
+	sent := []string{}
+	scores := 0
+	transmittedScores := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return scores
+	}
+
+	server := NewServer(
+		0,
+		WithClock(clock),
+		WithTransmitterFactory(
+			func(player system.PlayerState) transmitter.PlayerTransmitter {
+				return lockedTransmitter{
+					mockTransmitter: mockTransmitter{sent: &sent},
+					lock:            &lock,
+					scores:          &scores,
+				}
+			},
+		),
+	)
+	server.setPlayer(testPlayer())
+
+	// The section between the markers is 2 quarter notes at 120 bpm, i.e. 1
+	// second long.
+	if _, err := server.updateScoreWithInput(
+		"piano: c %verse d e %chorus f",
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Loop("verse", "chorus"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !server.IsPlaying() {
+		t.Error("expected the server to be playing while looping")
+	}
+
+	awaitScores := func(expected int) {
+		deadline := time.Now().Add(time.Second)
+		for transmittedScores() < expected {
+			if time.Now().After(deadline) {
+				t.Fatalf(
+					"expected %d iterations, got %d", expected, transmittedScores(),
+				)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// The first iteration is played right away.
+	awaitScores(1)
+
+	// Nothing else is played until the section ends.
+	advance(900 * time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if n := transmittedScores(); n != 1 {
+		t.Errorf("expected 1 iteration before the section ends, got %d", n)
+	}
+
+	advance(100 * time.Millisecond)
+	awaitScores(2)
+
+	advance(time.Second)
+	awaitScores(3)
+
+	if err := server.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.IsPlaying() {
+		t.Error("expected the server not to be playing after stopping")
+	}
+
+	// Once the loop is stopped, the section isn't played again.
+	advance(5 * time.Second)
+	time.Sleep(20 * time.Millisecond)
+	if n := transmittedScores(); n != 3 {
+		t.Errorf("expected 3 iterations after stopping, got %d", n)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(sent) != 1 || sent[0] != "stop" {
+		t.Errorf("expected a stop message to be sent, got %v", sent)
+	}
+}
+
+func TestLoopWithoutNotes(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	server := NewServer(0, WithDryRun())
+
+	if err := server.Loop("", ""); err == nil {
+		t.Error("expected an error when there are no notes to loop")
+	}
+
+	if err := server.Loop("nonexistent", ""); err == nil {
+		t.Error("expected an error for a marker that doesn't exist")
+	}
+}
+
+// failingTransmitter is a lockedTransmitter that fails to transmit any scores
+// after the first `succeed` scores.
+type failingTransmitter struct {
+	lockedTransmitter
+	succeed int
+}
+
+func (ft failingTransmitter) TransmitScore(
+	score *model.Score, opts ...transmitter.TransmissionOption,
+) error {
+	ft.lock.Lock()
+	defer ft.lock.Unlock()
+
+	*ft.scores++
+	if *ft.scores > ft.succeed {
+		return fmt.Errorf("transmission failed")
+	}
+
+	return nil
+}
+
+func TestLoopStopsWhenPlayingFails(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	originalPollInterval := loopPollInterval
+	t.Cleanup(func() { loopPollInterval = originalPollInterval })
+	loopPollInterval = time.Millisecond
+
+	var lock sync.Mutex
+	sent := []string{}
+	scores := 0
+
+	server := NewServer(0, WithTransmitterFactory(
+		func(player system.PlayerState) transmitter.PlayerTransmitter {
+			return failingTransmitter{
+				lockedTransmitter: lockedTransmitter{
+					mockTransmitter: mockTransmitter{sent: &sent},
+					lock:            &lock,
+					scores:          &scores,
+				},
+				succeed: 2,
+			}
+		},
+	))
+	server.setPlayer(testPlayer())
+
+	// A 32nd note at the default tempo is 62.5ms long.
+	if _, err := server.updateScoreWithInput("piano: c32"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Loop("", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for server.IsPlaying() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the loop to stop after failing to play the section")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if scores != 3 {
+		t.Errorf("expected 3 attempts to play the section, got %d", scores)
+	}
+}
+
+func TestLoopWhileHandlingRequests(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	originalPollInterval := loopPollInterval
+	t.Cleanup(func() { loopPollInterval = originalPollInterval })
+	loopPollInterval = time.Millisecond
+
+	server := NewServer(0, WithDryRun())
+	go server.handleRequests()
+	t.Cleanup(func() { close(server.requestQueue) })
+
+	// Responses are written to the client's end of the connection, so we need
+	// to read them.
+	conn, clientConn := net.Pipe()
+	t.Cleanup(func() { conn.Close() })
+	go io.Copy(io.Discard, clientConn)
+
+	request := func(msg map[string]interface{}) {
+		server.requestQueue <- nREPLRequest{conn: conn, msg: msg}
+	}
+
+	// At this tempo, the section is 7.5ms long.
+	request(map[string]interface{}{
+		"op": "eval-and-play", "code": "piano: (tempo 1000) c32",
+	})
+	request(map[string]interface{}{"op": "loop"})
+
+	// The routine that plays the loop takes turns with the requests.
+	for i := 0; i < 20; i++ {
+		request(map[string]interface{}{"op": "eval-and-play", "code": "d32"})
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Stopping the loop while handling a request must not wait for the routine
+	// that plays the loop, which might be waiting for its turn.
+	request(map[string]interface{}{"op": "stop"})
+	// Requests are handled one at a time, so once this one is received, the
+	// previous one has been handled.
+	request(map[string]interface{}{"op": "describe"})
+
+	if server.IsPlaying() {
+		t.Error("expected the loop to be stopped")
+	}
+}
@@ -0,0 +1,73 @@
+package repl
+
+import (
+	"fmt"
+	"sort"
+)
+
+// validatePartName returns an error if no part in the score has the provided
+// name or alias.
+func (server *Server) validatePartName(name string) error {
+	if len(server.score.PartsNamed(name)) == 0 {
+		return fmt.Errorf("no part named %q in the score", name)
+	}
+
+	return nil
+}
+
+// sortedNames returns the names in a set of part names, in a consistent order.
+func sortedNames(names map[string]bool) []string {
+	results := []string{}
+	for name := range names {
+		results = append(results, name)
+	}
+
+	sort.Strings(results)
+
+	return results
+}
+
+// MutePart silences the part with the provided name (e.g. "piano") or alias in
+// everything that the server plays from now on, until `UnmutePart` is called.
+//
+// Soloing a part (see `SoloPart`) takes precedence over muting.
+func (server *Server) MutePart(name string) error {
+	if err := server.validatePartName(name); err != nil {
+		return err
+	}
+
+	if server.mutedParts == nil {
+		server.mutedParts = map[string]bool{}
+	}
+
+	server.mutedParts[name] = true
+
+	return nil
+}
+
+// UnmutePart undoes `MutePart`. It has no effect if the part isn't muted.
+func (server *Server) UnmutePart(name string) {
+	delete(server.mutedParts, name)
+}
+
+// SoloPart makes the server play only the part with the provided name (e.g.
+// "piano") or alias, along with any other soloed parts, from now on, until
+// `UnsoloPart` is called. While any parts are soloed, mutes are ignored.
+func (server *Server) SoloPart(name string) error {
+	if err := server.validatePartName(name); err != nil {
+		return err
+	}
+
+	if server.soloedParts == nil {
+		server.soloedParts = map[string]bool{}
+	}
+
+	server.soloedParts[name] = true
+
+	return nil
+}
+
+// UnsoloPart undoes `SoloPart`. It has no effect if the part isn't soloed.
+func (server *Server) UnsoloPart(name string) {
+	delete(server.soloedParts, name)
+}
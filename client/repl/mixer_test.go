@@ -0,0 +1,107 @@
+package repl
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/daveyarwood/go-osc/osc"
+)
+
+// sentNotes returns the MIDI note numbers of the notes in an OSC bundle, in
+// ascending order. (The order of notes that start at the same time isn't
+// specified.)
+func sentNotes(bundle *osc.Bundle) []int32 {
+	notes := []int32{}
+
+	for _, msg := range bundle.Messages {
+		if strings.HasSuffix(msg.Address, "/midi/note") {
+			notes = append(notes, msg.Arguments[1].(int32))
+		}
+	}
+
+	sort.Slice(notes, func(i, j int) bool { return notes[i] < notes[j] })
+
+	return notes
+}
+
+func TestMuteAndSolo(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	bundles := []*osc.Bundle{}
+
+	server := NewServer(0, bundleTransmitterFactory(&bundles))
+	server.setPlayer(testPlayer())
+
+	input := `piano: c d e
+violin "fiddle": g a b`
+
+	if err := server.evalAndPlay(input); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.MutePart("flute"); err == nil {
+		t.Error("expected an error for a part that isn't in the score")
+	}
+
+	if err := server.SoloPart("flute"); err == nil {
+		t.Error("expected an error for a part that isn't in the score")
+	}
+
+	steps := []struct {
+		label    string
+		update   func() error
+		expected []int32
+	}{
+		{
+			label:    "mute piano",
+			update:   func() error { return server.MutePart("piano") },
+			expected: []int32{67, 69, 71},
+		},
+		{
+			label:    "solo piano (overrides the mute)",
+			update:   func() error { return server.SoloPart("piano") },
+			expected: []int32{60, 62, 64},
+		},
+		{
+			label: "solo violin instead, by its alias",
+			update: func() error {
+				server.UnsoloPart("piano")
+				return server.SoloPart("fiddle")
+			},
+			expected: []int32{67, 69, 71},
+		},
+		{
+			label: "clear the solo and the mute",
+			update: func() error {
+				server.UnsoloPart("fiddle")
+				server.UnmutePart("piano")
+				return nil
+			},
+			expected: []int32{60, 62, 64, 67, 69, 71},
+		},
+	}
+
+	for _, step := range steps {
+		if err := step.update(); err != nil {
+			t.Fatalf("%s: %v", step.label, err)
+		}
+
+		// Mutes and solos persist across plays.
+		for i := 0; i < 2; i++ {
+			bundles = nil
+
+			if err := server.PlayString(input); err != nil {
+				t.Fatalf("%s: %v", step.label, err)
+			}
+
+			notes := sentNotes(bundles[0])
+			if !reflect.DeepEqual(notes, step.expected) {
+				t.Errorf(
+					"%s: expected notes %v, got %v", step.label, step.expected, notes,
+				)
+			}
+		}
+	}
+}
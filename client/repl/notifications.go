@@ -0,0 +1,98 @@
+package repl
+
+import (
+	"net"
+	"time"
+
+	bencode "github.com/jackpal/bencode-go"
+
+	log "alda.io/client/logging"
+	"alda.io/client/system"
+)
+
+// How long the server waits for a client to accept a notification before
+// giving up on it, so that a client that isn't reading can't hold up the
+// `managePlayers` loop.
+var notificationTimeout = time.Second
+
+// addClient keeps track of a connected client, so that notifications can be
+// sent to it. (See `notifyClients`.)
+func (server *Server) addClient(conn net.Conn) {
+	server.clientsLock.Lock()
+	defer server.clientsLock.Unlock()
+
+	if server.clients == nil {
+		server.clients = map[net.Conn]struct{}{}
+	}
+
+	server.clients[conn] = struct{}{}
+}
+
+// removeClient stops sending notifications to a client that has disconnected.
+func (server *Server) removeClient(conn net.Conn) {
+	server.clientsLock.Lock()
+	defer server.clientsLock.Unlock()
+
+	delete(server.clients, conn)
+}
+
+// writeToClient sends a bencoded message to a client.
+//
+// Responses are sent from the routine that handles requests, whereas
+// notifications are sent from other routines, so this ensures that only one
+// message is written to a connection at a time.
+func (server *Server) writeToClient(
+	conn net.Conn, data map[string]interface{},
+) error {
+	server.clientsLock.Lock()
+	defer server.clientsLock.Unlock()
+
+	return bencode.Marshal(conn, data)
+}
+
+// notifyClients sends a message to every connected client that the client
+// didn't ask for, e.g. to tell editor integrations that something about the
+// server has changed.
+//
+// Notifications have a "notification" key that identifies the kind of
+// notification, and unlike responses, they have no "id".
+func (server *Server) notifyClients(
+	notification string, data map[string]interface{},
+) {
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+
+	data["notification"] = notification
+
+	server.clientsLock.Lock()
+	defer server.clientsLock.Unlock()
+
+	log.Info().
+		Interface("data", data).
+		Int("clients", len(server.clients)).
+		Msg("Sending notification.")
+
+	for conn := range server.clients {
+		conn.SetWriteDeadline(time.Now().Add(notificationTimeout))
+
+		if err := bencode.Marshal(conn, data); err != nil {
+			log.Warn().
+				Err(err).
+				Interface("data", data).
+				Msg("Failed to send notification.")
+		}
+
+		conn.SetWriteDeadline(time.Time{})
+	}
+}
+
+// notifyPlayerChanged tells connected clients that the server has switched to a
+// different player process, so that e.g. editors can update the player
+// information that they display.
+func (server *Server) notifyPlayerChanged(player system.PlayerState) {
+	server.notifyClients("player-changed", map[string]interface{}{
+		"player-id":   player.ID,
+		"player-port": int64(player.Port),
+	})
+}
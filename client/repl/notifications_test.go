@@ -0,0 +1,86 @@
+package repl
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	bencode "github.com/jackpal/bencode-go"
+
+	"alda.io/client/system"
+)
+
+// connectClients starts the server listening on a random port and connects the
+// specified number of mock clients to it.
+func connectClients(t *testing.T, server *Server, count int) []net.Conn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go server.listen(listener)
+
+	conns := []net.Conn{}
+	for i := 0; i < count; i++ {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+
+		conns = append(conns, conn)
+	}
+
+	// Wait for the server to accept all of the connections.
+	deadline := time.Now().Add(time.Second)
+	for {
+		server.clientsLock.Lock()
+		connected := len(server.clients)
+		server.clientsLock.Unlock()
+
+		if connected == count {
+			return conns
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d connected clients, got %d", count, connected)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPlayerChangedNotification(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	first := system.PlayerState{ID: "aaa", Port: 27278, State: "ready"}
+	second := system.PlayerState{ID: "bbb", Port: 27279, State: "ready"}
+
+	server := NewServer(0, WithDryRun())
+	clients := connectClients(t, server, 2)
+
+	server.setPlayer(first)
+	server.setPlayer(second)
+
+	for i, conn := range clients {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+
+		decoded, err := bencode.Decode(conn)
+		if err != nil {
+			t.Fatalf("client %d: expected a notification, got error: %v", i, err)
+		}
+
+		msg, ok := decoded.(map[string]interface{})
+		if !ok {
+			t.Fatalf("client %d: unexpected message: %#v", i, decoded)
+		}
+
+		if msg["notification"] != "player-changed" ||
+			msg["player-id"] != second.ID ||
+			fmt.Sprint(msg["player-port"]) != fmt.Sprint(second.Port) {
+			t.Errorf("client %d: unexpected notification: %#v", i, msg)
+		}
+	}
+}
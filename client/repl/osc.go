@@ -0,0 +1,119 @@
+package repl
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"alda.io/client/transmitter"
+)
+
+var errRawOSCDisabled = fmt.Errorf(
+	"sending raw OSC messages is disabled; start the REPL server with " +
+		"--allow-raw-osc to enable it",
+)
+
+// WithRawOSC allows clients to send arbitrary OSC messages to the player
+// process. (See `SendOSC`.)
+//
+// This is off by default because it's unsafe: a malformed message can put the
+// player process into a bad state, and the server doesn't know what such a
+// message does, so its idea of the score state can end up out of sync with the
+// player process.
+func WithRawOSC() ServerOption {
+	return func(server *Server) {
+		server.rawOSC = true
+	}
+}
+
+// parseOSCArgs parses a whitespace-separated list of OSC message arguments,
+// e.g. `0 60 0.5 "some string"`.
+//
+// Each argument is either an integer (sent as an int32), a floating-point
+// number (sent as a float32) or a double-quoted string. Anything else results
+// in an error.
+func parseOSCArgs(input string) ([]interface{}, error) {
+	args := []interface{}{}
+
+	for {
+		input = strings.TrimLeftFunc(input, unicode.IsSpace)
+		if input == "" {
+			return args, nil
+		}
+
+		if input[0] == '"' {
+			quoted, err := strconv.QuotedPrefix(input)
+			if err != nil {
+				return nil, fmt.Errorf("unterminated OSC string argument: %s", input)
+			}
+
+			str, err := strconv.Unquote(quoted)
+			if err != nil {
+				return nil, fmt.Errorf("invalid OSC string argument: %s", quoted)
+			}
+
+			args = append(args, str)
+			input = input[len(quoted):]
+			continue
+		}
+
+		token := input
+		if end := strings.IndexFunc(input, unicode.IsSpace); end != -1 {
+			token = input[:end]
+		}
+		input = input[len(token):]
+
+		arg, err := parseOSCNumber(token)
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, arg)
+	}
+}
+
+// parseOSCNumber parses an unquoted OSC message argument, which must be a
+// number.
+func parseOSCNumber(token string) (interface{}, error) {
+	i, err := strconv.ParseInt(token, 10, 32)
+	if err == nil {
+		return int32(i), nil
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return nil, fmt.Errorf("OSC int argument out of range: %s", token)
+	}
+
+	f, err := strconv.ParseFloat(token, 32)
+	if err == nil {
+		return float32(f), nil
+	}
+
+	return nil, fmt.Errorf(
+		"invalid OSC argument %q: expected an int, a float or a quoted string",
+		token,
+	)
+}
+
+// SendOSC sends an OSC message with the provided address (e.g. "/ping") and
+// arguments to the player process. This is intended for debugging and for
+// developing player processes.
+//
+// Returns an error without sending anything unless the server was created with
+// `WithRawOSC`.
+func (server *Server) SendOSC(address string, args ...interface{}) error {
+	if !server.rawOSC {
+		return errRawOSCDisabled
+	}
+
+	if !strings.HasPrefix(address, "/") {
+		return fmt.Errorf("invalid OSC address %q: must start with /", address)
+	}
+
+	return server.withTransmitter(
+		func(transmitter transmitter.PlayerTransmitter) error {
+			return transmitter.TransmitOSCMessage(address, args...)
+		},
+	)
+}
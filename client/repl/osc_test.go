@@ -0,0 +1,74 @@
+package repl
+
+import (
+	"reflect"
+	"testing"
+
+	"alda.io/client/system"
+	"alda.io/client/transmitter"
+)
+
+func TestSendOSC(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	sent := []string{}
+
+	newServer := func(opts ...ServerOption) *Server {
+		opts = append(opts, WithTransmitterFactory(
+			func(player system.PlayerState) transmitter.PlayerTransmitter {
+				return mockTransmitter{sent: &sent}
+			},
+		))
+
+		server := NewServer(0, opts...)
+		server.setPlayer(testPlayer())
+		return server
+	}
+
+	if err := newServer().SendOSC("/ping"); err != errRawOSCDisabled {
+		t.Errorf("expected %v, got %v", errRawOSCDisabled, err)
+	}
+
+	server := newServer(WithRawOSC())
+
+	args, err := parseOSCArgs("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.SendOSC("/ping", args...); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"osc /ping []"}
+	if !reflect.DeepEqual(expected, sent) {
+		t.Errorf("expected %v, got %v", expected, sent)
+	}
+
+	if err := server.SendOSC("ping"); err == nil {
+		t.Error("expected an error for an address that doesn't start with /")
+	}
+
+	args, err = parseOSCArgs(` 0  -60 0.5 "acoustic grand piano" `)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedArgs := []interface{}{
+		int32(0), int32(-60), float32(0.5), "acoustic grand piano",
+	}
+	if !reflect.DeepEqual(expectedArgs, args) {
+		t.Errorf("expected %#v, got %#v", expectedArgs, args)
+	}
+
+	for _, input := range []string{
+		"piano",
+		"0 1x",
+		`"unterminated`,
+		"9999999999",
+	} {
+		if _, err := parseOSCArgs(input); err == nil {
+			t.Errorf("expected an error for malformed args: %s", input)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	log "alda.io/client/logging"
+	"alda.io/client/model"
+	"alda.io/client/transmitter"
+)
+
+// How far in the past the start time provided to `PlayAt` can be before it is
+// rejected. A start time that has only just passed (e.g. because of network
+// latency between the caller and the server) is treated as "right now".
+var playAtTolerance = 50 * time.Millisecond
+
+// startTimer is overridden in tests, so that they don't have to wait for a
+// scheduled start time (see `PlayAt`).
+var startTimer = time.After
+
+// errScheduledPlayCancelled is returned by `PlayAt` when `CancelScheduled` is
+// called before the start time.
+var errScheduledPlayCancelled = fmt.Errorf("scheduled play cancelled")
+
+// WithClock makes the server use the provided function to tell the current
+// time when it keeps track of whether playback is in progress. (See
+// `IsPlaying`.) This is mostly useful for testing.
+func WithClock(now func() time.Time) ServerOption {
+	return func(server *Server) {
+		server.now = now
+	}
+}
+
+// playbackLength returns how long it takes, in milliseconds, for the provided
+// events to finish sounding, once they have been sent to the player process.
+//
+// `syncOffsets` has the same meaning as `transmitter.SyncOffsets`, i.e. the
+// offset of each part is subtracted from that part's events.
+func playbackLength(
+	events []model.ScoreEvent, syncOffsets map[*model.Part]float64,
+) float64 {
+	length := 0.0
+
+	for _, event := range events {
+		note, ok := event.(model.NoteEvent)
+		if !ok {
+			continue
+		}
+
+		offset := math.Max(0, note.Offset-syncOffsets[note.Part])
+		length = math.Max(length, offset+note.AudibleDuration)
+	}
+
+	return length
+}
+
+// recordPlayback takes note of the fact that events that take `lengthMs`
+// milliseconds to play (at normal speed; see `SetTempoScale`) have just been
+// sent to the player process.
+//
+// Anything that is already playing keeps playing, so playback ends at the later
+// of the two end times.
+func (server *Server) recordPlayback(lengthMs float64) {
+	lengthMs /= server.tempoScale
+	end := server.now().Add(time.Duration(lengthMs * float64(time.Millisecond)))
+
+	server.playbackLock.Lock()
+	defer server.playbackLock.Unlock()
+
+	if end.After(server.playbackEnd) {
+		server.playbackEnd = end
+	}
+}
+
+// clearPlaybackEnd takes note of the fact that nothing that the server has sent
+// to the player process is playing anymore.
+func (server *Server) clearPlaybackEnd() {
+	server.playbackLock.Lock()
+	defer server.playbackLock.Unlock()
+
+	server.playbackEnd = time.Time{}
+}
+
+// IsPlaying returns true if the server has sent the player process a score
+// that hasn't finished playing yet, or if a section of the score is looping.
+//
+// NB: This is based on the length of the scores that the server has sent, not
+// on what the player process reports, so it doesn't take into account any
+// latency between the server and the player process.
+//
+// This doesn't hold the state lock, so it can be called while handling a
+// request.
+func (server *Server) IsPlaying() bool {
+	server.playbackLock.Lock()
+	defer server.playbackLock.Unlock()
+
+	return server.loop != nil || server.now().Before(server.playbackEnd)
+}
+
+// PlayAt plays a score at the provided start time, e.g. so that several
+// servers (each with its own player process) can start playing in sync.
+//
+// Because the player process starts playing a score as soon as it receives it,
+// the server holds onto the score until the start time, adjusted by the clock
+// offset. (See `SetClockOffset`.) The server's playback settings are the ones
+// in effect when `PlayAt` is called.
+//
+// An error is returned if `startTime` is more than `playAtTolerance` in the
+// past, or if `CancelScheduled` is called before the score is sent.
+//
+// This waits until the score is sent, so it must not be called while handling
+// a request. Use `schedulePlay` instead.
+func (server *Server) PlayAt(score *model.Score, startTime time.Time) error {
+	server.stateLock.Lock()
+	result := server.schedulePlay(score, startTime)
+	server.stateLock.Unlock()
+
+	return <-result
+}
+
+// schedulePlay is like `PlayAt`, but it waits for the start time in the
+// background, and the result is delivered on the returned channel.
+//
+// This must be called while holding the state lock, which is the case when
+// handling a request. The lock is taken again when the score is sent.
+func (server *Server) schedulePlay(
+	score *model.Score, startTime time.Time,
+) <-chan error {
+	result := make(chan error, 1)
+
+	if late := server.now().Sub(startTime); late > playAtTolerance {
+		result <- fmt.Errorf("start time is %v in the past: %v", late, startTime)
+		return result
+	}
+
+	cancelled := server.scheduledCancellation()
+
+	playbackOpts := append(server.playbackOpts(), transmitter.StartAt(startTime))
+	// The clock offset applies to the start time, and it can be negative. (See
+	// `SetClockOffset`.)
+	sendTime := startTime.Add(server.clockOffset)
+
+	go func() {
+		if delay := sendTime.Sub(server.now()); delay > 0 {
+			log.Info().
+				Time("startTime", startTime).
+				Dur("delay", delay).
+				Msg("Waiting for scheduled start time.")
+
+			select {
+			case <-startTimer(delay):
+			case <-cancelled:
+				result <- errScheduledPlayCancelled
+				return
+			}
+		}
+
+		result <- server.sendScheduled(score, startTime, playbackOpts, cancelled)
+	}()
+
+	return result
+}
+
+// sendScheduled sends a score whose start time has arrived to the player
+// process, unless it has been cancelled. (See `schedulePlay`.)
+func (server *Server) sendScheduled(
+	score *model.Score,
+	startTime time.Time,
+	playbackOpts []transmitter.TransmissionOption,
+	cancelled <-chan struct{},
+) error {
+	server.stateLock.Lock()
+	defer server.stateLock.Unlock()
+
+	// `CancelScheduled` might have been called while we were waiting for the
+	// lock. Otherwise, it will wait for us to finish, and then clear the score
+	// that we're about to send.
+	select {
+	case <-cancelled:
+		return errScheduledPlayCancelled
+	default:
+	}
+
+	return server.withTransmitter(
+		func(oe transmitter.PlayerTransmitter) error {
+			log.Info().
+				Interface("player", server.currentPlayer()).
+				Time("startTime", startTime).
+				Msg("Sending OSC messages for scheduled score to player.")
+
+			// The score's instruments may replace the instruments of the session's
+			// score on the player process. (See `PlayString`.)
+			server.patchesSent = map[int32]int32{}
+
+			if err := oe.TransmitScore(score, playbackOpts...); err != nil {
+				return err
+			}
+
+			server.recordPlayback(playbackLength(score.Events, nil))
+
+			return nil
+		},
+	)
+}
+
+// PlayReader reads Alda source code from `r` (e.g. stdin) until there is no
+// more to read, then evaluates it and plays it, the same way that the
+// "eval-and-play" op does. The code becomes part of the current score.
+//
+// If there is nothing to read but whitespace, nothing happens.
+//
+// This holds the state lock while it evaluates the code, so it must not be
+// called while handling a request.
+func (server *Server) PlayReader(r io.Reader) error {
+	input, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(string(input)) == "" {
+		return nil
+	}
+
+	server.stateLock.Lock()
+	defer server.stateLock.Unlock()
+
+	return server.evalAndPlay(string(input))
+}
+
+// scheduledCancellation returns a channel that is closed when `CancelScheduled`
+// is called.
+func (server *Server) scheduledCancellation() <-chan struct{} {
+	server.scheduledLock.Lock()
+	defer server.scheduledLock.Unlock()
+
+	if server.cancelScheduled == nil {
+		server.cancelScheduled = make(chan struct{})
+	}
+
+	return server.cancelScheduled
+}
+
+// CancelScheduled cancels any scores that are waiting to be played at a start
+// time in the future (see `PlayAt`), and tells the player process to clear all
+// of its upcoming events, in case they have already been sent. The player
+// process also turns off any notes that are sounding, so that none are left
+// hanging.
+//
+// This holds the state lock while it talks to the player process, so it must
+// not be called while handling a request. Use `cancelScheduledPlays` instead.
+func (server *Server) CancelScheduled() error {
+	server.stateLock.Lock()
+	defer server.stateLock.Unlock()
+
+	return server.cancelScheduledPlays()
+}
+
+// cancelScheduledPlays does the work of `CancelScheduled`.
+//
+// This must be called while holding the state lock, which is the case when
+// handling a request.
+func (server *Server) cancelScheduledPlays() error {
+	server.scheduledLock.Lock()
+	if server.cancelScheduled != nil {
+		close(server.cancelScheduled)
+		server.cancelScheduled = nil
+	}
+	server.scheduledLock.Unlock()
+
+	return server.withTransmitter(
+		func(oe transmitter.PlayerTransmitter) error {
+			log.Info().
+				Interface("player", server.currentPlayer()).
+				Msg("Sending \"clear\" message to player process.")
+
+			if err := oe.TransmitClearMessage(); err != nil {
+				return err
+			}
+
+			server.clearPlaybackEnd()
+
+			return nil
+		},
+	)
+}
@@ -0,0 +1,426 @@
+package repl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daveyarwood/go-osc/osc"
+
+	"alda.io/client/model"
+	"alda.io/client/parser"
+	"alda.io/client/system"
+	"alda.io/client/transmitter"
+)
+
+func TestIsPlaying(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	server := NewServer(0, WithDryRun(), WithClock(clock))
+
+	if server.IsPlaying() {
+		t.Error("expected the server not to be playing before anything is sent")
+	}
+
+	// Two quarter notes at 120 bpm: the second note starts at 500 ms and its
+	// audible duration is 450 ms.
+	if err := server.PlayString("piano: c4 d4"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !server.IsPlaying() {
+		t.Error("expected the server to be playing right after sending a score")
+	}
+
+	now = now.Add(900 * time.Millisecond)
+	if !server.IsPlaying() {
+		t.Error("expected the server to be playing before the score ends")
+	}
+
+	now = now.Add(100 * time.Millisecond)
+	if server.IsPlaying() {
+		t.Error("expected the server not to be playing after the score ends")
+	}
+
+	if err := server.PlayString("piano: c1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.IsPlaying() {
+		t.Error("expected the server not to be playing after stopping")
+	}
+}
+
+func TestSetTempoScale(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	server := NewServer(0, WithDryRun(), WithClock(clock))
+
+	for _, factor := range []float64{0, -1} {
+		if err := server.SetTempoScale(factor); err == nil {
+			t.Errorf("expected an error for tempo scale %v", factor)
+		}
+	}
+
+	if err := server.SetTempoScale(0.5); err != nil {
+		t.Fatal(err)
+	}
+
+	// At half speed, two quarter notes at 120 bpm take 1900 ms to play instead
+	// of 950 ms.
+	if err := server.PlayString("piano: c4 d4"); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(1800 * time.Millisecond)
+	if !server.IsPlaying() {
+		t.Error("expected the server to be playing before the score ends")
+	}
+
+	now = now.Add(200 * time.Millisecond)
+	if server.IsPlaying() {
+		t.Error("expected the server not to be playing after the score ends")
+	}
+}
+
+func TestSetClockOffset(t *testing.T) {
+	server := NewServer(0, WithDryRun())
+
+	for _, offset := range []time.Duration{
+		-10 * time.Minute, 0, maxClockOffset,
+	} {
+		if err := server.SetClockOffset(offset); err != nil {
+			t.Errorf("expected clock offset %v to be accepted, got %v", offset, err)
+		}
+	}
+
+	// A long delay would keep the server from handling other requests.
+	if err := server.SetClockOffset(10 * time.Minute); err == nil {
+		t.Error("expected an error for a clock offset of 10m")
+	}
+
+	if server.clockOffset != maxClockOffset {
+		t.Errorf(
+			"expected the clock offset to stay %v, got %v",
+			maxClockOffset, server.clockOffset,
+		)
+	}
+}
+
+// bundleTransmitter records the OSC bundle that each score would be sent in.
+type bundleTransmitter struct {
+	mockTransmitter
+	bundles *[]*osc.Bundle
+}
+
+func (bt bundleTransmitter) TransmitScore(
+	score *model.Score, opts ...transmitter.TransmissionOption,
+) error {
+	bundle, err := transmitter.OSCTransmitter{}.ScoreToOSCBundle(score, opts...)
+	if err != nil {
+		return err
+	}
+
+	*bt.bundles = append(*bt.bundles, bundle)
+	return nil
+}
+
+// bundleTransmitterFactory returns a server option that makes the server record
+// the OSC bundles that it would send. (See `bundleTransmitter`.)
+func bundleTransmitterFactory(bundles *[]*osc.Bundle) ServerOption {
+	sent := []string{}
+
+	return WithTransmitterFactory(
+		func(player system.PlayerState) transmitter.PlayerTransmitter {
+			return bundleTransmitter{
+				mockTransmitter: mockTransmitter{sent: &sent},
+				bundles:         bundles,
+			}
+		},
+	)
+}
+
+// parseScore returns the score that results from evaluating Alda code.
+func parseScore(t *testing.T, input string) *model.Score {
+	ast, err := parser.ParseString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates, err := ast.Updates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score := model.NewScore()
+	if err := score.Update(updates...); err != nil {
+		t.Fatal(err)
+	}
+
+	return score
+}
+
+func TestPlayAt(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	waited := []time.Duration{}
+	originalStartTimer := startTimer
+	startTimer = func(d time.Duration) <-chan time.Time {
+		waited = append(waited, d)
+		now = now.Add(d)
+
+		timer := make(chan time.Time, 1)
+		timer <- now
+		return timer
+	}
+	t.Cleanup(func() { startTimer = originalStartTimer })
+
+	bundles := []*osc.Bundle{}
+
+	server := NewServer(0, WithClock(clock), bundleTransmitterFactory(&bundles))
+	server.setPlayer(testPlayer())
+
+	score := parseScore(t, "piano: c d e")
+
+	startTime := now.Add(2 * time.Second)
+	if err := server.PlayAt(score, startTime); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bundles) != 1 {
+		t.Fatalf("expected 1 score to be sent, got %d", len(bundles))
+	}
+
+	// OSC timetags have sub-nanosecond precision, but converting to and from
+	// them can introduce rounding errors.
+	timetag := bundles[0].Timetag.Time()
+	if diff := timetag.Sub(startTime); diff < -time.Microsecond ||
+		diff > time.Microsecond {
+		t.Errorf("expected timetag %s, got %s", startTime, timetag)
+	}
+
+	if len(waited) != 1 || waited[0] != 2*time.Second {
+		t.Errorf("expected to wait 2s for the start time, waited %v", waited)
+	}
+
+	if !server.IsPlaying() {
+		t.Error("expected the server to be playing after the start time")
+	}
+
+	// A negative clock offset makes the score start playing earlier.
+	if err := server.SetClockOffset(-500 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	waited = nil
+
+	if err := server.PlayAt(score, now.Add(2*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(waited) != 1 || waited[0] != 1500*time.Millisecond {
+		t.Errorf("expected to wait 1.5s for the start time, waited %v", waited)
+	}
+
+	if err := server.PlayAt(score, now.Add(-time.Second)); err == nil {
+		t.Error("expected an error for a start time in the past")
+	}
+
+	if len(bundles) != 2 {
+		t.Errorf("expected no score to be sent for a start time in the past")
+	}
+}
+
+func TestCancelScheduled(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	// The start time never arrives, so the score waits until it's cancelled.
+	waiting := make(chan struct{})
+	originalStartTimer := startTimer
+	startTimer = func(d time.Duration) <-chan time.Time {
+		close(waiting)
+		return nil
+	}
+	t.Cleanup(func() { startTimer = originalStartTimer })
+
+	sent := []string{}
+
+	server := NewServer(0, WithTransmitterFactory(
+		func(player system.PlayerState) transmitter.PlayerTransmitter {
+			return mockTransmitter{sent: &sent}
+		},
+	))
+	server.setPlayer(testPlayer())
+
+	score := parseScore(t, "piano: c d e")
+
+	result := make(chan error)
+	go func() {
+		result <- server.PlayAt(score, time.Now().Add(time.Hour))
+	}()
+
+	<-waiting
+
+	// The server's state isn't locked while the score is waiting to be played.
+	if server.IsPlaying() {
+		t.Error("expected the server not to be playing before the start time")
+	}
+
+	if err := server.CancelScheduled(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-result:
+		if err != errScheduledPlayCancelled {
+			t.Errorf("expected the scheduled play to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the scheduled play to be cancelled")
+	}
+
+	expected := []string{"clear"}
+	if strings.Join(sent, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("expected %v to be sent, got %v", expected, sent)
+	}
+}
+
+func TestPlaybackWhileHandlingRequest(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	// The start time never arrives, so the score waits until it's cancelled.
+	originalStartTimer := startTimer
+	startTimer = func(d time.Duration) <-chan time.Time { return nil }
+	t.Cleanup(func() { startTimer = originalStartTimer })
+
+	sent := []string{}
+
+	server := NewServer(0, WithTransmitterFactory(
+		func(player system.PlayerState) transmitter.PlayerTransmitter {
+			return mockTransmitter{sent: &sent}
+		},
+	))
+	server.setPlayer(testPlayer())
+
+	score := parseScore(t, "piano: c d e")
+
+	// Request handlers are called while holding the state lock.
+	handled := make(chan error)
+	go func() {
+		server.stateLock.Lock()
+		defer server.stateLock.Unlock()
+
+		if server.IsPlaying() {
+			t.Error("expected the server not to be playing")
+		}
+
+		result := server.schedulePlay(score, time.Now().Add(time.Hour))
+
+		if err := server.cancelScheduledPlays(); err != nil {
+			handled <- err
+			return
+		}
+
+		handled <- <-result
+	}()
+
+	select {
+	case err := <-handled:
+		if err != errScheduledPlayCancelled {
+			t.Errorf("expected the scheduled play to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the request to be handled without deadlocking")
+	}
+
+	expected := []string{"clear"}
+	if strings.Join(sent, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("expected %v to be sent, got %v", expected, sent)
+	}
+}
+
+func TestPlayReader(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	bundles := []*osc.Bundle{}
+
+	server := NewServer(0, bundleTransmitterFactory(&bundles))
+	server.setPlayer(testPlayer())
+
+	if err := server.PlayReader(strings.NewReader(" \n\t")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bundles) != 0 {
+		t.Errorf("expected nothing to be sent for empty input, got %v", bundles)
+	}
+
+	if err := server.PlayReader(strings.NewReader("piano: c d e\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bundles) != 1 {
+		t.Fatalf("expected 1 bundle to be sent, got %d", len(bundles))
+	}
+
+	expected := []int32{60, 62, 64}
+	if notes := sentNotes(bundles[0]); !reflect.DeepEqual(expected, notes) {
+		t.Errorf("expected notes %v, got %v", expected, notes)
+	}
+
+	if err := server.PlayReader(strings.NewReader("piano: c d e ]")); err == nil {
+		t.Error("expected an error for input that can't be parsed")
+	}
+}
+
+func TestCancelScheduledAsStartTimeArrives(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	sent := []string{}
+
+	server := NewServer(0, WithTransmitterFactory(
+		func(player system.PlayerState) transmitter.PlayerTransmitter {
+			return mockTransmitter{sent: &sent}
+		},
+	))
+	server.setPlayer(testPlayer())
+
+	// The scheduled play is cancelled at the same moment that the start time
+	// arrives.
+	originalStartTimer := startTimer
+	startTimer = func(d time.Duration) <-chan time.Time {
+		if err := server.CancelScheduled(); err != nil {
+			t.Error(err)
+		}
+
+		timer := make(chan time.Time, 1)
+		timer <- time.Now()
+		return timer
+	}
+	t.Cleanup(func() { startTimer = originalStartTimer })
+
+	score := parseScore(t, "piano: c d e")
+
+	err := server.PlayAt(score, time.Now().Add(time.Hour))
+	if err != errScheduledPlayCancelled {
+		t.Errorf("expected the scheduled play to be cancelled, got %v", err)
+	}
+
+	expected := []string{"clear"}
+	if strings.Join(sent, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("expected %v to be sent, got %v", expected, sent)
+	}
+}
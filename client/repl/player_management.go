@@ -1,80 +1,687 @@
 package repl
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
+	"alda.io/client/generated"
 	log "alda.io/client/logging"
+	"alda.io/client/model"
 	"alda.io/client/system"
 	"alda.io/client/transmitter"
 	"alda.io/client/util"
 )
 
-const findPlayerTimeout = 20 * time.Second
-const playerPoolFillInterval = 10 * time.Second
-const pingTimeout = 5 * time.Second
-const pingInterval = 1 * time.Second
+const defaultFindPlayerTimeout = 20 * time.Second
+const defaultPlayerPoolFillInterval = 10 * time.Second
+const defaultPingInterval = 1 * time.Second
 
-func findAvailablePlayer() (system.PlayerState, error) {
-	var player system.PlayerState
+// The default ping timeout is short enough that a single slow ping doesn't take
+// longer than it takes for the default threshold number of pings to be sent.
+// (See `Validate`.)
+const defaultPingTimeout = 2 * time.Second
 
-	if err := util.Await(
-		func() error {
-			availablePlayer, err := system.FindAvailablePlayer()
-			if err != nil {
-				return err
+// How often to check on the state of the player process that the server is
+// using, and to look for a replacement if the server doesn't have one. This is
+// much more frequent than pinging, so that a missing player process is
+// replaced quickly.
+const playerRefreshInterval = 100 * time.Millisecond
+
+// The default number of consecutive pings that must fail before we conclude
+// that the player process is unreachable and replace it. A single failed ping
+// isn't necessarily cause for alarm; the player might be momentarily busy (e.g.
+// pausing for garbage collection).
+const defaultFailedPingThreshold = 3
+
+// The default number of recent ping round-trip durations to keep track of. (See
+// `PingLatencies`.)
+const defaultPingLatencyHistorySize = 32
+
+// The number of consecutive attempts to fill the player pool that must fail
+// before we conclude that player processes can't be started (e.g. because the
+// player executable is missing) and report the player pool as unhealthy.
+const poolFillFailureThreshold = 3
+
+// Sending a ping can fail for transient reasons, so we make a few quick
+// attempts before considering it a failed ping.
+const pingAttempts = 3
+const pingRetryDelay = 10 * time.Millisecond
+
+// When waiting for a player process to become available, we start by checking
+// frequently and gradually check less often. (See `awaitAvailablePlayer`.)
+const findPlayerInitialBackoff = 50 * time.Millisecond
+const findPlayerMaxBackoff = 1 * time.Second
+
+// UnreachableReason describes why the server stopped using a player process.
+type UnreachableReason int
+
+const (
+	// The server hasn't stopped using a player process.
+	ReasonNone UnreachableReason = iota
+	// The player process didn't respond to pings in time.
+	ReasonPingTimeout
+	// The player process's state file disappeared, i.e. the player process
+	// deregistered itself.
+	ReasonNotFound
+	// Pings to the player process couldn't be sent, e.g. because the connection
+	// was refused.
+	ReasonSendError
+	// The server told the player process to shut down.
+	ReasonShutdown
+	// Another REPL server claimed the player process. (See `claimPlayer`.)
+	ReasonClaimed
+)
+
+func (reason UnreachableReason) String() string {
+	switch reason {
+	case ReasonNone:
+		return "none"
+	case ReasonPingTimeout:
+		return "ping-timeout"
+	case ReasonNotFound:
+		return "not-found"
+	case ReasonSendError:
+		return "send-error"
+	case ReasonShutdown:
+		return "shutdown"
+	case ReasonClaimed:
+		return "claimed"
+	default:
+		return fmt.Sprintf("UnreachableReason(%d)", int(reason))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler, so that reasons are readable
+// in JSON output, e.g. the `player-status` op.
+func (reason UnreachableReason) MarshalText() ([]byte, error) {
+	return []byte(reason.String()), nil
+}
+
+// PlayerManagementConfig controls the timing of the way that a REPL server
+// finds, monitors and replaces player processes. (See `managePlayers`.)
+//
+// Any field that is left as its zero value falls back to the default value.
+type PlayerManagementConfig struct {
+	// How long to wait for a player process to become available.
+	FindPlayerTimeout time.Duration
+	// How often to ensure that the player pool is full.
+	PlayerPoolFillInterval time.Duration
+	// How long to keep trying to ping the player process before considering the
+	// ping failed. This must be less than `PingInterval` times
+	// `FailedPingThreshold`.
+	PingTimeout time.Duration
+	// How often to ping the player process.
+	PingInterval time.Duration
+	// The number of consecutive pings that must fail before the player process
+	// is considered unreachable and replaced.
+	FailedPingThreshold int
+	// When true and more than one player process is available, the server
+	// measures the round trip time to each of them and uses the one that replies
+	// the fastest. Each probe is bounded by `PingTimeout`.
+	SelectFastestPlayer bool
+	// The number of recent ping round-trip durations to keep track of. (See
+	// `PingLatencies`.)
+	PingLatencyHistorySize int
+	// When true and no player process becomes available within
+	// `FindPlayerTimeout`, the server fills the player pool itself and waits for
+	// one of the new player processes, instead of giving up right away.
+	SpawnPlayerOnDemand bool
+	// How long a player state file can go without being updated before it's
+	// considered stale and deleted. (See `prunePlayers`.)
+	PlayerStateTTL time.Duration
+}
+
+func (config PlayerManagementConfig) withDefaults() PlayerManagementConfig {
+	if config.FindPlayerTimeout == 0 {
+		config.FindPlayerTimeout = defaultFindPlayerTimeout
+	}
+
+	if config.PlayerPoolFillInterval == 0 {
+		config.PlayerPoolFillInterval = defaultPlayerPoolFillInterval
+	}
+
+	if config.PingTimeout == 0 {
+		config.PingTimeout = defaultPingTimeout
+	}
+
+	if config.PingInterval == 0 {
+		config.PingInterval = defaultPingInterval
+	}
+
+	if config.FailedPingThreshold == 0 {
+		config.FailedPingThreshold = defaultFailedPingThreshold
+	}
+
+	if config.PingLatencyHistorySize == 0 {
+		config.PingLatencyHistorySize = defaultPingLatencyHistorySize
+	}
+
+	if config.PlayerStateTTL == 0 {
+		config.PlayerStateTTL = system.DefaultPlayerStateTTL
+	}
+
+	return config
+}
+
+// Validate returns an error if the config (with defaults applied) contains
+// values that would cause player management to misbehave.
+func (config PlayerManagementConfig) Validate() error {
+	config = config.withDefaults()
+
+	for name, duration := range map[string]time.Duration{
+		"find player timeout":       config.FindPlayerTimeout,
+		"player pool fill interval": config.PlayerPoolFillInterval,
+		"ping timeout":              config.PingTimeout,
+		"ping interval":             config.PingInterval,
+		"player state TTL":          config.PlayerStateTTL,
+	} {
+		if duration < 0 {
+			return fmt.Errorf("%s must be positive: %s", name, duration)
+		}
+	}
+
+	if config.FailedPingThreshold < 0 {
+		return fmt.Errorf(
+			"failed ping threshold must be positive: %d",
+			config.FailedPingThreshold,
+		)
+	}
+
+	if config.PingLatencyHistorySize < 0 {
+		return fmt.Errorf(
+			"ping latency history size must be positive: %d",
+			config.PingLatencyHistorySize,
+		)
+	}
+
+	// The server renews its claim on the player process each time it pings it
+	// (see `claimPlayer`), so the claim must not expire between pings.
+	if config.PingInterval >= system.PlayerClaimDuration {
+		return fmt.Errorf(
+			"ping interval (%s) must be less than the player claim duration (%s)",
+			config.PingInterval,
+			system.PlayerClaimDuration,
+		)
+	}
+
+	// A ping can take up to `PingTimeout` to fail, during which time the
+	// `managePlayers` loop is blocked. To ensure that an unreachable player is
+	// detected in a timely manner, a single slow ping must not take longer than
+	// the time it would take for the threshold number of pings to be sent.
+	if config.PingTimeout >=
+		config.PingInterval*time.Duration(config.FailedPingThreshold) {
+		return fmt.Errorf(
+			"ping timeout (%s) must be less than ping interval (%s) * "+
+				"failed ping threshold (%d)",
+			config.PingTimeout,
+			config.PingInterval,
+			config.FailedPingThreshold,
+		)
+	}
+
+	return nil
+}
+
+// errIncompatiblePlayer is returned while we're waiting for a player process
+// when the one that we were offered has a different major version than the
+// client.
+var errIncompatiblePlayer = fmt.Errorf("incompatible player version")
+
+// errPlayerClaimed is returned while we're waiting for a player process when
+// the one that we were offered has been claimed by another REPL server.
+var errPlayerClaimed = fmt.Errorf("player process claimed by another server")
+
+// errServerClosed is returned when the server is closed while we're waiting
+// for the `managePlayers` loop.
+var errServerClosed = fmt.Errorf("the REPL server was closed")
+
+// errPingTimeout is returned when a player process doesn't respond to a ping
+// in time.
+var errPingTimeout = fmt.Errorf("timed out pinging the player process")
+
+// pingWithin pings the player process, giving up if the ping takes longer
+// than the provided timeout.
+func pingWithin(player system.PlayerState, timeout time.Duration) error {
+	result := make(chan error, 1)
+
+	go func() { result <- pingPlayer(player) }()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		return errPingTimeout
+	}
+}
+
+// The functions that the server uses to interact with player processes are
+// stored in variables so that they can be swapped out in tests.
+var fillPlayerPool = system.FillPlayerPoolWith
+var findAvailablePlayer = system.FindAvailablePlayerFor
+var availablePlayers = system.AvailablePlayers
+var findPlayerByID = system.FindPlayerByID
+var playerPoolSize = system.PlayerPoolSize
+var listPlayers = system.ListPlayers
+var prunePlayers = system.PrunePlayersOlderThan
+var claimPlayer = system.ClaimPlayer
+var releasePlayer = system.ReleasePlayer
+var pingPlayer = func(player system.PlayerState) error {
+	return transmitter.TransmitWithRetry(
+		transmitter.OSCTransmitter{
+			Host: player.Host, Port: player.Port,
+		}.TransmitPingMessage,
+		pingAttempts,
+		pingRetryDelay,
+	)
+}
+var probePlayer = func(player system.PlayerState, timeout time.Duration) error {
+	// The latency request is the only message that the player process replies
+	// to, so it's what we use to measure the round trip. We don't need the
+	// latency itself.
+	_, err := transmitter.OSCTransmitter{
+		Host: player.Host, Port: player.Port,
+	}.RequestLatency(timeout)
+
+	return err
+}
+var transmitShutdown = func(
+	transmitter transmitter.PlayerTransmitter, offset int32,
+) error {
+	return transmitter.TransmitShutdownMessage(offset)
+}
+var transmitScoreState = func(
+	player system.PlayerState, score *model.Score,
+) error {
+	return transmitter.OSCTransmitter{
+		Host: player.Host, Port: player.Port,
+	}.TransmitScoreState(score)
+}
+
+var transmitSoundfont = func(player system.PlayerState, path string) error {
+	return transmitter.OSCTransmitter{
+		Host: player.Host, Port: player.Port,
+	}.TransmitSoundfontMessage(path)
+}
+
+type playerProbeResult struct {
+	player  system.PlayerState
+	latency time.Duration
+	err     error
+}
+
+// fastestPlayer sends each of the candidate player processes a request that it
+// replies to (see `probePlayer`), concurrently, and returns the one whose reply
+// arrives first.
+//
+// If none of the players reply within the ping timeout, we fall back to the
+// first candidate.
+func (server *Server) fastestPlayer(
+	candidates []system.PlayerState,
+) (system.PlayerState, error) {
+	probeTimeout := server.playerManagement.PingTimeout
+
+	// The channel is buffered so that probes that finish after we've stopped
+	// listening don't block forever.
+	results := make(chan playerProbeResult, len(candidates))
+
+	probe := probePlayer
+
+	for _, candidate := range candidates {
+		go func(player system.PlayerState) {
+			start := time.Now()
+			err := probe(player, probeTimeout)
+			results <- playerProbeResult{
+				player: player, latency: time.Since(start), err: err,
+			}
+		}(candidate)
+	}
+
+	timeout := time.After(probeTimeout)
+
+ProbeLoop:
+	for range candidates {
+		select {
+		case result := <-results:
+			if result.err != nil {
+				continue
 			}
 
-			player = availablePlayer
-			return nil
-		},
-		findPlayerTimeout,
-	); err != nil {
+			log.Debug().
+				Interface("player", result.player).
+				Dur("latency", result.latency).
+				Int("candidates", len(candidates)).
+				Msg("Selected fastest player process.")
+
+			return result.player, nil
+		case <-timeout:
+			break ProbeLoop
+		}
+	}
+
+	log.Debug().
+		Int("candidates", len(candidates)).
+		Msg("No player process responded to probes. Falling back.")
+
+	return candidates[0], nil
+}
+
+// selectAvailablePlayer returns a player process that is available for the
+// server to use, skipping any player processes whose IDs are in `rejected`.
+func (server *Server) selectAvailablePlayer(
+	rejected map[string]bool,
+) (system.PlayerState, error) {
+	if server.playerManagement.SelectFastestPlayer {
+		candidates, err := availablePlayers(server.claimant)
+		if err != nil {
+			return system.PlayerState{}, err
+		}
+
+		candidates = withoutRejectedPlayers(candidates, rejected)
+
+		if len(candidates) > 1 {
+			return server.fastestPlayer(candidates)
+		}
+	}
+
+	player, err := findAvailablePlayer(server.claimant)
+	if err != nil || !rejected[player.ID] {
+		return player, err
+	}
+
+	// The player process that we were offered has already been rejected, so we
+	// look for another one.
+	candidates, err := availablePlayers(server.claimant)
+	if err != nil {
 		return system.PlayerState{}, err
 	}
 
-	return player, nil
+	candidates = withoutRejectedPlayers(candidates, rejected)
+	if len(candidates) == 0 {
+		return system.PlayerState{}, system.ErrNoPlayersAvailable
+	}
+
+	return candidates[0], nil
+}
+
+func withoutRejectedPlayers(
+	players []system.PlayerState, rejected map[string]bool,
+) []system.PlayerState {
+	result := []system.PlayerState{}
+
+	for _, player := range players {
+		if !rejected[player.ID] {
+			result = append(result, player)
+		}
+	}
+
+	return result
 }
 
-func (server *Server) transmitter() (transmitter.OSCTransmitter, error) {
+// confirmPlayer pings a player process that the server is considering using,
+// to confirm that it's actually ready to receive messages. A player process can
+// report that it's available slightly before it's able to receive messages, in
+// which case the first messages that we send to it would be lost.
+func (server *Server) confirmPlayer(player system.PlayerState) error {
+	return util.Await(
+		func() error { return pingPlayer(player) },
+		server.playerManagement.PingTimeout,
+	)
+}
+
+// awaitAvailablePlayer waits up to the configured `FindPlayerTimeout` for a
+// player process to be available.
+//
+// If `SpawnPlayerOnDemand` is enabled and no player process becomes available
+// in time, we fill the player pool and wait for one of the new player processes
+// to become available.
+//
+// Player processes with any of the `excludedIDs` are never selected.
+func (server *Server) awaitAvailablePlayer(
+	excludedIDs ...string,
+) (system.PlayerState, error) {
+	player, err := server.awaitAvailablePlayerOnce(excludedIDs)
+	if err == nil || !server.playerManagement.SpawnPlayerOnDemand {
+		return player, err
+	}
+
+	log.Info().Err(err).Msg("No player process available. Spawning one.")
+
+	if err := fillPlayerPool(server.playerLaunch); err != nil {
+		return system.PlayerState{}, err
+	}
+
+	server.metrics.update(func(metrics *Metrics) { metrics.PoolFills++ })
+
+	return server.awaitAvailablePlayerOnce(excludedIDs)
+}
+
+// awaitAvailablePlayerOnce waits up to the configured `FindPlayerTimeout` for a
+// player process to be available.
+func (server *Server) awaitAvailablePlayerOnce(
+	excludedIDs []string,
+) (system.PlayerState, error) {
+	// Player processes that don't respond when we try to confirm that they're
+	// ready. We skip these and try other player processes instead.
+	rejected := map[string]bool{}
+	for _, id := range excludedIDs {
+		rejected[id] = true
+	}
+
+	// Player processes take a few seconds to start, so if none are available
+	// right now, there probably won't be one in the next 100ms either. We back off
+	// to avoid hammering the player state files while we wait.
+	return util.AwaitResultWithBackoff(
+		func() (system.PlayerState, error) {
+			availablePlayer, err := server.selectAvailablePlayer(rejected)
+			if err != nil {
+				return system.PlayerState{}, err
+			}
+
+			if !system.IsCompatiblePlayerVersion(availablePlayer.Version) {
+				log.Warn().
+					Interface("player", availablePlayer).
+					Str("clientVersion", generated.ClientVersion).
+					Msg("Player process version is incompatible. Will try another one.")
+
+				rejected[availablePlayer.ID] = true
+				return system.PlayerState{}, errIncompatiblePlayer
+			}
+
+			if err := server.confirmPlayer(availablePlayer); err != nil {
+				log.Warn().
+					Err(err).
+					Interface("player", availablePlayer).
+					Msg("Player process didn't respond. Will try another one.")
+
+				rejected[availablePlayer.ID] = true
+				return system.PlayerState{}, err
+			}
+
+			if !server.claimPlayer(availablePlayer) {
+				log.Debug().
+					Interface("player", availablePlayer).
+					Msg("Player process is claimed by another REPL server.")
+
+				rejected[availablePlayer.ID] = true
+				return system.PlayerState{}, errPlayerClaimed
+			}
+
+			return availablePlayer, nil
+		},
+		server.playerManagement.FindPlayerTimeout,
+		findPlayerInitialBackoff,
+		findPlayerMaxBackoff,
+	)
+}
+
+// oscTransmitter is the server's default way of sending messages to a player
+// process. (See `WithTransmitterFactory`.)
+func (server *Server) oscTransmitter(
+	player system.PlayerState,
+) transmitter.PlayerTransmitter {
+	return transmitter.OSCTransmitter{
+		Host:    player.Host,
+		Port:    player.Port,
+		Capture: server.oscCapture,
+		DryRun:  server.dryRun,
+		Queue:   server.sendQueue,
+	}
+}
+
+func (server *Server) transmitter() (transmitter.PlayerTransmitter, error) {
+	if server.dryRun {
+		return server.newTransmitter(system.PlayerState{}), nil
+	}
+
 	if !server.hasPlayer() {
-		return transmitter.OSCTransmitter{},
-			fmt.Errorf("no player process is available")
+		if pinnedPlayerID := server.pinnedPlayer(); pinnedPlayerID != "" {
+			return nil, fmt.Errorf(
+				"the pinned player process (%s) is unavailable", pinnedPlayerID,
+			)
+		}
+
+		return nil, fmt.Errorf("no player process is available")
 	}
 
-	return transmitter.OSCTransmitter{Port: server.player.Port}, nil
+	return server.newTransmitter(server.currentPlayer()), nil
+}
+
+// How often `WaitForPlayer` checks whether a player process is available.
+const waitForPlayerInterval = 100 * time.Millisecond
+
+// WaitForPlayer waits until the server has a player process to use, and returns
+// the player's state. Player management happens asynchronously (see
+// `managePlayers`), so this doesn't find a player process itself; it only waits
+// for the `managePlayers` loop to find one.
+//
+// Returns the context's error if the context is done before a player process is
+// available.
+func (server *Server) WaitForPlayer(
+	ctx context.Context,
+) (system.PlayerState, error) {
+	ticker := time.NewTicker(waitForPlayerInterval)
+	defer ticker.Stop()
+
+	for {
+		if server.hasPlayer() {
+			return server.currentPlayer(), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return system.PlayerState{}, ctx.Err()
+		case <-ticker.C:
+		}
+	}
 }
 
 // Player management happens asynchronously (see the loop in `managePlayers`),
 // so at any given moment, it is probable, but not 100% certain, that a player
 // process will be available. This function handles the boilerplate of waiting
-// for a player process to be available, constructing an OSCTransmitter that
-// will transmit to that player's port, and then running `execute`, a function
-// that uses the OSCTransmitter.
+// for a player process to be available, constructing a transmitter (an
+// OSCTransmitter, unless the server was created with `WithTransmitterFactory`)
+// that will transmit to that player, and then running `execute`, a function
+// that uses the transmitter.
+//
+// We wait up to the configured `FindPlayerTimeout` for a player process. (See
+// `withTransmitterCtx`.)
 func (server *Server) withTransmitter(
-	execute func(transmitter.OSCTransmitter) error,
+	execute func(transmitter.PlayerTransmitter) error,
 ) error {
-	var transmitter transmitter.OSCTransmitter
+	ctx, cancel := context.WithTimeout(
+		context.Background(), server.playerManagement.FindPlayerTimeout,
+	)
+	defer cancel()
 
-	if err := util.Await(
-		func() error {
-			oe, err := server.transmitter()
-			if err != nil {
-				return err
-			}
+	return server.withTransmitterCtx(ctx, execute)
+}
 
+// withTransmitterCtx is like `withTransmitter`, except that it stops waiting
+// for a player process as soon as the provided context is done, e.g. because
+// the caller has given up.
+//
+// In that case, the returned error wraps the context's error.
+func (server *Server) withTransmitterCtx(
+	ctx context.Context,
+	execute func(transmitter.PlayerTransmitter) error,
+) error {
+	ticker := time.NewTicker(waitForPlayerInterval)
+	defer ticker.Stop()
+
+	var transmitter transmitter.PlayerTransmitter
+	var player system.PlayerState
+
+	for {
+		oe, err := server.transmitter()
+		if err == nil {
 			transmitter = oe
-			return nil
-		},
-		findPlayerTimeout,
-	); err != nil {
+			player = server.currentPlayer()
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%v: %w", err, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+
+	if err := server.restoreScoreState(player); err != nil {
 		return err
 	}
 
 	return execute(transmitter)
 }
 
+// restoreScoreState brings the player process up to date with the last-known
+// state of the score (instruments, volume, panning and tempo) if the server has
+// switched player processes since it last transmitted anything. Each player
+// process has its own MIDI state, so without this, notes sent to a replacement
+// player would be played with the wrong settings.
+//
+// The soundfont chosen via `SetSoundfont`, if any, is restored as well.
+func (server *Server) restoreScoreState(player system.PlayerState) error {
+	if server.scoreStatePlayerID == player.ID {
+		return nil
+	}
+
+	// The program changes that we sent to the previous player process don't
+	// apply to this one.
+	server.patchesSent = map[int32]int32{}
+
+	// If we haven't transmitted anything since the score was reset, there is no
+	// state to restore.
+	if server.scoreStatePlayerID != "" {
+		log.Info().
+			Interface("player", player).
+			Str("previousPlayerID", server.scoreStatePlayerID).
+			Msg("Restoring score state on replacement player process.")
+
+		if err := transmitScoreState(player, server.score); err != nil {
+			return err
+		}
+	}
+
+	if server.soundfont != "" {
+		log.Info().
+			Interface("player", player).
+			Str("soundfont", server.soundfont).
+			Msg("Restoring soundfont on player process.")
+
+		if err := transmitSoundfont(player, server.soundfont); err != nil {
+			return err
+		}
+	}
+
+	server.scoreStatePlayerID = player.ID
+
+	return nil
+}
+
 // Boilerplate to overcome the slight awkwardness of Go's zero value semantics
 // for structs. We can't set `server.player` to nil because a struct can't be
 // nil, so the best we can do is set it to an empty struct
@@ -84,7 +691,47 @@ func (server *Server) withTransmitter(
 // For practical purposes, if Port is 0, then we can be reasonably certain that
 // the server doesn't have a player to talk to.
 func (server *Server) hasPlayer() bool {
-	return server.player != system.PlayerState{}
+	return server.currentPlayer() != system.PlayerState{}
+}
+
+// currentPlayer returns `server.player`.
+//
+// The `managePlayers` loop sets `server.player` in its own routine, while
+// requests from clients are handled in another, so reads and writes of
+// `server.player` must go through `currentPlayer` and `storePlayer`.
+func (server *Server) currentPlayer() system.PlayerState {
+	server.playerLock.RLock()
+	defer server.playerLock.RUnlock()
+
+	return server.player
+}
+
+func (server *Server) storePlayer(player system.PlayerState) {
+	server.playerLock.Lock()
+	defer server.playerLock.Unlock()
+
+	server.player = player
+}
+
+// pinnedPlayer returns `server.pinnedPlayerID`, which is guarded by
+// `playerLock` for the same reason as `server.player`. (See `currentPlayer`.)
+func (server *Server) pinnedPlayer() string {
+	server.playerLock.RLock()
+	defer server.playerLock.RUnlock()
+
+	return server.pinnedPlayerID
+}
+
+// storeStandbyPlayer sets `server.standbyPlayer`.
+//
+// Only the `managePlayers` loop changes the standby player process, so the loop
+// can read `server.standbyPlayer` directly, but it must be set while holding
+// `playerLock` so that `PlayerStatus` can read it from another routine.
+func (server *Server) storeStandbyPlayer(player system.PlayerState) {
+	server.playerLock.Lock()
+	defer server.playerLock.Unlock()
+
+	server.standbyPlayer = player
 }
 
 // The `managePlayers` loop regularly checks to see if the player process that
@@ -96,112 +743,849 @@ func (server *Server) hasPlayer() bool {
 // the zero value (`system.PlayerState{}`). At that point, `server.hasPlayer()`
 // will return false, and the player process will be replaced and
 // `server.player` will be set to the current state of the new player process.
-func (server *Server) unsetPlayer() {
+func (server *Server) unsetPlayer(reason UnreachableReason) {
+	server.releasePlayer(server.currentPlayer())
+
+	server.playerLock.Lock()
+	defer server.playerLock.Unlock()
+
 	server.player = system.PlayerState{}
+	server.lastUnreachableReason = reason
+	server.failedPings = 0
 }
 
-// The server has two responsibilities when it comes to managing player
-// processes:
+// setPlayer sets `server.player` to the provided player state. If this means
+// that the server is switching from one player process to a different one,
+// connected clients are notified (see `notifyPlayerChanged`) and the
+// `onPlayerChanged` callback (if there is one) is called.
+func (server *Server) setPlayer(player system.PlayerState) {
+	if player == (system.PlayerState{}) {
+		server.storePlayer(player)
+		return
+	}
+
+	server.playerLock.Lock()
+	server.player = player
+	old := server.lastPlayer
+	server.lastPlayer = player
+	server.playerLock.Unlock()
+
+	if old.ID == "" || old.ID == player.ID {
+		return
+	}
+
+	server.releasePlayer(old)
+	server.pingLatencies.reset()
+	server.metrics.update(func(metrics *Metrics) {
+		metrics.PlayerReplacements++
+	})
+
+	log.Info().
+		Interface("oldPlayer", old).
+		Interface("newPlayer", player).
+		Msg("Switched player processes.")
+
+	server.notifyPlayerChanged(player)
+
+	if server.onPlayerChanged != nil {
+		server.onPlayerChanged(old, player)
+	}
+}
+
+// PinPlayer makes the server use the player process with the provided ID.
 //
-// 1. Ensuring that the "player pool" is full, i.e. that there is always a fresh
-//    player process available to use if needed, e.g. if the one that the server
-//    is using falls over / becomes unavailable.
+// Normally, when the player process that the server is using becomes
+// unavailable, the server automatically switches to another player process.
+// While a player is pinned, the server doesn't do that; instead, it reports an
+// error and keeps trying to reach the pinned player. This is useful for
+// debugging playback issues.
 //
-// 2. Ensuring that there is one specific player process available for the
-//    server to use, and that that process remains available for as long as the
-//    server needs to use it. The server does this by sending a `/ping` message
-//    to the player at regular intervals. If the player becomes unresponsive,
-//    the server is responsible for recovering by switching to use another
-//    player process.
-func (server *Server) managePlayers() {
-	playerPoolLastFilled := time.Unix(0, 0)
-	lastPing := time.Unix(0, 0)
+// Returns an error if no player is found with the provided ID.
+func (server *Server) PinPlayer(id string) error {
+	player, err := findPlayerByID(id)
+	if err != nil {
+		return err
+	}
 
-	for {
-		now := time.Now()
-
-		// Fill the player pool.
-		if now.Sub(playerPoolLastFilled) > playerPoolFillInterval {
-			if err := system.FillPlayerPool(); err != nil {
-				log.Warn().Err(err).Msg("Failed to fill player pool.")
-			} else {
-				log.Debug().Msg("Filled player pool.")
-			}
+	server.setPlayer(player)
+
+	server.playerLock.Lock()
+	server.failedPings = 0
+	server.pinnedPlayerID = id
+	server.playerLock.Unlock()
+
+	log.Info().Interface("player", player).Msg("Pinned player process.")
+
+	return nil
+}
+
+// UnpinPlayer restores the default behavior where the server automatically
+// switches to another player process if the one it's using becomes unavailable.
+func (server *Server) UnpinPlayer() {
+	server.playerLock.Lock()
+	pinnedPlayerID := server.pinnedPlayerID
+	server.pinnedPlayerID = ""
+	server.playerLock.Unlock()
+
+	if pinnedPlayerID != "" {
+		log.Info().
+			Str("playerID", pinnedPlayerID).
+			Msg("Unpinned player process.")
+	}
+}
+
+// RestartPlayer shuts down the player process that the server is using and
+// waits until the server has switched to a different one. This is useful when
+// the player process has gotten into a bad state.
+//
+// Returns an error if no replacement player process becomes available within
+// the configured `FindPlayerTimeout`.
+func (server *Server) RestartPlayer() error {
+	// The player is swapped out by the `managePlayers` loop, so that the loop
+	// isn't trying to use the player process while we're replacing it.
+	result := make(chan error, 1)
+
+	select {
+	case server.restartRequests <- result:
+	case <-server.done:
+		return errServerClosed
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-server.done:
+		return errServerClosed
+	}
+}
+
+// restartPlayer does the work of `RestartPlayer` on behalf of the
+// `managePlayers` loop.
+func (server *Server) restartPlayer() error {
+	if pinnedPlayerID := server.pinnedPlayer(); pinnedPlayerID != "" {
+		return fmt.Errorf(
+			"unable to restart the pinned player process %s", pinnedPlayerID,
+		)
+	}
+
+	if !server.hasPlayer() {
+		return fmt.Errorf("there is no player process to restart")
+	}
+
+	old := server.currentPlayer()
+
+	log.Info().Interface("player", old).Msg("Restarting player process.")
+
+	if err := transmitShutdown(server.newTransmitter(old), 0); err != nil {
+		return err
+	}
 
-			playerPoolLastFilled = now
+	server.unsetPlayer(ReasonShutdown)
+
+	// If we have a standby player process, we can switch to it right away.
+	// Otherwise, we wait for another player process, taking care not to end up
+	// with the old one again, as it might not have cleaned up its state file yet.
+	server.checkStandbyPlayer()
+	if server.standbyPlayer.ID == old.ID {
+		server.storeStandbyPlayer(system.PlayerState{})
+	}
+
+	replacement := server.takeStandbyPlayer()
+
+	if replacement == (system.PlayerState{}) {
+		player, err := server.awaitAvailablePlayer(old.ID)
+		if err != nil {
+			return fmt.Errorf(
+				"no replacement player process became available: %w", err,
+			)
 		}
 
-		// If the server already has a player process that it's using, fetch updated
-		// state information about that player process.
-		if server.hasPlayer() {
-			updatedState, err := system.FindPlayerByID(server.player.ID)
-
-			// FIXME: We are brittly depending on the verbiage in the error messages
-			// returned by `system.FindPlayerByID`.
-			//
-			// TODO: Maybe UserFacingErrors could have an optional error code that we
-			// can depend on here?
-			if err == nil {
-				if updatedState.Port != 0{
-					server.player = updatedState
-				}
-			} else if strings.HasPrefix(err.Error(), "No player was found") {
-				// If the state information tells us that the player process no longer
-				// exists, then we forget about that player process and a new one will be
-				// found to replace it shortly.
-				log.Warn().
-					Interface("player", server.player).
-					Msg("Player process is offline.")
-				server.unsetPlayer()
-			} else {
-				log.Warn().Err(err).Msg("Failed to update player state information.")
+		replacement = player
+	}
+
+	server.setPlayer(replacement)
+
+	return nil
+}
+
+// handlePingResult keeps track of the number of consecutive pings to the
+// current player process that have failed. Once the configured threshold
+// number of pings in a row have failed, we give up on the player and unset it
+// so that it will be replaced. A successful ping resets the count.
+func (server *Server) handlePingResult(err error) {
+	server.playerLock.Lock()
+	if err == nil {
+		server.failedPings = 0
+		server.lastSuccessfulPing = time.Now()
+	} else {
+		server.failedPings++
+	}
+	failedPings := server.failedPings
+	server.playerLock.Unlock()
+
+	if err == nil {
+		log.Debug().
+			Interface("player", server.currentPlayer()).
+			Msg("Sent ping to player process.")
+
+		return
+	}
+
+	server.metrics.update(func(metrics *Metrics) { metrics.PingFailures++ })
+
+	if failedPings < server.playerManagement.FailedPingThreshold {
+		log.Debug().
+			Err(err).
+			Interface("player", server.currentPlayer()).
+			Int("failedPings", failedPings).
+			Msg("Failed to ping player process.")
+
+		return
+	}
+
+	log.Warn().
+		Err(err).
+		Interface("player", server.currentPlayer()).
+		Int("failedPings", failedPings).
+		Msg("Player process unreachable.")
+
+	if errors.Is(err, errPingTimeout) {
+		server.unsetPlayer(ReasonPingTimeout)
+	} else {
+		server.unsetPlayer(ReasonSendError)
+	}
+}
+
+// latencyHistory keeps track of the most recent ping round-trip durations in a
+// fixed-size ring buffer.
+type latencyHistory struct {
+	lock      sync.Mutex
+	latencies []time.Duration
+	// The index where the next latency will be recorded.
+	next int
+	// True once the buffer has wrapped around, i.e. every slot has a value.
+	full bool
+}
+
+func newLatencyHistory(size int) *latencyHistory {
+	return &latencyHistory{latencies: make([]time.Duration, size)}
+}
+
+func (history *latencyHistory) record(latency time.Duration) {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+
+	if len(history.latencies) == 0 {
+		return
+	}
+
+	history.latencies[history.next] = latency
+	history.next = (history.next + 1) % len(history.latencies)
+
+	if history.next == 0 {
+		history.full = true
+	}
+}
+
+// all returns the recorded latencies, from oldest to newest.
+func (history *latencyHistory) all() []time.Duration {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+
+	if !history.full {
+		return append([]time.Duration{}, history.latencies[:history.next]...)
+	}
+
+	return append(
+		append([]time.Duration{}, history.latencies[history.next:]...),
+		history.latencies[:history.next]...,
+	)
+}
+
+func (history *latencyHistory) reset() {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+
+	history.next = 0
+	history.full = false
+}
+
+// PingLatencies returns the round-trip durations of the most recent successful
+// pings to the player process that the server is using, from oldest to newest.
+// This can be useful for troubleshooting playback issues, e.g. if the player
+// process is slow to respond.
+//
+// The history is cleared when the server switches to a different player
+// process.
+func (server *Server) PingLatencies() []time.Duration {
+	return server.pingLatencies.all()
+}
+
+// PlayerStatus describes the state of the player process that a REPL server is
+// using, for diagnostic purposes.
+type PlayerStatus struct {
+	// The server's most recent information about the player process it is using,
+	// or the zero value if it doesn't currently have one.
+	Player system.PlayerState `json:"player"`
+	// The server's most recent information about its standby player process, or
+	// the zero value if it doesn't currently have one. (See
+	// `refreshStandbyPlayer`.)
+	StandbyPlayer system.PlayerState `json:"standbyPlayer"`
+	// The ID of the pinned player process, if there is one. (See `PinPlayer`.)
+	PinnedPlayerID string `json:"pinnedPlayerID,omitempty"`
+	// The number of player processes that are available or starting up, or -1 if
+	// this couldn't be determined.
+	PoolSize int `json:"poolSize"`
+	// When the server last successfully pinged its player process, or the zero
+	// value if it never has.
+	LastSuccessfulPing time.Time `json:"lastSuccessfulPing"`
+	// The number of consecutive pings to the player process that have failed.
+	FailedPings int `json:"failedPings"`
+	// False when repeated attempts to fill the player pool have failed, which
+	// means that new player processes can't be started.
+	PoolHealthy bool `json:"poolHealthy"`
+	// Why the server most recently stopped using a player process, if it ever
+	// has.
+	UnreachableReason UnreachableReason `json:"unreachableReason,omitempty"`
+}
+
+// PlayerStatus returns information about the player process that the server is
+// using and the pool of player processes that are available.
+func (server *Server) PlayerStatus() PlayerStatus {
+	poolSize, err := playerPoolSize()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to determine player pool size.")
+		poolSize = -1
+	}
+
+	server.playerLock.RLock()
+	defer server.playerLock.RUnlock()
+
+	return PlayerStatus{
+		Player:             server.player,
+		StandbyPlayer:      server.standbyPlayer,
+		PinnedPlayerID:     server.pinnedPlayerID,
+		PoolSize:           poolSize,
+		LastSuccessfulPing: server.lastSuccessfulPing,
+		FailedPings:        server.failedPings,
+		PoolHealthy:        server.poolHealthy,
+		UnreachableReason:  server.lastUnreachableReason,
+	}
+}
+
+// PlayerListing describes a player process in the player pool.
+type PlayerListing struct {
+	system.PlayerState
+	// True if this is the player process that the server is using.
+	Bound bool `json:"bound"`
+	// True if the player process's expiry has passed, which means that the
+	// player process has probably exited without cleaning up its state file.
+	Expired bool `json:"expired"`
+}
+
+// ListPlayers returns information about all of the player processes in the
+// player pool, including the one that the server is using.
+func (server *Server) ListPlayers() ([]PlayerListing, error) {
+	players, err := listPlayers()
+	if err != nil {
+		return nil, err
+	}
+
+	listings := []PlayerListing{}
+	for _, player := range players {
+		listings = append(listings, PlayerListing{
+			PlayerState: player,
+			Bound:       server.hasPlayer() && player.ID == server.currentPlayer().ID,
+			Expired:     player.Expired(),
+		})
+	}
+
+	return listings, nil
+}
+
+// Metrics is a snapshot of counters that describe the activity of the player
+// management loop since the server started. (See `Server.Metrics`.)
+type Metrics struct {
+	// The number of pings sent to the player process that the server is using.
+	PingsSent uint64 `json:"pingsSent"`
+	// The number of those pings that failed.
+	PingFailures uint64 `json:"pingFailures"`
+	// The number of times that the server switched from one player process to a
+	// different one.
+	PlayerReplacements uint64 `json:"playerReplacements"`
+	// The number of times that the player pool was filled.
+	PoolFills uint64 `json:"poolFills"`
+}
+
+// metricsCounter accumulates Metrics in a way that is safe to read while the
+// player management loop is updating it.
+type metricsCounter struct {
+	lock    sync.Mutex
+	metrics Metrics
+}
+
+func (counter *metricsCounter) update(f func(metrics *Metrics)) {
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+
+	f(&counter.metrics)
+}
+
+func (counter *metricsCounter) snapshot() Metrics {
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+
+	return counter.metrics
+}
+
+// Metrics returns the current values of the counters that describe the
+// activity of the player management loop, e.g. for alerting on excessive
+// player churn.
+func (server *Server) Metrics() Metrics {
+	return server.metrics.snapshot()
+}
+
+// claimPlayer claims the player process on behalf of this server, so that
+// other REPL servers don't use it at the same time. (See `system.ClaimPlayer`.)
+//
+// Returns false if another REPL server has already claimed it.
+func (server *Server) claimPlayer(player system.PlayerState) bool {
+	claimed, err := claimPlayer(player.ID, server.claimant)
+	if err != nil {
+		// Failing to claim the player process (e.g. because the cache directory
+		// isn't writable) shouldn't make playback impossible, so we carry on.
+		log.Warn().
+			Err(err).
+			Interface("player", player).
+			Msg("Failed to claim player process.")
+
+		return true
+	}
+
+	return claimed
+}
+
+// releasePlayer releases this server's claim on the player process, if it has
+// one.
+func (server *Server) releasePlayer(player system.PlayerState) {
+	if player.ID == "" {
+		return
+	}
+
+	if err := releasePlayer(player.ID, server.claimant); err != nil {
+		log.Warn().
+			Err(err).
+			Interface("player", player).
+			Msg("Failed to release player process.")
+	}
+}
+
+// prunePlayers deletes the state files of player processes that are probably
+// gone, so that we don't waste time trying to use them.
+func (server *Server) prunePlayers() {
+	removed, err := prunePlayers(server.playerManagement.PlayerStateTTL)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to prune player state files.")
+		return
+	}
+
+	if removed > 0 {
+		log.Info().
+			Int("removed", removed).
+			Msg("Pruned stale player state files.")
+	}
+}
+
+// fillPlayerPool ensures that there are spare player processes available in
+// the background.
+func (server *Server) fillPlayerPool() {
+	if err := fillPlayerPool(server.playerLaunch); err != nil {
+		server.failedPoolFills++
+
+		// We only escalate once, so that we don't flood the log with errors while
+		// the problem persists.
+		if server.failedPoolFills == poolFillFailureThreshold {
+			server.playerLock.Lock()
+			server.poolHealthy = false
+			server.playerLock.Unlock()
+
+			log.Error().
+				Err(err).
+				Int("failedPoolFills", server.failedPoolFills).
+				Msg("Unable to start player processes. Playback isn't possible.")
+		} else {
+			log.Warn().
+				Err(err).
+				Int("failedPoolFills", server.failedPoolFills).
+				Msg("Failed to fill player pool.")
+		}
+
+		return
+	}
+
+	server.playerLock.Lock()
+	wasHealthy := server.poolHealthy
+	server.poolHealthy = true
+	server.playerLock.Unlock()
+
+	if !wasHealthy {
+		log.Info().Msg("Player pool is healthy again.")
+	}
+
+	server.failedPoolFills = 0
+
+	log.Debug().Msg("Filled player pool.")
+	server.metrics.update(func(metrics *Metrics) { metrics.PoolFills++ })
+}
+
+// refreshPlayer fetches updated state information about the player process that
+// the server is using, finds a new player process if the server doesn't have
+// one (or the one it had went offline), and then pings the player process to
+// ensure that it's still reachable.
+func (server *Server) refreshPlayer() {
+	server.updatePlayer()
+	server.pingCurrentPlayer()
+	server.refreshStandbyPlayer()
+}
+
+// updatePlayer fetches updated state information about the player process that
+// the server is using, and finds a new player process if the server doesn't
+// have one (or the one it had went offline).
+//
+// Unlike `refreshPlayer`, this doesn't ping anything, so it's cheap enough to
+// do frequently. (See `playerRefreshInterval`.)
+func (server *Server) updatePlayer() {
+	// If the server already has a player process that it's using, fetch updated
+	// state information about that player process.
+	if server.hasPlayer() {
+		updatedState, err := findPlayerByID(server.currentPlayer().ID)
+
+		// FIXME: We are brittly depending on the verbiage in the error messages
+		// returned by `system.FindPlayerByID`.
+		//
+		// TODO: Maybe UserFacingErrors could have an optional error code that we
+		// can depend on here?
+		if err == nil {
+			if updatedState.Port != 0 {
+				server.setPlayer(updatedState)
 			}
+		} else if strings.HasPrefix(err.Error(), "No player was found") {
+			// If the state information tells us that the player process no longer
+			// exists, then we forget about that player process and a new one will be
+			// found to replace it shortly.
+			log.Warn().
+				Str("player-id", server.currentPlayer().ID).
+				Int("port", server.currentPlayer().Port).
+				Msg("Player process is offline.")
+			server.unsetPlayer(ReasonNotFound)
+		} else {
+			log.Warn().Err(err).Msg("Failed to update player state information.")
 		}
+	}
 
-		if !server.hasPlayer() {
-			player, err := findAvailablePlayer()
-			if err != nil {
-				log.Warn().Err(err).Msg("No player processes available.")
-			} else {
-				log.Info().Interface("player", player).Msg("Found player process.")
-				if player.Port != 0{
-					server.player = player
-				}
+	pinnedPlayerID := server.pinnedPlayer()
+
+	if !server.hasPlayer() && pinnedPlayerID != "" {
+		// When a player is pinned, we don't look for a replacement; the best we can
+		// do is try to reach the same player again.
+		player, err := findPlayerByID(pinnedPlayerID)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("playerID", pinnedPlayerID).
+				Msg("Pinned player process is unavailable.")
+		} else if player.Port != 0 {
+			server.setPlayer(player)
+		}
+	}
+
+	// If we have a standby player process ready to go, we can switch to it
+	// immediately instead of waiting to find an available player process.
+	if !server.hasPlayer() && pinnedPlayerID == "" {
+		server.checkStandbyPlayer()
+	}
+
+	if !server.hasPlayer() && pinnedPlayerID == "" {
+		if standby := server.takeStandbyPlayer(); standby.Port != 0 {
+			log.Info().
+				Interface("player", standby).
+				Msg("Promoting standby player process.")
+
+			server.setPlayer(standby)
+		}
+	}
+
+	if !server.hasPlayer() && pinnedPlayerID == "" {
+		player, err := server.awaitAvailablePlayer()
+		if err != nil {
+			log.Warn().Err(err).Msg("No player processes available.")
+		} else {
+			log.Info().Interface("player", player).Msg("Found player process.")
+			if player.Port != 0 {
+				server.setPlayer(player)
 			}
 		}
+	}
+}
 
-		if server.hasPlayer() && now.Sub(lastPing) > pingInterval {
-			// We can safely ignore `err` here because it should always be nil, given
-			// that we just checked that `server.hasPlayer()` is true.
-			transmitter, _ := server.transmitter()
+// pingCurrentPlayer pings the player process that the server is using, if any,
+// and renews the server's claim on it.
+func (server *Server) pingCurrentPlayer() {
+	if server.hasPlayer() {
+		player := server.currentPlayer()
 
-			if err := util.Await(
-				func() error { return transmitter.TransmitPingMessage() },
-				pingTimeout,
-			); err != nil {
-				log.Warn().
-					Err(err).
-					Interface("player", server.player).
-					Msg("Player process unreachable.")
+		server.metrics.update(func(metrics *Metrics) { metrics.PingsSent++ })
+
+		start := time.Now()
+		err := pingWithin(player, server.playerManagement.PingTimeout)
+
+		if err == nil {
+			server.pingLatencies.record(time.Since(start))
+		}
+
+		server.handlePingResult(err)
+
+		// We renew our claim on the player process each time we successfully
+		// ping it. If another REPL server has claimed it in the meantime (e.g.
+		// because our claim expired while we were unable to reach the player
+		// process), we find another one, unless it's pinned.
+		if err == nil && server.hasPlayer() && !server.claimPlayer(player) &&
+			server.pinnedPlayer() == "" {
+			log.Warn().
+				Interface("player", player).
+				Msg("Player process was claimed by another REPL server.")
+
+			server.unsetPlayer(ReasonClaimed)
+		}
+	}
+}
+
+// checkStandbyPlayer fetches updated state information about the standby player
+// process and pings it. If it's no longer available, we forget about it.
+func (server *Server) checkStandbyPlayer() {
+	// The standby player might have ended up being used as the main player, e.g.
+	// if it was found by `awaitAvailablePlayer`.
+	if server.standbyPlayer.ID == server.currentPlayer().ID {
+		server.storeStandbyPlayer(system.PlayerState{})
+	}
+
+	if server.standbyPlayer == (system.PlayerState{}) {
+		return
+	}
+
+	standby := server.standbyPlayer
 
-				server.unsetPlayer()
-			} else {
+	updatedState, err := findPlayerByID(standby.ID)
+	if err == nil && updatedState.State != "ready" {
+		err = fmt.Errorf("player state is %s", updatedState.State)
+	}
+
+	if err == nil {
+		err = util.Await(
+			func() error { return pingPlayer(updatedState) },
+			server.playerManagement.PingTimeout,
+		)
+	}
+
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Interface("player", standby).
+			Msg("Standby player process is no longer available.")
+
+		server.storeStandbyPlayer(system.PlayerState{})
+		return
+	}
+
+	server.storeStandbyPlayer(updatedState)
+}
+
+// takeStandbyPlayer claims the standby player process (see `claimPlayer`) so
+// that it can be promoted to be the player process that the server is using,
+// and forgets about it as a standby.
+//
+// Returns an empty PlayerState if there is no standby player process, or if
+// another REPL server has claimed it in the meantime.
+func (server *Server) takeStandbyPlayer() system.PlayerState {
+	standby := server.standbyPlayer
+	server.storeStandbyPlayer(system.PlayerState{})
+
+	if standby == (system.PlayerState{}) {
+		return standby
+	}
+
+	if !server.claimPlayer(standby) {
+		log.Debug().
+			Interface("player", standby).
+			Msg("Standby player process was claimed by another REPL server.")
+
+		return system.PlayerState{}
+	}
+
+	return standby
+}
+
+// refreshStandbyPlayer maintains a second, "warm" player process that isn't
+// being used, but is ready to be used immediately if the player process that
+// the server is using is lost. (See `refreshPlayer`.)
+//
+// Like the player process that the server is using, the standby player process
+// is pinged regularly. If it becomes unreachable or another server starts using
+// it, it's replaced by another available player process.
+func (server *Server) refreshStandbyPlayer() {
+	// When a player is pinned, we never switch to another player process, so
+	// there is no need for a standby.
+	if server.pinnedPlayer() != "" {
+		server.storeStandbyPlayer(system.PlayerState{})
+		return
+	}
+
+	server.checkStandbyPlayer()
+
+	if server.standbyPlayer == (system.PlayerState{}) {
+		candidates, err := availablePlayers(server.claimant)
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to find a standby player process.")
+			return
+		}
+
+		for _, candidate := range candidates {
+			if candidate.ID != server.currentPlayer().ID {
 				log.Debug().
-					Interface("player", server.player).
-					Msg("Sent ping to player process.")
+					Interface("player", candidate).
+					Msg("Found standby player process.")
+
+				server.storeStandbyPlayer(candidate)
+				return
 			}
+		}
+	}
+}
 
-			lastPing = now
+// The server has two responsibilities when it comes to managing player
+// processes:
+//
+//  1. Ensuring that the "player pool" is full, i.e. that there is always a fresh
+//     player process available to use if needed, e.g. if the one that the server
+//     is using falls over / becomes unavailable.
+//
+//  2. Ensuring that there is one specific player process available for the
+//     server to use, and that that process remains available for as long as the
+//     server needs to use it. The server does this by sending a `/ping` message
+//     to the player at regular intervals. If the player becomes unresponsive,
+//     the server is responsible for recovering by switching to use another
+//     player process.
+//
+// The loop runs until the server is closed.
+func (server *Server) managePlayers() {
+	poolFillTicker := time.NewTicker(
+		server.playerManagement.PlayerPoolFillInterval,
+	)
+	defer poolFillTicker.Stop()
+
+	refreshTicker := time.NewTicker(playerRefreshInterval)
+	defer refreshTicker.Stop()
+
+	server.managePlayersWithTicks(
+		poolFillTicker.C,
+		refreshTicker.C,
+		server.pingTicks(server.done),
+		server.done,
+	)
+}
+
+// When several REPL servers share the same player pool, we don't want their
+// `managePlayers` loops to end up pinging at the same instant, so we randomly
+// adjust the time between pings by up to this fraction of the ping interval.
+const pingJitter = 0.1
+
+// nextPingDelay returns the amount of time to wait before the next ping, which
+// is the configured ping interval, plus or minus some random jitter.
+func (server *Server) nextPingDelay() time.Duration {
+	jitter := (server.randomFloat64()*2 - 1) * pingJitter
+
+	return time.Duration(
+		float64(server.playerManagement.PingInterval) * (1 + jitter),
+	)
+}
+
+// pingTicks returns a channel that receives a tick each time it's time to ping
+// the player process (see `nextPingDelay`), until `done` is closed.
+func (server *Server) pingTicks(done <-chan struct{}) <-chan time.Time {
+	ticks := make(chan time.Time)
+
+	go func() {
+		timer := time.NewTimer(server.nextPingDelay())
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case tick := <-timer.C:
+				select {
+				case <-done:
+					return
+				case ticks <- tick:
+				}
+
+				timer.Reset(server.nextPingDelay())
+			}
 		}
+	}()
+
+	return ticks
+}
 
-		time.Sleep(100 * time.Millisecond)
+// managePlayersWithTicks is the loop that drives `managePlayers`. The player
+// pool is filled (and the player is refreshed) immediately, and thereafter
+// whenever a tick is received on the corresponding channel. The loop returns
+// when `done` is closed.
+//
+// Refresh ticks only update the player's state and replace it if it's missing
+// (see `updatePlayer`). Ping ticks do that too, before pinging the player.
+func (server *Server) managePlayersWithTicks(
+	poolFillTicks <-chan time.Time,
+	refreshTicks <-chan time.Time,
+	pingTicks <-chan time.Time,
+	done <-chan struct{},
+) {
+	server.prunePlayers()
+	server.fillPlayerPool()
+	server.refreshPlayer()
+
+	for {
+		select {
+		case <-done:
+			server.releasePlayer(server.currentPlayer())
+			return
+		case <-poolFillTicks:
+			server.prunePlayers()
+			server.fillPlayerPool()
+		case <-refreshTicks:
+			server.updatePlayer()
+		case <-pingTicks:
+			server.refreshPlayer()
+		case result := <-server.restartRequests:
+			result <- server.restartPlayer()
+		}
 	}
 }
 
+// shutdownPlayer tells the player process that the server is using to shut
+// down immediately, and un-sets the player so that it will be replaced.
 func (server *Server) shutdownPlayer() error {
+	return server.shutdownPlayerAfter(0)
+}
+
+// shutdownPlayerAfter is like `shutdownPlayer`, except that the player process
+// shuts down once it reaches the point `d` from now in its timeline. This gives
+// the player a chance to finish playing any notes that are currently scheduled
+// before it exits.
+func (server *Server) shutdownPlayerAfter(d time.Duration) error {
 	if err := server.withTransmitter(
-		func(transmitter transmitter.OSCTransmitter) error {
-			return transmitter.TransmitShutdownMessage(0)
+		func(transmitter transmitter.PlayerTransmitter) error {
+			return transmitShutdown(transmitter, int32(d.Milliseconds()))
 		},
 	); err != nil {
 		return err
@@ -217,7 +1601,7 @@ func (server *Server) shutdownPlayer() error {
 	// we double-unset it. But the risk is low because even if that happens, the
 	// worst case scenario is that we would end up replacing the player twice, and
 	// even if that happens, we would still end up with a player to use below.)
-	server.unsetPlayer()
+	server.unsetPlayer(ReasonShutdown)
 
 	return nil
 }
@@ -0,0 +1,1891 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"alda.io/client/generated"
+	log "alda.io/client/logging"
+	"alda.io/client/model"
+	"alda.io/client/system"
+	_ "alda.io/client/testing"
+	"alda.io/client/transmitter"
+	"github.com/daveyarwood/go-osc/osc"
+)
+
+func testPlayer() system.PlayerState {
+	return system.PlayerState{State: "ready", Port: 27278, ID: "abc"}
+}
+
+func testServer() *Server {
+	server := NewServer(0)
+	server.storePlayer(testPlayer())
+	return server
+}
+
+func TestPingFailuresBelowThreshold(t *testing.T) {
+	server := testServer()
+	failedPingThreshold := server.playerManagement.FailedPingThreshold
+
+	for i := 0; i < failedPingThreshold-1; i++ {
+		server.handlePingResult(fmt.Errorf("ping failed"))
+	}
+
+	if !server.hasPlayer() {
+		t.Fatalf(
+			"player was unset after %d failed pings", failedPingThreshold-1,
+		)
+	}
+
+	server.handlePingResult(nil)
+
+	if !server.hasPlayer() {
+		t.Fatal("player was unset after a successful ping")
+	}
+
+	if server.failedPings != 0 {
+		t.Errorf(
+			"expected failed ping count to be reset to 0, got %d",
+			server.failedPings,
+		)
+	}
+}
+
+func TestPingFailuresReachingThreshold(t *testing.T) {
+	server := testServer()
+	failedPingThreshold := server.playerManagement.FailedPingThreshold
+
+	for i := 0; i < failedPingThreshold; i++ {
+		server.handlePingResult(fmt.Errorf("ping failed"))
+	}
+
+	if server.hasPlayer() {
+		t.Fatalf(
+			"player was not unset after %d failed pings", failedPingThreshold,
+		)
+	}
+
+	if server.failedPings != 0 {
+		t.Errorf(
+			"expected failed ping count to be reset to 0, got %d",
+			server.failedPings,
+		)
+	}
+}
+
+func TestPlayerManagementConfigDefaults(t *testing.T) {
+	config := PlayerManagementConfig{PingInterval: 3 * time.Second}.withDefaults()
+
+	if config.PingInterval != 3*time.Second {
+		t.Errorf("expected ping interval to be 3s, got %s", config.PingInterval)
+	}
+
+	if config.FindPlayerTimeout != defaultFindPlayerTimeout {
+		t.Errorf(
+			"expected find player timeout to default to %s, got %s",
+			defaultFindPlayerTimeout, config.FindPlayerTimeout,
+		)
+	}
+
+	if config.FailedPingThreshold != defaultFailedPingThreshold {
+		t.Errorf(
+			"expected failed ping threshold to default to %d, got %d",
+			defaultFailedPingThreshold, config.FailedPingThreshold,
+		)
+	}
+}
+
+func TestPlayerManagementConfigValidation(t *testing.T) {
+	for _, config := range []PlayerManagementConfig{
+		{},
+		// This is what the server validates when it starts. (See `RunServer`.)
+		PlayerManagementConfig{}.withDefaults(),
+		{PingTimeout: 10 * time.Second, PingInterval: 5 * time.Second},
+		{FindPlayerTimeout: time.Minute, FailedPingThreshold: 10},
+		{FailedPingThreshold: 4},
+		{
+			PingTimeout:         defaultPingTimeout,
+			PingInterval:        defaultPingInterval,
+			FailedPingThreshold: defaultFailedPingThreshold,
+		},
+	} {
+		if err := config.Validate(); err != nil {
+			t.Errorf("expected %#v to be valid, got error: %v", config, err)
+		}
+	}
+
+	for _, config := range []PlayerManagementConfig{
+		{FindPlayerTimeout: -1 * time.Second},
+		{PingInterval: -1 * time.Second},
+		{FailedPingThreshold: -1},
+		{PingTimeout: 3 * time.Second},
+		{PingTimeout: 10 * time.Second, FailedPingThreshold: 5},
+		{PingInterval: 500 * time.Millisecond},
+		{PingInterval: system.PlayerClaimDuration, FailedPingThreshold: 1},
+	} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("expected %#v to be invalid", config)
+		}
+	}
+}
+
+// fakePlayerSystem stands in for the player processes that a server interacts
+// with, keeping track of how many times each kind of interaction happens.
+type fakePlayerSystem struct {
+	lock       sync.Mutex
+	players    []system.PlayerState
+	poolFills  int
+	pings      int
+	pingErrors []error
+	// The number of times that stale player state files were pruned.
+	prunes int
+	// The number of times the server asked for an available player.
+	availablePlayerLookups int
+	// When set, filling the player pool fails with this error.
+	poolFillError error
+	// Players that become available the next time the pool is filled.
+	spawnedOnPoolFill []system.PlayerState
+	// How long it takes each player (by ID) to respond to a ping.
+	pingLatencies map[string]time.Duration
+	// IDs of players that fail every ping.
+	unreachable map[string]bool
+	// The score state bundles that were sent, by player ID.
+	restoredStates map[string]*osc.Bundle
+	// The soundfonts that were restored, by player ID.
+	restoredSoundfonts map[string]string
+	// The offsets of the shutdown messages that were sent, by port.
+	shutdowns map[int][]int32
+	// The claimant of each claimed player (by ID). (See `system.ClaimPlayer`.)
+	claims map[string]string
+}
+
+// claimedByAnother returns true if someone other than `claimant` has claimed
+// the player with the provided ID. The caller must hold the lock.
+func (fake *fakePlayerSystem) claimedByAnother(id string, claimant string) bool {
+	owner, claimed := fake.claims[id]
+	return claimed && owner != claimant
+}
+
+// stubPlayerSystem swaps out the functions that the server uses to interact
+// with player processes for the duration of a test.
+func stubPlayerSystem(t *testing.T, fake *fakePlayerSystem) {
+	originalFillPlayerPool := fillPlayerPool
+	originalFindAvailablePlayer := findAvailablePlayer
+	originalFindPlayerByID := findPlayerByID
+	originalPingPlayer := pingPlayer
+	originalProbePlayer := probePlayer
+	originalAvailablePlayers := availablePlayers
+	originalTransmitScoreState := transmitScoreState
+	originalTransmitSoundfont := transmitSoundfont
+	originalPlayerPoolSize := playerPoolSize
+	originalListPlayers := listPlayers
+	originalPrunePlayers := prunePlayers
+	originalTransmitShutdown := transmitShutdown
+	originalClaimPlayer := claimPlayer
+	originalReleasePlayer := releasePlayer
+
+	t.Cleanup(func() {
+		claimPlayer = originalClaimPlayer
+		releasePlayer = originalReleasePlayer
+		transmitShutdown = originalTransmitShutdown
+		playerPoolSize = originalPlayerPoolSize
+		listPlayers = originalListPlayers
+		prunePlayers = originalPrunePlayers
+		transmitScoreState = originalTransmitScoreState
+		transmitSoundfont = originalTransmitSoundfont
+		availablePlayers = originalAvailablePlayers
+		fillPlayerPool = originalFillPlayerPool
+		findAvailablePlayer = originalFindAvailablePlayer
+		findPlayerByID = originalFindPlayerByID
+		pingPlayer = originalPingPlayer
+		probePlayer = originalProbePlayer
+	})
+
+	fillPlayerPool = func(config system.PlayerLaunchConfig) error {
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+
+		fake.poolFills++
+
+		if fake.poolFillError != nil {
+			return fake.poolFillError
+		}
+
+		fake.players = append(fake.players, fake.spawnedOnPoolFill...)
+		fake.spawnedOnPoolFill = nil
+		return nil
+	}
+
+	availablePlayers = func(claimant string) ([]system.PlayerState, error) {
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+
+		players := []system.PlayerState{}
+		for _, player := range fake.players {
+			if !fake.claimedByAnother(player.ID, claimant) {
+				players = append(players, player)
+			}
+		}
+
+		return players, nil
+	}
+
+	prunePlayers = func(ttl time.Duration) (int, error) {
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+
+		fake.prunes++
+		return 0, nil
+	}
+
+	listPlayers = func() ([]system.PlayerState, error) {
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+
+		return append([]system.PlayerState{}, fake.players...), nil
+	}
+
+	playerPoolSize = func() (int, error) {
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+
+		return len(fake.players), nil
+	}
+
+	findAvailablePlayer = func(claimant string) (system.PlayerState, error) {
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+
+		fake.availablePlayerLookups++
+
+		for _, player := range fake.players {
+			if !fake.claimedByAnother(player.ID, claimant) {
+				return player, nil
+			}
+		}
+
+		return system.PlayerState{}, system.ErrNoPlayersAvailable
+	}
+
+	claimPlayer = func(id string, claimant string) (bool, error) {
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+
+		if fake.claimedByAnother(id, claimant) {
+			return false, nil
+		}
+
+		if fake.claims == nil {
+			fake.claims = map[string]string{}
+		}
+
+		fake.claims[id] = claimant
+		return true, nil
+	}
+
+	releasePlayer = func(id string, claimant string) error {
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+
+		if !fake.claimedByAnother(id, claimant) {
+			delete(fake.claims, id)
+		}
+
+		return nil
+	}
+
+	findPlayerByID = func(id string) (system.PlayerState, error) {
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+
+		for _, player := range fake.players {
+			if player.ID == id {
+				return player, nil
+			}
+		}
+
+		return system.PlayerState{},
+			fmt.Errorf("No player was found with the ID %s", id)
+	}
+
+	transmitShutdown = func(
+		tr transmitter.PlayerTransmitter, offset int32,
+	) error {
+		oscTransmitter, ok := tr.(transmitter.OSCTransmitter)
+		if !ok {
+			return tr.TransmitShutdownMessage(offset)
+		}
+
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+
+		if fake.shutdowns == nil {
+			fake.shutdowns = map[int][]int32{}
+		}
+
+		port := oscTransmitter.Port
+		fake.shutdowns[port] = append(fake.shutdowns[port], offset)
+
+		return nil
+	}
+
+	transmitScoreState = func(
+		player system.PlayerState, score *model.Score,
+	) error {
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+
+		if fake.restoredStates == nil {
+			fake.restoredStates = map[string]*osc.Bundle{}
+		}
+
+		fake.restoredStates[player.ID] =
+			transmitter.OSCTransmitter{}.ScoreStateToOSCBundle(score)
+
+		return nil
+	}
+
+	transmitSoundfont = func(player system.PlayerState, path string) error {
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+
+		if fake.restoredSoundfonts == nil {
+			fake.restoredSoundfonts = map[string]string{}
+		}
+
+		fake.restoredSoundfonts[player.ID] = path
+
+		return nil
+	}
+
+	probePlayer = func(player system.PlayerState, timeout time.Duration) error {
+		fake.lock.Lock()
+		latency := fake.pingLatencies[player.ID]
+		unreachable := fake.unreachable[player.ID]
+		fake.lock.Unlock()
+
+		if unreachable || latency > timeout {
+			time.Sleep(timeout)
+			return fmt.Errorf("player %s didn't reply", player.ID)
+		}
+
+		time.Sleep(latency)
+		return nil
+	}
+
+	pingPlayer = func(player system.PlayerState) error {
+		fake.lock.Lock()
+		latency := fake.pingLatencies[player.ID]
+		fake.lock.Unlock()
+
+		time.Sleep(latency)
+
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+
+		fake.pings++
+
+		if fake.unreachable[player.ID] {
+			return fmt.Errorf("player %s is unreachable", player.ID)
+		}
+
+		if len(fake.pingErrors) > 0 {
+			err := fake.pingErrors[0]
+			fake.pingErrors = fake.pingErrors[1:]
+			return err
+		}
+
+		return nil
+	}
+}
+
+// runWithFakeClock runs the player management loop, simulating the passage of
+// `span` amount of time by sending ticks in chronological order, as if from
+// tickers with the configured pool fill and ping intervals and the player
+// refresh interval.
+func runWithFakeClock(server *Server, span time.Duration) {
+	poolFillTicks := make(chan time.Time)
+	refreshTicks := make(chan time.Time)
+	pingTicks := make(chan time.Time)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		server.managePlayersWithTicks(poolFillTicks, refreshTicks, pingTicks, done)
+		close(finished)
+	}()
+
+	poolFillInterval := server.playerManagement.PlayerPoolFillInterval
+	pingInterval := server.playerManagement.PingInterval
+	start := time.Unix(0, 0)
+
+	for elapsed := time.Millisecond; elapsed < span; elapsed += time.Millisecond {
+		now := start.Add(elapsed)
+
+		if elapsed%poolFillInterval == 0 {
+			poolFillTicks <- now
+		}
+
+		if elapsed%playerRefreshInterval == 0 {
+			refreshTicks <- now
+		}
+
+		if elapsed%pingInterval == 0 {
+			pingTicks <- now
+		}
+	}
+
+	close(done)
+	<-finished
+}
+
+func TestManagePlayersTiming(t *testing.T) {
+	fake := &fakePlayerSystem{players: []system.PlayerState{testPlayer()}}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0)
+	runWithFakeClock(server, time.Minute)
+
+	// The pool is filled once at the beginning, and then once every 10 seconds.
+	if fake.poolFills != 6 {
+		t.Errorf("expected 6 pool fills, got %d", fake.poolFills)
+	}
+
+	// Stale player state files are pruned each time before the pool is filled.
+	if fake.prunes != 6 {
+		t.Errorf("expected 6 prunes, got %d", fake.prunes)
+	}
+
+	// The player is pinged once to confirm that it's ready to use, once more at
+	// the beginning, and then once every second.
+	if fake.pings != 61 {
+		t.Errorf("expected 61 pings, got %d", fake.pings)
+	}
+
+	if server.player != testPlayer() {
+		t.Errorf(
+			"expected server to be using %#v, got %#v", testPlayer(), server.player,
+		)
+	}
+}
+
+func TestMissingPlayerIsReplacedBetweenPings(t *testing.T) {
+	first := testPlayer()
+	second := system.PlayerState{State: "ready", Port: 27279, ID: "xyz"}
+
+	fake := &fakePlayerSystem{players: []system.PlayerState{first}}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0)
+
+	refreshTicks := make(chan time.Time)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		server.managePlayersWithTicks(nil, refreshTicks, nil, done)
+		close(finished)
+	}()
+
+	defer func() {
+		close(done)
+		<-finished
+	}()
+
+	refreshTicks <- time.Now()
+
+	if server.currentPlayer() != first {
+		t.Fatalf("expected %#v, got %#v", first, server.currentPlayer())
+	}
+
+	// Simulate the player going offline.
+	fake.lock.Lock()
+	fake.players = []system.PlayerState{second}
+	fake.lock.Unlock()
+
+	// The loop is done with the first tick once it's ready for the second one.
+	refreshTicks <- time.Now()
+	refreshTicks <- time.Now()
+
+	if server.currentPlayer() != second {
+		t.Errorf(
+			"expected the player to be replaced without waiting for a ping, "+
+				"got %#v",
+			server.currentPlayer(),
+		)
+	}
+}
+
+func TestManagePlayersStopsWhenServerIsClosed(t *testing.T) {
+	fake := &fakePlayerSystem{players: []system.PlayerState{testPlayer()}}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0, WithPlayerManagementConfig(PlayerManagementConfig{
+		PingInterval: 10 * time.Millisecond,
+		PingTimeout:  5 * time.Millisecond,
+	}))
+
+	finished := make(chan struct{})
+
+	go func() {
+		server.managePlayers()
+		close(finished)
+	}()
+
+	server.Close()
+
+	select {
+	case <-finished:
+	case <-time.After(server.playerManagement.PingInterval * 10):
+		t.Fatal("managePlayers loop did not stop after the server was closed")
+	}
+
+	// Closing the server more than once is harmless.
+	server.Close()
+}
+
+func TestSelectFastestPlayer(t *testing.T) {
+	fake := &fakePlayerSystem{
+		players: []system.PlayerState{
+			{State: "ready", Port: 1, ID: "slo"},
+			{State: "ready", Port: 2, ID: "fst"},
+			{State: "ready", Port: 3, ID: "mid"},
+		},
+		pingLatencies: map[string]time.Duration{
+			"slo": 200 * time.Millisecond,
+			"fst": 0,
+			"mid": 50 * time.Millisecond,
+		},
+	}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0, WithPlayerManagementConfig(PlayerManagementConfig{
+		SelectFastestPlayer: true,
+	}))
+
+	player, err := server.selectAvailablePlayer(map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if player.ID != "fst" {
+		t.Errorf(
+			"expected the fastest player (fst) to be selected, got %s", player.ID,
+		)
+	}
+}
+
+func TestSelectFastestPlayerFallback(t *testing.T) {
+	fake := &fakePlayerSystem{
+		players: []system.PlayerState{
+			{State: "ready", Port: 1, ID: "aaa"},
+			{State: "ready", Port: 2, ID: "bbb"},
+		},
+		unreachable: map[string]bool{"aaa": true, "bbb": true},
+	}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0, WithPlayerManagementConfig(PlayerManagementConfig{
+		SelectFastestPlayer: true,
+		PingTimeout:         50 * time.Millisecond,
+	}))
+
+	player, err := server.selectAvailablePlayer(map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if player.ID != "aaa" {
+		t.Errorf(
+			"expected to fall back to the first available player (aaa), got %s",
+			player.ID,
+		)
+	}
+}
+
+func TestSelectFastestPlayerFallbackSkipsRejectedPlayers(t *testing.T) {
+	fake := &fakePlayerSystem{
+		players: []system.PlayerState{
+			{State: "ready", Port: 1, ID: "aaa"},
+			{State: "ready", Port: 2, ID: "bbb"},
+			{State: "ready", Port: 3, ID: "ccc"},
+		},
+		unreachable: map[string]bool{"aaa": true, "bbb": true, "ccc": true},
+	}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0, WithPlayerManagementConfig(PlayerManagementConfig{
+		SelectFastestPlayer: true,
+		PingTimeout:         50 * time.Millisecond,
+	}))
+
+	player, err := server.selectAvailablePlayer(map[string]bool{"aaa": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if player.ID != "bbb" {
+		t.Errorf(
+			"expected to fall back to the first player that wasn't rejected (bbb), "+
+				"got %s",
+			player.ID,
+		)
+	}
+}
+
+func TestPinnedPlayerIsNotReplaced(t *testing.T) {
+	pinned := system.PlayerState{State: "ready", Port: 1, ID: "pin"}
+	other := system.PlayerState{State: "ready", Port: 2, ID: "oth"}
+
+	fake := &fakePlayerSystem{
+		players:     []system.PlayerState{other, pinned},
+		unreachable: map[string]bool{"pin": true},
+	}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0, WithPlayerManagementConfig(PlayerManagementConfig{
+		PingTimeout: time.Millisecond,
+	}))
+
+	if err := server.PinPlayer("pin"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < server.playerManagement.FailedPingThreshold*2; i++ {
+		server.refreshPlayer()
+	}
+
+	if server.player.ID == "oth" {
+		t.Fatal("server switched away from the pinned player")
+	}
+
+	if _, err := server.transmitter(); server.hasPlayer() == (err != nil) {
+		t.Errorf("unexpected transmitter error state: %v", err)
+	}
+
+	server.UnpinPlayer()
+
+	for i := 0; i < server.playerManagement.FailedPingThreshold; i++ {
+		server.refreshPlayer()
+	}
+
+	if server.player.ID != "oth" {
+		t.Errorf("expected server to switch to oth after unpinning, got %#v",
+			server.player)
+	}
+}
+
+func TestPinUnknownPlayer(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	server := NewServer(0)
+
+	if err := server.PinPlayer("nop"); err == nil {
+		t.Fatal("expected an error pinning a nonexistent player")
+	}
+
+	if server.pinnedPlayerID != "" {
+		t.Errorf("expected no pinned player, got %s", server.pinnedPlayerID)
+	}
+}
+
+func TestPlayerChangedCallback(t *testing.T) {
+	first := testPlayer()
+	second := system.PlayerState{State: "ready", Port: 27279, ID: "xyz"}
+
+	fake := &fakePlayerSystem{players: []system.PlayerState{first, second}}
+	stubPlayerSystem(t, fake)
+
+	type change struct{ old, new system.PlayerState }
+	changes := []change{}
+
+	server := NewServer(0, WithPlayerChangedCallback(
+		func(old, new system.PlayerState) {
+			changes = append(changes, change{old, new})
+		},
+	))
+
+	// Finding the first player isn't a change from one player to another.
+	server.refreshPlayer()
+	server.refreshPlayer()
+
+	if len(changes) != 0 {
+		t.Fatalf("expected no player changes yet, got %#v", changes)
+	}
+
+	// Simulate the first player going offline.
+	fake.lock.Lock()
+	fake.players = []system.PlayerState{second}
+	fake.lock.Unlock()
+
+	server.refreshPlayer()
+	server.refreshPlayer()
+
+	if len(changes) != 1 {
+		t.Fatalf("expected exactly 1 player change, got %#v", changes)
+	}
+
+	if changes[0].old != first || changes[0].new != second {
+		t.Errorf(
+			"expected change from %#v to %#v, got %#v",
+			first, second, changes[0],
+		)
+	}
+}
+
+func TestPlayerOfflineJSONLog(t *testing.T) {
+	var buf bytes.Buffer
+	log.ConfigureJSON(&buf)
+	t.Cleanup(func() { log.ConfigureText(os.Stderr) })
+
+	first := testPlayer()
+	second := system.PlayerState{State: "ready", Port: 27279, ID: "xyz"}
+
+	fake := &fakePlayerSystem{players: []system.PlayerState{first, second}}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0)
+	server.setPlayer(first)
+
+	// Simulate the player going offline.
+	fake.lock.Lock()
+	fake.players = []system.PlayerState{second}
+	fake.lock.Unlock()
+
+	server.refreshPlayer()
+
+	var entry map[string]interface{}
+
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("log line is not JSON: %q: %v", scanner.Text(), err)
+		}
+
+		if line["message"] == "Player process is offline." {
+			entry = line
+		}
+	}
+
+	if entry == nil {
+		t.Fatalf("no player offline log line found in:\n%s", buf.String())
+	}
+
+	expected := map[string]interface{}{
+		"level":     "warn",
+		"player-id": first.ID,
+		"port":      float64(first.Port),
+	}
+
+	for field, value := range expected {
+		if entry[field] != value {
+			t.Errorf(
+				"expected %s to be %#v, got %#v", field, value, entry[field],
+			)
+		}
+	}
+}
+
+func TestScoreStateIsRestoredOnReplacementPlayer(t *testing.T) {
+	first := testPlayer()
+	second := system.PlayerState{State: "ready", Port: 27279, ID: "xyz"}
+
+	fake := &fakePlayerSystem{players: []system.PlayerState{first, second}}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0)
+	server.setPlayer(first)
+
+	noop := func(transmitter.PlayerTransmitter) error { return nil }
+
+	if _, err := server.updateScoreWithInput(
+		"marimba: (tempo 90) (track-vol 50) c d e",
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.withTransmitter(noop); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.restoredStates) != 0 {
+		t.Fatalf(
+			"expected no state to be restored yet, got %#v", fake.restoredStates,
+		)
+	}
+
+	server.setPlayer(second)
+
+	if err := server.withTransmitter(noop); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, restored := fake.restoredStates["xyz"]
+	if !restored {
+		t.Fatal("expected score state to be restored on the replacement player")
+	}
+
+	messages := map[string][]interface{}{}
+	for _, msg := range bundle.Messages {
+		messages[msg.Address] = msg.Arguments
+	}
+
+	// marimba is General MIDI patch 12 (zero-indexed)
+	if args, ok := messages["/track/1/midi/patch"]; !ok ||
+		args[1] != int32(12) {
+		t.Errorf("expected marimba patch message, got %#v", messages)
+	}
+
+	if args, ok := messages["/system/tempo"]; !ok || args[1] != float32(90) {
+		t.Errorf("expected tempo message with tempo 90, got %#v", messages)
+	}
+
+	if args, ok := messages["/track/1/midi/volume"]; !ok ||
+		args[1] != int32(64) {
+		t.Errorf("expected track volume message, got %#v", messages)
+	}
+
+	// Subsequent transmissions to the same player don't restore the state again.
+	delete(fake.restoredStates, "xyz")
+
+	if err := server.withTransmitter(noop); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.restoredStates) != 0 {
+		t.Errorf("expected state not to be restored again")
+	}
+}
+
+func TestSoundfontIsRestoredOnReplacementPlayer(t *testing.T) {
+	first := testPlayer()
+	second := system.PlayerState{State: "ready", Port: 27279, ID: "xyz"}
+
+	fake := &fakePlayerSystem{players: []system.PlayerState{first, second}}
+	stubPlayerSystem(t, fake)
+
+	soundfont := filepath.Join(t.TempDir(), "test.sf2")
+	if err := os.WriteFile(soundfont, []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(0, WithDryRun())
+	server.setPlayer(first)
+
+	if err := server.SetSoundfont(soundfont); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.restoredSoundfonts) != 0 {
+		t.Fatalf(
+			"expected no soundfont to be restored yet, got %#v",
+			fake.restoredSoundfonts,
+		)
+	}
+
+	server.setPlayer(second)
+
+	noop := func(transmitter.PlayerTransmitter) error { return nil }
+	if err := server.withTransmitter(noop); err != nil {
+		t.Fatal(err)
+	}
+
+	if restored := fake.restoredSoundfonts["xyz"]; restored != soundfont {
+		t.Errorf(
+			"expected soundfont %q to be restored, got %#v",
+			soundfont, fake.restoredSoundfonts,
+		)
+	}
+}
+
+func TestSetSoundfontWithBadPath(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	// The server has no player process, so if it looked for one before checking
+	// the path, it would wait for the whole FindPlayerTimeout.
+	server := NewServer(0)
+	server.playerManagement.FindPlayerTimeout = time.Minute
+
+	start := time.Now()
+
+	err := server.SetSoundfont(filepath.Join(t.TempDir(), "missing.sf2"))
+	if err == nil {
+		t.Fatal("expected an error for a soundfont that doesn't exist")
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the error right away, but it took %s", elapsed)
+	}
+
+	if server.soundfont != "" {
+		t.Errorf("expected soundfont not to be set, got %q", server.soundfont)
+	}
+}
+
+func TestAwaitAvailablePlayerBacksOff(t *testing.T) {
+	lookups := 0
+
+	stubPlayerSystem(t, &fakePlayerSystem{})
+	findAvailablePlayer = func(claimant string) (system.PlayerState, error) {
+		lookups++
+		return system.PlayerState{}, system.ErrNoPlayersAvailable
+	}
+
+	timeout := 1 * time.Second
+
+	server := NewServer(0, WithPlayerManagementConfig(PlayerManagementConfig{
+		FindPlayerTimeout: timeout,
+	}))
+
+	start := time.Now()
+
+	if _, err := server.awaitAvailablePlayer(); err == nil {
+		t.Fatal("expected an error when no players are available")
+	}
+
+	if elapsed := time.Since(start); elapsed < timeout {
+		t.Errorf("expected to keep trying for %s, gave up after %s",
+			timeout, elapsed)
+	}
+
+	// Polling every 100ms (as `util.Await` does) would result in at least 10
+	// lookups.
+	if lookups >= 10 {
+		t.Errorf("expected fewer than 10 lookups, got %d", lookups)
+	}
+
+	if lookups < 3 {
+		t.Errorf("expected at least 3 lookups, got %d", lookups)
+	}
+}
+
+func TestPlayerStatus(t *testing.T) {
+	other := system.PlayerState{State: "ready", Port: 27279, ID: "xyz"}
+
+	stubPlayerSystem(t, &fakePlayerSystem{
+		players: []system.PlayerState{testPlayer(), other},
+	})
+
+	server := testServer()
+
+	status := server.PlayerStatus()
+	if !status.LastSuccessfulPing.IsZero() {
+		t.Errorf(
+			"expected no successful ping yet, got %s", status.LastSuccessfulPing,
+		)
+	}
+
+	server.handlePingResult(nil)
+	server.handlePingResult(fmt.Errorf("ping failed"))
+
+	status = server.PlayerStatus()
+
+	if status.Player != testPlayer() {
+		t.Errorf("expected player %#v, got %#v", testPlayer(), status.Player)
+	}
+
+	if status.PoolSize != 2 {
+		t.Errorf("expected pool size 2, got %d", status.PoolSize)
+	}
+
+	if status.FailedPings != 1 {
+		t.Errorf("expected 1 failed ping, got %d", status.FailedPings)
+	}
+
+	if status.LastSuccessfulPing.IsZero() {
+		t.Error("expected the last successful ping to be recorded")
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{
+		"player", "poolSize", "lastSuccessfulPing", "failedPings",
+	} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("expected key %q in %s", key, data)
+		}
+	}
+
+	if id := decoded["player"].(map[string]interface{})["id"]; id != "abc" {
+		t.Errorf("expected player ID abc in %s", data)
+	}
+}
+
+func TestShutdownPlayer(t *testing.T) {
+	fake := &fakePlayerSystem{}
+	stubPlayerSystem(t, fake)
+
+	server := testServer()
+
+	if err := server.shutdownPlayer(); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.hasPlayer() {
+		t.Error("expected player to be unset after shutting it down")
+	}
+
+	server.setPlayer(testPlayer())
+
+	if err := server.shutdownPlayerAfter(1500 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []int32{0, 1500}
+	actual := fake.shutdowns[testPlayer().Port]
+
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf("expected shutdown offsets %v, got %v", expected, actual)
+	}
+}
+
+func TestPingJitter(t *testing.T) {
+	interval := 1 * time.Second
+
+	server := NewServer(
+		0,
+		WithPlayerManagementConfig(PlayerManagementConfig{PingInterval: interval}),
+		WithPingJitterSource(rand.NewSource(42)),
+	)
+
+	iterations := 10000
+	total := time.Duration(0)
+	distinctDelays := map[time.Duration]bool{}
+
+	for i := 0; i < iterations; i++ {
+		delay := server.nextPingDelay()
+
+		if delay < interval*9/10 || delay > interval*11/10 {
+			t.Fatalf("ping delay %s is outside of %s +/- 10%%", delay, interval)
+		}
+
+		total += delay
+		distinctDelays[delay] = true
+	}
+
+	mean := total / time.Duration(iterations)
+	if mean < interval*99/100 || mean > interval*101/100 {
+		t.Errorf("expected mean ping delay to be close to %s, got %s",
+			interval, mean)
+	}
+
+	if len(distinctDelays) < iterations/2 {
+		t.Errorf("expected ping delays to vary, got %d distinct values",
+			len(distinctDelays))
+	}
+}
+
+func TestPingJitterIsDeterministicWithSeed(t *testing.T) {
+	delays := func() []time.Duration {
+		server := NewServer(0, WithPingJitterSource(rand.NewSource(7)))
+
+		result := []time.Duration{}
+		for i := 0; i < 10; i++ {
+			result = append(result, server.nextPingDelay())
+		}
+
+		return result
+	}
+
+	first, second := delays(), delays()
+	if fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Errorf("expected the same delays, got %v and %v", first, second)
+	}
+}
+
+func TestStandbyPlayerIsPromoted(t *testing.T) {
+	primary := testPlayer()
+	standby := system.PlayerState{State: "ready", Port: 27279, ID: "xyz"}
+
+	fake := &fakePlayerSystem{players: []system.PlayerState{primary, standby}}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0)
+	server.refreshPlayer()
+
+	if server.player != primary {
+		t.Fatalf("expected player %#v, got %#v", primary, server.player)
+	}
+
+	if server.standbyPlayer != standby {
+		t.Fatalf(
+			"expected standby player %#v, got %#v", standby, server.standbyPlayer,
+		)
+	}
+
+	lookups := fake.availablePlayerLookups
+
+	// Simulate the primary player going offline.
+	fake.lock.Lock()
+	fake.players = []system.PlayerState{standby}
+	fake.lock.Unlock()
+
+	server.refreshPlayer()
+
+	if server.player != standby {
+		t.Errorf(
+			"expected standby %#v to be promoted, got %#v", standby, server.player,
+		)
+	}
+
+	if fake.availablePlayerLookups != lookups {
+		t.Errorf("expected the standby to be promoted without a lookup")
+	}
+
+	if server.standbyPlayer != (system.PlayerState{}) {
+		t.Errorf(
+			"expected no standby player to be available, got %#v",
+			server.standbyPlayer,
+		)
+	}
+}
+
+func TestStandbyPlayerClaimedByAnotherServerIsNotPromoted(t *testing.T) {
+	primary := testPlayer()
+	standby := system.PlayerState{State: "ready", Port: 27279, ID: "xyz"}
+	spare := system.PlayerState{State: "ready", Port: 27280, ID: "spr"}
+
+	fake := &fakePlayerSystem{
+		players: []system.PlayerState{primary, standby},
+	}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0)
+	server.refreshPlayer()
+
+	if server.standbyPlayer != standby {
+		t.Fatalf(
+			"expected standby player %#v, got %#v", standby, server.standbyPlayer,
+		)
+	}
+
+	// The primary goes offline, and another REPL server claims the standby
+	// before this one gets around to promoting it.
+	fake.lock.Lock()
+	fake.players = []system.PlayerState{standby, spare}
+	fake.claims[standby.ID] = "server1"
+	fake.lock.Unlock()
+
+	server.refreshPlayer()
+
+	if server.player != spare {
+		t.Errorf("expected player %#v, got %#v", spare, server.player)
+	}
+
+	if claimant := fake.claims[standby.ID]; claimant != "server1" {
+		t.Errorf("expected server1 to keep its claim, got %q", claimant)
+	}
+}
+
+func TestUnreachableStandbyPlayerIsNotPromoted(t *testing.T) {
+	primary := testPlayer()
+	standby := system.PlayerState{State: "ready", Port: 27279, ID: "xyz"}
+	spare := system.PlayerState{State: "ready", Port: 27280, ID: "spr"}
+
+	fake := &fakePlayerSystem{players: []system.PlayerState{primary, standby}}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0, WithPlayerManagementConfig(PlayerManagementConfig{
+		PingTimeout: time.Millisecond,
+	}))
+	server.refreshPlayer()
+
+	if server.standbyPlayer != standby {
+		t.Fatalf(
+			"expected standby player %#v, got %#v", standby, server.standbyPlayer,
+		)
+	}
+
+	// The primary goes offline, and the standby stops responding.
+	fake.lock.Lock()
+	fake.players = []system.PlayerState{spare, standby}
+	fake.unreachable = map[string]bool{"xyz": true}
+	fake.lock.Unlock()
+
+	server.refreshPlayer()
+
+	if server.player != spare {
+		t.Errorf("expected player %#v, got %#v", spare, server.player)
+	}
+}
+
+func TestPingLatencyHistory(t *testing.T) {
+	history := newLatencyHistory(3)
+
+	if latencies := history.all(); len(latencies) != 0 {
+		t.Errorf("expected no latencies, got %v", latencies)
+	}
+
+	history.record(1 * time.Millisecond)
+	history.record(2 * time.Millisecond)
+
+	expected := []time.Duration{1 * time.Millisecond, 2 * time.Millisecond}
+	if latencies := history.all(); fmt.Sprint(latencies) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, latencies)
+	}
+
+	for i := 3; i <= 7; i++ {
+		history.record(time.Duration(i) * time.Millisecond)
+	}
+
+	expected = []time.Duration{
+		5 * time.Millisecond, 6 * time.Millisecond, 7 * time.Millisecond,
+	}
+	if latencies := history.all(); fmt.Sprint(latencies) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, latencies)
+	}
+
+	history.reset()
+
+	if latencies := history.all(); len(latencies) != 0 {
+		t.Errorf("expected no latencies after reset, got %v", latencies)
+	}
+}
+
+func TestPingLatenciesAreRecorded(t *testing.T) {
+	fake := &fakePlayerSystem{
+		players:       []system.PlayerState{testPlayer()},
+		pingLatencies: map[string]time.Duration{"abc": 5 * time.Millisecond},
+		pingErrors:    []error{nil, fmt.Errorf("ping failed")},
+	}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0, WithPlayerManagementConfig(PlayerManagementConfig{
+		PingLatencyHistorySize: 2,
+		PingTimeout:            time.Second,
+	}))
+	server.setPlayer(testPlayer())
+
+	for i := 0; i < 4; i++ {
+		server.refreshPlayer()
+	}
+
+	// The failed ping isn't recorded, and only the 2 most recent successful
+	// pings are kept.
+	latencies := server.PingLatencies()
+	if len(latencies) != 2 {
+		t.Fatalf("expected 2 latencies, got %v", latencies)
+	}
+
+	for _, latency := range latencies {
+		if latency < 5*time.Millisecond {
+			t.Errorf("expected latency of at least 5ms, got %s", latency)
+		}
+	}
+}
+
+func TestFailedPingIsNotRetried(t *testing.T) {
+	fake := &fakePlayerSystem{
+		players:     []system.PlayerState{testPlayer()},
+		unreachable: map[string]bool{"abc": true},
+	}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0, WithPlayerManagementConfig(PlayerManagementConfig{
+		PingTimeout: 500 * time.Millisecond,
+	}))
+	server.setPlayer(testPlayer())
+
+	server.refreshPlayer()
+
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	// Retrying until the ping timeout would mean that the last attempt could
+	// start just before the timeout and then take up to the ping timeout itself.
+	if fake.pings != 1 {
+		t.Errorf("expected the player to be pinged once, got %d pings", fake.pings)
+	}
+}
+
+func TestWaitForPlayer(t *testing.T) {
+	server := testServer()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	player, err := server.WaitForPlayer(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if player != testPlayer() {
+		t.Errorf("expected player %#v, got %#v", testPlayer(), player)
+	}
+}
+
+func TestWaitForPlayerTimeout(t *testing.T) {
+	server := NewServer(0)
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(), 50*time.Millisecond,
+	)
+	defer cancel()
+
+	_, err := server.WaitForPlayer(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if server.hasPlayer() {
+		t.Errorf("expected player to remain unset, got %#v", server.player)
+	}
+}
+
+func TestWaitForPlayerCanceled(t *testing.T) {
+	server := NewServer(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := server.WaitForPlayer(ctx)
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestWithTransmitterCtx(t *testing.T) {
+	server := testServer()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var port int
+
+	if err := server.withTransmitterCtx(
+		ctx,
+		func(tr transmitter.PlayerTransmitter) error {
+			port = tr.(transmitter.OSCTransmitter).Port
+			return nil
+		},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if port != testPlayer().Port {
+		t.Errorf("expected to transmit to port %d, got %d", testPlayer().Port, port)
+	}
+}
+
+func TestWithTransmitterCtxCanceled(t *testing.T) {
+	// No player process is available, and the timeout is long enough that the
+	// test would fail if we waited for it.
+	server := NewServer(0, WithPlayerManagementConfig(PlayerManagementConfig{
+		FindPlayerTimeout: time.Minute,
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+
+	err := server.withTransmitterCtx(
+		ctx,
+		func(transmitter transmitter.PlayerTransmitter) error {
+			t.Error("expected not to transmit anything")
+			return nil
+		},
+	)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected to stop waiting promptly, waited %v", elapsed)
+	}
+}
+
+func TestUnreachableReason(t *testing.T) {
+	for _, tc := range []struct {
+		fake     *fakePlayerSystem
+		expected UnreachableReason
+	}{
+		{
+			// The player process responds to pings, but not quickly enough.
+			fake: &fakePlayerSystem{
+				pingLatencies: map[string]time.Duration{
+					testPlayer().ID: 50 * time.Millisecond,
+				},
+			},
+			expected: ReasonPingTimeout,
+		},
+		{
+			fake: &fakePlayerSystem{
+				unreachable: map[string]bool{testPlayer().ID: true},
+			},
+			expected: ReasonSendError,
+		},
+	} {
+		fake := tc.fake
+		stubPlayerSystem(t, fake)
+
+		server := NewServer(0, WithPlayerManagementConfig(PlayerManagementConfig{
+			PingTimeout:         5 * time.Millisecond,
+			FailedPingThreshold: 1,
+		}))
+		server.storePlayer(testPlayer())
+
+		fake.lock.Lock()
+		fake.players = []system.PlayerState{testPlayer()}
+		fake.lock.Unlock()
+
+		// We don't want the server to find a replacement, so that we can see
+		// that the player process was deemed unreachable.
+		server.pinnedPlayerID = testPlayer().ID
+
+		server.refreshPlayer()
+
+		if server.hasPlayer() {
+			t.Errorf("expected the player to be unset (%s)", tc.expected)
+		}
+
+		actual := server.PlayerStatus().UnreachableReason
+		if actual != tc.expected {
+			t.Errorf("expected reason %s, got %s", tc.expected, actual)
+		}
+	}
+}
+
+func TestUnreachableReasonJSON(t *testing.T) {
+	status := PlayerStatus{UnreachableReason: ReasonPingTimeout}
+
+	statusJSON, err := json.Marshal(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(
+		string(statusJSON), `"unreachableReason":"ping-timeout"`,
+	) {
+		t.Errorf("expected reason in JSON, got %s", statusJSON)
+	}
+}
+
+func TestUnresponsivePlayerIsSkipped(t *testing.T) {
+	unresponsive := system.PlayerState{State: "ready", Port: 27279, ID: "bad"}
+
+	fake := &fakePlayerSystem{
+		players:     []system.PlayerState{unresponsive, testPlayer()},
+		unreachable: map[string]bool{"bad": true},
+	}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0, WithPlayerManagementConfig(PlayerManagementConfig{
+		PingTimeout: time.Millisecond,
+	}))
+
+	server.refreshPlayer()
+
+	if server.player != testPlayer() {
+		t.Errorf("expected player %#v, got %#v", testPlayer(), server.player)
+	}
+}
+
+func TestIncompatiblePlayerIsSkipped(t *testing.T) {
+	incompatible := system.PlayerState{
+		State: "ready", Port: 27279, ID: "old", Version: "1.99.0",
+	}
+
+	compatible := testPlayer()
+	compatible.Version = generated.ClientVersion
+
+	fake := &fakePlayerSystem{
+		players: []system.PlayerState{incompatible, compatible},
+	}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0)
+
+	player, err := server.awaitAvailablePlayer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if player != compatible {
+		t.Errorf("expected player %#v, got %#v", compatible, player)
+	}
+
+	// Only the compatible player should have been pinged to confirm that it's
+	// ready.
+	if fake.pings != 1 {
+		t.Errorf("expected 1 ping, got %d", fake.pings)
+	}
+}
+
+func TestListPlayers(t *testing.T) {
+	future := time.Now().Add(time.Minute).UnixMilli()
+	past := time.Now().Add(-time.Minute).UnixMilli()
+
+	players := []system.PlayerState{
+		{State: "ready", Port: 27278, ID: "abc", Expiry: future},
+		{State: "starting", Port: 27279, ID: "def", Expiry: future},
+		{State: "ready", Port: 27280, ID: "ghi", Expiry: past},
+	}
+
+	stubPlayerSystem(t, &fakePlayerSystem{players: players})
+
+	server := NewServer(0)
+	server.setPlayer(players[0])
+
+	listings, err := server.ListPlayers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []PlayerListing{
+		{PlayerState: players[0], Bound: true},
+		{PlayerState: players[1]},
+		{PlayerState: players[2], Expired: true},
+	}
+
+	if len(listings) != len(expected) {
+		t.Fatalf("expected %d players, got %#v", len(expected), listings)
+	}
+
+	for i, listing := range listings {
+		if listing != expected[i] {
+			t.Errorf("expected %#v, got %#v", expected[i], listing)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	players := []system.PlayerState{}
+	for i := 0; i < 4; i++ {
+		players = append(players, system.PlayerState{
+			State: "ready", Port: 27280 + i, ID: fmt.Sprintf("player%d", i),
+		})
+	}
+
+	fake := &fakePlayerSystem{players: players}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0)
+	server.fillPlayerPool()
+	server.refreshPlayer()
+
+	// Each time the player process that the server is using goes offline, the
+	// server replaces it with another one.
+	for i := 0; i < 3; i++ {
+		fake.lock.Lock()
+		fake.players = fake.players[1:]
+		fake.lock.Unlock()
+
+		server.refreshPlayer()
+	}
+
+	fake.lock.Lock()
+	fake.unreachable = map[string]bool{"player3": true}
+	fake.lock.Unlock()
+
+	server.refreshPlayer()
+
+	expected := Metrics{
+		PingsSent:          5,
+		PingFailures:       1,
+		PlayerReplacements: 3,
+		PoolFills:          1,
+	}
+
+	if actual := server.Metrics(); actual != expected {
+		t.Errorf("expected metrics %#v, got %#v", expected, actual)
+	}
+}
+
+func TestRepeatedPoolFillFailures(t *testing.T) {
+	fake := &fakePlayerSystem{
+		poolFillError: fmt.Errorf("player executable not found"),
+	}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0)
+
+	for i := 1; i <= poolFillFailureThreshold; i++ {
+		if !server.PlayerStatus().PoolHealthy {
+			t.Fatalf("expected pool to be healthy after %d failed fills", i-1)
+		}
+
+		server.fillPlayerPool()
+	}
+
+	if server.PlayerStatus().PoolHealthy {
+		t.Errorf(
+			"expected pool to be unhealthy after %d failed fills",
+			poolFillFailureThreshold,
+		)
+	}
+
+	fake.poolFillError = nil
+	server.fillPlayerPool()
+
+	if !server.PlayerStatus().PoolHealthy {
+		t.Error("expected pool to be healthy after a successful fill")
+	}
+}
+
+// runPlayerManagement runs the player management loop in the background until
+// the test is finished. The loop doesn't receive any ticks, so apart from
+// finding a player process initially, it only does what it's asked to do (e.g.
+// via `RestartPlayer`).
+func runPlayerManagement(t *testing.T, server *Server) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		server.managePlayersWithTicks(nil, nil, nil, done)
+		close(finished)
+	}()
+
+	t.Cleanup(func() {
+		close(done)
+		<-finished
+	})
+}
+
+func TestRestartPlayer(t *testing.T) {
+	replacement := system.PlayerState{State: "ready", Port: 27279, ID: "xyz"}
+
+	fake := &fakePlayerSystem{
+		players: []system.PlayerState{testPlayer(), replacement},
+	}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0)
+	runPlayerManagement(t, server)
+
+	if err := server.RestartPlayer(); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.player.ID != replacement.ID {
+		t.Errorf("expected player %s, got %s", replacement.ID, server.player.ID)
+	}
+
+	shutdowns := fake.shutdowns[testPlayer().Port]
+	if len(shutdowns) != 1 {
+		t.Errorf("expected the old player to be shut down, got %v", shutdowns)
+	}
+}
+
+func TestRestartPlayerUsesTransmitterFactory(t *testing.T) {
+	replacement := system.PlayerState{State: "ready", Port: 27279, ID: "xyz"}
+
+	fake := &fakePlayerSystem{
+		players: []system.PlayerState{testPlayer(), replacement},
+	}
+	stubPlayerSystem(t, fake)
+
+	sent := []string{}
+
+	server := NewServer(0, WithTransmitterFactory(
+		func(player system.PlayerState) transmitter.PlayerTransmitter {
+			return mockTransmitter{sent: &sent}
+		},
+	))
+	runPlayerManagement(t, server)
+
+	if err := server.RestartPlayer(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"shutdown 0"}
+	if strings.Join(sent, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("expected %v to be sent, got %v", expected, sent)
+	}
+
+	if len(fake.shutdowns) != 0 {
+		t.Errorf(
+			"expected no shutdown to bypass the transmitter factory, got %v",
+			fake.shutdowns,
+		)
+	}
+}
+
+func TestRestartPlayerWithoutReplacement(t *testing.T) {
+	fake := &fakePlayerSystem{players: []system.PlayerState{testPlayer()}}
+	stubPlayerSystem(t, fake)
+
+	server := NewServer(0, WithPlayerManagementConfig(PlayerManagementConfig{
+		FindPlayerTimeout: 10 * time.Millisecond,
+	}))
+	runPlayerManagement(t, server)
+
+	if err := server.RestartPlayer(); err == nil {
+		t.Error("expected an error when no replacement player is available")
+	}
+
+	if server.hasPlayer() {
+		t.Errorf("expected no player, got %#v", server.player)
+	}
+}
+
+func TestServersClaimDifferentPlayers(t *testing.T) {
+	other := system.PlayerState{State: "ready", Port: 27279, ID: "xyz"}
+
+	fake := &fakePlayerSystem{
+		players: []system.PlayerState{testPlayer(), other},
+	}
+	stubPlayerSystem(t, fake)
+
+	servers := []*Server{NewServer(0), NewServer(0)}
+
+	for _, server := range servers {
+		server.refreshPlayer()
+	}
+
+	if servers[0].player.ID != testPlayer().ID {
+		t.Errorf(
+			"expected first server to use %s, got %s",
+			testPlayer().ID, servers[0].player.ID,
+		)
+	}
+
+	if servers[1].player.ID != other.ID {
+		t.Errorf(
+			"expected second server to use %s, got %s",
+			other.ID, servers[1].player.ID,
+		)
+	}
+
+	// When the first server stops using its player process, the claim is
+	// released.
+	servers[0].unsetPlayer(ReasonShutdown)
+
+	if _, claimed := fake.claims[testPlayer().ID]; claimed {
+		t.Errorf("expected claim on %s to be released", testPlayer().ID)
+	}
+}
+
+func TestSpawnPlayerOnDemand(t *testing.T) {
+	for _, spawnOnDemand := range []bool{false, true} {
+		fake := &fakePlayerSystem{
+			spawnedOnPoolFill: []system.PlayerState{testPlayer()},
+		}
+		stubPlayerSystem(t, fake)
+
+		server := NewServer(0, WithPlayerManagementConfig(PlayerManagementConfig{
+			FindPlayerTimeout:   10 * time.Millisecond,
+			SpawnPlayerOnDemand: spawnOnDemand,
+		}))
+
+		player, err := server.awaitAvailablePlayer()
+
+		if !spawnOnDemand {
+			if err == nil {
+				t.Errorf("expected no player to be found, got %#v", player)
+			}
+
+			if fake.poolFills != 0 {
+				t.Errorf("expected no pool fills, got %d", fake.poolFills)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if player != testPlayer() {
+			t.Errorf("expected player %#v, got %#v", testPlayer(), player)
+		}
+
+		if fake.poolFills != 1 {
+			t.Errorf("expected 1 pool fill, got %d", fake.poolFills)
+		}
+	}
+}
+
+// Run with `go test -race` to check that `server.player` is safe to access
+// from more than one routine.
+func TestConcurrentPlayerAccess(t *testing.T) {
+	server := testServer()
+	other := system.PlayerState{State: "ready", Port: 27279, ID: "oth"}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// Like the `managePlayers` loop, swapping players.
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			if i%2 == 0 {
+				server.setPlayer(other)
+			} else {
+				server.unsetPlayer(ReasonPingTimeout)
+			}
+		}
+	}()
+
+	// Like a client request, checking whether there is a player.
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			server.hasPlayer()
+		}
+	}()
+
+	wg.Wait()
+}
+
+// Run with `go test -race` to check that the state of player management is
+// safe to access from more than one routine.
+func TestConcurrentPlayerStatusAccess(t *testing.T) {
+	other := system.PlayerState{State: "ready", Port: 27279, ID: "oth"}
+
+	fake := &fakePlayerSystem{
+		players: []system.PlayerState{testPlayer(), other},
+	}
+	stubPlayerSystem(t, fake)
+
+	server := testServer()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// Like the `managePlayers` loop.
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			server.fillPlayerPool()
+			server.refreshPlayer()
+		}
+	}()
+
+	// Like client requests.
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			if err := server.PinPlayer(other.ID); err != nil {
+				t.Error(err)
+			}
+			server.transmitter()
+			server.PlayerStatus()
+			server.UnpinPlayer()
+			server.unsetPlayer(ReasonShutdown)
+		}
+	}()
+
+	wg.Wait()
+}
@@ -0,0 +1,29 @@
+package repl
+
+import (
+	"math/rand"
+)
+
+// SetRandomSeed makes everything that the server does randomly (e.g. varying
+// the time between pings to its player process) reproducible. Two servers with
+// the same seed make the same random choices, in the same order.
+//
+// The names of temporary files are still random, so that servers with the same
+// seed don't write to the same files.
+//
+// By default, the server is seeded with the time when it was started.
+func (server *Server) SetRandomSeed(seed int64) {
+	server.randomLock.Lock()
+	defer server.randomLock.Unlock()
+
+	server.random = rand.New(rand.NewSource(seed))
+}
+
+// randomFloat64 returns a random number in the range [0.0, 1.0) from the
+// server's source of randomness. (See `SetRandomSeed`.)
+func (server *Server) randomFloat64() float64 {
+	server.randomLock.Lock()
+	defer server.randomLock.Unlock()
+
+	return server.random.Float64()
+}
@@ -0,0 +1,48 @@
+package repl
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// pingSchedule returns the delays before the next several pings.
+func pingSchedule(server *Server) []time.Duration {
+	delays := []time.Duration{}
+	for i := 0; i < 10; i++ {
+		delays = append(delays, server.nextPingDelay())
+	}
+
+	return delays
+}
+
+func TestSetRandomSeed(t *testing.T) {
+	first := NewServer(0)
+	first.SetRandomSeed(1234)
+
+	second := NewServer(0)
+	// Random choices made before the seed is set don't matter.
+	pingSchedule(second)
+	second.SetRandomSeed(1234)
+
+	third := NewServer(0)
+	third.SetRandomSeed(5678)
+
+	expected := pingSchedule(first)
+
+	if actual := pingSchedule(second); fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf(
+			"expected servers with the same seed to have the same ping schedule, "+
+				"got %v and %v",
+			expected, actual,
+		)
+	}
+
+	if actual := pingSchedule(third); fmt.Sprint(actual) == fmt.Sprint(expected) {
+		t.Errorf(
+			"expected servers with different seeds to have different ping "+
+				"schedules, got %v for both",
+			expected,
+		)
+	}
+}
@@ -2,6 +2,7 @@ package repl
 
 import (
 	encjson "encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -12,6 +13,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -38,6 +40,9 @@ type nREPLRequest struct {
 type Server struct {
 	// A short, generated ID that appears in `alda ps` output.
 	id string
+	// The ID with which the server claims player processes. (See
+	// `system.NewPlayerClaimant`.)
+	claimant string
 	// The Port on which the server listens for nREPL messages from clients.
 	Port int
 	// The string of input that is built up over time as clients submit code, line
@@ -49,13 +54,156 @@ type Server struct {
 	// The current index into `score.Events`, representing where to start playing
 	// any new events that are added to the score when input is added.
 	eventIndex int
+	// When true, each new string of input is evaluated by re-parsing all of the
+	// input received so far, instead of parsing only the new input. (See
+	// `updateScoreWithInput`.)
+	fullReparse bool
+	// The number of semitones by which the score is transposed. (See
+	// `Transpose`.)
+	transposition int
 	// The server's most recent information about the player process it is using.
+	// (See `currentPlayer`.)
 	player system.PlayerState
+	// Guards `player`, which is read and written from different routines. The
+	// fields that describe the state of player management (`lastPlayer`,
+	// `standbyPlayer`, `failedPings`, `lastUnreachableReason`,
+	// `lastSuccessfulPing`, `pinnedPlayerID` and `poolHealthy`) are written by the
+	// `managePlayers` loop as well as while handling requests, and read by
+	// `PlayerStatus`, so they are guarded by it too.
+	playerLock sync.RWMutex
+	// The most recent player process that the server used. Unlike `player`, this
+	// is not unset when the player process becomes unavailable, which allows us
+	// to tell when the server switches to a different player process.
+	lastPlayer system.PlayerState
+	// The ID of the player process that the server has been transmitting the
+	// current score to. If the server switches to a different player process, we
+	// use this to tell that the new player process needs to be brought up to
+	// date. (See `restoreScoreState`.)
+	scoreStatePlayerID string
+	// Returns the transmitter to use to send messages to the provided player
+	// process. (See `WithTransmitterFactory`.)
+	newTransmitter func(system.PlayerState) transmitter.PlayerTransmitter
+	// The MIDI patch number most recently sent to the player process for each
+	// track of the current score, so that we only send program changes when
+	// necessary. (See `transmitter.PatchesSent`.)
+	patchesSent map[int32]int32
+	// The absolute path of the soundfont file that the player process should use,
+	// or "" for the player's default soundfont. (See `SetSoundfont`.)
+	soundfont string
+	// When set, this function is called whenever the server switches from one
+	// player process to a different one. (See `WithPlayerChangedCallback`.)
+	onPlayerChanged func(old, new system.PlayerState)
+	// A second player process that the server keeps track of, but doesn't use, so
+	// that it can switch to it immediately if the player process that it's using
+	// is lost. (See `refreshStandbyPlayer`.)
+	standbyPlayer system.PlayerState
+	// The number of consecutive pings to the current player process that have
+	// failed. (See `PlayerManagementConfig.FailedPingThreshold`.)
+	failedPings int
+	// Why the server most recently stopped using a player process. (See
+	// `unsetPlayer`.)
+	lastUnreachableReason UnreachableReason
+	// When the server last successfully pinged the player process it is using.
+	lastSuccessfulPing time.Time
+	// The round-trip durations of the most recent successful pings to the player
+	// process that the server is using. (See `PingLatencies`.)
+	pingLatencies *latencyHistory
+	// Counters that describe the activity of the player management loop. (See
+	// `Metrics`.)
+	metrics metricsCounter
+	// When set, the server only ever uses the player process with this ID. (See
+	// `PinPlayer`.)
+	pinnedPlayerID string
+	// The number of consecutive attempts to fill the player pool that have
+	// failed. (See `fillPlayerPool`.)
+	failedPoolFills int
+	// False when repeated attempts to fill the player pool have failed, i.e.
+	// playback is impossible until the problem is resolved.
+	poolHealthy bool
+	// Settings that control how new player processes are launched when the
+	// server fills the player pool. (See `WithPlayerLaunchConfig`.)
+	playerLaunch system.PlayerLaunchConfig
+	// Settings that control the timing of player management.
+	playerManagement PlayerManagementConfig
+	// The port on which the server answers HTTP health checks, or 0 if it
+	// doesn't. (See `WithHealthCheckPort`.)
+	healthCheckPort int
+	// The source of randomness for everything that the server does randomly,
+	// e.g. varying the time between pings. (See `SetRandomSeed`.)
+	random *rand.Rand
+	// Guards `random`, which is used from different routines.
+	randomLock sync.Mutex
+	// When true, the server doesn't need a player process; everything that it
+	// would send to one is discarded. (See `WithDryRun`.)
+	dryRun bool
+	// When true, clients can send arbitrary OSC messages to the player process.
+	// (See `WithRawOSC`.)
+	rawOSC bool
+	// When set, OSC messages for the player process (other than pings) are sent
+	// through this queue. (See `WithSendQueue`.)
+	sendQueue *transmitter.SendQueue
+	// When set, a human-readable description of every OSC message that the server
+	// sends to its player process is written here. (See `CaptureOSC`.)
+	oscCapture io.Writer
+	// When OSC messages are being captured to a file, this is the file, so that
+	// we can close it when we're done.
+	oscCaptureFile *os.File
 	// A queue onto which bdecoded messages from clients are placed in one
 	// routine. In another routine, the messages are handled synchronously, one at
 	// a time. Therefore, messages can be received asynchronously, but results are
 	// processed synchronously to avoid concurrency issues due to global state.
 	requestQueue chan nREPLRequest
+	// Serializes access to the server's state (the score, playback settings,
+	// etc.). Requests are handled while holding it (see `handleRequests`), so
+	// routines that use the state outside of handling a request, like the one
+	// started by `Watch`, must hold it too.
+	stateLock sync.Mutex
+	// Requests for the `managePlayers` loop to restart the player process. The
+	// result of each restart is sent on the provided channel. (See
+	// `RestartPlayer`.)
+	restartRequests chan chan error
+	// Closed when the server is closed, signaling background routines like the
+	// `managePlayers` loop to stop.
+	done chan struct{}
+	// Ensures that `done` is only closed once, even if `Close()` is called more
+	// than once.
+	closeOnce sync.Once
+	// Tracks the `manageStateFile` routine, so that `Close()` can wait for it to
+	// stop before removing the state file.
+	stateFileRoutine sync.WaitGroup
+	// Returns the current time. (See `WithClock`.)
+	now func() time.Time
+	// When the scores that the server has sent to the player process so far will
+	// finish playing. (See `IsPlaying`.)
+	playbackEnd time.Time
+	// Guards `playbackEnd` and `loop`, so that `IsPlaying` can be called with or
+	// without holding the state lock. `loop` is only changed while holding both
+	// locks, so it can be read while holding either one.
+	playbackLock sync.Mutex
+	// The factor by which playback is sped up or slowed down. (See
+	// `SetTempoScale`.)
+	tempoScale float64
+	// Added to the time when everything that the server plays starts playing.
+	// (See `SetClockOffset`.)
+	clockOffset time.Duration
+	// The section of the score that is playing over and over, if any. (See
+	// `Loop`.)
+	loop *loop
+	// Closed to cancel the scores that are waiting for their scheduled start
+	// time. (See `PlayAt` and `CancelScheduled`.)
+	cancelScheduled chan struct{}
+	// Guards `cancelScheduled`, which is used from different routines.
+	scheduledLock sync.Mutex
+	// The names or aliases of parts that are muted. (See `MutePart`.)
+	mutedParts map[string]bool
+	// The names or aliases of parts that are soloed. (See `SoloPart`.)
+	soloedParts map[string]bool
+	// The connections of the clients that are connected to the server, which
+	// receive notifications. (See `notifyClients`.)
+	clients map[net.Conn]struct{}
+	// Guards `clients`, and ensures that only one message at a time is written
+	// to a client connection.
+	clientsLock sync.Mutex
 }
 
 func (server *Server) stateFile() string {
@@ -81,7 +229,7 @@ func (server *Server) respond(
 
 	log.Info().Interface("data", data).Msg("Sending response.")
 
-	if err := bencode.Marshal(req.conn, data); err != nil {
+	if err := server.writeToClient(req.conn, data); err != nil {
 		log.Warn().Interface("data", data).Msg("Failed to send response.")
 	}
 }
@@ -109,7 +257,30 @@ func (server *Server) respondError(
 	server.respondErrors(req, []string{problem}, data)
 }
 
-func (server *Server) resetState() error {
+// problemsWithInput returns the problems to report to the client when
+// evaluating input fails. When the input has several syntax errors, each one is
+// reported as a separate problem.
+func problemsWithInput(err error) []string {
+	parseErrors, ok := err.(parser.ParseErrors)
+	if !ok {
+		return []string{err.Error()}
+	}
+
+	problems := []string{}
+	for _, parseError := range parseErrors {
+		problems = append(problems, parseError.Error())
+	}
+
+	return problems
+}
+
+// Reset clears the score that the server has accumulated from the input that it
+// has received so far, so that the next input starts a new score. The player
+// process is shut down as well, so that nothing from the old score keeps
+// playing.
+func (server *Server) Reset() error {
+	server.stopLoop()
+
 	if server.hasPlayer() {
 		if err := server.shutdownPlayer(); err != nil {
 			return err
@@ -119,6 +290,11 @@ func (server *Server) resetState() error {
 	server.input = ""
 	server.score = model.NewScore()
 	server.eventIndex = 0
+	server.fullReparse = false
+	server.transposition = 0
+	server.scoreStatePlayerID = ""
+	server.patchesSent = map[int32]int32{}
+	server.clearPlaybackEnd()
 
 	return nil
 }
@@ -136,14 +312,122 @@ func generateId() string {
 	return string(b)
 }
 
+// ServerOption is a function that customizes a Server instance.
+type ServerOption func(*Server)
+
+// WithPlayerManagementConfig overrides the default timing of the way that the
+// server finds, monitors and replaces player processes.
+func WithPlayerManagementConfig(config PlayerManagementConfig) ServerOption {
+	return func(server *Server) {
+		server.playerManagement = config.withDefaults()
+	}
+}
+
+// WithPingJitterSource overrides the source of randomness that the server uses
+// to vary the time between pings to its player process. This is useful in
+// tests, where a deterministic source can be used.
+//
+// NB: The same source is used for everything else that the server does
+// randomly. (See `SetRandomSeed`.)
+func WithPingJitterSource(source rand.Source) ServerOption {
+	return func(server *Server) {
+		server.random = rand.New(source)
+	}
+}
+
+// WithPlayerChangedCallback registers a function to be called whenever the
+// server switches from one player process to a different one, e.g. because the
+// player process it was using became unreachable. Because each player process
+// has its own MIDI state (instruments, tempo, etc.), this is a signal that the
+// state of the old player process has been lost.
+//
+// The callback is called synchronously from the `managePlayers` loop, so it
+// should return quickly.
+func WithPlayerChangedCallback(
+	callback func(old, new system.PlayerState),
+) ServerOption {
+	return func(server *Server) {
+		server.onPlayerChanged = callback
+	}
+}
+
+// WithPlayerLaunchConfig customizes how the server launches new player
+// processes when it fills the player pool, e.g. to use an `alda-player`
+// executable that isn't on the PATH.
+func WithPlayerLaunchConfig(config system.PlayerLaunchConfig) ServerOption {
+	return func(server *Server) {
+		server.playerLaunch = config
+	}
+}
+
+// WithSendQueue makes the server send everything that it sends to its player
+// process through a transmitter.SendQueue with the provided capacity and
+// overflow mode, so that playback can't flood a player process that has fallen
+// behind.
+//
+// Pings are not queued, so that the `managePlayers` loop can tell whether the
+// player process is reachable even while a backlog of other messages is
+// waiting to be sent.
+func WithSendQueue(capacity int, mode transmitter.OverflowMode) ServerOption {
+	return func(server *Server) {
+		server.sendQueue = transmitter.NewSendQueue(capacity, mode)
+	}
+}
+
+// WithDryRun makes the server discard everything that it would otherwise send
+// to a player process, so that it doesn't need a player process at all. This is
+// useful for exercising the server's request handlers in tests.
+func WithDryRun() ServerOption {
+	return func(server *Server) {
+		server.dryRun = true
+	}
+}
+
+// WithTransmitterFactory overrides the way that the server sends messages to
+// its player process. The provided function is called with the player process
+// that the server is using (or an empty PlayerState in a dry run), and returns
+// the transmitter to use. By default, the server sends OSC messages.
+//
+// NB: Bringing a replacement player process up to date with the score (see
+// `restoreScoreState`) always uses OSC, since it's specific to player
+// processes.
+func WithTransmitterFactory(
+	newTransmitter func(system.PlayerState) transmitter.PlayerTransmitter,
+) ServerOption {
+	return func(server *Server) {
+		server.newTransmitter = newTransmitter
+	}
+}
+
 // NewServer returns an initialized instance of an Alda REPL server.
-func NewServer(port int) *Server {
+func NewServer(port int, opts ...ServerOption) *Server {
 	server := &Server{
-		id:           generateId(),
-		Port:         port,
-		requestQueue: make(chan nREPLRequest),
+		id:               generateId(),
+		claimant:         system.NewPlayerClaimant(),
+		Port:             port,
+		requestQueue:     make(chan nREPLRequest),
+		done:             make(chan struct{}),
+		restartRequests:  make(chan chan error),
+		poolHealthy:      true,
+		playerManagement: PlayerManagementConfig{}.withDefaults(),
+		random:           rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:              time.Now,
+		tempoScale:       1,
+	}
+
+	for _, opt := range opts {
+		opt(server)
+	}
+
+	if server.newTransmitter == nil {
+		server.newTransmitter = server.oscTransmitter
 	}
-	server.resetState()
+
+	server.pingLatencies = newLatencyHistory(
+		server.playerManagement.PingLatencyHistorySize,
+	)
+
+	server.Reset()
 	return server
 }
 
@@ -201,9 +485,17 @@ func (server *Server) manageStateFile() {
 	// update the last modified time without re-writing the file.
 	server.writeStateFile()
 
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
 	for {
 		server.touchStateFile()
-		time.Sleep(10 * time.Second)
+
+		select {
+		case <-server.done:
+			return
+		case <-ticker.C:
+		}
 	}
 }
 
@@ -217,8 +509,22 @@ func (server *Server) removeStateFile() {
 
 // Close cleans up after a server is done serving.
 //
-// This includes actions like removing the nREPL port file.
+// This includes actions like removing the nREPL port file and stopping the
+// `managePlayers` loop.
 func (server *Server) Close() {
+	server.closeOnce.Do(func() { close(server.done) })
+	server.stateFileRoutine.Wait()
+
+	server.stateLock.Lock()
+	server.stopLoop()
+	server.stateLock.Unlock()
+
+	server.CaptureOSC(nil)
+
+	if server.sendQueue != nil {
+		server.sendQueue.Close()
+	}
+
 	server.removePortFile()
 	server.removeStateFile()
 }
@@ -230,24 +536,42 @@ func (server *Server) Close() {
 // NOTE: The caller is responsible for calling `Close()` on the server instance
 // when it is no longer needed. Otherwise, resources like the .alda-nrepl-port
 // file will not be cleaned up.
-func RunServer(port int) (*Server, error) {
-	server := NewServer(port)
+func RunServer(port int, opts ...ServerOption) (*Server, error) {
+	server := NewServer(port, opts...)
+
+	if err := server.playerManagement.Validate(); err != nil {
+		return nil, err
+	}
 
 	l, err := net.Listen("tcp", "localhost:"+strconv.Itoa(server.Port))
 	if err != nil {
 		return nil, err
 	}
 
+	if err := server.serveHealthChecks(); err != nil {
+		l.Close()
+		return nil, err
+	}
+
 	// This writes an .alda-nrepl-port file, which gets cleaned up when `Close()`
 	// is invoked.
 	server.writePortFile()
 
 	// Continuously writes a state file so that this REPL server can be included
 	// in the output of `alda ps`. This file also gets cleaned up by `Close()`.
-	go server.manageStateFile()
+	server.stateFileRoutine.Add(1)
+	go func() {
+		defer server.stateFileRoutine.Done()
+		server.manageStateFile()
+	}()
 
 	// See repl/player_management.go
-	go server.managePlayers()
+	//
+	// In a dry run, nothing is sent to a player process, so there is no need to
+	// find one (or to start any).
+	if !server.dryRun {
+		go server.managePlayers()
+	}
 
 	go server.listen(l)
 	go server.handleRequests()
@@ -275,11 +599,17 @@ func (server *Server) listen(l net.Listener) {
 
 	for {
 		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+
 		if err != nil {
 			log.Warn().Int("port", server.Port).Msg("Failed to accept connection.")
 			continue
 		}
 
+		server.addClient(conn)
+
 		// We do this part (receiving and bdecoding bytes from the connection) in a
 		// goroutine so that we can avoid blocking and immediately start waiting for
 		// the next connection. That way, the message receiving part can be
@@ -287,6 +617,7 @@ func (server *Server) listen(l net.Listener) {
 		// synchronously by putting them onto a queue.
 		go func() {
 			defer conn.Close()
+			defer server.removeClient(conn)
 
 			for {
 				decoded, err := bencode.Decode(conn)
@@ -358,6 +689,30 @@ func init() {
 	describeResponse["ops"] = describedOps
 }
 
+// partOp returns the handler of an op like "mute", which does something with
+// the part whose name or alias is provided as the "part" parameter.
+func partOp(
+	handle func(server *Server, name string) error,
+) func(*Server, nREPLRequest) {
+	return func(server *Server, req nREPLRequest) {
+		errors := validateRequest(
+			req.msg,
+			requestFieldSpec{name: "part", valueType: typeString, required: true},
+		)
+		if len(errors) > 0 {
+			server.respondErrors(req, errors, nil)
+			return
+		}
+
+		if err := handle(server, req.msg["part"].(string)); err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
+
+		server.respondDone(req, nil)
+	}
+}
+
 var ops = map[string]func(*Server, nREPLRequest){
 	// NOTE: This is mostly for general nREPL protocol adherence. Sessions don't
 	// have much meaning to an Alda REPL server. For now, we just fake it by
@@ -368,6 +723,41 @@ var ops = map[string]func(*Server, nREPLRequest){
 		})
 	},
 
+	"clock-offset": func(server *Server, req nREPLRequest) {
+		errors := validateRequest(
+			req.msg,
+			requestFieldSpec{name: "offset-ms", valueType: typeInt64, required: true},
+		)
+		if len(errors) > 0 {
+			server.respondErrors(req, errors, nil)
+			return
+		}
+
+		offsetMs := req.msg["offset-ms"].(int64)
+		err := server.SetClockOffset(time.Duration(offsetMs) * time.Millisecond)
+		if err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
+
+		server.respondDone(req, nil)
+	},
+
+	"complete-instrument": func(server *Server, req nREPLRequest) {
+		errors := validateRequest(
+			req.msg,
+			requestFieldSpec{name: "prefix", valueType: typeString, required: true},
+		)
+		if len(errors) > 0 {
+			server.respondErrors(req, errors, nil)
+			return
+		}
+
+		server.respondDone(req, map[string]interface{}{
+			"instruments": model.MatchInstruments(req.msg["prefix"].(string)),
+		})
+	},
+
 	// NOTE: This is for nREPL protocol adherence.
 	"describe": func(server *Server, req nREPLRequest) {
 		server.respondDone(req, describeResponse)
@@ -393,7 +783,7 @@ var ops = map[string]func(*Server, nREPLRequest){
 		input := req.msg["code"].(string)
 
 		if err := server.evalAndPlay(input); err != nil {
-			server.respondError(req, err.Error(), nil)
+			server.respondErrors(req, problemsWithInput(err), nil)
 			return
 		}
 
@@ -401,6 +791,25 @@ var ops = map[string]func(*Server, nREPLRequest){
 	},
 
 	"export": func(server *Server, req nREPLRequest) {
+		errors := validateRequest(
+			req.msg,
+			requestFieldSpec{name: "file", valueType: typeString, required: false},
+		)
+		if len(errors) > 0 {
+			server.respondErrors(req, errors, nil)
+			return
+		}
+
+		if file, hit := req.msg["file"]; hit {
+			if err := server.exportToFile(file.(string)); err != nil {
+				server.respondError(req, err.Error(), nil)
+				return
+			}
+
+			server.respondDone(req, nil)
+			return
+		}
+
 		binaryData, err := server.export()
 		if err != nil {
 			server.respondError(req, err.Error(), nil)
@@ -428,7 +837,7 @@ var ops = map[string]func(*Server, nREPLRequest){
 
 		input := req.msg["code"].(string)
 
-		if err := server.load(input); err != nil {
+		if err := server.load(input, 0); err != nil {
 			server.respondError(req, err.Error(), nil)
 			return
 		}
@@ -436,8 +845,17 @@ var ops = map[string]func(*Server, nREPLRequest){
 		server.respondDone(req, nil)
 	},
 
-	"new-score": func(server *Server, req nREPLRequest) {
-		if err := server.resetState(); err != nil {
+	"load-instruments": func(server *Server, req nREPLRequest) {
+		errors := validateRequest(
+			req.msg,
+			requestFieldSpec{name: "path", valueType: typeString, required: true},
+		)
+		if len(errors) > 0 {
+			server.respondErrors(req, errors, nil)
+			return
+		}
+
+		if err := server.LoadInstruments(req.msg["path"].(string)); err != nil {
 			server.respondError(req, err.Error(), nil)
 			return
 		}
@@ -445,26 +863,17 @@ var ops = map[string]func(*Server, nREPLRequest){
 		server.respondDone(req, nil)
 	},
 
-	"replay": func(server *Server, req nREPLRequest) {
-		transmitOpts := []transmitter.TransmissionOption{}
-
-		from, hit := req.msg["from"]
-		if hit {
-			switch f := from.(type) {
-			case string:
-				transmitOpts = append(transmitOpts, transmitter.TransmitFrom(f))
-			}
-		}
-
-		to, hit := req.msg["to"]
-		if hit {
-			switch t := to.(type) {
-			case string:
-				transmitOpts = append(transmitOpts, transmitter.TransmitTo(t))
-			}
+	"loglevel": func(server *Server, req nREPLRequest) {
+		errors := validateRequest(
+			req.msg,
+			requestFieldSpec{name: "level", valueType: typeString, required: true},
+		)
+		if len(errors) > 0 {
+			server.respondErrors(req, errors, nil)
+			return
 		}
 
-		if err := server.replay(transmitOpts...); err != nil {
+		if err := log.SetLevel(req.msg["level"].(string)); err != nil {
 			server.respondError(req, err.Error(), nil)
 			return
 		}
@@ -472,116 +881,493 @@ var ops = map[string]func(*Server, nREPLRequest){
 		server.respondDone(req, nil)
 	},
 
-	"score-data": func(server *Server, req nREPLRequest) {
-		server.respondDone(req, map[string]interface{}{
-			"data": server.score.JSON().String(),
-		})
-	},
-
-	"score-events": func(server *Server, req nREPLRequest) {
-		ast, err := parser.ParseString(server.input)
-		if err != nil {
-			server.respondError(req, err.Error(), nil)
+	"loop": func(server *Server, req nREPLRequest) {
+		errors := validateRequest(
+			req.msg,
+			requestFieldSpec{name: "from", valueType: typeString},
+			requestFieldSpec{name: "to", valueType: typeString},
+		)
+		if len(errors) > 0 {
+			server.respondErrors(req, errors, nil)
 			return
 		}
 
-		scoreUpdates, err := ast.Updates()
-		if err != nil {
+		from, _ := req.msg["from"].(string)
+		to, _ := req.msg["to"].(string)
+
+		if err := server.Loop(from, to); err != nil {
 			server.respondError(req, err.Error(), nil)
 			return
 		}
 
-		updates := json.Array()
-		for _, update := range scoreUpdates {
-			updates.ArrayAppend(update.JSON())
-		}
-
-		server.respondDone(req, map[string]interface{}{"events": updates.String()})
+		server.respondDone(req, nil)
 	},
 
-	"score-ast": func(server *Server, req nREPLRequest) {
-		ast, err := parser.ParseString(server.input)
-		if err != nil {
+	"mute": partOp(func(server *Server, name string) error {
+		return server.MutePart(name)
+	}),
+
+	"new-score": func(server *Server, req nREPLRequest) {
+		if err := server.Reset(); err != nil {
 			server.respondError(req, err.Error(), nil)
 			return
 		}
 
-		server.respondDone(req, map[string]interface{}{"ast": ast.JSON().String()})
+		server.respondDone(req, nil)
 	},
 
-	"score-text": func(server *Server, req nREPLRequest) {
-		server.respondDone(req, map[string]interface{}{"text": server.input})
-	},
+	"osc": func(server *Server, req nREPLRequest) {
+		errors := validateRequest(
+			req.msg,
+			requestFieldSpec{name: "address", valueType: typeString, required: true},
+			requestFieldSpec{name: "args", valueType: typeString},
+		)
+		if len(errors) > 0 {
+			server.respondErrors(req, errors, nil)
+			return
+		}
 
-	"stopall": func(server *Server, req nREPLRequest) {
-		players := []system.PlayerState{}
-		knownPlayers, _ := system.ReadPlayerStates()
-		players = append(players, knownPlayers...)
+		argsString, _ := req.msg["args"].(string)
+		args, err := parseOSCArgs(argsString)
+		if err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
 
-		for _, player := range players {
-			transmitter := transmitter.OSCTransmitter{Port: player.Port}
-			if err := transmitter.TransmitStopMessage(); err != nil {
-				log.Warn().
-					Interface("player", player).
-					Err(err).
-					Msg("Failed to send \"stop\" message to player process.")
-			} else {
-				log.Info().
-					Interface("player", player).
-					Msg("Sent \"stop\" message to player process.")
-			}
+		if err := server.SendOSC(req.msg["address"].(string), args...); err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
 		}
 
 		server.respondDone(req, nil)
 	},
 
-	"stop": func(server *Server, req nREPLRequest) {
-		if err := server.withTransmitter(
-			func(transmitter transmitter.OSCTransmitter) error {
-				log.Info().
-					Interface("player", server.player).
-					Msg("Sending \"stop\" message to player process.")
-				return transmitter.TransmitStopMessage()
-			},
-		); err != nil {
-			server.respondError(req, err.Error(), nil)
+	"parse": func(server *Server, req nREPLRequest) {
+		errors := validateRequest(
+			req.msg,
+			requestFieldSpec{name: "code", valueType: typeString, required: true},
+		)
+		if len(errors) > 0 {
+			server.respondErrors(req, errors, nil)
 			return
 		}
 
-		server.respondDone(req, nil)
+		ast, err := parseToJSON(req.msg["code"].(string))
+		if err != nil {
+			server.respondErrors(req, problemsWithInput(err), nil)
+			return
+		}
+
+		server.respondDone(req, map[string]interface{}{"ast": ast})
 	},
-}
 
-// Runs in a loop, handling requests from the queue as they come in in a
-// synchronous fashion, one at a time.
-func (server *Server) handleRequests() {
-	for req := range server.requestQueue {
+	"pin-player": func(server *Server, req nREPLRequest) {
 		errors := validateRequest(
 			req.msg,
-			requestFieldSpec{name: "op", valueType: typeString, required: true},
+			requestFieldSpec{name: "player-id", valueType: typeString, required: true},
 		)
 		if len(errors) > 0 {
 			server.respondErrors(req, errors, nil)
-			continue
+			return
 		}
 
-		op := req.msg["op"].(string)
+		if err := server.PinPlayer(req.msg["player-id"].(string)); err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
 
-		handler, supported := ops[op]
-		if !supported {
-			server.respond(req, []string{"done", "error", "unknown-op"}, nil)
-			continue
+		server.respondDone(req, nil)
+	},
+
+	"player-clock": func(server *Server, req nREPLRequest) {
+		clock, err := server.PlayerClock()
+		if err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
 		}
 
-		handler(server, req)
-	}
-}
+		server.respondDone(
+			req, map[string]interface{}{"clock": clock.UnixMilli()},
+		)
+	},
+
+	"player-latency": func(server *Server, req nREPLRequest) {
+		latency, err := server.PlayerLatency()
+		if err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
+
+		server.respondDone(
+			req, map[string]interface{}{"latency": latency.Microseconds()},
+		)
+	},
+
+	"player-status": func(server *Server, req nREPLRequest) {
+		status, err := encjson.Marshal(server.PlayerStatus())
+		if err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
+
+		server.respondDone(req, map[string]interface{}{"data": string(status)})
+	},
+
+	"players": func(server *Server, req nREPLRequest) {
+		players, err := server.ListPlayers()
+		if err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
+
+		playersJSON, err := encjson.Marshal(players)
+		if err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
+
+		server.respondDone(req, map[string]interface{}{"data": string(playersJSON)})
+	},
+
+	"replay": func(server *Server, req nREPLRequest) {
+		transmitOpts := []transmitter.TransmissionOption{}
+
+		from, hit := req.msg["from"]
+		if hit {
+			switch f := from.(type) {
+			case string:
+				transmitOpts = append(transmitOpts, transmitter.TransmitFrom(f))
+			}
+		}
+
+		to, hit := req.msg["to"]
+		if hit {
+			switch t := to.(type) {
+			case string:
+				transmitOpts = append(transmitOpts, transmitter.TransmitTo(t))
+			}
+		}
+
+		if err := server.replay(transmitOpts...); err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
+
+		server.respondDone(req, nil)
+	},
+
+	"restart-player": func(server *Server, req nREPLRequest) {
+		if err := server.RestartPlayer(); err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
+
+		server.respondDone(req, nil)
+	},
+
+	"score-data": func(server *Server, req nREPLRequest) {
+		server.respondDone(req, map[string]interface{}{
+			"data": server.score.JSON().String(),
+		})
+	},
+
+	"score-events": func(server *Server, req nREPLRequest) {
+		ast, err := parser.ParseString(server.input)
+		if err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
+
+		scoreUpdates, err := ast.Updates()
+		if err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
+
+		updates := json.Array()
+		for _, update := range scoreUpdates {
+			updates.ArrayAppend(update.JSON())
+		}
+
+		server.respondDone(req, map[string]interface{}{"events": updates.String()})
+	},
+
+	"score-info": func(server *Server, req nREPLRequest) {
+		server.respondDone(req, map[string]interface{}{
+			"info": scoreInfoJSON(server.score).String(),
+		})
+	},
+
+	"score-ast": func(server *Server, req nREPLRequest) {
+		ast, err := parser.ParseString(server.input)
+		if err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
+
+		server.respondDone(req, map[string]interface{}{"ast": ast.JSON().String()})
+	},
+
+	"score-rendered": func(server *Server, req nREPLRequest) {
+		server.respondDone(
+			req, map[string]interface{}{"text": model.Render(server.score)},
+		)
+	},
+
+	"score-text": func(server *Server, req nREPLRequest) {
+		server.respondDone(req, map[string]interface{}{"text": server.input})
+	},
+
+	"solo": partOp(func(server *Server, name string) error {
+		return server.SoloPart(name)
+	}),
+
+	"start-osc-capture": func(server *Server, req nREPLRequest) {
+		errors := validateRequest(
+			req.msg,
+			requestFieldSpec{name: "file", valueType: typeString, required: false},
+		)
+		if len(errors) > 0 {
+			server.respondErrors(req, errors, nil)
+			return
+		}
+
+		file, hit := req.msg["file"]
+		if !hit {
+			server.CaptureOSC(os.Stderr)
+			server.respondDone(req, nil)
+			return
+		}
+
+		if err := server.captureOSCToFile(file.(string)); err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
+
+		server.respondDone(req, nil)
+	},
+
+	"stopall": func(server *Server, req nREPLRequest) {
+		players := []system.PlayerState{}
+		knownPlayers, _ := system.ReadPlayerStates()
+		players = append(players, knownPlayers...)
+
+		for _, player := range players {
+			transmitter := transmitter.OSCTransmitter{
+				Host: player.Host, Port: player.Port,
+			}
+			if err := transmitter.TransmitStopMessage(); err != nil {
+				log.Warn().
+					Interface("player", player).
+					Err(err).
+					Msg("Failed to send \"stop\" message to player process.")
+			} else {
+				log.Info().
+					Interface("player", player).
+					Msg("Sent \"stop\" message to player process.")
+			}
+		}
+
+		server.respondDone(req, nil)
+	},
+
+	"stop": func(server *Server, req nREPLRequest) {
+		if err := server.Stop(); err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
+
+		server.respondDone(req, nil)
+	},
+
+	"stop-osc-capture": func(server *Server, req nREPLRequest) {
+		server.CaptureOSC(nil)
+		server.respondDone(req, nil)
+	},
+
+	"tempo-scale": func(server *Server, req nREPLRequest) {
+		errors := validateRequest(
+			req.msg,
+			requestFieldSpec{name: "factor", valueType: typeString, required: true},
+		)
+		if len(errors) > 0 {
+			server.respondErrors(req, errors, nil)
+			return
+		}
+
+		// bencode doesn't have floats, so the factor is sent as a string.
+		factor, err := strconv.ParseFloat(req.msg["factor"].(string), 64)
+		if err != nil {
+			server.respondError(req, fmt.Sprintf("invalid factor: %v", err), nil)
+			return
+		}
+
+		if err := server.SetTempoScale(factor); err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
+
+		server.respondDone(req, nil)
+	},
+
+	"transpose": func(server *Server, req nREPLRequest) {
+		errors := validateRequest(
+			req.msg,
+			requestFieldSpec{name: "semitones", valueType: typeInt64, required: true},
+		)
+		if len(errors) > 0 {
+			server.respondErrors(req, errors, nil)
+			return
+		}
+
+		semitones := req.msg["semitones"].(int64)
+
+		if err := server.Transpose(int(semitones)); err != nil {
+			server.respondError(req, err.Error(), nil)
+			return
+		}
+
+		server.respondDone(req, nil)
+	},
+
+	"unmute": partOp(func(server *Server, name string) error {
+		server.UnmutePart(name)
+		return nil
+	}),
+
+	"unpin-player": func(server *Server, req nREPLRequest) {
+		server.UnpinPlayer()
+		server.respondDone(req, nil)
+	},
+
+	"unsolo": partOp(func(server *Server, name string) error {
+		server.UnsoloPart(name)
+		return nil
+	}),
+}
+
+// Runs in a loop, handling requests from the queue as they come in in a
+// synchronous fashion, one at a time.
+func (server *Server) handleRequests() {
+	for req := range server.requestQueue {
+		errors := validateRequest(
+			req.msg,
+			requestFieldSpec{name: "op", valueType: typeString, required: true},
+		)
+		if len(errors) > 0 {
+			server.respondErrors(req, errors, nil)
+			continue
+		}
+
+		op := req.msg["op"].(string)
+
+		handler, supported := ops[op]
+		if !supported {
+			server.respond(req, []string{"done", "error", "unknown-op"}, nil)
+			continue
+		}
+
+		server.stateLock.Lock()
+		handler(server, req)
+		server.stateLock.Unlock()
+	}
+}
+
+// A scoreInput is a string of input to be added to the session's score.
+type scoreInput struct {
+	text string
+	// The file that the input was read from, or "" if it wasn't read from a
+	// file. Relative imports in the input are resolved against the directory of
+	// this file.
+	filename string
+	// The input's AST, if the input has already been parsed.
+	ast *parser.ASTNode
+}
+
+// parse returns the AST of the input, parsing it first if necessary.
+func (input scoreInput) parse() (parser.ASTNode, error) {
+	if input.ast != nil {
+		return *input.ast, nil
+	}
+
+	return parser.Parse(input.filename, input.text)
+}
+
+// updateScore parses `input` and applies the resulting updates to `score`.
+func updateScore(score *model.Score, input scoreInput) error {
+	ast, err := input.parse()
+	if err != nil {
+		return err
+	}
+
+	scoreUpdates, err := ast.Updates()
+	if err != nil {
+		return err
+	}
+
+	return score.Update(scoreUpdates...)
+}
+
+// reparseWithInput replaces the server's score with a new score built from all
+// of the input received so far, followed by `input`. This is slower than
+// updating the existing score with `input`, but it's necessary when the input
+// received so far affects how `input` is parsed. (See
+// `parser.ChangesLaterScanning`.)
+//
+// `partOffsets` are offsets of the parts in the current score. The same offsets
+// are returned, but for the corresponding parts in the new score.
+//
+// NB: Because the entire input is parsed, the line numbers in any errors are
+// relative to the beginning of the session's input, not `input`. For the same
+// reason, relative imports anywhere in the session's input are resolved against
+// the directory of the file that `input` came from, if any.
+func (server *Server) reparseWithInput(
+	input scoreInput, partOffsets map[*model.Part]float64,
+) (map[*model.Part]float64, error) {
+	score := model.NewScore()
+	err := updateScore(
+		score, scoreInput{text: server.input + input.text, filename: input.filename},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := score.Transpose(server.transposition); err != nil {
+		return nil, err
+	}
+
+	// The parts of the new score are added in the same order as they were added
+	// to the current score, so each part's counterpart is at the same index.
+	newPartOffsets := map[*model.Part]float64{}
+	for i, part := range server.score.Parts {
+		if i < len(score.Parts) {
+			newPartOffsets[score.Parts[i]] = partOffsets[part]
+		}
+	}
+
+	server.score = score
+
+	return newPartOffsets, nil
+}
 
 // Parses a string of `input`, updates the server's score and related state, and
 // returns a list of transmission options that would make it so that we're
 // transmitting only the new events that resulted from this string of input.
+//
+// Usually, only the new input is parsed, and the resulting updates are applied
+// to the existing score. Once the session's input includes a directive that
+// changes how later input is parsed, the score is rebuilt from all of the input
+// instead. (See `reparseWithInput`.)
 func (server *Server) updateScoreWithInput(
 	input string,
+) ([]transmitter.TransmissionOption, error) {
+	return server.updateScoreWith(scoreInput{text: input})
+}
+
+// updateScoreWith is like `updateScoreWithInput`, but the input can come from a
+// file and it can be parsed already.
+func (server *Server) updateScoreWith(
+	input scoreInput,
 ) ([]transmitter.TransmissionOption, error) {
 	// Take note of the current offsets of all parts in the score, for the purpose
 	// of synchronization. (See below where we use the transmitter.SyncOffsets
@@ -592,77 +1378,248 @@ func (server *Server) updateScoreWithInput(
 	// playing from when we want to play the new events.
 	eventIndex := server.eventIndex
 
+	if server.fullReparse {
+		offsets, err := server.reparseWithInput(input, partOffsets)
+		if err != nil {
+			return nil, err
+		}
+
+		partOffsets = offsets
+	} else if err := updateScore(server.score, input); err != nil {
+		return nil, err
+	} else if err := server.score.TransposeFrom(
+		eventIndex, server.transposition,
+	); err != nil {
+		return nil, err
+	}
+
+	if parser.ChangesLaterScanning(input.text) {
+		server.fullReparse = true
+	}
+
+	// Add the provided `input` to our total string of input representing the
+	// entire score.
+	server.input += strings.TrimSpace(input.text) + "\n"
+
+	// Update the starting index so that the next invocation of `evalAndPlay` for
+	// this same score will result in only playing newly added events.
+	server.eventIndex = len(server.score.Events)
+
+	return []transmitter.TransmissionOption{
+		// Transmit only the new events, i.e. events added as a result of parsing
+		// the provided `input` and applying the resulting updates to the score.
+		transmitter.TransmitFromIndex(eventIndex),
+		// The previous offset of each part is subtracted from any new events for
+		// that part. The effect is that we "synchronize" that part with the events
+		// that we already sent to the player. For example, if a client submits the
+		// following code:
+		//
+		//   piano: c d e f
+		//
+		// Followed by (sometime before the 4 notes above finish playing):
+		//
+		//   piano: g a b > c
+		//
+		// Then the notes `c d e f g a b > c` will be played in time.
+		transmitter.SyncOffsets(partOffsets),
+		// Program changes are only sent for tracks whose instruments the player
+		// process doesn't already have.
+		transmitter.PatchesSent(server.patchesSent),
+	}, nil
+}
+
+func (server *Server) evalAndPlay(
+	input string, additionalTransmitOpts ...transmitter.TransmissionOption,
+) error {
+	return server.evalAndPlayInput(
+		scoreInput{text: input}, additionalTransmitOpts...,
+	)
+}
+
+// evalAndPlayInput is like `evalAndPlay`, but the input can come from a file
+// and it can be parsed already.
+func (server *Server) evalAndPlayInput(
+	input scoreInput, additionalTransmitOpts ...transmitter.TransmissionOption,
+) error {
+	playbackOpts := server.playbackOpts()
+
+	return server.withTransmitter(
+		func(transmitter transmitter.PlayerTransmitter) error {
+			eventIndex := server.eventIndex
+			partOffsets := server.score.PartOffsets()
+
+			transmitOpts, err := server.updateScoreWith(input)
+			if err != nil {
+				return err
+			}
+
+			log.Info().
+				Interface("player", server.currentPlayer()).
+				Msg("Sending OSC messages to player.")
+
+			transmitOpts = append(transmitOpts, playbackOpts...)
+
+			err = transmitter.TransmitScore(
+				server.score,
+				(append(transmitOpts, additionalTransmitOpts...))...,
+			)
+			if err != nil {
+				// We don't know which messages (if any) made it to the player process.
+				server.patchesSent = map[int32]int32{}
+				return err
+			}
+
+			server.recordPlayback(
+				playbackLength(server.score.Events[eventIndex:], partOffsets),
+			)
+
+			return nil
+		},
+	)
+}
+
+// PlayString plays a snippet of Alda code as a score of its own, without adding
+// it to the REPL session's score.
+//
+// The snippet is parsed before anything is sent to the player process, so if
+// it can't be parsed, the error is returned right away.
+//
+// NB: The snippet is played on the same player process as the session's score,
+// so its instruments may take the place of the session's instruments on the
+// player process's MIDI channels.
+func (server *Server) PlayString(input string) error {
+	ast, err := parser.ParseString(input)
+	if err != nil {
+		return err
+	}
+
+	scoreUpdates, err := ast.Updates()
+	if err != nil {
+		return err
+	}
+
+	score := model.NewScore()
+	if err := score.Update(scoreUpdates...); err != nil {
+		return err
+	}
+
+	playbackOpts := server.playbackOpts()
+
+	return server.withTransmitter(
+		func(transmitter transmitter.PlayerTransmitter) error {
+			log.Info().
+				Interface("player", server.currentPlayer()).
+				Msg("Sending OSC messages for snippet to player.")
+
+			// The snippet's instruments may replace the instruments of the
+			// session's score on the player process, so we can no longer assume
+			// that the player process has them.
+			server.patchesSent = map[int32]int32{}
+
+			if err := transmitter.TransmitScore(score, playbackOpts...); err != nil {
+				return err
+			}
+
+			server.recordPlayback(playbackLength(score.Events, nil))
+
+			return nil
+		},
+	)
+}
+
+// parseToJSON parses the provided input and returns the AST as a JSON string,
+// without evaluating it or affecting the server's score.
+func parseToJSON(input string) (string, error) {
 	ast, err := parser.ParseString(input)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	scoreUpdates, err := ast.Updates()
-	if err != nil {
-		return nil, err
-	}
+	return ast.JSON().String(), nil
+}
 
-	if err := server.score.Update(scoreUpdates...); err != nil {
-		return nil, err
+// scoreInfoJSON returns a summary of the score: how long it is and which parts
+// it has. (See `model.Score.Duration` and `model.Score.PartSummaries`.)
+func scoreInfoJSON(score *model.Score) *json.Container {
+	parts := json.Array()
+	for _, part := range score.PartSummaries() {
+		parts.ArrayAppend(part.JSON())
 	}
 
-	// Add the provided `input` to our total string of input representing the
-	// entire score.
-	server.input += strings.TrimSpace(input) + "\n"
+	return json.Object(
+		"duration-ms", score.Duration().Milliseconds(),
+		"parts", parts,
+	)
+}
 
-	// Update the starting index so that the next invocation of `evalAndPlay` for
-	// this same score will result in only playing newly added events.
-	server.eventIndex = len(server.score.Events)
+// PlayFileOption is a function that customizes the way that `PlayFile` plays a
+// file.
+type PlayFileOption func(*playFileContext)
 
-	return []transmitter.TransmissionOption{
-		// Transmit only the new events, i.e. events added as a result of parsing
-		// the provided `input` and applying the resulting updates to the score.
-		transmitter.TransmitFromIndex(eventIndex),
-		// The previous offset of each part is subtracted from any new events for
-		// that part. The effect is that we "synchronize" that part with the events
-		// that we already sent to the player. For example, if a client submits the
-		// following code:
-		//
-		//   piano: c d e f
-		//
-		// Followed by (sometime before the 4 notes above finish playing):
-		//
-		//   piano: g a b > c
-		//
-		// Then the notes `c d e f g a b > c` will be played in time.
-		transmitter.SyncOffsets(partOffsets),
-	}, nil
+type playFileContext struct {
+	replaceScore bool
 }
 
-func (server *Server) evalAndPlay(
-	input string, additionalTransmitOpts ...transmitter.TransmissionOption,
-) error {
-	return server.withTransmitter(
-		func(transmitter transmitter.OSCTransmitter) error {
-			transmitOpts, err := server.updateScoreWithInput(input)
-			if err != nil {
-				return err
-			}
+// ReplaceScore makes `PlayFile` replace the REPL session's score with the
+// file's score, instead of adding the file's score to it.
+func ReplaceScore() PlayFileOption {
+	return func(ctx *playFileContext) {
+		ctx.replaceScore = true
+	}
+}
 
-			log.Info().
-				Interface("player", server.player).
-				Msg("Sending OSC messages to player.")
+// PlayFile reads and parses an Alda score file and plays it.
+//
+// By default, the file's contents are added to the REPL session's score, as if
+// they had been entered at the REPL. The `ReplaceScore` option makes the file's
+// score replace the session's score instead.
+//
+// The file is parsed before anything is sent to the player process or the
+// session's score is changed, so if the file can't be read or parsed, the error
+// is returned right away.
+func (server *Server) PlayFile(path string, opts ...PlayFileOption) error {
+	ctx := &playFileContext{}
+	for _, opt := range opts {
+		opt(ctx)
+	}
 
-			return transmitter.TransmitScore(
-				server.score,
-				(append(transmitOpts, additionalTransmitOpts...))...,
-			)
-		},
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	input := string(contents)
+
+	// The file is parsed before the score is reset, so that the score is left
+	// alone if the file can't be parsed.
+	ast, err := parser.Parse(path, input)
+	if err != nil {
+		return err
+	}
+
+	if ctx.replaceScore {
+		if err := server.Reset(); err != nil {
+			return err
+		}
+	}
+
+	return server.evalAndPlayInput(
+		scoreInput{text: input, filename: path, ast: &ast},
 	)
 }
 
-func (server *Server) load(input string) error {
-	if err := server.resetState(); err != nil {
+// load replaces the server's score with one built from `input` and transposed
+// by `transposition` semitones (see `Transpose`), and sends it to the player
+// process without playing it.
+func (server *Server) load(input string, transposition int) error {
+	if err := server.Reset(); err != nil {
 		return err
 	}
 
+	server.transposition = transposition
+
 	return server.withTransmitter(
-		func(t transmitter.OSCTransmitter) error {
+		func(t transmitter.PlayerTransmitter) error {
 			transmitOpts, err := server.updateScoreWithInput(input)
 			if err != nil {
 				return err
@@ -671,11 +1628,13 @@ func (server *Server) load(input string) error {
 			transmitOpts = append(transmitOpts, transmitter.LoadOnly())
 
 			log.Info().
-				Interface("player", server.player).
+				Interface("player", server.currentPlayer()).
 				Msg("Transmitting score to player.")
 
 			err = t.TransmitScore(server.score, transmitOpts...)
 			if err != nil {
+				// We don't know which messages (if any) made it to the player process.
+				server.patchesSent = map[int32]int32{}
 				return err
 			}
 
@@ -688,7 +1647,7 @@ func (server *Server) load(input string) error {
 			}
 
 			log.Info().
-				Interface("player", server.player).
+				Interface("player", server.currentPlayer()).
 				Int32("newOffset", newOffset).
 				Msg("Transmitting new offset to player.")
 
@@ -697,18 +1656,312 @@ func (server *Server) load(input string) error {
 	)
 }
 
+// Stop tells the player process that the server is using to stop playback
+// immediately. Unlike shutting down the player process, this leaves the player
+// process running, so the server can continue to use it.
+//
+// If a section of the score is looping (see `Loop`), the loop stops too.
+func (server *Server) Stop() error {
+	server.stopLoop()
+
+	return server.withTransmitter(
+		func(transmitter transmitter.PlayerTransmitter) error {
+			log.Info().
+				Interface("player", server.currentPlayer()).
+				Msg("Sending \"stop\" message to player process.")
+
+			if err := transmitter.TransmitStopMessage(); err != nil {
+				return err
+			}
+
+			server.clearPlaybackEnd()
+
+			return nil
+		},
+	)
+}
+
+// CaptureOSC makes the server write a human-readable description of every OSC
+// message that it sends to its player process to `w`, which is useful for
+// debugging. Passing nil turns capturing off.
+func (server *Server) CaptureOSC(w io.Writer) {
+	if server.oscCaptureFile != nil {
+		if err := server.oscCaptureFile.Close(); err != nil {
+			log.Warn().Err(err).Msg("Failed to close OSC capture file.")
+		}
+
+		server.oscCaptureFile = nil
+	}
+
+	server.oscCapture = w
+}
+
+// captureOSCToFile is like CaptureOSC, but it appends the OSC messages to the
+// file at the provided path.
+func (server *Server) captureOSCToFile(filename string) error {
+	file, err := os.OpenFile(
+		filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644,
+	)
+	if err != nil {
+		return err
+	}
+
+	server.CaptureOSC(file)
+	server.oscCaptureFile = file
+
+	return nil
+}
+
+// SetTempoScale makes everything that the server plays from now on play faster
+// or slower than written, by the provided factor. For example, a factor of 0.7
+// plays at 70% speed, which is handy for rehearsing a tricky passage without
+// editing the tempo in the score. A factor of 1.0 plays at normal speed.
+//
+// Notes that have already been sent to the player process are not affected.
+func (server *Server) SetTempoScale(factor float64) error {
+	if factor <= 0 {
+		return fmt.Errorf("tempo scale must be positive: %v", factor)
+	}
+
+	server.tempoScale = factor
+
+	return nil
+}
+
+// The largest positive offset that `SetClockOffset` accepts. The server can't
+// handle other requests while it waits to send a score, so a longer delay would
+// leave it unresponsive.
+var maxClockOffset = 250 * time.Millisecond
+
+// SetClockOffset makes the server add the provided offset to the time when
+// everything that it plays from now on starts playing. This is useful for
+// lining up playback with other software or external MIDI gear, e.g. to make up
+// for the time that they take to respond.
+//
+// A positive offset delays sending each score to the player process, since the
+// player process starts playing a score as soon as it receives it. A negative
+// offset only has an effect on scores that are scheduled to play in the future
+// (see `PlayAt`).
+//
+// Returns an error if the offset is greater than `maxClockOffset`.
+func (server *Server) SetClockOffset(offset time.Duration) error {
+	if offset > maxClockOffset {
+		return fmt.Errorf(
+			"clock offset must be at most %v: %v", maxClockOffset, offset,
+		)
+	}
+
+	server.clockOffset = offset
+
+	return nil
+}
+
+// playbackOpts returns the transmission options that apply the server's
+// playback settings (see `SetTempoScale`, `SetClockOffset`, `MutePart` and
+// `SoloPart`) to everything that it plays.
+func (server *Server) playbackOpts() []transmitter.TransmissionOption {
+	return []transmitter.TransmissionOption{
+		transmitter.TempoScale(server.tempoScale),
+		transmitter.ClockOffset(server.clockOffset),
+		transmitter.MuteParts(sortedNames(server.mutedParts)...),
+		transmitter.SoloParts(sortedNames(server.soloedParts)...),
+	}
+}
+
+// SetTempo changes the tempo (in beats per minute) of the player process that
+// the server is using, effective immediately. Unlike evaluating a `(tempo ...)`
+// attribute change, this doesn't affect the score, so the change only lasts
+// until the next time that the score is transmitted with tempo information.
+func (server *Server) SetTempo(bpm float64) error {
+	if bpm <= 0 {
+		return fmt.Errorf("tempo must be positive: %v", bpm)
+	}
+
+	return server.withTransmitter(
+		func(transmitter transmitter.PlayerTransmitter) error {
+			log.Info().
+				Interface("player", server.currentPlayer()).
+				Float64("bpm", bpm).
+				Msg("Transmitting tempo to player.")
+
+			return transmitter.TransmitTempoMessage(bpm, 0)
+		},
+	)
+}
+
+// SetVolume changes the volume of a track on the player process that the
+// server is using, effective immediately. The volume is a number between 0.0
+// and 1.0.
+//
+// Like `SetTempo`, this doesn't affect the score, so this is useful for making
+// live adjustments, e.g. fading a part in or out.
+func (server *Server) SetVolume(track int32, volume float64) error {
+	return server.withTransmitter(
+		func(transmitter transmitter.PlayerTransmitter) error {
+			log.Info().
+				Interface("player", server.currentPlayer()).
+				Int32("track", track).
+				Float64("volume", volume).
+				Msg("Transmitting track volume to player.")
+
+			return transmitter.TransmitVolumeMessage(track, volume, 0)
+		},
+	)
+}
+
+// SetPanning changes the panning (stereo position) of a track on the player
+// process that the server is using, effective immediately. The panning is a
+// number between 0.0 (hard left) and 1.0 (hard right).
+//
+// Like `SetVolume`, this doesn't affect the score.
+func (server *Server) SetPanning(track int32, panning float64) error {
+	return server.withTransmitter(
+		func(transmitter transmitter.PlayerTransmitter) error {
+			log.Info().
+				Interface("player", server.currentPlayer()).
+				Int32("track", track).
+				Float64("panning", panning).
+				Msg("Transmitting track panning to player.")
+
+			return transmitter.TransmitPanningMessage(track, panning, 0)
+		},
+	)
+}
+
+// SetSoundfont tells the player process that the server is using to load the
+// soundfont (.sf2) file at the provided path. The server remembers the choice
+// and sends it to any player process that it switches to later, so the
+// soundfont stays in effect for the rest of the session.
+//
+// Returns an error right away, without looking for a player process, if the
+// file doesn't exist.
+func (server *Server) SetSoundfont(path string) error {
+	// The player process doesn't necessarily have the same working directory as
+	// the server, so we give it an absolute path.
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("unable to use soundfont: %w", err)
+	}
+
+	if err := server.withTransmitter(
+		func(transmitter transmitter.PlayerTransmitter) error {
+			log.Info().
+				Interface("player", server.currentPlayer()).
+				Str("soundfont", path).
+				Msg("Transmitting soundfont to player.")
+
+			return transmitter.TransmitSoundfontMessage(path)
+		},
+	); err != nil {
+		return err
+	}
+
+	server.soundfont = path
+
+	return nil
+}
+
+// LoadInstruments loads the instrument definitions and aliases in the JSON file
+// at the provided path (see `model.LoadInstruments`), so that they can be used
+// in the score from now on. Parts that were already declared are unaffected.
+func (server *Server) LoadInstruments(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to load instruments: %w", err)
+	}
+	defer file.Close()
+
+	log.Info().Str("path", path).Msg("Loading instrument definitions.")
+
+	return model.LoadInstruments(file)
+}
+
+// PlayerLatency asks the player process that the server is using for the
+// latency of its audio output. This is useful for syncing Alda playback with
+// other software or hardware.
+//
+// Returns an error if the player process doesn't reply within the configured
+// `PingTimeout`.
+func (server *Server) PlayerLatency() (time.Duration, error) {
+	var latency time.Duration
+
+	if err := server.withTransmitter(
+		func(transmitter transmitter.PlayerTransmitter) error {
+			l, err := transmitter.RequestLatency(
+				server.playerManagement.PingTimeout,
+			)
+			if err != nil {
+				return err
+			}
+
+			latency = l
+			return nil
+		},
+	); err != nil {
+		return 0, err
+	}
+
+	return latency, nil
+}
+
+// PlayerClock asks the player process that the server is using for the
+// current time according to its system clock. Comparing it with the local time
+// shows how far apart the two clocks are, which is useful for diagnosing timing
+// problems.
+//
+// Returns an error if the player process doesn't reply within the configured
+// `PingTimeout`.
+func (server *Server) PlayerClock() (time.Time, error) {
+	var clock time.Time
+
+	if err := server.withTransmitter(
+		func(transmitter transmitter.PlayerTransmitter) error {
+			c, err := transmitter.RequestClock(server.playerManagement.PingTimeout)
+			if err != nil {
+				return err
+			}
+
+			clock = c
+			return nil
+		},
+	); err != nil {
+		return time.Time{}, err
+	}
+
+	return clock, nil
+}
+
+// Transpose shifts the pitch of every note in the score by the provided number
+// of semitones. The transposition is applied to any input evaluated later as
+// well, including when the score is rebuilt from the input received so far,
+// e.g. when it's replayed or exported.
+func (server *Server) Transpose(semitones int) error {
+	if err := server.score.Transpose(semitones); err != nil {
+		return err
+	}
+
+	server.transposition += semitones
+
+	return nil
+}
+
 func (server *Server) reload() error {
-	return server.load(server.input)
+	return server.load(server.input, server.transposition)
 }
 
 func (server *Server) replay(
 	transmitOpts ...transmitter.TransmissionOption,
 ) error {
-	// `input` is the one thing about the server state that we DON'T want to
-	// reset, so we keep track of it here. After we reset the state, we invoke
-	// `server.evalAndPlay` on this input, which has the effect of both playing it
-	// and re-adding it to the state of the server.
+	// `input` and `transposition` are the things about the server state that we
+	// DON'T want to reset, so we keep track of them here. After we reset the
+	// state, we invoke `server.evalAndPlay` on this input, which has the effect
+	// of both playing it and re-adding it to the state of the server.
 	input := server.input
+	transposition := server.transposition
 
 	// We reset the server state here so that we can re-transmit the score "from
 	// scratch" (or just re-transmit the part that we want to hear, if `from`
@@ -720,10 +1973,12 @@ func (server *Server) replay(
 	// between the user entering each line of REPL input, which we are presuming
 	// is not what the user wants. (This would also be a departure from the
 	// behavior of `:play` in the Alda v1 REPL.)
-	if err := server.resetState(); err != nil {
+	if err := server.Reset(); err != nil {
 		return err
 	}
 
+	server.transposition = transposition
+
 	// At this point, the `managePlayers` loop should find a replacement for the
 	// player, and this should generally happen quickly. `server.evalAndPlay` will
 	// handle the case that a player process isn't immediately available, so it's
@@ -737,6 +1992,49 @@ func (server *Server) replay(
 //
 // Returns an error if something goes wrong somewhere along the way.
 func (server *Server) export() ([]byte, error) {
+	tmpdir, err := ioutil.TempDir("", "alda-repl-server")
+	if err != nil {
+		return nil, err
+	}
+
+	// The player process writes the file, and we wait for it to exist (see
+	// `exportToFile`), so the temporary file only serves to reserve a unique
+	// name.
+	midiFile, err := os.CreateTemp(tmpdir, "export-*.mid")
+	if err != nil {
+		return nil, err
+	}
+
+	midiFilename := midiFile.Name()
+
+	if err := midiFile.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(midiFilename); err != nil {
+		return nil, err
+	}
+
+	if err := server.exportToFile(midiFilename); err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(midiFilename)
+}
+
+// Reloads the score into a fresh player, sends a "MIDI export" message to the
+// player, and waits for the player to write the MIDI file at the provided path.
+//
+// Returns an error if something goes wrong somewhere along the way, including
+// if no player process is available.
+func (server *Server) exportToFile(midiFilename string) error {
+	// The player process doesn't necessarily have the same working directory as
+	// the server, so we give it an absolute path.
+	midiFilename, err := filepath.Abs(midiFilename)
+	if err != nil {
+		return err
+	}
+
 	// Reloading the score is important because of the subtleties of the tempo
 	// messages in the MIDI sequence.
 	//
@@ -747,46 +2045,22 @@ func (server *Server) export() ([]byte, error) {
 	// sequencer, which does include sending tempo messages, so that the MIDI
 	// sequence includes tempo changes in the places where we want them.
 	if err := server.reload(); err != nil {
-		return nil, err
-	}
-
-	tmpdir, err := ioutil.TempDir("", "alda-repl-server")
-	if err != nil {
-		return nil, err
+		return fmt.Errorf("unable to export the score: %w", err)
 	}
 
-	midiFilename := filepath.Join(
-		tmpdir, fmt.Sprintf(
-			"export-%d-%d.mid",
-			time.Now().Unix(),
-			rand.Intn(10000),
-		),
-	)
-
 	if err := server.withTransmitter(
-		func(transmitter transmitter.OSCTransmitter) error {
+		func(transmitter transmitter.PlayerTransmitter) error {
 			return transmitter.TransmitMidiExportMessage(midiFilename)
 		},
 	); err != nil {
-		return nil, err
+		return fmt.Errorf("unable to export the score: %w", err)
 	}
 
-	var midiFile *os.File
-
-	if err := util.Await(
+	return util.Await(
 		func() error {
-			mf, err := os.Open(midiFilename)
-			if err != nil {
-				return err
-			}
-
-			midiFile = mf
-			return nil
+			_, err := os.Stat(midiFilename)
+			return err
 		},
 		midiExportTimeout,
-	); err != nil {
-		return nil, err
-	}
-
-	return ioutil.ReadAll(midiFile)
+	)
 }
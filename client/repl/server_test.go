@@ -0,0 +1,841 @@
+package repl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daveyarwood/go-osc/osc"
+
+	"alda.io/client/model"
+	"alda.io/client/system"
+	_ "alda.io/client/testing"
+	"alda.io/client/transmitter"
+)
+
+// fakePlayerProcess listens for OSC packets over TCP, like a player process
+// does, and returns the port it's listening on along with a channel that
+// receives the raw bytes of each packet received.
+func fakePlayerProcess(t *testing.T) (int, <-chan []byte) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	packets := make(chan []byte, 10)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			data, _ := ioutil.ReadAll(conn)
+			conn.Close()
+
+			packets <- data
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port, packets
+}
+
+func TestStop(t *testing.T) {
+	port, packets := fakePlayerProcess(t)
+	player := system.PlayerState{State: "ready", Port: port, ID: "abc"}
+
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	server := NewServer(0)
+	server.setPlayer(player)
+
+	if err := server.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case data := <-packets:
+		if !bytes.Contains(data, []byte("/system/stop")) {
+			t.Errorf("expected a /system/stop message, got %q", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("player process didn't receive a message")
+	}
+
+	if server.player != player {
+		t.Errorf("expected player to be unchanged, got %#v", server.player)
+	}
+}
+
+func TestStopKeepsScoreState(t *testing.T) {
+	port, packets := fakePlayerProcess(t)
+	player := system.PlayerState{State: "ready", Port: port, ID: "abc"}
+
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	server := NewServer(0)
+	server.setPlayer(player)
+
+	if err := server.evalAndPlay("piano: c d e"); err != nil {
+		t.Fatal(err)
+	}
+	<-packets
+
+	input := server.input
+	score := server.score
+	events := len(server.score.Events)
+	eventIndex := server.eventIndex
+
+	if err := server.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	<-packets
+
+	if server.player != player {
+		t.Errorf("expected player to be unchanged, got %#v", server.player)
+	}
+
+	if server.input != input {
+		t.Errorf("expected input %q to be unchanged, got %q", input, server.input)
+	}
+
+	if server.score != score ||
+		len(server.score.Events) != events ||
+		server.eventIndex != eventIndex {
+		t.Errorf("expected the score to be unchanged")
+	}
+
+	// Input after stopping picks up where the score left off.
+	if err := server.evalAndPlay("f"); err != nil {
+		t.Fatal(err)
+	}
+
+	last := server.score.Events[len(server.score.Events)-1].(model.NoteEvent)
+	if last.Offset != 1500 {
+		t.Errorf("expected the next note to be at offset 1500, got %f", last.Offset)
+	}
+}
+
+// mockTransmitter records the messages that it's asked to send, instead of
+// sending them anywhere.
+type mockTransmitter struct {
+	sent *[]string
+}
+
+func (mt mockTransmitter) record(format string, args ...interface{}) error {
+	*mt.sent = append(*mt.sent, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (mt mockTransmitter) TransmitScore(
+	score *model.Score, opts ...transmitter.TransmissionOption,
+) error {
+	return mt.record("score (%d events)", len(score.Events))
+}
+
+func (mt mockTransmitter) TransmitOffsetMessage(offset int32) error {
+	return mt.record("offset %d", offset)
+}
+
+func (mt mockTransmitter) TransmitStopMessage() error {
+	return mt.record("stop")
+}
+
+func (mt mockTransmitter) TransmitClearMessage() error {
+	return mt.record("clear")
+}
+
+func (mt mockTransmitter) TransmitOSCMessage(
+	address string, args ...interface{},
+) error {
+	return mt.record("osc %s %v", address, args)
+}
+
+func (mt mockTransmitter) TransmitShutdownMessage(offset int32) error {
+	return mt.record("shutdown %d", offset)
+}
+
+func (mt mockTransmitter) TransmitTempoMessage(bpm float64, offset int32) error {
+	return mt.record("tempo %v", bpm)
+}
+
+func (mt mockTransmitter) TransmitVolumeMessage(
+	track int32, volume float64, offset int32,
+) error {
+	return mt.record("volume %d %v", track, volume)
+}
+
+func (mt mockTransmitter) TransmitPanningMessage(
+	track int32, panning float64, offset int32,
+) error {
+	return mt.record("panning %d %v", track, panning)
+}
+
+func (mt mockTransmitter) TransmitSoundfontMessage(path string) error {
+	return mt.record("soundfont %s", path)
+}
+
+func (mt mockTransmitter) TransmitMidiExportMessage(filename string) error {
+	return mt.record("midi export %s", filename)
+}
+
+func (mt mockTransmitter) RequestLatency(
+	timeout time.Duration,
+) (time.Duration, error) {
+	return 0, mt.record("latency")
+}
+
+func (mt mockTransmitter) RequestClock(
+	timeout time.Duration,
+) (time.Time, error) {
+	return time.Time{}, mt.record("clock")
+}
+
+func TestTransmitterFactory(t *testing.T) {
+	player := system.PlayerState{State: "ready", Port: 12345, ID: "abc"}
+
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	sent := []string{}
+	players := []system.PlayerState{}
+
+	server := NewServer(0, WithTransmitterFactory(
+		func(player system.PlayerState) transmitter.PlayerTransmitter {
+			players = append(players, player)
+			return mockTransmitter{sent: &sent}
+		},
+	))
+	server.setPlayer(player)
+
+	if err := server.evalAndPlay("piano: c d e"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.SetTempo(90); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.SetPanning(1, 0.25); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"score (3 events)", "stop", "tempo 90", "panning 1 0.25",
+	}
+	if strings.Join(sent, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("expected %v to be sent, got %v", expected, sent)
+	}
+
+	for _, p := range players {
+		if p != player {
+			t.Errorf("expected a transmitter for %#v, got one for %#v", player, p)
+		}
+	}
+}
+
+func TestExportWithoutPlayer(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	server := NewServer(0, WithPlayerManagementConfig(PlayerManagementConfig{
+		FindPlayerTimeout: 10 * time.Millisecond,
+	}))
+
+	err := server.exportToFile(filepath.Join(t.TempDir(), "score.mid"))
+	if err == nil {
+		t.Fatal("expected an error when no player process is available")
+	}
+
+	if !strings.Contains(err.Error(), "no player process is available") {
+		t.Errorf("expected a clear error message, got %q", err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	server := NewServer(0, WithDryRun())
+
+	if err := server.evalAndPlay("piano: c d e"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.SetTempo(120); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDryRunDoesNotStartPlayers(t *testing.T) {
+	fake := &fakePlayerSystem{}
+	stubPlayerSystem(t, fake)
+
+	originalCacheDir := system.CacheDir
+	system.CacheDir = t.TempDir()
+	t.Cleanup(func() { system.CacheDir = originalCacheDir })
+
+	server, err := RunServer(0, WithDryRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Close waits for the server's background routines to stop, so that none of
+	// them is still using the cache directory when the test restores it.
+	server.Close()
+
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	if fake.poolFills != 0 || fake.prunes != 0 {
+		t.Errorf(
+			"expected the player pool not to be managed, got %d pool fills and %d "+
+				"prunes",
+			fake.poolFills, fake.prunes,
+		)
+	}
+}
+
+func TestPlayString(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	var capture bytes.Buffer
+
+	server := NewServer(0, WithDryRun())
+	server.CaptureOSC(&capture)
+
+	if err := server.PlayString("piano: c d e"); err != nil {
+		t.Fatal(err)
+	}
+
+	notes := capturedNotes(capture.String())
+
+	expected := []string{"60", "62", "64"}
+	if strings.Join(notes, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected notes %v, got %v", expected, notes)
+	}
+
+	if server.input != "" {
+		t.Errorf("expected the session's score to be unchanged: %q", server.input)
+	}
+}
+
+// capturedNotes returns the MIDI note numbers of the notes in the captured OSC
+// messages for the first track.
+func capturedNotes(capture string) []string {
+	notes := []string{}
+	for _, line := range strings.Split(capture, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 3 && fields[0] == "/track/1/midi/note" {
+			// The note's MIDI note number comes after the offset.
+			notes = append(notes, fields[3])
+		}
+	}
+
+	return notes
+}
+
+func TestPlayFile(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	dir := t.TempDir()
+
+	writeScore := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	first := writeScore("first.alda", "piano: c d e")
+	second := writeScore("second.alda", "piano: f g")
+
+	var capture bytes.Buffer
+
+	server := NewServer(0, WithDryRun())
+	server.CaptureOSC(&capture)
+
+	if err := server.PlayFile(first); err != nil {
+		t.Fatal(err)
+	}
+
+	notes := capturedNotes(capture.String())
+
+	expected := []string{"60", "62", "64"}
+	if strings.Join(notes, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected notes %v, got %v", expected, notes)
+	}
+
+	// By default, the file is added to the session's score.
+	if err := server.PlayFile(second); err != nil {
+		t.Fatal(err)
+	}
+
+	if notes := scoreNotes(server); len(notes) != 5 {
+		t.Errorf("expected the score to have 5 notes, got %v", notes)
+	}
+
+	if err := server.PlayFile(second, ReplaceScore()); err != nil {
+		t.Fatal(err)
+	}
+
+	if notes := scoreNotes(server); len(notes) != 2 {
+		t.Errorf("expected the score to have 2 notes, got %v", notes)
+	}
+}
+
+func TestPlayFileResolvesImportsRelativeToTheFile(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"motif.alda": "c d e",
+		"score.alda": `piano: (import "motif.alda")`,
+	}
+
+	for name, contents := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := NewServer(0, WithDryRun())
+
+	if err := server.PlayFile(filepath.Join(dir, "score.alda")); err != nil {
+		t.Fatal(err)
+	}
+
+	if notes := scoreNotes(server); len(notes) != 3 {
+		t.Errorf("expected the score to have 3 notes, got %v", notes)
+	}
+}
+
+func TestPlayFileErrors(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	server := NewServer(0, WithDryRun())
+
+	if err := server.evalAndPlay("piano: c d e"); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+
+	err := server.PlayFile(filepath.Join(dir, "missing.alda"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a file not found error, got %v", err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.alda")
+	if err := os.WriteFile(invalid, []byte("piano: c ] d"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err = server.PlayFile(invalid, ReplaceScore())
+	if err == nil || !strings.Contains(err.Error(), "invalid.alda") {
+		t.Errorf("expected a parse error for the file, got %v", err)
+	}
+
+	if notes := scoreNotes(server); len(notes) != 3 {
+		t.Errorf("expected the score to be unchanged, got %v", notes)
+	}
+}
+
+func TestPlayStringWithParseError(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	var capture bytes.Buffer
+
+	server := NewServer(0, WithDryRun())
+	server.CaptureOSC(&capture)
+
+	if err := server.PlayString("piano: c d (e"); err == nil {
+		t.Fatal("expected a parse error")
+	}
+
+	if capture.Len() != 0 {
+		t.Errorf("expected nothing to be sent, got:\n%s", capture.String())
+	}
+}
+
+func TestEvalAndPlayReportsEachParseError(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	server := NewServer(0, WithDryRun())
+
+	err := server.evalAndPlay("piano: c ] d | e } f | g")
+	if err == nil {
+		t.Fatal("expected parse errors")
+	}
+
+	problems := problemsWithInput(err)
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems, got %d: %v", len(problems), problems)
+	}
+
+	if len(scoreNotes(server)) != 0 {
+		t.Errorf("expected the score to be unchanged, got %v", scoreNotes(server))
+	}
+}
+
+// scoreNotes returns the MIDI note numbers of the notes in the server's score.
+func scoreNotes(server *Server) []int32 {
+	notes := []int32{}
+	for _, event := range server.score.Events {
+		if note, ok := event.(model.NoteEvent); ok {
+			notes = append(notes, note.MidiNote)
+		}
+	}
+
+	return notes
+}
+
+// astNode is the JSON representation of a parser.ASTNode.
+type astNode struct {
+	Type     string      `json:"type"`
+	Literal  interface{} `json:"literal"`
+	Children []astNode   `json:"children"`
+}
+
+// findNodes returns all of the nodes of the provided type in the AST.
+func findNodes(node astNode, nodeType string) []astNode {
+	found := []astNode{}
+
+	if node.Type == nodeType {
+		found = append(found, node)
+	}
+
+	for _, child := range node.Children {
+		found = append(found, findNodes(child, nodeType)...)
+	}
+
+	return found
+}
+
+func TestParse(t *testing.T) {
+	ast, err := parseToJSON("piano: c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var root astNode
+	if err := json.Unmarshal([]byte(ast), &root); err != nil {
+		t.Fatalf("AST is not valid JSON: %s: %v", ast, err)
+	}
+
+	notes := findNodes(root, "NoteNode")
+	if len(notes) != 1 {
+		t.Fatalf("expected 1 note in %s, got %d", ast, len(notes))
+	}
+
+	letters := findNodes(notes[0], "NoteLetterNode")
+	if len(letters) != 1 || letters[0].Literal != "c" {
+		t.Errorf("expected the note to be a C, got %s", ast)
+	}
+}
+
+func TestParseWithParseErrors(t *testing.T) {
+	_, err := parseToJSON("piano: c ] d | e } f")
+	if err == nil {
+		t.Fatal("expected parse errors")
+	}
+
+	if problems := problemsWithInput(err); len(problems) != 2 {
+		t.Errorf("expected 2 problems, got %d: %v", len(problems), problems)
+	}
+}
+
+func TestScoreStateAccumulatesAcrossInput(t *testing.T) {
+	server := NewServer(0)
+
+	for _, input := range []string{"piano: o5 c", "d e"} {
+		if _, err := server.updateScoreWithInput(input); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The second input continues the piano part, in octave 5.
+	expected := []int32{72, 74, 76}
+	if notes := scoreNotes(server); fmt.Sprint(notes) != fmt.Sprint(expected) {
+		t.Errorf("expected notes %v, got %v", expected, notes)
+	}
+}
+
+func TestReset(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	server := NewServer(0)
+
+	if _, err := server.updateScoreWithInput("piano: o5 c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.input != "" {
+		t.Errorf("expected input to be cleared, got %q", server.input)
+	}
+
+	// The new piano part starts from scratch, in the default octave (4).
+	if _, err := server.updateScoreWithInput("piano: c"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []int32{60}
+	if notes := scoreNotes(server); fmt.Sprint(notes) != fmt.Sprint(expected) {
+		t.Errorf("expected notes %v, got %v", expected, notes)
+	}
+}
+
+func TestTransposeAppliesToTheWholeSession(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	server := NewServer(0, WithDryRun())
+	go server.handleRequests()
+	t.Cleanup(func() { close(server.requestQueue) })
+
+	conn, clientConn := net.Pipe()
+	t.Cleanup(func() { conn.Close() })
+	go io.Copy(io.Discard, clientConn)
+
+	request := func(msg map[string]interface{}) {
+		server.requestQueue <- nREPLRequest{conn: conn, msg: msg}
+	}
+
+	request(map[string]interface{}{"op": "eval-and-play", "code": "piano: c d"})
+	request(map[string]interface{}{"op": "transpose", "semitones": int64(12)})
+	// Input evaluated after the transposition is transposed too.
+	request(map[string]interface{}{"op": "eval-and-play", "code": "e"})
+	// The score is rebuilt from the input when it's replayed.
+	request(map[string]interface{}{"op": "replay"})
+	// Requests are handled one at a time, so once this one is received, the
+	// previous ones have been handled.
+	request(map[string]interface{}{"op": "describe"})
+
+	expected := []int32{72, 74, 76}
+	if notes := scoreNotes(server); fmt.Sprint(notes) != fmt.Sprint(expected) {
+		t.Errorf("expected notes %v after replay, got %v", expected, notes)
+	}
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := server.SaveSession(path); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewServer(0, WithDryRun())
+	if err := restored.LoadSession(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if notes := scoreNotes(restored); fmt.Sprint(notes) != fmt.Sprint(expected) {
+		t.Errorf("expected notes %v after loading session, got %v", expected, notes)
+	}
+}
+
+func TestScoreInfoJSON(t *testing.T) {
+	server := NewServer(0)
+
+	if _, err := server.updateScoreWithInput(
+		"piano: c d e (tempo 60) f\nviolin: g a",
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	info := scoreInfoJSON(server.score)
+
+	// 3 quarter notes at 120 bpm, then 1 at 60 bpm.
+	durationMs := info.Search("duration-ms").Data()
+	if durationMs != int64(2500) {
+		t.Errorf("expected duration 2500 ms, got %v", durationMs)
+	}
+
+	expected := `[{"instrument":"midi-acoustic-grand-piano","name":"piano",` +
+		`"notes":4},{"instrument":"midi-violin","name":"violin","notes":2}]`
+	if parts := info.Search("parts").String(); parts != expected {
+		t.Errorf("expected parts %s, got %s", expected, parts)
+	}
+}
+
+func TestLoadInstruments(t *testing.T) {
+	t.Cleanup(model.SaveInstruments())
+
+	path := filepath.Join(t.TempDir(), "instruments.json")
+	err := os.WriteFile(
+		path, []byte(`{"aliases": {"test-fiddle": "midi-violin"}}`), 0644,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(0)
+
+	if _, err := server.updateScoreWithInput("test-fiddle: c"); err == nil {
+		t.Fatal("expected test-fiddle not to be an instrument yet")
+	}
+
+	if err := server.LoadInstruments(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := server.updateScoreWithInput("test-fiddle: c"); err != nil {
+		t.Fatal(err)
+	}
+
+	instrument := server.score.Parts[0].StockInstrument.Name()
+	if instrument != "midi-violin" {
+		t.Errorf("expected test-fiddle to be a midi-violin, got %s", instrument)
+	}
+
+	if err := server.LoadInstruments(path + ".missing"); err == nil {
+		t.Error("expected an error for a file that doesn't exist")
+	}
+}
+
+// evalIncrementally evaluates each string of input in turn, the way that the
+// server does in a REPL session, and returns the resulting score.
+func evalIncrementally(inputs []string) (*model.Score, error) {
+	server := NewServer(0, WithDryRun())
+
+	for _, input := range inputs {
+		if _, err := server.updateScoreWithInput(input); err != nil {
+			return nil, err
+		}
+	}
+
+	return server.score, nil
+}
+
+func TestIncrementalEvalMatchesFullParse(t *testing.T) {
+	for _, inputs := range [][]string{
+		{"piano: c d e", "f g"},
+		{"piano: c", "violin: d", "piano: e", "piano/violin: f"},
+		{"piano: (tempo 90) o3 c8", "d e", "(tempo! 60)", "f"},
+		{"foo = c d", "piano: foo", "foo = e f", "piano: foo"},
+		{"piano: V1: c d V2: e f", "g a", "V0: b"},
+		{"piano: c %verse d", "@verse e"},
+		{`piano "pno": c`, "pno: d", `violin "vln": e`, "pno/vln: f"},
+		// A directive that changes how later input is scanned, which makes the
+		// server fall back to parsing all of the input each time.
+		{
+			"(note-names! :solfege)", "piano: do re", "mi fa",
+			"(note-names! :english)", "c",
+		},
+	} {
+		incremental, err := evalIncrementally(inputs)
+		if err != nil {
+			t.Errorf("%q: %v", inputs, err)
+			continue
+		}
+
+		full := model.NewScore()
+		err = updateScore(full, scoreInput{text: strings.Join(inputs, "\n")})
+		if err != nil {
+			t.Errorf("%q: %v", inputs, err)
+			continue
+		}
+
+		if len(incremental.Events) != len(full.Events) {
+			t.Errorf(
+				"%q: expected %d events, got %d",
+				inputs, len(full.Events), len(incremental.Events),
+			)
+		}
+
+		expected := model.Render(full)
+		if actual := model.Render(incremental); actual != expected {
+			t.Errorf("%q: expected:\n%s\ngot:\n%s", inputs, expected, actual)
+		}
+	}
+}
+
+func TestFullReparseKeepsNewEventsInSync(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	bundles := []*osc.Bundle{}
+
+	server := NewServer(0, bundleTransmitterFactory(&bundles))
+	server.setPlayer(testPlayer())
+
+	for _, input := range []string{
+		"(note-names! :solfege)", "piano: do re", "mi",
+	} {
+		if err := server.evalAndPlay(input); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the new note is sent, and it's synchronized with the notes that were
+	// sent before, so it plays right away.
+	notes := []*osc.Message{}
+	for _, msg := range bundles[len(bundles)-1].Messages {
+		if strings.HasSuffix(msg.Address, "/midi/note") {
+			notes = append(notes, msg)
+		}
+	}
+
+	if len(notes) != 1 {
+		t.Fatalf("expected 1 note to be sent, got %d", len(notes))
+	}
+
+	offset, note := notes[0].Arguments[0], notes[0].Arguments[1]
+	if note != int32(64) || offset != int32(0) {
+		t.Errorf("expected note 64 at offset 0, got %v at offset %v", note, offset)
+	}
+}
+
+// benchmarkSession is the input of a long REPL session, one line at a time.
+func benchmarkSession() []string {
+	inputs := []string{"piano: (tempo 120) o4"}
+	for i := 0; i < 200; i++ {
+		inputs = append(inputs, "c8 d e f g a b > c < | c4. d8 e2")
+	}
+
+	return inputs
+}
+
+func BenchmarkIncrementalEval(b *testing.B) {
+	inputs := benchmarkSession()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := evalIncrementally(inputs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkFullReparseEval is for comparison with BenchmarkIncrementalEval. It
+// evaluates the same session, but always parses all of the input received so
+// far.
+func BenchmarkFullReparseEval(b *testing.B) {
+	inputs := benchmarkSession()
+
+	for i := 0; i < b.N; i++ {
+		server := NewServer(0, WithDryRun())
+		server.fullReparse = true
+
+		for _, input := range inputs {
+			if _, err := server.updateScoreWithInput(input); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
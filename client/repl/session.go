@@ -0,0 +1,88 @@
+package repl
+
+import (
+	encjson "encoding/json"
+	"fmt"
+	"os"
+
+	"alda.io/client/generated"
+	log "alda.io/client/logging"
+)
+
+// A session file records the state of a REPL session, so that the session can
+// be resumed later, e.g. after the REPL server is restarted.
+type session struct {
+	// The version of Alda that saved the session.
+	Version string `json:"version"`
+	// The Alda code that was evaluated over the course of the session.
+	Input string `json:"input"`
+	// The number of semitones by which the score was transposed. (See
+	// `Server.Transpose`.)
+	Transposition int `json:"transposition,omitempty"`
+	// The score that the input produced. This is for reference only; when a
+	// session is loaded, the score is rebuilt from the input.
+	Score encjson.RawMessage `json:"score"`
+}
+
+// SaveSession writes the input that the server has received so far, along with
+// the resulting score, to a JSON file at the provided path. (See
+// `LoadSession`.)
+func (server *Server) SaveSession(path string) error {
+	sessionJSON, err := encjson.MarshalIndent(
+		session{
+			Version:       generated.ClientVersion,
+			Input:         server.input,
+			Transposition: server.transposition,
+			Score:         encjson.RawMessage(server.score.JSON().String()),
+		},
+		"",
+		"  ",
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, sessionJSON, 0644); err != nil {
+		return err
+	}
+
+	log.Info().Str("path", path).Msg("Saved session.")
+
+	return nil
+}
+
+// LoadSession replaces the server's score with one that it rebuilds from the
+// input recorded in a session file. (See `SaveSession`.)
+//
+// The score isn't sent to the player process, so nothing is heard until more
+// input is evaluated or the score is replayed.
+func (server *Server) LoadSession(path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var saved session
+	if err := encjson.Unmarshal(contents, &saved); err != nil {
+		return fmt.Errorf("unable to read session file %s: %w", path, err)
+	}
+
+	if err := server.Reset(); err != nil {
+		return err
+	}
+
+	server.transposition = saved.Transposition
+
+	if saved.Input != "" {
+		if _, err := server.updateScoreWithInput(saved.Input); err != nil {
+			return fmt.Errorf("unable to restore session from %s: %w", path, err)
+		}
+	}
+
+	log.Info().
+		Str("path", path).
+		Str("savedByVersion", saved.Version).
+		Msg("Loaded session.")
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"alda.io/client/model"
+	_ "alda.io/client/testing"
+)
+
+func TestSaveAndLoadSession(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	path := filepath.Join(t.TempDir(), "session.json")
+
+	server := NewServer(0)
+
+	for _, input := range []string{
+		"piano: (tempo 90) o5 c8 d e",
+		"f g",
+		"violin: o5 a2 b",
+	} {
+		if _, err := server.updateScoreWithInput(input); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := server.SaveSession(path); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewServer(0)
+
+	if err := restored.LoadSession(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.input != server.input {
+		t.Errorf("expected input %q, got %q", server.input, restored.input)
+	}
+
+	expected := model.Render(server.score)
+	if actual := model.Render(restored.score); actual != expected {
+		t.Errorf("expected score:\n%s\ngot:\n%s", expected, actual)
+	}
+
+	// New input continues where the session left off.
+	if _, err := restored.updateScoreWithInput("c"); err != nil {
+		t.Fatal(err)
+	}
+
+	notes := scoreNotes(restored)
+	if notes[len(notes)-1] != 72 {
+		t.Errorf("expected the violin to continue in octave 5, got %v", notes)
+	}
+}
+
+func TestLoadInvalidSession(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := os.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(0)
+
+	if _, err := server.updateScoreWithInput("piano: c d e"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.LoadSession(path); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// The session in progress is left alone.
+	if server.input == "" {
+		t.Error("expected the server's input to be unchanged")
+	}
+}
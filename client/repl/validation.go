@@ -7,6 +7,9 @@ import (
 
 var typeString = reflect.TypeOf("")
 
+// Bencoded integers are decoded as int64 values.
+var typeInt64 = reflect.TypeOf(int64(0))
+
 type requestValidationRule interface {
 	validate(request map[string]interface{}) []string
 }
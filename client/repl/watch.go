@@ -0,0 +1,165 @@
+package repl
+
+import (
+	"crypto/sha256"
+	"os"
+	"sync"
+	"time"
+
+	log "alda.io/client/logging"
+	"alda.io/client/parser"
+)
+
+// How often `Watch` checks whether the file has changed.
+var watchPollInterval = 50 * time.Millisecond
+
+// How long `Watch` waits for a file to stop changing before it replays it, so
+// that e.g. an editor saving a file in several writes results in a single
+// replay.
+var watchDebounce = 200 * time.Millisecond
+
+// fileVersion identifies a version of a file well enough to tell when it has
+// changed.
+//
+// Some file systems only record modification times to the nearest second or
+// two, so two saves of the same length in quick succession can have the same
+// modification time and size. The hash of the contents tells them apart.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+}
+
+func readFileVersion(path string) (fileVersion, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}, err
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return fileVersion{}, err
+	}
+
+	return fileVersion{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		hash:    sha256.Sum256(contents),
+	}, nil
+}
+
+// Watch replays an Alda score file whenever it changes, which is useful for
+// live coding: each time the file is saved, playback stops and the file's score
+// replaces the REPL session's score and is played from the beginning.
+//
+// Rapid changes are debounced, i.e. the file is replayed once it has stopped
+// changing for a short time.
+//
+// If the changed file can't be parsed, the error is logged and the previous
+// playback carries on undisturbed.
+//
+// NB: The file is polled for changes, because this module doesn't depend on a
+// library for file system notifications (e.g. fsnotify).
+//
+// Call the returned function to stop watching the file. It waits for a replay
+// that is in progress to finish, so it must not be called while handling a
+// request.
+func (server *Server) Watch(path string) (stop func(), err error) {
+	lastVersion, err := readFileVersion(path)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(watchPollInterval)
+		defer ticker.Stop()
+
+		var lastChange time.Time
+		pending := false
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-server.done:
+				return
+			case now := <-ticker.C:
+				version, err := readFileVersion(path)
+				if err != nil {
+					// The file might be in the middle of being replaced by an editor, so
+					// we'll try again next time.
+					continue
+				}
+
+				if version != lastVersion {
+					lastVersion = version
+					lastChange = now
+					pending = true
+					continue
+				}
+
+				if pending && now.Sub(lastChange) >= watchDebounce {
+					pending = false
+					server.replayWatchedFile(path, done)
+				}
+			}
+		}
+	}()
+
+	var stopOnce sync.Once
+
+	return func() {
+		stopOnce.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}, nil
+}
+
+// replayWatchedFile replays a watched file (see `Watch`), unless watching the
+// file has stopped, i.e. `done` is closed.
+//
+// This runs in the routine that polls the file, so it holds the state lock
+// while it replaces the score, in case a request is being handled at the same
+// time.
+func (server *Server) replayWatchedFile(path string, done <-chan struct{}) {
+	// We parse the file before stopping playback, so that the previous playback
+	// is left alone if the file can't be parsed.
+	if _, err := parser.ParseFile(path); err != nil {
+		log.Warn().
+			Err(err).
+			Str("path", path).
+			Msg("Failed to parse watched file.")
+
+		return
+	}
+
+	server.stateLock.Lock()
+	defer server.stateLock.Unlock()
+
+	select {
+	case <-done:
+		return
+	case <-server.done:
+		return
+	default:
+	}
+
+	log.Info().Str("path", path).Msg("Watched file changed. Replaying.")
+
+	if err := server.Stop(); err != nil {
+		log.Warn().Err(err).Msg("Failed to stop playback.")
+	}
+
+	if err := server.PlayFile(path, ReplaceScore()); err != nil {
+		log.Warn().
+			Err(err).
+			Str("path", path).
+			Msg("Failed to play watched file.")
+	}
+}
@@ -0,0 +1,171 @@
+package repl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	originalPollInterval := watchPollInterval
+	t.Cleanup(func() { watchPollInterval = originalPollInterval })
+	watchPollInterval = 10 * time.Millisecond
+
+	path := filepath.Join(t.TempDir(), "score.alda")
+
+	writeScore := func(contents string) {
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeScore("piano: c")
+
+	var capture bytes.Buffer
+
+	server := NewServer(0, WithDryRun())
+	server.CaptureOSC(&capture)
+
+	stop, err := server.Watch(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two saves in quick succession result in a single replay of the latest
+	// version of the file.
+	writeScore("piano: d")
+	time.Sleep(20 * time.Millisecond)
+	writeScore("piano: e f")
+
+	time.Sleep(watchDebounce + 300*time.Millisecond)
+
+	// A version of the file that can't be parsed isn't played.
+	writeScore("piano: g ]")
+
+	time.Sleep(watchDebounce + 300*time.Millisecond)
+
+	stop()
+
+	notes := capturedNotes(capture.String())
+
+	expected := []string{"64", "65"}
+	if strings.Join(notes, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected notes %v to be played, got %v", expected, notes)
+	}
+
+	if strings.Count(capture.String(), "/system/stop") != 1 {
+		t.Errorf("expected playback to be stopped once:\n%s", capture.String())
+	}
+
+	if notes := scoreNotes(server); len(notes) != 2 {
+		t.Errorf("expected the score to be the last valid version, got %v", notes)
+	}
+}
+
+func TestWatchNoticesChangeWithSameModTimeAndSize(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	originalPollInterval := watchPollInterval
+	t.Cleanup(func() { watchPollInterval = originalPollInterval })
+	watchPollInterval = 10 * time.Millisecond
+
+	path := filepath.Join(t.TempDir(), "score.alda")
+	if err := os.WriteFile(path, []byte("piano: c"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var capture bytes.Buffer
+
+	server := NewServer(0, WithDryRun())
+	server.CaptureOSC(&capture)
+
+	stop, err := server.Watch(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A file system with a coarse modification time resolution would record the
+	// same modification time for both saves.
+	if err := os.WriteFile(path, []byte("piano: d"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(watchDebounce + 300*time.Millisecond)
+
+	stop()
+
+	notes := capturedNotes(capture.String())
+
+	expected := []string{"62"}
+	if strings.Join(notes, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected notes %v to be played, got %v", expected, notes)
+	}
+}
+
+func TestWatchWhileHandlingRequests(t *testing.T) {
+	stubPlayerSystem(t, &fakePlayerSystem{})
+
+	originalPollInterval := watchPollInterval
+	originalDebounce := watchDebounce
+	t.Cleanup(func() {
+		watchPollInterval = originalPollInterval
+		watchDebounce = originalDebounce
+	})
+	watchPollInterval = time.Millisecond
+	watchDebounce = time.Millisecond
+
+	path := filepath.Join(t.TempDir(), "score.alda")
+	if err := os.WriteFile(path, []byte("piano: c"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(0, WithDryRun())
+	go server.handleRequests()
+	t.Cleanup(func() { close(server.requestQueue) })
+
+	// Responses are written to the client's end of the connection, so we need
+	// to read them.
+	conn, clientConn := net.Pipe()
+	t.Cleanup(func() { conn.Close() })
+	go io.Copy(io.Discard, clientConn)
+
+	stop, err := server.Watch(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When run with -race, this fails if the replays of the watched file aren't
+	// synchronized with the requests.
+	for i := 0; i < 20; i++ {
+		contents := fmt.Sprintf("piano: o4 c%d", i+1)
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		server.requestQueue <- nREPLRequest{
+			conn: conn,
+			msg:  map[string]interface{}{"op": "eval-and-play", "code": "d"},
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	stop()
+}
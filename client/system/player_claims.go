@@ -0,0 +1,275 @@
+package system
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "alda.io/client/logging"
+)
+
+// When more than one Alda REPL server is running, each one needs a player
+// process of its own. Otherwise, two REPL servers could end up sending their
+// scores to the same player process, and the scores would be played over each
+// other.
+//
+// To prevent that, a REPL server claims the player process that it's using by
+// writing a claim file into the Alda cache directory. The claim includes the
+// claimant ID of the REPL server and an expiry. The REPL server renews its claim
+// regularly, and releases it when it stops using the player process. If the
+// REPL server dies without releasing its claim, the claim expires, and the
+// player process is fair game again.
+
+// NewPlayerClaimant returns a claimant ID that is unique to the caller. The ID
+// includes the PID of the current process and a random token, so that REPL
+// servers running in the same process don't share claims.
+func NewPlayerClaimant() string {
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		panic(err)
+	}
+
+	return fmt.Sprintf("%d-%s", os.Getpid(), hex.EncodeToString(token))
+}
+
+// PlayerClaimDuration is how long a claim on a player process lasts if it isn't
+// renewed.
+const PlayerClaimDuration = 10 * time.Second
+
+// PlayerClaim describes a claim that a REPL server has on a player process.
+type PlayerClaim struct {
+	// The claimant ID of the REPL server that claimed the player process. (See
+	// `NewPlayerClaimant`.)
+	Claimant string `json:"claimant"`
+	// When the claim expires, in milliseconds since the Unix epoch.
+	Expiry int64 `json:"expiry"`
+}
+
+func (claim PlayerClaim) expired() bool {
+	return time.Now().UnixMilli() > claim.Expiry
+}
+
+func playerClaimPath(playerID string) string {
+	return CachePath("state", "player-claims", playerID+".json")
+}
+
+// readPlayerClaim returns the current claim on the player process with the
+// provided ID. The returned boolean is false if there is no such claim.
+func readPlayerClaim(playerID string) (PlayerClaim, bool, error) {
+	contents, err := os.ReadFile(playerClaimPath(playerID))
+	if errors.Is(err, os.ErrNotExist) {
+		return PlayerClaim{}, false, nil
+	}
+	if err != nil {
+		return PlayerClaim{}, false, err
+	}
+
+	var claim PlayerClaim
+	if err := json.Unmarshal(contents, &claim); err != nil {
+		return PlayerClaim{}, false, err
+	}
+
+	return claim, true, nil
+}
+
+// ClaimPlayer claims the player process with the provided ID on behalf of
+// `claimant`, or renews the claim if `claimant` has already claimed it.
+//
+// Returns false if another claimant has a claim on the player process that
+// hasn't expired.
+func ClaimPlayer(id string, claimant string) (bool, error) {
+	claim, found, err := readPlayerClaim(id)
+	if err != nil {
+		return false, err
+	}
+
+	if found && claim.Claimant != claimant && !claim.expired() {
+		return false, nil
+	}
+
+	claimJSON, err := json.Marshal(PlayerClaim{
+		Claimant: claimant,
+		Expiry:   time.Now().Add(PlayerClaimDuration).UnixMilli(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	path := playerClaimPath(id)
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return false, err
+	}
+
+	switch {
+	// Other claimants leave an unexpired claim alone, so renewing our own claim
+	// can't race with them.
+	case found && claim.Claimant == claimant && !claim.expired():
+		if err := writeFileAtomically(path, claimJSON); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	case found:
+		claimed, err := takeOverExpiredClaim(id, claimant, claimJSON)
+		if err != nil || !claimed {
+			return false, err
+		}
+	default:
+		created, err := createFileExclusively(path, claimJSON)
+		if err != nil || !created {
+			return false, err
+		}
+	}
+
+	// Confirm that the claim that we made is the one in place.
+	claim, found, err = readPlayerClaim(id)
+	if err != nil {
+		return false, err
+	}
+
+	return found && claim.Claimant == claimant, nil
+}
+
+// takeOverExpiredClaim replaces an expired claim on the player process with the
+// provided ID with our own claim.
+//
+// Several processes might find the same expired claim at the same time, so
+// only the one that holds a lock file gets to replace it. Returns false if
+// another process holds the lock, or if the claim was renewed in the meantime.
+func takeOverExpiredClaim(
+	id string, claimant string, claimJSON []byte,
+) (bool, error) {
+	path := playerClaimPath(id)
+	lockPath := path + ".lock"
+
+	locked, err := createFileExclusively(lockPath, []byte{})
+	if err != nil {
+		return false, err
+	}
+
+	if !locked {
+		// If a process died while holding the lock, the lock is removed, so that
+		// the next attempt can succeed.
+		if info, err := os.Stat(lockPath); err == nil &&
+			time.Since(info.ModTime()) > PlayerClaimDuration {
+			os.Remove(lockPath)
+		}
+
+		return false, nil
+	}
+	defer os.Remove(lockPath)
+
+	claim, found, err := readPlayerClaim(id)
+	if err != nil {
+		return false, err
+	}
+
+	if found && claim.Claimant != claimant && !claim.expired() {
+		return false, nil
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+
+	return createFileExclusively(path, claimJSON)
+}
+
+// writeTempFile writes `contents` to a new temporary file in the same directory
+// as `path`, and returns the temporary file's path.
+func writeTempFile(path string, contents []byte) (string, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = file.Write(contents)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), nil
+}
+
+// createFileExclusively creates a file at `path` with the provided contents,
+// unless there is already a file there, in which case it returns false.
+//
+// The file is written elsewhere first and then linked into place, so that
+// other processes never see it partially written.
+func createFileExclusively(path string, contents []byte) (bool, error) {
+	tempPath, err := writeTempFile(path, contents)
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tempPath)
+
+	if err := os.Link(tempPath, path); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// writeFileAtomically replaces the file at `path` with one that has the
+// provided contents, so that other processes see either the old contents or
+// the new contents, never a partially written file.
+func writeFileAtomically(path string, contents []byte) error {
+	tempPath, err := writeTempFile(path, contents)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	return nil
+}
+
+// ReleasePlayer releases `claimant`'s claim on the player process with the
+// provided ID. This is a no-op if `claimant` doesn't have a claim on the player
+// process.
+func ReleasePlayer(id string, claimant string) error {
+	claim, found, err := readPlayerClaim(id)
+	if err != nil || !found || claim.Claimant != claimant {
+		return err
+	}
+
+	err = os.Remove(playerClaimPath(id))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// claimedByAnother returns true if a claimant other than `claimant` has an
+// unexpired claim on the player process with the provided ID.
+func claimedByAnother(id string, claimant string) bool {
+	claim, found, err := readPlayerClaim(id)
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("playerID", id).
+			Msg("Failed to read player claim.")
+
+		return false
+	}
+
+	return found && claim.Claimant != claimant && !claim.expired()
+}
@@ -0,0 +1,160 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	_ "alda.io/client/testing"
+)
+
+func useTempCacheDir(t *testing.T) {
+	originalCacheDir := CacheDir
+	CacheDir = t.TempDir()
+	t.Cleanup(func() { CacheDir = originalCacheDir })
+}
+
+func TestClaimPlayer(t *testing.T) {
+	useTempCacheDir(t)
+
+	first := NewPlayerClaimant()
+	// Claimants in the same process are distinct.
+	second := NewPlayerClaimant()
+
+	claim := func(claimant string, expected bool) {
+		t.Helper()
+
+		claimed, err := ClaimPlayer("abc", claimant)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if claimed != expected {
+			t.Errorf(
+				"expected claim by %s to return %v, got %v",
+				claimant, expected, claimed,
+			)
+		}
+	}
+
+	claim(first, true)
+	// Renewing a claim is allowed.
+	claim(first, true)
+	// Another claimant can't claim the same player.
+	claim(second, false)
+
+	if !claimedByAnother("abc", second) {
+		t.Error("expected player to be claimed by another claimant")
+	}
+
+	if claimedByAnother("abc", first) {
+		t.Error("expected player not to be claimed by another claimant")
+	}
+
+	// A caller that isn't a claimant sees every claim.
+	if !claimedByAnother("abc", "") {
+		t.Error("expected player to be claimed")
+	}
+
+	// Only the claimant that claimed the player can release the claim.
+	if err := ReleasePlayer("abc", second); err != nil {
+		t.Fatal(err)
+	}
+	claim(second, false)
+
+	if err := ReleasePlayer("abc", first); err != nil {
+		t.Fatal(err)
+	}
+	claim(second, true)
+}
+
+func TestExpiredPlayerClaim(t *testing.T) {
+	useTempCacheDir(t)
+
+	expiredClaim, err := json.Marshal(PlayerClaim{
+		Claimant: "1",
+		Expiry:   time.Now().Add(-time.Second).UnixMilli(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ClaimPlayer("abc", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(playerClaimPath("abc"), expiredClaim, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := ClaimPlayer("abc", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !claimed {
+		t.Error("expected an expired claim to be superseded")
+	}
+}
+
+func TestConcurrentPlayerClaims(t *testing.T) {
+	useTempCacheDir(t)
+
+	claimConcurrently := func() int {
+		t.Helper()
+
+		var wg sync.WaitGroup
+		var lock sync.Mutex
+		winners := 0
+
+		for i := 1; i <= 20; i++ {
+			wg.Add(1)
+			go func(claimant string) {
+				defer wg.Done()
+
+				claimed, err := ClaimPlayer("abc", claimant)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				if claimed {
+					lock.Lock()
+					winners++
+					lock.Unlock()
+				}
+			}(fmt.Sprint(i))
+		}
+
+		wg.Wait()
+		return winners
+	}
+
+	if winners := claimConcurrently(); winners != 1 {
+		t.Errorf("expected exactly 1 claimant to claim the player, got %d", winners)
+	}
+
+	expiredClaim, err := json.Marshal(PlayerClaim{
+		Claimant: "100",
+		Expiry:   time.Now().Add(-time.Second).UnixMilli(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(playerClaimPath("abc"), expiredClaim, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if winners := claimConcurrently(); winners != 1 {
+		t.Errorf(
+			"expected exactly 1 claimant to take over an expired claim, got %d",
+			winners,
+		)
+	}
+}
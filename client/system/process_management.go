@@ -8,7 +8,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"alda.io/client/color"
@@ -23,9 +25,11 @@ import (
 const reasonableTimeout = 20 * time.Second
 
 func DeletePlayerStateFile(playerID string) error {
-	path := CachePath(
-		"state", "players", generated.ClientVersion, playerID+".json",
-	)
+	return deletePlayerStateFile(generated.ClientVersion, playerID)
+}
+
+func deletePlayerStateFile(version string, playerID string) error {
+	path := CachePath("state", "players", version, playerID+".json")
 
 	log.Debug().
 		Str("path", path).
@@ -111,6 +115,7 @@ func CleanUpStaleStateFiles() error {
 	for _, stateDir := range []string{
 		CachePath("state", "players"),
 		CachePath("state", "repl-servers"),
+		CachePath("state", "player-claims"),
 	} {
 		if err := cleanUpStaleStateFiles(stateDir); err != nil {
 			return err
@@ -122,12 +127,28 @@ func CleanUpStaleStateFiles() error {
 
 // PlayerState describes the current state of a player process. These states are
 // continously written to files by each player process. (See: StateManager.kt.)
+//
+// When Host is empty, the player process is assumed to be running on
+// DefaultPlayerHost.
+//
+// Version is the version of the player process. Each player writes its state
+// file into a directory named after its version, so Version is populated from
+// the name of that directory rather than from the contents of the file.
 type PlayerState struct {
-	State     string
-	Port      int
-	Expiry    int64
-	ID        string
-	ReadError error
+	State     string `json:"state"`
+	Host      string `json:"host,omitempty"`
+	Port      int    `json:"port"`
+	Expiry    int64  `json:"expiry"`
+	ID        string `json:"id"`
+	Version   string `json:"version,omitempty"`
+	ReadError error  `json:"-"`
+}
+
+// Expired returns true if the player process's expiry (in milliseconds since
+// the Unix epoch) has passed. A player process shuts itself down when it
+// expires, so a player process whose state is expired is probably stale.
+func (state PlayerState) Expired() bool {
+	return time.Now().UnixMilli() > state.Expiry
 }
 
 // REPLServerState describes the current state of an Alda REPL server process.
@@ -166,8 +187,16 @@ func processFiles(
 // directory and returns a list of player state structs describing the current
 // state of each player process.
 //
+// Only players whose version is the same as the client version are included.
+//
 // Returns an error if something goes wrong.
 func ReadPlayerStates() ([]PlayerState, error) {
+	return readPlayerStates(generated.ClientVersion)
+}
+
+// readPlayerStates reads the state files of the player processes of the
+// specified version.
+func readPlayerStates(version string) ([]PlayerState, error) {
 	if err := CleanUpStaleStateFiles(); err != nil {
 		log.Warn().Err(err).Msg("Failed to clean up stale state files.")
 	}
@@ -176,7 +205,7 @@ func ReadPlayerStates() ([]PlayerState, error) {
 	var state PlayerState
 
 	if err := processFiles(
-		CachePath("state", "players", generated.ClientVersion),
+		CachePath("state", "players", version),
 		func(filename string, contents []byte, readError error) {
 			
 
@@ -188,6 +217,7 @@ func ReadPlayerStates() ([]PlayerState, error) {
 			}
 
 			state.ID = strings.Replace(filename, ".json", "", 1)
+			state.Version = version
 			state.ReadError = readError
 
 			states = append(states, state)
@@ -299,11 +329,20 @@ To troubleshoot:
 //
 // Returns an error if the player isn't reachable after the timeout duration.
 func PingPlayer(port int) (*osc.Client, error) {
+	return PingPlayerAt(DefaultPlayerHost, port)
+}
+
+// PingPlayerAt is like PingPlayer, but for a player process running on the
+// provided host.
+func PingPlayerAt(host string, port int) (*osc.Client, error) {
 	log.Debug().
+		Str("host", host).
 		Int("port", port).
 		Msg("Waiting for player to respond to ping.")
 
-	client := osc.NewClient("localhost", port, osc.ClientProtocol(osc.TCP))
+	client := osc.NewClient(
+		OSCClientHost(host), port, osc.ClientProtocol(osc.TCP),
+	)
 
 	err := util.Await(
 		func() error {
@@ -315,6 +354,129 @@ func PingPlayer(port int) (*osc.Client, error) {
 	return client, err
 }
 
+// AvailablePlayers returns the current states of all player processes that are
+// in an available state.
+//
+// Player processes that a claimant other than `claimant` has claimed are not
+// included. (See `ClaimPlayer`.)
+//
+// Unlike FindAvailablePlayer, this does not confirm that the players are
+// reachable.
+func AvailablePlayers(claimant string) ([]PlayerState, error) {
+	players, err := ReadPlayerStates()
+	if err != nil {
+		return nil, err
+	}
+
+	availablePlayers := []PlayerState{}
+	for _, player := range players {
+		if player.State == "ready" &&
+			!claimedByAnother(player.ID, claimant) {
+			availablePlayers = append(availablePlayers, player)
+		}
+	}
+
+	return availablePlayers, nil
+}
+
+// DefaultPlayerStateTTL is how long a player state file can go without being
+// updated before PrunePlayers considers it stale. A running player process
+// touches its state file every 10 seconds, so a state file that hasn't been
+// touched for a while most likely belongs to a player process that is gone.
+const DefaultPlayerStateTTL = 30 * time.Second
+
+// PrunePlayers is like PrunePlayersOlderThan, using DefaultPlayerStateTTL.
+func PrunePlayers() (removed int, err error) {
+	return PrunePlayersOlderThan(DefaultPlayerStateTTL)
+}
+
+// PrunePlayersOlderThan deletes the state files of player processes that are
+// probably gone, so that they aren't mistaken for available player processes.
+// A player process is considered gone if its state file hasn't been updated
+// within the provided TTL, or if its expiry has passed (see `Expired`).
+//
+// Player state files don't include the PID of the player process, so we can't
+// check whether the process is still running directly.
+//
+// Returns the number of state files that were deleted.
+func PrunePlayersOlderThan(ttl time.Duration) (removed int, err error) {
+	dir := CachePath("state", "players", generated.ClientVersion)
+
+	if err := processFiles(
+		dir,
+		func(filename string, contents []byte, readError error) {
+			if readError != nil {
+				return
+			}
+
+			var state PlayerState
+			if err := json.Unmarshal(contents, &state); err != nil {
+				return
+			}
+
+			path := filepath.Join(dir, filename)
+
+			fileInfo, err := os.Stat(path)
+			if err != nil {
+				return
+			}
+
+			age := time.Since(fileInfo.ModTime())
+			if age <= ttl && !state.Expired() {
+				return
+			}
+
+			log.Debug().
+				Str("path", path).
+				Float64("seconds-since-modified", age.Seconds()).
+				Bool("expired", state.Expired()).
+				Msg("Deleting stale player state file.")
+
+			err = os.Remove(path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Warn().
+					Err(err).
+					Str("path", path).
+					Msg("Failed to delete stale player state file.")
+				return
+			}
+
+			removed++
+		},
+	); err != nil {
+		return removed, err
+	}
+
+	return removed, nil
+}
+
+// ListPlayers returns the current states of all player processes, whether or
+// not they are available, sorted by ID.
+//
+// Player state files that couldn't be read are skipped.
+func ListPlayers() ([]PlayerState, error) {
+	states, err := ReadPlayerStates()
+	if err != nil {
+		return nil, err
+	}
+
+	players := []PlayerState{}
+	for _, state := range states {
+		if state.ReadError != nil {
+			log.Warn().Err(state.ReadError).Msg("Failed to read player state")
+			continue
+		}
+
+		players = append(players, state)
+	}
+
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].ID < players[j].ID
+	})
+
+	return players, nil
+}
+
 // FindAvailablePlayer finds a player that is in an available state, confirms
 // that it can be reached by sending a ping, and returns current information
 // about the player's state.
@@ -326,10 +488,28 @@ func PingPlayer(port int) (*osc.Client, error) {
 // and will also fill the player pool to help ensure that we don't run out of
 // players.
 //
+// Player processes that have been claimed are skipped. (See `ClaimPlayer`.)
+//
 // Returns `ErrNoPlayersAvailable` if no player is currently in an available
 // state.
 func FindAvailablePlayer() (PlayerState, error) {
-	players, err := ReadPlayerStates()
+	return FindAvailablePlayerFor("")
+}
+
+// FindAvailablePlayerFor is like FindAvailablePlayer, but it doesn't skip player
+// processes that `claimant` has claimed.
+func FindAvailablePlayerFor(claimant string) (PlayerState, error) {
+	return findAvailablePlayer(generated.ClientVersion, claimant)
+}
+
+// FindAvailablePlayerForVersion is like FindAvailablePlayer, but it looks for a
+// player process of the specified version instead of the client version.
+func FindAvailablePlayerForVersion(v string) (PlayerState, error) {
+	return findAvailablePlayer(v, "")
+}
+
+func findAvailablePlayer(v string, claimant string) (PlayerState, error) {
+	players, err := readPlayerStates(v)
 	if err != nil {
 		return PlayerState{}, err
 	}
@@ -339,13 +519,19 @@ func FindAvailablePlayer() (PlayerState, error) {
 			continue
 		}
 
-		if _, err := PingPlayer(player.Port); err != nil {
+		// Another claimant (e.g. a different REPL server) is using this player
+		// process. (See `ClaimPlayer`.)
+		if claimedByAnother(player.ID, claimant) {
+			continue
+		}
+
+		if _, err := PingPlayerAt(player.Host, player.Port); err != nil {
 			log.Warn().
 				Interface("player", player).
 				Err(err).
 				Msg("Failed to reach player process. Will try another one.")
 
-			if err := DeletePlayerStateFile(player.ID); err != nil {
+			if err := deletePlayerStateFile(v, player.ID); err != nil {
 				return PlayerState{}, err
 			}
 
@@ -353,7 +539,7 @@ func FindAvailablePlayer() (PlayerState, error) {
 				return PlayerState{}, err
 			}
 
-			return FindAvailablePlayer()
+			return findAvailablePlayer(v, claimant)
 		}
 
 		return player, nil
@@ -417,6 +603,15 @@ func AldaPlayerPath() (playerPath string, sameVersion bool, err error) {
 		return "", false, ErrAldaPlayerNotFoundOnPath
 	}
 
+	return checkPlayerVersion(aldaPlayer)
+}
+
+// checkPlayerVersion runs `alda-player info` using the provided `alda-player`
+// executable and compares the player version with the client version, logging
+// a warning if they're different.
+func checkPlayerVersion(
+	aldaPlayer string,
+) (playerPath string, sameVersion bool, err error) {
 	infoCmd := exec.Command(aldaPlayer, "info")
 	infoCmd.Stdout = nil
 	infoCmd.Stderr = nil
@@ -448,14 +643,74 @@ func AldaPlayerPath() (playerPath string, sameVersion bool, err error) {
 	return aldaPlayer, playerVersion == generated.ClientVersion, nil
 }
 
+// majorVersion returns the major version component of a version string, e.g.
+// "2" for "2.2.1".
+func majorVersion(version string) string {
+	return strings.SplitN(version, ".", 2)[0]
+}
+
+// IsCompatiblePlayerVersion returns true if a player process of the specified
+// version can be used by this client, i.e. the major version of the player is
+// the same as the major version of the client.
+//
+// An empty version is considered compatible, as there is nothing that we can
+// check.
+func IsCompatiblePlayerVersion(playerVersion string) bool {
+	return playerVersion == "" ||
+		majorVersion(playerVersion) == majorVersion(generated.ClientVersion)
+}
+
+// PlayerLaunchConfig controls how player processes are launched when the
+// player pool is filled. The zero value launches `alda-player run` from the
+// PATH, with the same environment as the current process.
+type PlayerLaunchConfig struct {
+	// The path to the `alda-player` executable. When empty, `alda-player` is
+	// looked up on the PATH.
+	BinaryPath string
+	// Additional arguments to include after `alda-player run`.
+	ExtraArgs []string
+	// Additional environment variables, in the form "KEY=value", to set for the
+	// player processes on top of the environment of the current process.
+	Env []string
+}
+
+// playerPath returns the path to the `alda-player` executable to use. (See
+// `AldaPlayerPath`.)
+func (config PlayerLaunchConfig) playerPath() (string, error) {
+	if config.BinaryPath == "" {
+		playerPath, _, err := AldaPlayerPath()
+		return playerPath, err
+	}
+
+	playerPath, _, err := checkPlayerVersion(config.BinaryPath)
+	return playerPath, err
+}
+
+// spawnCommand returns the command that launches a player process using the
+// provided `alda-player` executable.
+func (config PlayerLaunchConfig) spawnCommand(playerPath string) *exec.Cmd {
+	runCmd := exec.Command(
+		playerPath, append([]string{"run"}, config.ExtraArgs...)...,
+	)
+
+	if len(config.Env) > 0 {
+		runCmd.Env = append(os.Environ(), config.Env...)
+	}
+
+	return runCmd
+}
+
 // spawnPlayer spawns an Alda player process, using the provided absolute path
 // to `alda-player`.
 //
 // Note that this path can be obtained by calling `AldaPlayerPath()`, which also
 // checks that the client and player versions are the same and logs a warning if
 // they aren't.
-func spawnPlayer(playerPath string) error {
-	runCmd := exec.Command(playerPath, "run")
+//
+// This is overridden in tests, so that filling the player pool doesn't start
+// real player processes.
+var spawnPlayer = func(config PlayerLaunchConfig, playerPath string) error {
+	runCmd := config.spawnCommand(playerPath)
 	if err := runCmd.Start(); err != nil {
 		return err
 	}
@@ -465,11 +720,64 @@ func spawnPlayer(playerPath string) error {
 	return nil
 }
 
+// PlayerPoolSize returns the number of player processes that are either
+// available or starting up, i.e. the number of player processes that are
+// available, or will soon be available, for a REPL server to use.
+//
+// Returns an error if something goes wrong.
+func PlayerPoolSize() (int, error) {
+	players, err := ReadPlayerStates()
+	if err != nil {
+		return 0, err
+	}
+
+	poolSize := 0
+	for _, player := range players {
+		if player.State == "ready" || player.State == "starting" {
+			poolSize++
+		}
+	}
+
+	return poolSize, nil
+}
+
+// DefaultPlayerPoolSize is the number of player processes that FillPlayerPool
+// keeps available, unless SetPlayerPoolSize is called.
+const DefaultPlayerPoolSize = 3
+
+// The number of player processes that FillPlayerPool keeps available. This is
+// accessed atomically, because the player pool is filled from background
+// routines. (See SetPlayerPoolSize.)
+var desiredPlayerPoolSize int32 = DefaultPlayerPoolSize
+
+// SetPlayerPoolSize sets the number of player processes that FillPlayerPool
+// keeps available. Each player process uses a fair amount of memory, so on
+// constrained machines, it can make sense to keep fewer of them around.
+//
+// Returns an error if `n` is less than 1, because there must always be a player
+// process available to play with.
+func SetPlayerPoolSize(n int) error {
+	if n < 1 {
+		return fmt.Errorf("player pool size must be at least 1, got %d", n)
+	}
+
+	atomic.StoreInt32(&desiredPlayerPoolSize, int32(n))
+
+	return nil
+}
+
 // FillPlayerPool ensures that a minimum desired number of player processes is
-// available. Spawns as many player processes as it takes to make that happen.
+// available (see SetPlayerPoolSize). Spawns as many player processes as it
+// takes to make that happen.
 //
 // Returns an error if something goes wrong.
 func FillPlayerPool() error {
+	return FillPlayerPoolWith(PlayerLaunchConfig{})
+}
+
+// FillPlayerPoolWith is like FillPlayerPool, but it launches any new player
+// processes as described by the provided PlayerLaunchConfig.
+func FillPlayerPoolWith(config PlayerLaunchConfig) error {
 	// If ALDA_DISABLE_SPAWNING is set to true, we do nothing.
 	//
 	// This is useful for CI/CD purposes. (See .circleci/config.yml.)
@@ -481,24 +789,17 @@ func FillPlayerPool() error {
 		return nil
 	}
 
-	playerPath, _, err := AldaPlayerPath()
+	playerPath, err := config.playerPath()
 	if err != nil {
 		return err
 	}
 
-	players, err := ReadPlayerStates()
+	availablePlayers, err := PlayerPoolSize()
 	if err != nil {
 		return err
 	}
 
-	availablePlayers := 0
-	for _, player := range players {
-		if player.State == "ready" || player.State == "starting" {
-			availablePlayers++
-		}
-	}
-
-	desiredAvailablePlayers := 3
+	desiredAvailablePlayers := int(atomic.LoadInt32(&desiredPlayerPoolSize))
 	playersToStart := desiredAvailablePlayers - availablePlayers
 
 	log.Debug().
@@ -512,7 +813,7 @@ func FillPlayerPool() error {
 	for i := 0; i < playersToStart; i++ {
 		result := make(chan error)
 		results = append(results, result)
-		go func() { result <- spawnPlayer(playerPath) }()
+		go func() { result <- spawnPlayer(config, playerPath) }()
 	}
 
 	for _, result := range results {
@@ -0,0 +1,283 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"alda.io/client/generated"
+	_ "alda.io/client/testing"
+)
+
+func TestIsCompatiblePlayerVersion(t *testing.T) {
+	major := majorVersion(generated.ClientVersion)
+
+	for version, expected := range map[string]bool{
+		"":                      true,
+		generated.ClientVersion: true,
+		major + ".0.0":          true,
+		major + ".999.999":      true,
+		major + "0.0.0":         false,
+		"0.0.1":                 false,
+		"999.0.0":               false,
+		"not-a-version":         false,
+	} {
+		if actual := IsCompatiblePlayerVersion(version); actual != expected {
+			t.Errorf(
+				"expected compatibility of player version %q to be %v, got %v",
+				version, expected, actual,
+			)
+		}
+	}
+}
+
+func TestListPlayers(t *testing.T) {
+	useTempCacheDir(t)
+
+	dir := CachePath("state", "players", generated.ClientVersion)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, state := range map[string]string{
+		"ccc": `{"port": 27280, "expiry": 1, "state": "ready"}`,
+		"aaa": `{"port": 27278, "expiry": 1, "state": "ready"}`,
+		"bbb": `{"port": 27279, "expiry": 1, "state": "starting"}`,
+	} {
+		err := os.WriteFile(
+			filepath.Join(dir, id+".json"), []byte(state), 0644,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	players, err := ListPlayers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []PlayerState{
+		{ID: "aaa", Port: 27278, Expiry: 1, State: "ready"},
+		{ID: "bbb", Port: 27279, Expiry: 1, State: "starting"},
+		{ID: "ccc", Port: 27280, Expiry: 1, State: "ready"},
+	}
+
+	if len(players) != len(expected) {
+		t.Fatalf("expected %d players, got %#v", len(expected), players)
+	}
+
+	for i, player := range players {
+		expected[i].Version = generated.ClientVersion
+
+		if player != expected[i] {
+			t.Errorf("expected player %#v, got %#v", expected[i], player)
+		}
+
+		if !player.Expired() {
+			t.Errorf("expected player %s to be expired", player.ID)
+		}
+	}
+}
+
+func TestPrunePlayers(t *testing.T) {
+	useTempCacheDir(t)
+
+	dir := CachePath("state", "players", generated.ClientVersion)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	future := time.Now().Add(time.Minute).UnixMilli()
+	past := time.Now().Add(-time.Minute).UnixMilli()
+
+	for id, expiry := range map[string]int64{
+		"fresh":   future,
+		"expired": past,
+		"stale":   future,
+	} {
+		err := os.WriteFile(
+			filepath.Join(dir, id+".json"),
+			[]byte(fmt.Sprintf(`{"port": 27278, "expiry": %d}`, expiry)),
+			0644,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The "stale" player hasn't updated its state file in a while.
+	longAgo := time.Now().Add(-time.Hour)
+	err := os.Chtimes(filepath.Join(dir, "stale.json"), longAgo, longAgo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := PrunePlayersOlderThan(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 2 {
+		t.Errorf("expected 2 state files to be removed, got %d", removed)
+	}
+
+	for id, shouldExist := range map[string]bool{
+		"fresh":   true,
+		"expired": false,
+		"stale":   false,
+	} {
+		_, err := os.Stat(filepath.Join(dir, id+".json"))
+		if exists := err == nil; exists != shouldExist {
+			t.Errorf("expected %s to exist: %v, got %v", id, shouldExist, exists)
+		}
+	}
+}
+
+func TestPlayerLaunchConfigSpawnCommand(t *testing.T) {
+	config := PlayerLaunchConfig{
+		BinaryPath: "/opt/alda/bin/alda-player",
+		ExtraArgs:  []string{"--soundfont", "/opt/alda/FluidR3_GM.sf2"},
+		Env:        []string{"ALDA_TEST=yes"},
+	}
+
+	cmd := config.spawnCommand(config.BinaryPath)
+
+	if cmd.Path != config.BinaryPath {
+		t.Errorf("expected path %s, got %s", config.BinaryPath, cmd.Path)
+	}
+
+	expectedArgs := []string{
+		"/opt/alda/bin/alda-player", "run", "--soundfont", "/opt/alda/FluidR3_GM.sf2",
+	}
+
+	if fmt.Sprint(cmd.Args) != fmt.Sprint(expectedArgs) {
+		t.Errorf("expected args %q, got %q", expectedArgs, cmd.Args)
+	}
+
+	if len(cmd.Env) == 0 || cmd.Env[len(cmd.Env)-1] != "ALDA_TEST=yes" {
+		t.Errorf("expected ALDA_TEST=yes in the environment, got %q", cmd.Env)
+	}
+
+	// By default, player processes are launched with `alda-player run`, and
+	// they inherit the environment of the current process.
+	cmd = PlayerLaunchConfig{}.spawnCommand("alda-player")
+
+	if fmt.Sprint(cmd.Args) != fmt.Sprint([]string{"alda-player", "run"}) {
+		t.Errorf("expected default args, got %q", cmd.Args)
+	}
+
+	if cmd.Env != nil {
+		t.Errorf("expected the environment to be inherited, got %q", cmd.Env)
+	}
+}
+
+// fakePlayerExecutable writes a script that answers `alda-player info` like a
+// real `alda-player` executable, and returns its path.
+func fakePlayerExecutable(t *testing.T) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake alda-player executable is a shell script")
+	}
+
+	path := filepath.Join(t.TempDir(), "alda-player")
+	script := fmt.Sprintf(
+		"#!/bin/sh\necho \"alda-player %s\"\n", generated.ClientVersion,
+	)
+
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestFillPlayerPoolRespectsPoolSize(t *testing.T) {
+	useTempCacheDir(t)
+	t.Setenv("ALDA_DISABLE_SPAWNING", "")
+
+	t.Cleanup(func() { SetPlayerPoolSize(DefaultPlayerPoolSize) })
+	if err := SetPlayerPoolSize(2); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := CachePath("state", "players", generated.ClientVersion)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	// Instead of starting a player process, each spawn writes the state file of
+	// a player process that is starting up.
+	originalSpawnPlayer := spawnPlayer
+	t.Cleanup(func() { spawnPlayer = originalSpawnPlayer })
+
+	var lock sync.Mutex
+	spawned := 0
+	spawnPlayer = func(config PlayerLaunchConfig, playerPath string) error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		spawned++
+		state := fmt.Sprintf(
+			`{"port": %d, "expiry": %d, "state": "starting"}`,
+			27278+spawned, time.Now().Add(time.Minute).UnixMilli(),
+		)
+
+		return os.WriteFile(
+			filepath.Join(dir, fmt.Sprintf("player%d.json", spawned)),
+			[]byte(state),
+			0644,
+		)
+	}
+
+	config := PlayerLaunchConfig{BinaryPath: fakePlayerExecutable(t)}
+
+	for cycle := 0; cycle < 3; cycle++ {
+		if err := FillPlayerPoolWith(config); err != nil {
+			t.Fatal(err)
+		}
+
+		poolSize, err := PlayerPoolSize()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if poolSize != 2 {
+			t.Errorf("cycle %d: expected pool size 2, got %d", cycle, poolSize)
+		}
+
+		// After the first cycle, a player process leaves the pool (e.g. because a
+		// REPL server is using it), so the next cycle has to replace it.
+		if cycle == 0 {
+			err := os.WriteFile(
+				filepath.Join(dir, "player1.json"),
+				[]byte(`{"port": 27279, "state": "busy"}`),
+				0644,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if spawned != 3 {
+		t.Errorf("expected 3 player processes to be spawned, got %d", spawned)
+	}
+}
+
+func TestSetPlayerPoolSizeValidation(t *testing.T) {
+	t.Cleanup(func() { SetPlayerPoolSize(DefaultPlayerPoolSize) })
+
+	for _, n := range []int{0, -1} {
+		if err := SetPlayerPoolSize(n); err == nil {
+			t.Errorf("expected an error for pool size %d", n)
+		}
+	}
+
+	if err := SetPlayerPoolSize(1); err != nil {
+		t.Errorf("expected pool size 1 to be valid, got %v", err)
+	}
+}
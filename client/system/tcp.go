@@ -25,3 +25,25 @@ func FindOpenPort() (int, error) {
 
 	return int(port), nil
 }
+
+// DefaultPlayerHost is the host that player processes are assumed to be running
+// on when a host isn't specified.
+const DefaultPlayerHost = "127.0.0.1"
+
+// OSCClientHost returns the host to give to an OSC client in order to reach a
+// player process running on `host`, which can be a hostname, an IPv4 address or
+// an IPv6 address. An empty `host` means DefaultPlayerHost.
+//
+// The OSC client dials "host:port", so an IPv6 address needs to be wrapped in
+// square brackets in order for the address to be unambiguous.
+func OSCClientHost(host string) string {
+	if host == "" {
+		return DefaultPlayerHost
+	}
+
+	if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+		return "[" + host + "]"
+	}
+
+	return host
+}
@@ -0,0 +1,27 @@
+package system
+
+import (
+	"testing"
+
+	_ "alda.io/client/testing"
+)
+
+func TestOSCClientHost(t *testing.T) {
+	for host, expected := range map[string]string{
+		"":                "127.0.0.1",
+		"127.0.0.1":       "127.0.0.1",
+		"localhost":       "localhost",
+		"example.com":     "example.com",
+		"::1":             "[::1]",
+		"[::1]":           "[::1]",
+		"fe80::1ff:fe23":  "[fe80::1ff:fe23]",
+		"192.168.100.200": "192.168.100.200",
+	} {
+		if actual := OSCClientHost(host); actual != expected {
+			t.Errorf(
+				"expected OSC client host for %q to be %q, got %q",
+				host, expected, actual,
+			)
+		}
+	}
+}
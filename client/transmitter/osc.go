@@ -2,18 +2,35 @@ package transmitter
 
 import (
 	"fmt"
+	"io"
 	"math"
+	"path/filepath"
 	"sort"
 	"time"
 
 	log "alda.io/client/logging"
 	"alda.io/client/model"
+	"alda.io/client/system"
 	"github.com/daveyarwood/go-osc/osc"
 )
 
 // OSCTransmitter sends OSC messages to a player process.
 type OSCTransmitter struct {
+	// The host that the player process is running on. When empty, the player is
+	// assumed to be running on system.DefaultPlayerHost.
+	Host string
 	Port int
+	// When set, a human-readable description of every OSC packet that is sent is
+	// written here, for debugging purposes. (See `writeCapture`.)
+	Capture io.Writer
+	// When true, OSC packets are prepared (and captured, if capturing is
+	// enabled) as usual, but then discarded instead of being sent. This is
+	// useful for exercising the play path without a player process, e.g. in
+	// tests.
+	DryRun bool
+	// When set, packets are sent through this queue instead of being sent right
+	// away. (See `SendQueue`.)
+	Queue *SendQueue
 }
 
 func pingMsg() *osc.Message {
@@ -26,6 +43,12 @@ func systemMidiExportMsg(filename string) *osc.Message {
 	return msg
 }
 
+func systemSoundfontMsg(path string) *osc.Message {
+	msg := osc.NewMessage("/system/soundfont")
+	msg.Append(path)
+	return msg
+}
+
 func systemPlayMsg() *osc.Message {
 	return osc.NewMessage("/system/play")
 }
@@ -34,6 +57,10 @@ func systemStopMsg() *osc.Message {
 	return osc.NewMessage("/system/stop")
 }
 
+func systemClearMsg() *osc.Message {
+	return osc.NewMessage("/system/clear")
+}
+
 func systemShutdownMsg(offset int32) *osc.Message {
 	msg := osc.NewMessage("/system/shutdown")
 	msg.Append(offset)
@@ -93,42 +120,172 @@ func midiPanningMsg(track int32, offset int32, panning int32) *osc.Message {
 	return msg
 }
 
-func oscClient(port int) *osc.Client {
-	return osc.NewClient("localhost", int(port), osc.ClientProtocol(osc.TCP))
+func midiPitchBendMsg(track int32, offset int32, bend int32) *osc.Message {
+	msg := osc.NewMessage(fmt.Sprintf("/track/%d/midi/pitch-bend", track))
+	msg.Append(offset)
+	msg.Append(bend)
+	return msg
+}
+
+// The range of a MIDI pitch bend in each direction, in cents. This is the
+// General MIDI default of 2 semitones.
+const pitchBendRangeCents = 200
+
+// pitchBend returns the MIDI pitch bend value (0-16383, where 8192 is no bend)
+// that detunes a note by the provided number of cents. Detuning beyond the
+// range of a pitch bend is clamped to the range.
+func pitchBend(cents float64) int32 {
+	bend := math.Round(8192 + cents/pitchBendRangeCents*8192)
+	return int32(math.Max(0, math.Min(16383, bend)))
+}
+
+var _ PlayerTransmitter = OSCTransmitter{}
+
+func oscClient(host string, port int) *osc.Client {
+	return osc.NewClient(
+		system.OSCClientHost(host), int(port), osc.ClientProtocol(osc.TCP),
+	)
+}
+
+// send sends an OSC packet to the player process listening on the provided
+// host and port. It's a variable so that it can be swapped out in tests.
+//
+// NB: Each packet is sent over a connection of its own, and we don't try to
+// reuse connections. Over TCP, the player process reads everything that it
+// receives on a connection until the connection is closed, and then it parses
+// that as one packet. So closing the connection is how we tell the player where
+// a packet ends. This also means that routines that send packets concurrently
+// (e.g. the REPL server's player management loop and a client's request to
+// play something) can't interfere with each other.
+var send = func(host string, port int, packet osc.Packet) error {
+	return oscClient(host, port).Send(packet)
+}
+
+// transmit sends an OSC packet to the player process, capturing it first if
+// capturing is enabled. In a dry run, the packet is discarded instead.
+func (oe OSCTransmitter) transmit(packet osc.Packet) error {
+	if oe.Capture != nil {
+		writeCapture(oe.Capture, time.Now(), packet)
+	}
+
+	if oe.DryRun {
+		return nil
+	}
+
+	if oe.Queue != nil {
+		return oe.Queue.send(oe.Host, oe.Port, packet)
+	}
+
+	return send(oe.Host, oe.Port, packet)
 }
 
 // TransmitMidiExportMessage sends a "MIDI export" message to a player process.
 func (oe OSCTransmitter) TransmitMidiExportMessage(filename string) error {
-	return oscClient(oe.Port).Send(systemMidiExportMsg(filename))
+	return oe.transmit(systemMidiExportMsg(filename))
+}
+
+// TransmitSoundfontMessage sends a message to a player process that tells it
+// to load the soundfont (.sf2) file at the provided path.
+//
+// The player process might not be running on this host, so we can't check that
+// the file exists. The player reports it if the file can't be loaded. Returns
+// an error without sending anything if the path isn't absolute, because the
+// player process wouldn't know what a relative path is relative to.
+func (oe OSCTransmitter) TransmitSoundfontMessage(path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("soundfont path must be absolute: %s", path)
+	}
+
+	return oe.transmit(systemSoundfontMsg(path))
 }
 
 // TransmitPingMessage sends a "ping" message to a player process.
 func (oe OSCTransmitter) TransmitPingMessage() error {
-	return oscClient(oe.Port).Send(pingMsg())
+	return oe.transmit(pingMsg())
 }
 
 // TransmitPlayMessage sends a "play" message to a player process.
 func (oe OSCTransmitter) TransmitPlayMessage() error {
-	return oscClient(oe.Port).Send(systemPlayMsg())
+	return oe.transmit(systemPlayMsg())
 }
 
 // TransmitStopMessage sends a "stop" message to a player process.
 func (oe OSCTransmitter) TransmitStopMessage() error {
-	return oscClient(oe.Port).Send(systemStopMsg())
+	return oe.transmit(systemStopMsg())
+}
+
+// TransmitClearMessage sends a "clear" message to a player process, which
+// removes all upcoming events from every track and silences any notes that are
+// sounding.
+func (oe OSCTransmitter) TransmitClearMessage() error {
+	return oe.transmit(systemClearMsg())
+}
+
+// TransmitOSCMessage sends an arbitrary OSC message with the provided address
+// and arguments to a player process. This is intended for debugging and for
+// developing player processes; unlike the other messages, nothing checks that
+// the player process understands it.
+func (oe OSCTransmitter) TransmitOSCMessage(
+	address string, args ...interface{},
+) error {
+	return oe.transmit(osc.NewMessage(address, args...))
 }
 
 // TransmitShutdownMessage sends a "shutdown" message to a player process.
 func (oe OSCTransmitter) TransmitShutdownMessage(offset int32) error {
-	return oscClient(oe.Port).Send(systemShutdownMsg(offset))
+	return oe.transmit(systemShutdownMsg(offset))
+}
+
+// TransmitTempoMessage sends a "tempo" message to a player process, which
+// changes the tempo (in beats per minute) at the provided offset.
+func (oe OSCTransmitter) TransmitTempoMessage(bpm float64, offset int32) error {
+	return oe.transmit(systemTempoMsg(offset, float32(bpm)))
+}
+
+// TransmitVolumeMessage sends a message to a player process that changes the
+// volume of a track at the provided offset.
+//
+// The volume is a number between 0.0 and 1.0, like a `(track-vol ...)`
+// attribute value in an Alda score. Returns an error if the volume is out of
+// range.
+func (oe OSCTransmitter) TransmitVolumeMessage(
+	track int32, volume float64, offset int32,
+) error {
+	if volume < 0 || volume > 1 {
+		return fmt.Errorf("volume must be between 0.0 and 1.0: %v", volume)
+	}
+
+	return oe.transmit(
+		midiVolumeMsg(track, offset, int32(math.Round(volume*127))),
+	)
+}
+
+// TransmitPanningMessage sends a message to a player process that changes the
+// panning (stereo position) of a track at the provided offset.
+//
+// The panning is a number between 0.0 (hard left) and 1.0 (hard right), like a
+// `(panning ...)` attribute value in an Alda score. 0.5 is center. Returns an
+// error if the panning is out of range.
+func (oe OSCTransmitter) TransmitPanningMessage(
+	track int32, panning float64, offset int32,
+) error {
+	if panning < 0 || panning > 1 {
+		return fmt.Errorf("panning must be between 0.0 and 1.0: %v", panning)
+	}
+
+	return oe.transmit(
+		midiPanningMsg(track, offset, int32(math.Round(panning*127))),
+	)
 }
 
 // TransmitOffsetMessage sends an "offset" message to a player process.
 func (oe OSCTransmitter) TransmitOffsetMessage(offset int32) error {
-	return oscClient(oe.Port).Send(systemOffsetMsg(offset))
+	return oe.transmit(systemOffsetMsg(offset))
 }
 
 func tempoMessages(
-	score *model.Score, startOffset float64, endOffset float64,
+	ctx *TransmissionContext, score *model.Score, startOffset float64,
+	endOffset float64,
 ) []*osc.Message {
 	tempoItinerary := score.TempoItinerary()
 
@@ -188,9 +345,13 @@ func tempoMessages(
 			offset = 0
 		}
 
+		if ctx.tempoScale != 0 {
+			tempo *= ctx.tempoScale
+		}
+
 		// The OSC API works with int offsets and float tempos, so we do the
 		// necessary conversions here.
-		offsetRounded := int32(math.Round(offset))
+		offsetRounded := int32(math.Round(ctx.scaledMs(offset)))
 		tempo32 := float32(tempo)
 		messages = append(messages, systemTempoMsg(offsetRounded, tempo32))
 	}
@@ -216,6 +377,10 @@ func (oe OSCTransmitter) ScoreToOSCBundle(
 		Str("ctx", fmt.Sprintf("%#v", ctx)).
 		Msg("Transmission options applied.")
 
+	if err := score.ValidateNoteBalance(); err != nil {
+		return nil, err
+	}
+
 	events := score.Events[ctx.fromIndex:ctx.toIndex]
 
 	startOffset := 0.0
@@ -239,7 +404,7 @@ func (oe OSCTransmitter) ScoreToOSCBundle(
 		endOffset = offset
 	}
 
-	bundle := osc.NewBundle(time.Now())
+	bundle := osc.NewBundle(ctx.playbackStart())
 
 	// In order to support features like:
 	//
@@ -265,12 +430,29 @@ func (oe OSCTransmitter) ScoreToOSCBundle(
 	currentVolume := map[int32]float64{}
 	currentPanning := map[int32]float64{}
 
+	// Detuning works the same way, via pitch bend messages. Most parts are never
+	// detuned, so we only send pitch bend messages for the parts that are
+	// detuned at some point in the score. For those parts, we always send the
+	// initial pitch bend, in case the channel was left bent by something we sent
+	// earlier (e.g. in a previous REPL input).
+	currentDetune := map[int32]float64{}
+	detunedParts := map[*model.Part]bool{}
+	for _, event := range score.Events {
+		if note, ok := event.(model.NoteEvent); ok && note.Detune != 0 {
+			detunedParts[note.Part] = true
+		}
+	}
+
 	tracks := score.Tracks()
 
 	for part, trackNumber := range tracks {
 		currentVolume[trackNumber] = -1
 		currentPanning[trackNumber] = -1
 
+		if detunedParts[part] {
+			currentDetune[trackNumber] = math.NaN()
+		}
+
 		// We currently only have MIDI instruments. This might change in the future,
 		// which is why Instrument is an interface instead of a plain struct. For
 		// now, we're operating under the assumption that all instruments are MIDI
@@ -278,6 +460,17 @@ func (oe OSCTransmitter) ScoreToOSCBundle(
 		stockInstrument := part.StockInstrument.(model.MidiInstrument)
 
 		patchNumber := stockInstrument.PatchNumber
+
+		// Sending the same program change again can cause a glitch in playback, so
+		// we avoid it when we know which patch the track already has.
+		if ctx.patchesSent != nil {
+			if sent, ok := ctx.patchesSent[trackNumber]; ok && sent == patchNumber {
+				continue
+			}
+
+			ctx.patchesSent[trackNumber] = patchNumber
+		}
+
 		bundle.Append(midiPatchMsg(trackNumber, 0, patchNumber))
 
 		if stockInstrument.IsPercussion {
@@ -300,7 +493,7 @@ func (oe OSCTransmitter) ScoreToOSCBundle(
 	// that the MIDI file can include context about the tempo when it's imported
 	// into other tools.
 	if len(ctx.syncOffsets) == 0 {
-		for _, tempoMsg := range tempoMessages(score, startOffset, endOffset) {
+		for _, tempoMsg := range tempoMessages(ctx, score, startOffset, endOffset) {
 			bundle.Append(tempoMsg)
 		}
 	}
@@ -311,6 +504,8 @@ func (oe OSCTransmitter) ScoreToOSCBundle(
 	// of the score.
 	scoreLength := 0.0
 
+	silencedParts := ctx.silencedParts(score)
+
 	for _, event := range events {
 		eventOffset := event.EventOffset()
 
@@ -327,6 +522,12 @@ func (oe OSCTransmitter) ScoreToOSCBundle(
 
 		switch event := event.(type) {
 		case model.NoteEvent:
+			// Notes of muted parts (or of parts other than the soloed ones) are left
+			// out entirely.
+			if silencedParts[event.Part] {
+				continue
+			}
+
 			track := tracks[event.Part]
 
 			// We subtract `startOffset` from the offset so that when the `--from`
@@ -351,6 +552,15 @@ func (oe OSCTransmitter) ScoreToOSCBundle(
 			// work the way they're supposed to.)
 			offset -= ctx.syncOffsets[event.Part]
 
+			// A grace note at the beginning of a REPL input lands before the point
+			// where the part left off. We can't schedule anything before the start of
+			// the bundle, so we play it as early as we can.
+			offset = math.Max(0, offset)
+
+			offset = ctx.scaledMs(offset)
+			duration := ctx.scaledMs(event.Duration)
+			audibleDuration := ctx.scaledMs(event.AudibleDuration)
+
 			// The OSC API works with offsets that are ints, not floats, so we do the
 			// rounding here and work with the int value from here onward.
 			offsetRounded := int32(math.Round(offset))
@@ -379,16 +589,25 @@ func (oe OSCTransmitter) ScoreToOSCBundle(
 				)
 			}
 
+			// NB: NaN (see above) is never equal to anything.
+			if event.Detune != currentDetune[track] {
+				currentDetune[track] = event.Detune
+
+				bundle.Append(
+					midiPitchBendMsg(track, offsetRounded, pitchBend(event.Detune)),
+				)
+			}
+
 			bundle.Append(midiNoteMsg(
 				track,
 				offsetRounded,
 				event.MidiNote,
-				int32(math.Round(event.Duration)),
-				int32(math.Round(event.AudibleDuration)),
-				int32(math.Round(event.Volume*127)),
+				int32(math.Round(duration)),
+				int32(math.Round(audibleDuration)),
+				event.Velocity(),
 			))
 
-			scoreLength = math.Max(scoreLength, offset+event.AudibleDuration)
+			scoreLength = math.Max(scoreLength, offset+audibleDuration)
 		default:
 			return nil, fmt.Errorf("unsupported event: %#v", event)
 		}
@@ -415,9 +634,125 @@ func (oe OSCTransmitter) TransmitScore(
 		return err
 	}
 
+	ctx := &TransmissionContext{}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	// The player process starts playing a score as soon as it receives it, so
+	// we hold onto the score until it's time to play it.
+	start := ctx.playbackStart()
+	if start.After(time.Now()) {
+		waitUntil(start)
+	}
+
+	if ctx.streamWindow > 0 {
+		return oe.streamBundle(bundle, ctx.streamWindow, start)
+	}
+
+	return oe.sendBundle(bundle)
+}
+
+// TransmitBundle sends the provided OSC messages to a player process together
+// in a single OSC bundle with the provided timetag, so that the player process
+// receives and schedules them all at once, in order.
+func (oe OSCTransmitter) TransmitBundle(
+	msgs []*osc.Message, timetag time.Time,
+) error {
+	bundle := osc.NewBundle(timetag)
+
+	for _, msg := range msgs {
+		if err := bundle.Append(msg); err != nil {
+			return err
+		}
+	}
+
+	return oe.sendBundle(bundle)
+}
+
+func (oe OSCTransmitter) sendBundle(bundle *osc.Bundle) error {
 	log.Debug().
 		Interface("bundle", bundle).
 		Msg("Sending OSC bundle.")
 
-	return oscClient(oe.Port).Send(bundle)
+	return oe.transmit(bundle)
+}
+
+// ScoreStateToOSCBundle returns an OSC bundle that brings a player process up
+// to date with the last-known state of the provided score: the instrument,
+// volume and panning of each track, and the current tempo.
+//
+// Unlike ScoreToOSCBundle, the bundle doesn't include any notes, and it doesn't
+// tell the player to start playing. The use case for this is bringing a fresh
+// player process up to speed when an Alda REPL server switches to it in the
+// middle of a session, since each player process has its own MIDI state.
+func (oe OSCTransmitter) ScoreStateToOSCBundle(score *model.Score) *osc.Bundle {
+	bundle := osc.NewBundle(time.Now())
+
+	tracks := score.Tracks()
+
+	// We only care about the last-known values, so we take the latest note event
+	// for each track.
+	lastNotes := map[int32]model.NoteEvent{}
+	for _, event := range score.Events {
+		note, ok := event.(model.NoteEvent)
+		if !ok {
+			continue
+		}
+
+		track := tracks[note.Part]
+		if last, seen := lastNotes[track]; !seen || note.Offset >= last.Offset {
+			lastNotes[track] = note
+		}
+	}
+
+	trackNumbers := []int32{}
+	instruments := map[int32]model.MidiInstrument{}
+	for part, trackNumber := range tracks {
+		trackNumbers = append(trackNumbers, trackNumber)
+		instruments[trackNumber] = part.StockInstrument.(model.MidiInstrument)
+	}
+	sort.Slice(trackNumbers, func(i, j int) bool {
+		return trackNumbers[i] < trackNumbers[j]
+	})
+
+	for _, track := range trackNumbers {
+		instrument := instruments[track]
+
+		bundle.Append(midiPatchMsg(track, 0, instrument.PatchNumber))
+
+		if instrument.IsPercussion {
+			bundle.Append(midiPercussionMsg(track, 0))
+		}
+
+		if note, ok := lastNotes[track]; ok {
+			bundle.Append(
+				midiVolumeMsg(track, 0, int32(math.Round(note.TrackVolume*127))),
+			)
+			bundle.Append(
+				midiPanningMsg(track, 0, int32(math.Round(note.Panning*127))),
+			)
+
+			if note.Detune != 0 {
+				bundle.Append(midiPitchBendMsg(track, 0, pitchBend(note.Detune)))
+			}
+		}
+	}
+
+	tempoItinerary := score.TempoItinerary()
+	lastTempoOffset := 0.0
+	for offset := range tempoItinerary {
+		lastTempoOffset = math.Max(lastTempoOffset, offset)
+	}
+	bundle.Append(
+		systemTempoMsg(0, float32(tempoItinerary[lastTempoOffset])),
+	)
+
+	return bundle
+}
+
+// TransmitScoreState sends the last-known state of the provided score to a
+// player process. (See ScoreStateToOSCBundle.)
+func (oe OSCTransmitter) TransmitScoreState(score *model.Score) error {
+	return oe.sendBundle(oe.ScoreStateToOSCBundle(score))
 }
@@ -0,0 +1,90 @@
+package transmitter
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/daveyarwood/go-osc/osc"
+)
+
+// oscTypeTag returns the OSC type tag character corresponding to the type of
+// the provided argument.
+func oscTypeTag(arg interface{}) string {
+	switch arg := arg.(type) {
+	case int32:
+		return "i"
+	case int64:
+		return "h"
+	case float32:
+		return "f"
+	case float64:
+		return "d"
+	case string:
+		return "s"
+	case []byte:
+		return "b"
+	case bool:
+		if arg {
+			return "T"
+		}
+		return "F"
+	case nil:
+		return "N"
+	default:
+		return "?"
+	}
+}
+
+func writeCapturedMessage(w io.Writer, indent string, msg *osc.Message) {
+	typeTags := ","
+	args := ""
+	for _, arg := range msg.Arguments {
+		typeTags += oscTypeTag(arg)
+		args += fmt.Sprintf(" %v", arg)
+	}
+
+	fmt.Fprintf(w, "%s%s %s%s\n", indent, msg.Address, typeTags, args)
+}
+
+func writeCapturedBundle(w io.Writer, indent string, bundle *osc.Bundle) {
+	fmt.Fprintf(
+		w,
+		"%s#bundle %s\n",
+		indent,
+		bundle.Timetag.Time().UTC().Format(time.RFC3339Nano),
+	)
+
+	for _, msg := range bundle.Messages {
+		writeCapturedMessage(w, indent+"  ", msg)
+	}
+
+	for _, nested := range bundle.Bundles {
+		writeCapturedBundle(w, indent+"  ", nested)
+	}
+}
+
+// writeCapture writes a human-readable description of an OSC packet (address,
+// type tags and arguments of each message) to `w`, preceded by the time that it
+// was sent.
+//
+// Example output:
+//
+//	--- 2022-04-23T18:02:11.123456Z
+//	#bundle 2022-04-23T18:02:11.123456Z
+//	  /track/1/midi/patch ,ii 0 0
+//	  /system/tempo ,if 0 120
+//	  /track/1/midi/note ,iiiii 0 60 500 450 100
+//	  /system/play ,
+func writeCapture(w io.Writer, sentAt time.Time, packet osc.Packet) {
+	fmt.Fprintf(w, "--- %s\n", sentAt.UTC().Format(time.RFC3339Nano))
+
+	switch packet := packet.(type) {
+	case *osc.Message:
+		writeCapturedMessage(w, "", packet)
+	case *osc.Bundle:
+		writeCapturedBundle(w, "", packet)
+	default:
+		fmt.Fprintf(w, "%#v\n", packet)
+	}
+}
@@ -0,0 +1,218 @@
+package transmitter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"alda.io/client/system"
+	"alda.io/client/util"
+	"github.com/daveyarwood/go-osc/osc"
+)
+
+// Unlike the other messages that we send to a player process, the "latency" and
+// "clock" messages ask the player process for information. Each message
+// includes the port and host of a short-lived OSC server that we start on this
+// end, and the player process sends its reply there.
+//
+// NB: Older player processes ignore the host and reply to localhost, so they
+// can only reply when they're running on the same host.
+
+const latencyReplyAddress = "/system/latency/reply"
+
+func systemLatencyMsg(replyHost string, replyPort int32) *osc.Message {
+	msg := osc.NewMessage("/system/latency")
+	msg.Append(replyPort)
+	msg.Append(replyHost)
+	return msg
+}
+
+var errNoLatencyReply = errors.New(
+	"the player process didn't reply to the latency request",
+)
+
+// replyForwarder is an osc.Dispatcher that puts each reply with the expected
+// address that it receives onto a channel.
+type replyForwarder struct {
+	address string
+	replies chan *osc.Message
+}
+
+func (rf replyForwarder) Dispatch(packet osc.Packet) {
+	msg, ok := packet.(*osc.Message)
+	if !ok || msg.Address != rf.address {
+		return
+	}
+
+	// We only need the first reply, so if one is already waiting to be handled,
+	// we drop this one instead of blocking.
+	select {
+	case rf.replies <- msg:
+	default:
+	}
+}
+
+// replyHost returns the address of this host that the player process at
+// `host`:`port` can reach us at, which is the local address that we use to
+// connect to it.
+func replyHost(host string, port int) (string, error) {
+	if host == "" {
+		host = system.DefaultPlayerHost
+	}
+
+	// Dialing over UDP doesn't send anything. It only picks the local address
+	// that packets to the player process would be sent from.
+	conn, err := net.Dial("udp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
+
+// requestReply sends the player process a message that asks for information,
+// and waits for the player process to send a message with the address
+// `replyAddress` back to us. `newMsg` returns the message to send, given the
+// host and port that we're listening on for the reply.
+//
+// Returns `errNoReply` if the player process doesn't reply within the provided
+// timeout.
+func (oe OSCTransmitter) requestReply(
+	newMsg func(replyHost string, replyPort int32) *osc.Message,
+	replyAddress string,
+	errNoReply error,
+	timeout time.Duration,
+) (*osc.Message, error) {
+	host, err := replyHost(oe.Host, oe.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	replies := make(chan *osc.Message, 1)
+
+	server := osc.NewServer(
+		listener.Addr().String(),
+		replyForwarder{address: replyAddress, replies: replies},
+		0,
+		osc.ServerProtocol(osc.TCP),
+	)
+
+	// Serve returns an error as soon as we close the listener, which is expected,
+	// so we ignore it.
+	go server.Serve(osc.TCPReceive(listener))
+
+	replyPort := listener.Addr().(*net.TCPAddr).Port
+	if err := oe.transmit(newMsg(host, int32(replyPort))); err != nil {
+		return nil, err
+	}
+
+	var reply *osc.Message
+
+	if err := util.Await(
+		func() error {
+			select {
+			case reply = <-replies:
+				return nil
+			default:
+				return errNoReply
+			}
+		},
+		timeout,
+	); err != nil {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// RequestLatency asks the player process for the latency of its audio output,
+// i.e. how long it takes for a note to be heard after the player's synthesizer
+// receives it.
+//
+// Returns an error if the player process doesn't reply within the provided
+// timeout.
+func (oe OSCTransmitter) RequestLatency(
+	timeout time.Duration,
+) (time.Duration, error) {
+	if oe.DryRun {
+		return 0, fmt.Errorf("unable to request latency in a dry run")
+	}
+
+	reply, err := oe.requestReply(
+		systemLatencyMsg, latencyReplyAddress, errNoLatencyReply, timeout,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	// The player reports its latency in microseconds.
+	if len(reply.Arguments) != 1 {
+		return 0, fmt.Errorf("unexpected latency reply: %v", reply)
+	}
+
+	micros, ok := reply.Arguments[0].(int32)
+	if !ok {
+		return 0, fmt.Errorf("unexpected latency reply: %v", reply)
+	}
+
+	return time.Duration(micros) * time.Microsecond, nil
+}
+
+const clockReplyAddress = "/system/clock/reply"
+
+func systemClockMsg(replyHost string, replyPort int32) *osc.Message {
+	msg := osc.NewMessage("/system/clock")
+	msg.Append(replyPort)
+	msg.Append(replyHost)
+	return msg
+}
+
+var errNoClockReply = errors.New(
+	"the player process didn't reply to the clock request",
+)
+
+// RequestClock asks the player process for the current time according to its
+// system clock. Comparing it with our own clock tells us how far apart the two
+// clocks are, which is useful for diagnosing timing problems.
+//
+// NB: The reply takes some time to arrive, so the time is only accurate to
+// within the round trip time, which is usually very short when the player
+// process is running on the same host.
+//
+// Returns an error if the player process doesn't reply within the provided
+// timeout.
+func (oe OSCTransmitter) RequestClock(
+	timeout time.Duration,
+) (time.Time, error) {
+	if oe.DryRun {
+		return time.Time{}, fmt.Errorf("unable to request clock in a dry run")
+	}
+
+	reply, err := oe.requestReply(
+		systemClockMsg, clockReplyAddress, errNoClockReply, timeout,
+	)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// The player reports its clock in milliseconds since the Unix epoch.
+	if len(reply.Arguments) != 1 {
+		return time.Time{}, fmt.Errorf("unexpected clock reply: %v", reply)
+	}
+
+	millis, ok := reply.Arguments[0].(int64)
+	if !ok {
+		return time.Time{}, fmt.Errorf("unexpected clock reply: %v", reply)
+	}
+
+	return time.UnixMilli(millis), nil
+}
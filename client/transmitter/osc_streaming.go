@@ -0,0 +1,366 @@
+package transmitter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "alda.io/client/logging"
+	"github.com/daveyarwood/go-osc/osc"
+)
+
+// waitUntil blocks until the provided time. It's a variable so that it can be
+// swapped out in tests.
+var waitUntil = func(t time.Time) {
+	time.Sleep(time.Until(t))
+}
+
+// messageOffset returns the offset (in ms) at which the player process will
+// handle the provided message, or false if the message isn't scheduled, e.g.
+// `/system/play`, which is handled immediately.
+func messageOffset(msg *osc.Message) (int32, bool) {
+	if msg.Address != "/system/tempo" &&
+		msg.Address != "/system/shutdown" &&
+		!strings.HasPrefix(msg.Address, "/track/") {
+		return 0, false
+	}
+
+	if len(msg.Arguments) == 0 {
+		return 0, false
+	}
+
+	offset, ok := msg.Arguments[0].(int32)
+	return offset, ok
+}
+
+// A streamChunk is the messages of a bundle that fall within one time window.
+type streamChunk struct {
+	// When the window starts, relative to the start of playback.
+	start time.Duration
+	msgs  []*osc.Message
+}
+
+// chunkBundle splits the messages in a bundle into consecutive time windows of
+// the provided length, based on their offsets. The messages in each window are
+// kept in their original order.
+//
+// Messages that aren't scheduled (see `messageOffset`) belong to the first
+// window, so that e.g. the player process starts playing as soon as the first
+// window is received. Empty windows are omitted.
+func chunkBundle(bundle *osc.Bundle, window time.Duration) []streamChunk {
+	windowMs := int32(window / time.Millisecond)
+	if windowMs < 1 {
+		windowMs = 1
+	}
+
+	windows := map[int32][]*osc.Message{}
+	lastWindow := int32(0)
+
+	for _, msg := range bundle.Messages {
+		i := int32(0)
+		if offset, ok := messageOffset(msg); ok && offset > 0 {
+			i = offset / windowMs
+		}
+
+		windows[i] = append(windows[i], msg)
+
+		if i > lastWindow {
+			lastWindow = i
+		}
+	}
+
+	chunks := []streamChunk{}
+	for i := int32(0); i <= lastWindow; i++ {
+		if msgs, ok := windows[i]; ok {
+			chunks = append(chunks, streamChunk{
+				start: time.Duration(i*windowMs) * time.Millisecond,
+				msgs:  msgs,
+			})
+		}
+	}
+
+	return chunks
+}
+
+// trackNumber returns the number of the track that the provided message is
+// for, or false if it isn't a `/track/...` message.
+func trackNumber(msg *osc.Message) (int32, bool) {
+	var track int32
+	if _, err := fmt.Sscanf(msg.Address, "/track/%d/", &track); err != nil {
+		return 0, false
+	}
+
+	return track, true
+}
+
+// withOffset returns a copy of a scheduled message (see `messageOffset`) with a
+// different offset.
+func withOffset(msg *osc.Message, offset int32) *osc.Message {
+	args := append([]interface{}{offset}, msg.Arguments[1:]...)
+	return osc.NewMessage(msg.Address, args...)
+}
+
+// isNoteMessage returns true if the provided message is a `/track/.../note`
+// message.
+func isNoteMessage(msg *osc.Message) bool {
+	return strings.HasSuffix(msg.Address, "/midi/note")
+}
+
+// A trackBatch is the scheduled `/track/...` messages for one track that are
+// sent to the player process together.
+type trackBatch struct {
+	// The index of the chunk that the batch is sent in.
+	chunk int
+	// The positions of the messages in the bundle, so that their original order
+	// can be restored.
+	positions []int
+	msgs      []*osc.Message
+	hasNotes  bool
+}
+
+// regroupChunks moves `/track/...` messages between chunks so that the way that
+// the player process schedules each batch of messages for a track (see
+// `rebaseChunks`) can be predicted:
+//
+//   - A batch with no notes resets the track's start offset, so messages for a
+//     track in a chunk that has no notes for it are sent along with the
+//     track's next notes instead, or with its previous notes if there are no
+//     more.
+//
+//   - When a batch arrives after the track's previous notes have finished, the
+//     player process starts it "now" instead of where the previous notes
+//     ended. So a batch that follows a rest, or the first notes of a part that
+//     doesn't start playing until a later window, is sent early enough that it
+//     arrives at least one window before the track's previous notes end. For
+//     a part that enters late, that's the first chunk.
+//
+// Chunks that end up empty are omitted.
+func regroupChunks(chunks []streamChunk, window time.Duration) []streamChunk {
+	windowMs := int32(window / time.Millisecond)
+	if windowMs < 1 {
+		windowMs = 1
+	}
+
+	// Messages that stay in the chunk they're in, by chunk index.
+	others := map[int][]int{}
+	// The batches for each track, in order.
+	batches := map[int32][]*trackBatch{}
+	tracks := []int32{}
+	msgsByPosition := []*osc.Message{}
+
+	for _, chunk := range chunks {
+		index := int(chunk.start / window)
+
+		for _, msg := range chunk.msgs {
+			position := len(msgsByPosition)
+			msgsByPosition = append(msgsByPosition, msg)
+
+			track, isTrackMsg := trackNumber(msg)
+			_, isScheduled := messageOffset(msg)
+			if !isTrackMsg || !isScheduled {
+				others[index] = append(others[index], position)
+				continue
+			}
+
+			if _, seen := batches[track]; !seen {
+				tracks = append(tracks, track)
+			}
+
+			trackBatches := batches[track]
+			if len(trackBatches) == 0 ||
+				trackBatches[len(trackBatches)-1].chunk != index {
+				trackBatches = append(trackBatches, &trackBatch{chunk: index})
+				batches[track] = trackBatches
+			}
+
+			batch := trackBatches[len(trackBatches)-1]
+			batch.positions = append(batch.positions, position)
+			batch.msgs = append(batch.msgs, msg)
+			batch.hasNotes = batch.hasNotes || isNoteMessage(msg)
+		}
+	}
+
+	positionsByChunk := others
+
+	for _, track := range tracks {
+		trackBatches := batches[track]
+
+		// Attach each batch without notes to the next batch with notes, or to the
+		// previous one if there are no more notes.
+		noteBatches := []*trackBatch{}
+		pending := &trackBatch{}
+
+		for _, batch := range trackBatches {
+			pending.positions = append(pending.positions, batch.positions...)
+			pending.msgs = append(pending.msgs, batch.msgs...)
+
+			if batch.hasNotes {
+				pending.chunk = batch.chunk
+				pending.hasNotes = true
+				noteBatches = append(noteBatches, pending)
+				pending = &trackBatch{}
+			}
+		}
+
+		if len(noteBatches) == 0 {
+			// There are no notes on this track, so there's nothing to mistime.
+			for _, batch := range trackBatches {
+				positionsByChunk[batch.chunk] = append(
+					positionsByChunk[batch.chunk], batch.positions...,
+				)
+			}
+
+			continue
+		}
+
+		last := noteBatches[len(noteBatches)-1]
+		last.positions = append(last.positions, pending.positions...)
+		last.msgs = append(last.msgs, pending.msgs...)
+
+		// Where the track's previous notes end, relative to the start of the
+		// score.
+		trackEnd := int32(0)
+
+		for _, batch := range noteBatches {
+			// Each chunk after the first is sent one window before it starts
+			// playing, so the chunk with this index is sent at least one window
+			// before the track's previous notes end.
+			if latest := int(trackEnd / windowMs); latest < batch.chunk {
+				batch.chunk = latest
+			}
+
+			for _, msg := range batch.msgs {
+				if !isNoteMessage(msg) {
+					continue
+				}
+
+				offset, _ := messageOffset(msg)
+				duration, _ := msg.Arguments[2].(int32)
+				if end := offset + duration; end > trackEnd {
+					trackEnd = end
+				}
+			}
+
+			positionsByChunk[batch.chunk] = append(
+				positionsByChunk[batch.chunk], batch.positions...,
+			)
+		}
+	}
+
+	indices := []int{}
+	for index := range positionsByChunk {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+
+	regrouped := []streamChunk{}
+	for _, index := range indices {
+		positions := positionsByChunk[index]
+		sort.Ints(positions)
+
+		msgs := []*osc.Message{}
+		for _, position := range positions {
+			msgs = append(msgs, msgsByPosition[position])
+		}
+
+		regrouped = append(regrouped, streamChunk{
+			start: time.Duration(index) * window,
+			msgs:  msgs,
+		})
+	}
+
+	return regrouped
+}
+
+// rebaseChunks makes the offsets of the `/track/...` messages in each chunk
+// after the first relative to where the previous chunk ends on that track.
+//
+// This is necessary because the player process ignores bundle timetags.
+// Instead, each track keeps a running start offset, which it adds to the
+// offsets of every batch of events that it receives, and then moves to the end
+// of the last note in that batch. So the offsets in the bundle, which are
+// relative to the start of the score, are only correct for the first chunk.
+//
+// System messages (e.g. `/system/tempo`) are scheduled at absolute offsets, so
+// they are left alone.
+//
+// The player process only behaves this way when each batch of messages for a
+// track includes notes and arrives before the track's previous notes end, so
+// the chunks are expected to have been through `regroupChunks`.
+func rebaseChunks(chunks []streamChunk) []streamChunk {
+	// The offset, relative to the start of the score, at which the player
+	// process considers the previous chunk on each track to end.
+	trackEnds := map[int32]int32{}
+
+	rebased := []streamChunk{}
+
+	for _, chunk := range chunks {
+		msgs := []*osc.Message{}
+		chunkEnds := map[int32]int32{}
+
+		for _, msg := range chunk.msgs {
+			track, isTrackMsg := trackNumber(msg)
+			offset, isScheduled := messageOffset(msg)
+			if !isTrackMsg || !isScheduled {
+				msgs = append(msgs, msg)
+				continue
+			}
+
+			if isNoteMessage(msg) {
+				duration, _ := msg.Arguments[2].(int32)
+				if end := offset + duration; end > chunkEnds[track] {
+					chunkEnds[track] = end
+				}
+			}
+
+			msgs = append(msgs, withOffset(msg, offset-trackEnds[track]))
+		}
+
+		for track, end := range chunkEnds {
+			trackEnds[track] = end
+		}
+
+		rebased = append(rebased, streamChunk{start: chunk.start, msgs: msgs})
+	}
+
+	return rebased
+}
+
+// streamBundle sends the messages in a bundle to the player process in chunks,
+// one time window at a time, instead of all at once. This avoids overflowing
+// the player process's input buffer with a long score.
+//
+// The first window is sent right away, and the rest are each sent one window
+// ahead of their playback time, counting from `start`. The timetag of each
+// bundle is the time when its window starts playing. This function blocks
+// until every window has been sent.
+//
+// The messages in each window and their offsets are adjusted to account for
+// the way that the player process schedules events. (See `regroupChunks` and
+// `rebaseChunks`.)
+func (oe OSCTransmitter) streamBundle(
+	bundle *osc.Bundle, window time.Duration, start time.Time,
+) error {
+	chunks := rebaseChunks(regroupChunks(chunkBundle(bundle, window), window))
+
+	for i, chunk := range chunks {
+		playbackTime := start.Add(chunk.start)
+
+		if i > 0 {
+			waitUntil(playbackTime.Add(-window))
+		}
+
+		log.Debug().
+			Dur("start", chunk.start).
+			Int("messages", len(chunk.msgs)).
+			Msg("Streaming OSC messages.")
+
+		err := oe.TransmitBundle(chunk.msgs, playbackTime)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
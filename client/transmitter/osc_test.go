@@ -0,0 +1,1275 @@
+package transmitter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"alda.io/client/model"
+	"alda.io/client/parser"
+	"alda.io/client/system"
+	_ "alda.io/client/testing"
+	"github.com/daveyarwood/go-osc/osc"
+)
+
+// captureSent swaps out the function that sends OSC packets to player processes
+// for the duration of a test, and returns a pointer to the packets that were
+// sent.
+func captureSent(t *testing.T) *[]osc.Packet {
+	sent := []osc.Packet{}
+
+	originalSend := send
+	t.Cleanup(func() { send = originalSend })
+
+	send = func(host string, port int, packet osc.Packet) error {
+		sent = append(sent, packet)
+		return nil
+	}
+
+	return &sent
+}
+
+func TestTransmitTempoMessage(t *testing.T) {
+	sent := captureSent(t)
+
+	if err := (OSCTransmitter{Port: 27278}).TransmitTempoMessage(
+		93.5, 1000,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*sent) != 1 {
+		t.Fatalf("expected 1 packet to be sent, got %d", len(*sent))
+	}
+
+	msg, ok := (*sent)[0].(*osc.Message)
+	if !ok {
+		t.Fatalf("expected an OSC message, got %#v", (*sent)[0])
+	}
+
+	if msg.Address != "/system/tempo" {
+		t.Errorf("expected address /system/tempo, got %s", msg.Address)
+	}
+
+	if len(msg.Arguments) != 2 {
+		t.Fatalf("expected 2 arguments, got %#v", msg.Arguments)
+	}
+
+	if msg.Arguments[0] != int32(1000) {
+		t.Errorf("expected offset 1000, got %#v", msg.Arguments[0])
+	}
+
+	if msg.Arguments[1] != float32(93.5) {
+		t.Errorf("expected tempo 93.5, got %#v", msg.Arguments[1])
+	}
+}
+
+func TestTransmitVolumeMessage(t *testing.T) {
+	sent := captureSent(t)
+
+	transmitter := OSCTransmitter{Port: 27278}
+
+	for _, volume := range []float64{-0.1, 1.5} {
+		if err := transmitter.TransmitVolumeMessage(2, volume, 0); err == nil {
+			t.Errorf("expected volume %v to be rejected", volume)
+		}
+	}
+
+	if len(*sent) != 0 {
+		t.Fatalf("expected no packets to be sent, got %d", len(*sent))
+	}
+
+	if err := transmitter.TransmitVolumeMessage(2, 0.5, 250); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*sent) != 1 {
+		t.Fatalf("expected 1 packet to be sent, got %d", len(*sent))
+	}
+
+	msg, ok := (*sent)[0].(*osc.Message)
+	if !ok {
+		t.Fatalf("expected an OSC message, got %#v", (*sent)[0])
+	}
+
+	if msg.Address != "/track/2/midi/volume" {
+		t.Errorf("expected address /track/2/midi/volume, got %s", msg.Address)
+	}
+
+	expected := []interface{}{int32(250), int32(64)}
+	if len(msg.Arguments) != len(expected) {
+		t.Fatalf("expected arguments %#v, got %#v", expected, msg.Arguments)
+	}
+
+	for i, arg := range expected {
+		if msg.Arguments[i] != arg {
+			t.Errorf("expected arguments %#v, got %#v", expected, msg.Arguments)
+			break
+		}
+	}
+}
+
+func TestTransmitPanningMessage(t *testing.T) {
+	sent := captureSent(t)
+
+	transmitter := OSCTransmitter{Port: 27278}
+
+	for _, panning := range []float64{-1, 1.1} {
+		if err := transmitter.TransmitPanningMessage(3, panning, 0); err == nil {
+			t.Errorf("expected panning %v to be rejected", panning)
+		}
+	}
+
+	if len(*sent) != 0 {
+		t.Fatalf("expected no packets to be sent, got %d", len(*sent))
+	}
+
+	if err := transmitter.TransmitPanningMessage(3, 0.75, 500); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*sent) != 1 {
+		t.Fatalf("expected 1 packet to be sent, got %d", len(*sent))
+	}
+
+	msg, ok := (*sent)[0].(*osc.Message)
+	if !ok {
+		t.Fatalf("expected an OSC message, got %#v", (*sent)[0])
+	}
+
+	if msg.Address != "/track/3/midi/panning" {
+		t.Errorf("expected address /track/3/midi/panning, got %s", msg.Address)
+	}
+
+	expected := []interface{}{int32(500), int32(95)}
+	if len(msg.Arguments) != len(expected) {
+		t.Fatalf("expected arguments %#v, got %#v", expected, msg.Arguments)
+	}
+
+	for i, arg := range expected {
+		if msg.Arguments[i] != arg {
+			t.Errorf("expected arguments %#v, got %#v", expected, msg.Arguments)
+			break
+		}
+	}
+}
+
+func TestPanningChanges(t *testing.T) {
+	ast, err := parser.ParseString(
+		"piano: c (pan 75) d e violin: (panning 0) f",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates, err := ast.Updates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score := model.NewScore()
+	if err := score.Update(updates...); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := OSCTransmitter{}.ScoreToOSCBundle(score)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	panning := []string{}
+	for _, msg := range bundle.Messages {
+		if strings.HasSuffix(msg.Address, "/midi/panning") {
+			panning = append(panning, fmt.Sprintf(
+				"%s %v %v", msg.Address, msg.Arguments[0], msg.Arguments[1],
+			))
+		}
+	}
+
+	// A panning message is sent for the initial panning of each track, and then
+	// whenever it changes.
+	expected := []string{
+		"/track/1/midi/panning 0 64",
+		"/track/1/midi/panning 500 95",
+		"/track/2/midi/panning 0 0",
+	}
+
+	// The order of simultaneous events from different tracks is unspecified.
+	sort.Strings(panning)
+
+	if strings.Join(panning, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected panning messages %v, got %v", expected, panning)
+	}
+}
+
+func TestDetune(t *testing.T) {
+	ast, err := parser.ParseString(
+		"piano: c (detune 50) d (detune 0) e violin: f",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates, err := ast.Updates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score := model.NewScore()
+	if err := score.Update(updates...); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := OSCTransmitter{}.ScoreToOSCBundle(score)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The pitch bend and note messages of each track, in order.
+	messages := map[string][]string{}
+	for _, msg := range bundle.Messages {
+		if !strings.HasSuffix(msg.Address, "/midi/pitch-bend") &&
+			!strings.HasSuffix(msg.Address, "/midi/note") {
+			continue
+		}
+
+		track := strings.Split(msg.Address, "/")[2]
+		messages[track] = append(messages[track], fmt.Sprintf(
+			"%s %v %v", msg.Address, msg.Arguments[0], msg.Arguments[1],
+		))
+	}
+
+	// A pitch bend message is sent before each note whose detuning differs from
+	// the previous note's. +50 cents is a quarter of the way from no bend (8192)
+	// to the maximum bend of 2 semitones.
+	expected := []string{
+		"/track/1/midi/pitch-bend 0 8192",
+		"/track/1/midi/note 0 60",
+		"/track/1/midi/pitch-bend 500 10240",
+		"/track/1/midi/note 500 62",
+		"/track/1/midi/pitch-bend 1000 8192",
+		"/track/1/midi/note 1000 64",
+	}
+
+	if fmt.Sprint(messages["1"]) != fmt.Sprint(expected) {
+		t.Errorf("expected messages %v, got %v", expected, messages["1"])
+	}
+
+	// The violin is never detuned, so no pitch bend messages are sent for it.
+	expected = []string{"/track/2/midi/note 0 65"}
+	if fmt.Sprint(messages["2"]) != fmt.Sprint(expected) {
+		t.Errorf("expected messages %v, got %v", expected, messages["2"])
+	}
+}
+
+func TestTransmitStopMessage(t *testing.T) {
+	sent := captureSent(t)
+
+	if err := (OSCTransmitter{Port: 27278}).TransmitStopMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*sent) != 1 {
+		t.Fatalf("expected 1 packet to be sent, got %d", len(*sent))
+	}
+
+	msg, ok := (*sent)[0].(*osc.Message)
+	if !ok {
+		t.Fatalf("expected an OSC message, got %#v", (*sent)[0])
+	}
+
+	if msg.Address != "/system/stop" {
+		t.Errorf("expected address /system/stop, got %s", msg.Address)
+	}
+}
+
+func TestTransmitBundle(t *testing.T) {
+	sent := captureSent(t)
+
+	msgs := []*osc.Message{
+		midiPatchMsg(1, 0, 12),
+		midiNoteMsg(1, 0, 60, 500, 450, 100),
+		midiNoteMsg(1, 500, 62, 500, 450, 100),
+	}
+
+	timetag := time.Now().Add(time.Second)
+
+	if err := (OSCTransmitter{Port: 27278}).TransmitBundle(
+		msgs, timetag,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*sent) != 1 {
+		t.Fatalf("expected 1 packet to be sent, got %d", len(*sent))
+	}
+
+	bundle, ok := (*sent)[0].(*osc.Bundle)
+	if !ok {
+		t.Fatalf("expected an OSC bundle, got %#v", (*sent)[0])
+	}
+
+	if len(bundle.Messages) != len(msgs) {
+		t.Fatalf(
+			"expected %d messages, got %d", len(msgs), len(bundle.Messages),
+		)
+	}
+
+	for i, msg := range bundle.Messages {
+		if msg != msgs[i] {
+			t.Errorf("expected message %d to be %s, got %s", i, msgs[i], msg)
+		}
+	}
+
+	// OSC timetags have sub-nanosecond precision, but converting to and from
+	// them can introduce rounding errors.
+	if diff := bundle.Timetag.Time().Sub(timetag); diff < -time.Microsecond ||
+		diff > time.Microsecond {
+		t.Errorf(
+			"expected timetag %s, got %s", timetag, bundle.Timetag.Time(),
+		)
+	}
+}
+
+// patchMessages returns the program change messages in a bundle.
+func patchMessages(bundle *osc.Bundle) []*osc.Message {
+	msgs := []*osc.Message{}
+	for _, msg := range bundle.Messages {
+		if strings.HasSuffix(msg.Address, "/midi/patch") {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	return msgs
+}
+
+func TestProgramChanges(t *testing.T) {
+	score := model.NewScore()
+
+	addInput := func(input string) {
+		ast, err := parser.ParseString(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		updates, err := ast.Updates()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := score.Update(updates...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	addInput("piano: c d e")
+
+	bundle, err := OSCTransmitter{}.ScoreToOSCBundle(score)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if patches := patchMessages(bundle); len(patches) != 1 {
+		t.Fatalf("expected 1 program change, got %d: %v", len(patches), patches)
+	}
+
+	// When we keep track of the patches that were sent, subsequent
+	// transmissions for the same score only include a program change for a
+	// track whose instrument hasn't been sent yet.
+	patchesSent := map[int32]int32{}
+
+	bundle, err = OSCTransmitter{}.ScoreToOSCBundle(
+		score, PatchesSent(patchesSent),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if patches := patchMessages(bundle); len(patches) != 1 {
+		t.Fatalf("expected 1 program change, got %d: %v", len(patches), patches)
+	}
+
+	eventIndex := len(score.Events)
+	addInput("f g violin: c d")
+
+	bundle, err = OSCTransmitter{}.ScoreToOSCBundle(
+		score, TransmitFromIndex(eventIndex), PatchesSent(patchesSent),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patches := patchMessages(bundle)
+	if len(patches) != 1 || patches[0].Address != "/track/2/midi/patch" {
+		t.Fatalf("expected a program change for track 2 only, got %v", patches)
+	}
+}
+
+func TestQuantization(t *testing.T) {
+	ast, err := parser.ParseString("(quantize! 50) piano: c d e")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates, err := ast.Updates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score := model.NewScore()
+	if err := score.Update(updates...); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := OSCTransmitter{}.ScoreToOSCBundle(score)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notes := []*osc.Message{}
+	for _, msg := range bundle.Messages {
+		if strings.HasSuffix(msg.Address, "/midi/note") {
+			notes = append(notes, msg)
+		}
+	}
+
+	if len(notes) != 3 {
+		t.Fatalf("expected 3 notes, got %d: %v", len(notes), notes)
+	}
+
+	// With a quantization of 50%, each note is heard for the first half of its
+	// duration, i.e. it ends halfway to the next note.
+	for i, msg := range notes {
+		offset := msg.Arguments[0].(int32)
+		duration := msg.Arguments[2].(int32)
+		audibleDuration := msg.Arguments[3].(int32)
+
+		if offset != int32(i*500) || duration != 500 {
+			t.Errorf(
+				"expected note %d to be 500ms long at offset %d, got %dms at %d",
+				i+1, i*500, duration, offset,
+			)
+		}
+
+		if offset+audibleDuration != offset+duration/2 {
+			t.Errorf(
+				"expected note %d to end at %d, got %d",
+				i+1, offset+duration/2, offset+audibleDuration,
+			)
+		}
+	}
+}
+
+func TestTempoScale(t *testing.T) {
+	ast, err := parser.ParseString("piano: (tempo 120) c d e")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates, err := ast.Updates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score := model.NewScore()
+	if err := score.Update(updates...); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := OSCTransmitter{}.ScoreToOSCBundle(score, TempoScale(0.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notes := []*osc.Message{}
+	for _, msg := range bundle.Messages {
+		switch {
+		case strings.HasSuffix(msg.Address, "/midi/note"):
+			notes = append(notes, msg)
+		case msg.Address == "/system/tempo":
+			if tempo := msg.Arguments[1].(float32); tempo != 60 {
+				t.Errorf("expected tempo 60, got %v", tempo)
+			}
+		}
+	}
+
+	if len(notes) != 3 {
+		t.Fatalf("expected 3 notes, got %d: %v", len(notes), notes)
+	}
+
+	// At half speed, the offset and duration of each note are doubled.
+	for i, msg := range notes {
+		offset := msg.Arguments[0].(int32)
+		duration := msg.Arguments[2].(int32)
+
+		if offset != int32(i*1000) || duration != 1000 {
+			t.Errorf(
+				"expected note %d to be 1000ms long at offset %d, got %dms at %d",
+				i+1, i*1000, duration, offset,
+			)
+		}
+	}
+}
+
+func TestPlayRangeBetweenMarkers(t *testing.T) {
+	ast, err := parser.ParseString("piano: %intro c d %verse e f g %chorus a b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates, err := ast.Updates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score := model.NewScore()
+	if err := score.Update(updates...); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := OSCTransmitter{}.ScoreToOSCBundle(
+		score, TransmitFrom("verse"), TransmitTo("chorus"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only e, f and g are in range, and their offsets are relative to the verse
+	// marker.
+	expectedNotes := []int32{64, 65, 67}
+	expectedOffsets := []int32{0, 500, 1000}
+
+	notes := []*osc.Message{}
+	for _, msg := range bundle.Messages {
+		if strings.HasSuffix(msg.Address, "/midi/note") {
+			notes = append(notes, msg)
+		}
+	}
+
+	if len(notes) != len(expectedNotes) {
+		t.Fatalf(
+			"expected %d notes, got %d: %v", len(expectedNotes), len(notes), notes,
+		)
+	}
+
+	for i, msg := range notes {
+		offset, note := msg.Arguments[0].(int32), msg.Arguments[1].(int32)
+		if note != expectedNotes[i] || offset != expectedOffsets[i] {
+			t.Errorf(
+				"expected note %d at offset %d, got note %d at offset %d",
+				expectedNotes[i], expectedOffsets[i], note, offset,
+			)
+		}
+	}
+}
+
+func TestStreamInWindows(t *testing.T) {
+	sent := captureSent(t)
+
+	waits := []time.Time{}
+	originalWaitUntil := waitUntil
+	t.Cleanup(func() { waitUntil = originalWaitUntil })
+	waitUntil = func(t time.Time) { waits = append(waits, t) }
+
+	// At the default tempo, each note is 500ms long, so the score is about 83
+	// minutes long.
+	ast, err := parser.ParseString("piano: " + strings.Repeat("c ", 10000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates, err := ast.Updates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score := model.NewScore()
+	if err := score.Update(updates...); err != nil {
+		t.Fatal(err)
+	}
+
+	window := 10 * time.Second
+
+	if err := (OSCTransmitter{Port: 27278}).TransmitScore(
+		score, StreamInWindows(window),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// 5,000,000ms of notes in 10,000ms windows
+	if len(*sent) != 500 {
+		t.Fatalf("expected 500 bundles to be sent, got %d", len(*sent))
+	}
+
+	if len(waits) != len(*sent)-1 {
+		t.Errorf("expected to wait before each bundle after the first")
+	}
+
+	windowMs := int32(window / time.Millisecond)
+	notes := 0
+
+	for i, packet := range *sent {
+		bundle := packet.(*osc.Bundle)
+
+		for _, msg := range bundle.Messages {
+			if !strings.HasSuffix(msg.Address, "/midi/note") {
+				continue
+			}
+
+			notes++
+
+			// Every window is full of notes, so each window starts where the
+			// previous one ends, and the offsets within it start over at 0.
+			if offset := msg.Arguments[0].(int32); offset < 0 || offset >= windowMs {
+				t.Fatalf("expected note at offset %d in bundle %d", offset, i)
+			}
+		}
+	}
+
+	// Each bundle is sent one window after the previous one.
+	for i := 1; i < len(waits); i++ {
+		if gap := waits[i].Sub(waits[i-1]); gap != window {
+			t.Errorf(
+				"expected bundles %d and %d to be %s apart, got %s",
+				i, i+1, window, gap,
+			)
+		}
+	}
+
+	if notes != 10000 {
+		t.Errorf("expected 10000 notes to be sent, got %d", notes)
+	}
+}
+
+func TestStreamedOffsetsAreRelativeToPreviousWindow(t *testing.T) {
+	sent := captureSent(t)
+
+	originalWaitUntil := waitUntil
+	t.Cleanup(func() { waitUntil = originalWaitUntil })
+	waitUntil = func(t time.Time) {}
+
+	// At the default tempo, a quarter note is 500ms long and a whole note is
+	// 2000ms long.
+	ast, err := parser.ParseString(`
+piano: c4 d e1 r4 f4
+violin: g2 a4 b1 c4`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates, err := ast.Updates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score := model.NewScore()
+	if err := score.Update(updates...); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (OSCTransmitter{Port: 27278}).TransmitScore(
+		score, StreamInWindows(time.Second),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// The offsets of the notes on each track, per window. The offsets in each
+	// window are relative to where the last note of the track's previous window
+	// ends.
+	expected := [][]string{
+		{"/track/1 0", "/track/1 500", "/track/2 0"},
+		// The violin's last note in this window ends at 3500ms.
+		{"/track/1 0", "/track/2 0", "/track/2 500"},
+		// The next window (2000-3000ms) has no notes, so it isn't sent.
+		{"/track/1 500", "/track/2 0"},
+	}
+
+	actual := [][]string{}
+	for _, packet := range *sent {
+		notes := []string{}
+
+		for _, msg := range packet.(*osc.Bundle).Messages {
+			if strings.HasSuffix(msg.Address, "/midi/note") {
+				notes = append(notes, fmt.Sprintf(
+					"%s %d",
+					strings.TrimSuffix(msg.Address, "/midi/note"),
+					msg.Arguments[0],
+				))
+			}
+		}
+
+		sort.Strings(notes)
+		actual = append(actual, notes)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected note offsets %v, got %v", expected, actual)
+	}
+}
+
+func TestStreamedPartThatEntersLate(t *testing.T) {
+	sent := captureSent(t)
+
+	originalWaitUntil := waitUntil
+	t.Cleanup(func() { waitUntil = originalWaitUntil })
+	waitUntil = func(t time.Time) {}
+
+	// The violin doesn't start playing until 3000ms, and the piano rests from
+	// 500ms to 4500ms.
+	ast, err := parser.ParseString(`
+piano: c4 r1 r1 d4 e f g
+violin: r1 r2 c4 d e f g a`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates, err := ast.Updates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score := model.NewScore()
+	if err := score.Update(updates...); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (OSCTransmitter{Port: 27278}).TransmitScore(
+		score, StreamInWindows(time.Second),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each batch of messages for a track is sent at least one window before the
+	// track's previous notes end, so the first notes of the violin and the
+	// piano's notes after the rest are sent in the first bundle.
+	expected := [][]string{
+		{
+			"/track/1/midi/note 0",
+			"/track/1/midi/note 4500",
+			"/track/1/midi/patch 0",
+			"/track/2/midi/note 3000",
+			"/track/2/midi/note 3500",
+			"/track/2/midi/patch 0",
+		},
+		// The violin's notes from 3000ms end at 4000ms.
+		{"/track/2/midi/note 0", "/track/2/midi/note 500"},
+		// The piano's notes from 4500ms end at 5000ms, and the violin's notes
+		// from 4000ms end at 5000ms.
+		{
+			"/track/1/midi/note 0",
+			"/track/1/midi/note 500",
+			"/track/2/midi/note 0",
+			"/track/2/midi/note 500",
+		},
+		{"/track/1/midi/note 0"},
+	}
+
+	actual := [][]string{}
+	for _, packet := range *sent {
+		msgs := []string{}
+
+		for _, msg := range packet.(*osc.Bundle).Messages {
+			if strings.HasSuffix(msg.Address, "/midi/note") ||
+				strings.HasSuffix(msg.Address, "/midi/patch") {
+				msgs = append(msgs, fmt.Sprintf("%s %d", msg.Address, msg.Arguments[0]))
+			}
+		}
+
+		sort.Strings(msgs)
+		actual = append(actual, msgs)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected messages %v, got %v", expected, actual)
+	}
+}
+
+func TestClockOffset(t *testing.T) {
+	sent := captureSent(t)
+
+	waits := []time.Time{}
+	originalWaitUntil := waitUntil
+	t.Cleanup(func() { waitUntil = originalWaitUntil })
+	waitUntil = func(t time.Time) { waits = append(waits, t) }
+
+	ast, err := parser.ParseString("piano: " + strings.Repeat("c ", 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates, err := ast.Updates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score := model.NewScore()
+	if err := score.Update(updates...); err != nil {
+		t.Fatal(err)
+	}
+
+	clockOffset := time.Hour
+	window := 10 * time.Second
+
+	for _, opts := range [][]TransmissionOption{
+		{ClockOffset(clockOffset)},
+		{ClockOffset(clockOffset), StreamInWindows(window)},
+	} {
+		*sent = nil
+		waits = nil
+		start := time.Now()
+
+		if err := (OSCTransmitter{Port: 27278}).TransmitScore(
+			score, opts...,
+		); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(*sent) == 0 {
+			t.Fatal("expected bundles to be sent")
+		}
+
+		// The player process starts playing as soon as it receives the score, so
+		// sending the score is delayed by the offset.
+		if len(waits) == 0 ||
+			waits[0].Before(start.Add(clockOffset)) ||
+			waits[0].After(start.Add(clockOffset).Add(time.Second)) {
+			t.Errorf("expected to wait %s before sending, got %v", clockOffset, waits)
+		}
+
+		// When streaming, each bundle's timetag is the time when its window
+		// starts playing, which is `window` later for each bundle.
+		for i, packet := range *sent {
+			bundle := packet.(*osc.Bundle)
+			earliest := start.Add(clockOffset).Add(time.Duration(i) * window)
+			latest := earliest.Add(time.Second)
+
+			timetag := bundle.Timetag.Time()
+			if timetag.Before(earliest) || timetag.After(latest) {
+				t.Errorf(
+					"expected bundle %d to have a timetag between %s and %s, got %s",
+					i, earliest, latest, timetag,
+				)
+			}
+		}
+	}
+}
+
+func TestStartAt(t *testing.T) {
+	sent := captureSent(t)
+
+	originalWaitUntil := waitUntil
+	t.Cleanup(func() { waitUntil = originalWaitUntil })
+	waitUntil = func(t time.Time) {}
+
+	ast, err := parser.ParseString("piano: " + strings.Repeat("c ", 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates, err := ast.Updates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score := model.NewScore()
+	if err := score.Update(updates...); err != nil {
+		t.Fatal(err)
+	}
+
+	startTime := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := 10 * time.Second
+
+	for _, opts := range [][]TransmissionOption{
+		{StartAt(startTime)},
+		{StartAt(startTime), StreamInWindows(window)},
+	} {
+		*sent = nil
+
+		if err := (OSCTransmitter{Port: 27278}).TransmitScore(
+			score, opts...,
+		); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(*sent) == 0 {
+			t.Fatal("expected bundles to be sent")
+		}
+
+		for i, packet := range *sent {
+			bundle := packet.(*osc.Bundle)
+			expected := startTime.Add(time.Duration(i) * window)
+
+			// OSC timetags have sub-nanosecond precision, but converting to and
+			// from them can introduce rounding errors.
+			timetag := bundle.Timetag.Time()
+			if diff := timetag.Sub(expected); diff < -time.Microsecond ||
+				diff > time.Microsecond {
+				t.Errorf(
+					"expected bundle %d to have timetag %s, got %s",
+					i, expected, timetag,
+				)
+			}
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	sent := captureSent(t)
+
+	ast, err := parser.ParseString("piano: (tempo 90) c d e")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates, err := ast.Updates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score := model.NewScore()
+	if err := score.Update(updates...); err != nil {
+		t.Fatal(err)
+	}
+
+	capture := &bytes.Buffer{}
+	transmitter := OSCTransmitter{Port: 27278, Capture: capture, DryRun: true}
+
+	if err := transmitter.TransmitScore(score); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := transmitter.TransmitStopMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*sent) != 0 {
+		t.Errorf("expected no packets to be sent, got %d", len(*sent))
+	}
+
+	// Packets are still captured in a dry run.
+	if !strings.Contains(capture.String(), "/system/stop") {
+		t.Errorf("expected the stop message to be captured, got:\n%s", capture)
+	}
+}
+
+func TestCapture(t *testing.T) {
+	captureSent(t)
+
+	ast, err := parser.ParseString("piano: (tempo 90) c d e")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates, err := ast.Updates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score := model.NewScore()
+	if err := score.Update(updates...); err != nil {
+		t.Fatal(err)
+	}
+
+	capture := &bytes.Buffer{}
+	transmitter := OSCTransmitter{Port: 27278, Capture: capture}
+
+	if err := transmitter.TransmitScore(score); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := transmitter.TransmitStopMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	output := capture.String()
+
+	for _, expected := range []string{
+		"#bundle ",
+		"  /track/1/midi/patch ,ii 0 0\n",
+		"  /system/tempo ,if 0 90\n",
+		"  /track/1/midi/note ,iiiii 0 60 ",
+		"  /system/play ,\n",
+		"\n/system/stop ,\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected capture to contain %q, got:\n%s", expected, output)
+		}
+	}
+
+	if count := strings.Count(output, "--- "); count != 2 {
+		t.Errorf("expected 2 captured packets, got %d:\n%s", count, output)
+	}
+}
+
+// listenForPackets listens for OSC packets over TCP on the provided network
+// (e.g. "tcp6") and address, like a player process does, and returns the port
+// it's listening on along with a channel that receives the raw bytes of each
+// packet received.
+func listenForPackets(
+	t *testing.T, network string, address string,
+) (int, <-chan []byte) {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Skipf("unable to listen on %s: %v", address, err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	packets := make(chan []byte, 10)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			data, _ := ioutil.ReadAll(conn)
+			conn.Close()
+
+			packets <- data
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port, packets
+}
+
+func TestTransmitToHost(t *testing.T) {
+	for _, tc := range []struct {
+		network       string
+		listenAddress string
+		host          string
+	}{
+		// The default host
+		{"tcp4", "127.0.0.1:0", ""},
+		// IPv6 loopback
+		{"tcp6", "[::1]:0", "::1"},
+	} {
+		port, packets := listenForPackets(t, tc.network, tc.listenAddress)
+
+		transmitter := OSCTransmitter{Host: tc.host, Port: port}
+		if err := transmitter.TransmitPingMessage(); err != nil {
+			t.Errorf("failed to transmit to host %q: %v", tc.host, err)
+			continue
+		}
+
+		select {
+		case data := <-packets:
+			if !bytes.Contains(data, []byte("/ping")) {
+				t.Errorf("expected a /ping message, got %q", data)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("no message received on host %q", tc.host)
+		}
+	}
+}
+
+func TestOneConnectionPerPacket(t *testing.T) {
+	port, packets := listenForPackets(t, "tcp4", "127.0.0.1:0")
+	transmitter := OSCTransmitter{Port: port}
+
+	// Send packets from two routines at the same time, like the REPL server
+	// might when it pings its player process while a client plays something.
+	var wg sync.WaitGroup
+	for _, transmit := range []func() error{
+		transmitter.TransmitPingMessage, transmitter.TransmitStopMessage,
+	} {
+		wg.Add(1)
+		go func(transmit func() error) {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				if err := transmit(); err != nil {
+					t.Error(err)
+				}
+			}
+		}(transmit)
+	}
+	wg.Wait()
+
+	// The player process treats everything received on a connection as one
+	// packet, so each connection must contain exactly one message.
+	for i := 0; i < 10; i++ {
+		select {
+		case data := <-packets:
+			messages := bytes.Count(data, []byte("/ping")) +
+				bytes.Count(data, []byte("/system/stop"))
+			if messages != 1 {
+				t.Errorf("expected 1 message per connection, got %d: %q", messages, data)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected 10 connections, got %d", i)
+		}
+	}
+}
+
+func TestTransmitMidiExportMessage(t *testing.T) {
+	sent := captureSent(t)
+
+	filename := "/tmp/my-score.mid"
+
+	if err := (OSCTransmitter{Port: 27278}).TransmitMidiExportMessage(
+		filename,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*sent) != 1 {
+		t.Fatalf("expected 1 packet to be sent, got %d", len(*sent))
+	}
+
+	msg, ok := (*sent)[0].(*osc.Message)
+	if !ok {
+		t.Fatalf("expected an OSC message, got %#v", (*sent)[0])
+	}
+
+	if msg.Address != "/system/midi/export" {
+		t.Errorf("expected address /system/midi/export, got %s", msg.Address)
+	}
+
+	if len(msg.Arguments) != 1 || msg.Arguments[0] != filename {
+		t.Errorf("expected filename argument %q, got %#v", filename, msg.Arguments)
+	}
+}
+
+func TestTransmitSoundfontMessage(t *testing.T) {
+	sent := captureSent(t)
+
+	transmitter := OSCTransmitter{Port: 27278}
+
+	if err := transmitter.TransmitSoundfontMessage("test.sf2"); err == nil {
+		t.Error("expected an error for a relative soundfont path")
+	}
+
+	if len(*sent) != 0 {
+		t.Fatalf("expected no packets to be sent, got %d", len(*sent))
+	}
+
+	// The file doesn't need to exist on this host, because the player process
+	// might be running somewhere else.
+	soundfont := filepath.Join(t.TempDir(), "missing.sf2")
+	if err := transmitter.TransmitSoundfontMessage(soundfont); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*sent) != 1 {
+		t.Fatalf("expected 1 packet to be sent, got %d", len(*sent))
+	}
+
+	msg, ok := (*sent)[0].(*osc.Message)
+	if !ok {
+		t.Fatalf("expected an OSC message, got %#v", (*sent)[0])
+	}
+
+	if msg.Address != "/system/soundfont" {
+		t.Errorf("expected address /system/soundfont, got %s", msg.Address)
+	}
+
+	if len(msg.Arguments) != 1 || msg.Arguments[0] != soundfont {
+		t.Errorf(
+			"expected soundfont argument %q, got %#v", soundfont, msg.Arguments,
+		)
+	}
+}
+
+func TestRequestLatency(t *testing.T) {
+	// A fake player process that replies to latency requests.
+	originalSend := send
+	t.Cleanup(func() { send = originalSend })
+
+	send = func(host string, port int, packet osc.Packet) error {
+		msg, ok := packet.(*osc.Message)
+		if !ok || msg.Address != "/system/latency" {
+			t.Errorf("expected a latency request, got %#v", packet)
+			return nil
+		}
+
+		replyPort := int(msg.Arguments[0].(int32))
+		replyHost := msg.Arguments[1].(string)
+
+		reply := osc.NewMessage("/system/latency/reply")
+		reply.Append(int32(12500))
+
+		return osc.NewClient(
+			system.OSCClientHost(replyHost), replyPort, osc.ClientProtocol(osc.TCP),
+		).Send(reply)
+	}
+
+	latency, err := OSCTransmitter{Port: 27278}.RequestLatency(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if latency != 12500*time.Microsecond {
+		t.Errorf("expected latency 12.5ms, got %s", latency)
+	}
+}
+
+func TestRequestClock(t *testing.T) {
+	playerClock := time.UnixMilli(1600000000000)
+
+	// A fake player process that replies to clock requests.
+	originalSend := send
+	t.Cleanup(func() { send = originalSend })
+
+	send = func(host string, port int, packet osc.Packet) error {
+		msg, ok := packet.(*osc.Message)
+		if !ok || msg.Address != "/system/clock" {
+			t.Errorf("expected a clock request, got %#v", packet)
+			return nil
+		}
+
+		replyPort := int(msg.Arguments[0].(int32))
+		replyHost := msg.Arguments[1].(string)
+
+		reply := osc.NewMessage("/system/clock/reply")
+		reply.Append(playerClock.UnixMilli())
+
+		return osc.NewClient(
+			system.OSCClientHost(replyHost), replyPort, osc.ClientProtocol(osc.TCP),
+		).Send(reply)
+	}
+
+	clock, err := OSCTransmitter{Port: 27278}.RequestClock(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !clock.Equal(playerClock) {
+		t.Errorf("expected clock %s, got %s", playerClock, clock)
+	}
+}
+
+func TestRequestLatencyWithoutReply(t *testing.T) {
+	captureSent(t)
+
+	_, err := OSCTransmitter{Port: 27278}.RequestLatency(
+		200 * time.Millisecond,
+	)
+	if !errors.Is(err, errNoLatencyReply) {
+		t.Errorf("expected %v, got %v", errNoLatencyReply, err)
+	}
+}
+
+func TestReplyHost(t *testing.T) {
+	// A player process on this host is reached over the loopback interface, so
+	// that's where it should send its reply.
+	for _, host := range []string{"", "127.0.0.1"} {
+		replyHost, err := replyHost(host, 27278)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if replyHost != "127.0.0.1" {
+			t.Errorf("expected reply host 127.0.0.1 for %q, got %s", host, replyHost)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package transmitter
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/daveyarwood/go-osc/osc"
+)
+
+// ErrQueueFull is returned when a packet can't be sent because the send queue
+// is full and the queue is in ErrorWhenFull mode.
+var ErrQueueFull = errors.New("the OSC send queue is full")
+
+var errSendQueueClosed = errors.New("the OSC send queue is closed")
+
+// OverflowMode determines what happens when a packet is added to a full send
+// queue.
+type OverflowMode int
+
+const (
+	// BlockWhenFull makes the sender wait until there is room in the queue.
+	BlockWhenFull OverflowMode = iota
+	// ErrorWhenFull makes the sender give up right away with ErrQueueFull.
+	ErrorWhenFull
+)
+
+type queuedPacket struct {
+	host   string
+	port   int
+	packet osc.Packet
+	result chan error
+}
+
+// A SendQueue sends OSC packets to player processes one at a time, in the
+// order in which they were queued, and limits how many packets can be waiting
+// to be sent.
+//
+// Without a queue, every routine that transmits something sends its packet
+// right away. If the player process falls behind, those sends pile up. When an
+// OSCTransmitter has a SendQueue, the queue applies backpressure instead: once
+// `capacity` packets are waiting, the sender either waits or gets an error,
+// depending on the OverflowMode.
+//
+// Transmitters without a queue (e.g. the ones that the REPL server uses to ping
+// its player process) are unaffected, so they don't have to wait behind a
+// backlog of queued packets.
+type SendQueue struct {
+	packets   chan queuedPacket
+	mode      OverflowMode
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSendQueue returns a SendQueue that holds up to `capacity` packets that are
+// waiting to be sent, and starts sending them in a separate routine. Call
+// `Close` to stop that routine when the queue is no longer needed.
+func NewSendQueue(capacity int, mode OverflowMode) *SendQueue {
+	queue := &SendQueue{
+		packets: make(chan queuedPacket, capacity),
+		mode:    mode,
+		done:    make(chan struct{}),
+	}
+
+	go queue.run()
+
+	return queue
+}
+
+func (queue *SendQueue) run() {
+	for {
+		select {
+		case <-queue.done:
+			return
+		case qp := <-queue.packets:
+			qp.result <- send(qp.host, qp.port, qp.packet)
+		}
+	}
+}
+
+// send adds a packet to the queue and waits for it to be sent. Returns the
+// error from sending the packet, or ErrQueueFull if the queue is full and in
+// ErrorWhenFull mode.
+func (queue *SendQueue) send(host string, port int, packet osc.Packet) error {
+	qp := queuedPacket{
+		host:   host,
+		port:   port,
+		packet: packet,
+		result: make(chan error, 1),
+	}
+
+	switch queue.mode {
+	case ErrorWhenFull:
+		select {
+		case queue.packets <- qp:
+		default:
+			return ErrQueueFull
+		}
+	default:
+		select {
+		case queue.packets <- qp:
+		case <-queue.done:
+			return errSendQueueClosed
+		}
+	}
+
+	select {
+	case err := <-qp.result:
+		return err
+	case <-queue.done:
+		return errSendQueueClosed
+	}
+}
+
+// Close stops sending packets. Packets that are still waiting in the queue are
+// never sent; their senders get an error instead.
+func (queue *SendQueue) Close() {
+	queue.closeOnce.Do(func() { close(queue.done) })
+}
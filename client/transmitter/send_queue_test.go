@@ -0,0 +1,118 @@
+package transmitter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/daveyarwood/go-osc/osc"
+)
+
+// stallSends swaps out the function that sends OSC packets so that every send
+// waits until the returned channel is closed, as if the player process had
+// fallen behind. Each send that starts is reported on the `started` channel.
+func stallSends(t *testing.T) (started <-chan struct{}, release chan struct{}) {
+	originalSend := send
+	t.Cleanup(func() { send = originalSend })
+
+	startedCh := make(chan struct{}, 10)
+	release = make(chan struct{})
+
+	send = func(host string, port int, packet osc.Packet) error {
+		startedCh <- struct{}{}
+		<-release
+		return nil
+	}
+
+	return startedCh, release
+}
+
+// fillQueue starts one send that stalls, and then queues `capacity` more
+// behind it, so that the queue is full. Returns a channel that receives the
+// result of each of those sends.
+func fillQueue(
+	t *testing.T, oe OSCTransmitter, capacity int, started <-chan struct{},
+) <-chan error {
+	results := make(chan error, capacity+1)
+
+	go func() { results <- oe.TransmitPlayMessage() }()
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("the first send never started")
+	}
+
+	for i := 0; i < capacity; i++ {
+		go func() { results <- oe.TransmitPlayMessage() }()
+	}
+
+	// Wait for the queued sends to be in the queue.
+	deadline := time.Now().Add(time.Second)
+	for len(oe.Queue.packets) < capacity {
+		if time.Now().After(deadline) {
+			t.Fatal("the queue never filled up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	return results
+}
+
+func TestSendQueueErrorWhenFull(t *testing.T) {
+	started, release := stallSends(t)
+
+	queue := NewSendQueue(2, ErrorWhenFull)
+	defer queue.Close()
+
+	oe := OSCTransmitter{Port: 27278, Queue: queue}
+	results := fillQueue(t, oe, 2, started)
+
+	if err := oe.TransmitStopMessage(); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+
+	close(release)
+
+	for i := 0; i < 3; i++ {
+		if err := <-results; err != nil {
+			t.Errorf("expected queued send to succeed, got %v", err)
+		}
+	}
+}
+
+func TestSendQueueBlockWhenFull(t *testing.T) {
+	started, release := stallSends(t)
+
+	queue := NewSendQueue(2, BlockWhenFull)
+	defer queue.Close()
+
+	oe := OSCTransmitter{Port: 27278, Queue: queue}
+	results := fillQueue(t, oe, 2, started)
+
+	blocked := make(chan error, 1)
+	go func() { blocked <- oe.TransmitStopMessage() }()
+
+	select {
+	case err := <-blocked:
+		t.Fatalf("expected send to a full queue to block, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	select {
+	case err := <-blocked:
+		if err != nil {
+			t.Errorf("expected blocked send to succeed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked send never completed")
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := <-results; err != nil {
+			t.Errorf("expected queued send to succeed, got %v", err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package transmitter
 
 import (
 	"fmt"
+	"time"
 
 	log "alda.io/client/logging"
 	"alda.io/client/model"
@@ -34,6 +35,56 @@ type TransmissionContext struct {
 	// When true, the score will only be loaded, as opposed to being played,
 	// displayed, performed, etc.
 	loadOnly bool
+	// An optional record of the MIDI patch number most recently sent for each
+	// track. When provided, the patch for a track is only sent if it's different
+	// from the one that was sent before, and the record is updated with any
+	// patches that are sent.
+	patchesSent map[int32]int32
+	// When non-zero, the score is streamed in chunks of this length (in terms of
+	// playback time), instead of being transmitted all at once.
+	streamWindow time.Duration
+	// When non-zero, playback is sped up (> 1) or slowed down (< 1) by this
+	// factor. (See `TempoScale`.)
+	tempoScale float64
+	// Added to the time when the score is to start playing. (See
+	// `ClockOffset`.)
+	clockOffset time.Duration
+	// When non-zero, the time when the score is to start playing. (See
+	// `StartAt`.)
+	startAt time.Time
+	// The names or aliases of parts whose notes are not transmitted. (See
+	// `MuteParts`.)
+	mutedParts []string
+	// When non-empty, the names or aliases of the only parts whose notes are
+	// transmitted. (See `SoloParts`.)
+	soloedParts []string
+}
+
+// startTime returns the time when the score is to start playing: the time
+// provided via `StartAt`, or else right now.
+func (ctx *TransmissionContext) startTime() time.Time {
+	if ctx.startAt.IsZero() {
+		return time.Now()
+	}
+
+	return ctx.startAt
+}
+
+// playbackStart returns the time when the player process is to start playing
+// the score, i.e. the start time adjusted by the clock offset. (See
+// `ClockOffset`.)
+func (ctx *TransmissionContext) playbackStart() time.Time {
+	return ctx.startTime().Add(ctx.clockOffset)
+}
+
+// scaledMs returns the provided length of time (in milliseconds), adjusted for
+// the tempo scale.
+func (ctx *TransmissionContext) scaledMs(ms float64) float64 {
+	if ctx.tempoScale == 0 {
+		return ms
+	}
+
+	return ms / ctx.tempoScale
 }
 
 // TransmissionOption is a function that customizes a TransmissionContext
@@ -124,9 +175,204 @@ func LoadOnly() TransmissionOption {
 	}
 }
 
+// PatchesSent uses the provided map of track numbers to MIDI patch numbers as a
+// record of the instruments that the player process already has, so that
+// program change messages are only sent when a track's instrument changes. The
+// map is updated with any patches that are sent. The use case for this is REPL
+// usage, where the same player process receives many transmissions for the
+// same score.
+func PatchesSent(patchesSent map[int32]int32) TransmissionOption {
+	return func(ctx *TransmissionContext) {
+		log.Debug().
+			Str("patchesSent", fmt.Sprintf("%#v", patchesSent)).
+			Msg("Applying transmission option")
+
+		ctx.patchesSent = patchesSent
+	}
+}
+
+// StreamInWindows specifies that the score should be streamed in chunks, each
+// covering a time window of the provided length, instead of being transmitted
+// all at once. This is useful for very long scores.
+//
+// For the OSC transmitter, this means that the events in each window are sent
+// to the player process in a separate OSC bundle, shortly before they're due
+// to be played. Transmitting the score blocks until the last window is sent.
+func StreamInWindows(window time.Duration) TransmissionOption {
+	return func(ctx *TransmissionContext) {
+		log.Debug().
+			Dur("streamWindow", window).
+			Msg("Applying transmission option")
+
+		ctx.streamWindow = window
+	}
+}
+
+// TempoScale makes the score play faster or slower than written, by
+// multiplying every tempo in the score by the provided factor. For example, a
+// factor of 0.5 plays the score at half speed, which doubles the offset and
+// duration of every note.
+func TempoScale(factor float64) TransmissionOption {
+	return func(ctx *TransmissionContext) {
+		log.Debug().
+			Float64("tempoScale", factor).
+			Msg("Applying transmission option")
+
+		ctx.tempoScale = factor
+	}
+}
+
+// ClockOffset adds the provided offset to the time when the score is to start
+// playing. This is useful for lining up playback with other software or
+// external MIDI gear, e.g. to make up for the time that they take to respond.
+//
+// Because the player process starts playing a score as soon as it receives it,
+// a positive offset delays sending the score. A negative offset only has an
+// effect when the score is to start playing in the future (see `StartAt`);
+// otherwise, the score can't be sent any sooner than right away.
+//
+// NB: The offset is reflected in the timetag of each OSC bundle too, but the
+// player process doesn't use timetags.
+func ClockOffset(offset time.Duration) TransmissionOption {
+	return func(ctx *TransmissionContext) {
+		log.Debug().
+			Dur("clockOffset", offset).
+			Msg("Applying transmission option")
+
+		ctx.clockOffset = offset
+	}
+}
+
+// StartAt sets the time when the score is to start playing. By default, the
+// score starts playing right away.
+//
+// Because the player process starts playing a score as soon as it receives it,
+// sending the score is delayed until the start time. (See also
+// `repl.Server.PlayAt`, which can be cancelled while it waits.)
+func StartAt(t time.Time) TransmissionOption {
+	return func(ctx *TransmissionContext) {
+		log.Debug().
+			Time("startAt", t).
+			Msg("Applying transmission option")
+
+		ctx.startAt = t
+	}
+}
+
+// MuteParts specifies parts whose notes are not to be transmitted, by the
+// part's name (e.g. "piano") or alias. Names that don't match any part in the
+// score are ignored.
+func MuteParts(names ...string) TransmissionOption {
+	return func(ctx *TransmissionContext) {
+		log.Debug().
+			Strs("mutedParts", names).
+			Msg("Applying transmission option")
+
+		ctx.mutedParts = names
+	}
+}
+
+// SoloParts specifies that only the notes of these parts are to be transmitted,
+// by the part's name (e.g. "piano") or alias. This takes precedence over
+// `MuteParts`, i.e. a part that is both muted and soloed is heard.
+func SoloParts(names ...string) TransmissionOption {
+	return func(ctx *TransmissionContext) {
+		log.Debug().
+			Strs("soloedParts", names).
+			Msg("Applying transmission option")
+
+		ctx.soloedParts = names
+	}
+}
+
+// silencedParts returns the parts in the score whose notes are not to be
+// transmitted, based on the parts that are muted and soloed.
+func (ctx *TransmissionContext) silencedParts(
+	score *model.Score,
+) map[*model.Part]bool {
+	silenced := map[*model.Part]bool{}
+
+	if len(ctx.soloedParts) > 0 {
+		soloed := map[*model.Part]bool{}
+		for _, name := range ctx.soloedParts {
+			for _, part := range score.PartsNamed(name) {
+				soloed[part] = true
+			}
+		}
+
+		for _, part := range score.Parts {
+			if !soloed[part] {
+				silenced[part] = true
+			}
+		}
+
+		return silenced
+	}
+
+	for _, name := range ctx.mutedParts {
+		for _, part := range score.PartsNamed(name) {
+			silenced[part] = true
+		}
+	}
+
+	return silenced
+}
+
 // A Transmitter sends score data somewhere for performance, visualization,
 // etc.
 type Transmitter interface {
 	// TransmitScore sends score data somewhere.
 	TransmitScore(score *model.Score, opts ...TransmissionOption) error
 }
+
+// A PlayerTransmitter is a Transmitter that can also send the other messages
+// that an Alda REPL server sends to its player process, e.g. to stop playback
+// or change the tempo.
+//
+// OSCTransmitter is the standard implementation, which sends OSC messages to a
+// player process, but an alternative backend (or a mock, in tests) can be used
+// instead.
+type PlayerTransmitter interface {
+	Transmitter
+	TransmitOffsetMessage(offset int32) error
+	TransmitStopMessage() error
+	TransmitClearMessage() error
+	TransmitOSCMessage(address string, args ...interface{}) error
+	TransmitShutdownMessage(offset int32) error
+	TransmitTempoMessage(bpm float64, offset int32) error
+	TransmitVolumeMessage(track int32, volume float64, offset int32) error
+	TransmitPanningMessage(track int32, panning float64, offset int32) error
+	TransmitSoundfontMessage(path string) error
+	TransmitMidiExportMessage(filename string) error
+	RequestLatency(timeout time.Duration) (time.Duration, error)
+	RequestClock(timeout time.Duration) (time.Time, error)
+}
+
+// TransmitWithRetry calls `transmit` up to `attempts` times, waiting `delay`
+// between attempts, until it succeeds. This is useful for riding out transient
+// network errors.
+//
+// Returns the error from the last attempt if every attempt fails.
+func TransmitWithRetry(
+	transmit func() error, attempts int, delay time.Duration,
+) error {
+	var err error
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = transmit(); err == nil {
+			return nil
+		}
+
+		log.Debug().
+			Err(err).
+			Int("attempt", attempt).
+			Int("attempts", attempts).
+			Msg("Transmission failed.")
+
+		if attempt < attempts {
+			time.Sleep(delay)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,45 @@
+package transmitter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	_ "alda.io/client/testing"
+)
+
+func TestTransmitWithRetry(t *testing.T) {
+	calls := 0
+	transmit := func() error {
+		calls++
+		if calls <= 2 {
+			return fmt.Errorf("transient failure %d", calls)
+		}
+		return nil
+	}
+
+	if err := TransmitWithRetry(transmit, 3, time.Millisecond); err != nil {
+		t.Errorf("expected eventual success, got %v", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestTransmitWithRetryGivesUp(t *testing.T) {
+	calls := 0
+	transmit := func() error {
+		calls++
+		return fmt.Errorf("failure %d", calls)
+	}
+
+	err := TransmitWithRetry(transmit, 3, time.Millisecond)
+	if err == nil || err.Error() != "failure 3" {
+		t.Errorf("expected the last failure, got %v", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
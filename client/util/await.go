@@ -1,15 +1,43 @@
 package util
 
-import "time"
+import (
+	"context"
+	"fmt"
+	"time"
+)
 
 // Await runs the provided `test` function once every 100ms and returns as soon
 // as either:
 //
 // * The function returns nil, indicating success, or
 // * The function returns an error and we've exceeded the provided timeout.
+//
+// On timeout, the returned error wraps the last error returned by the function,
+// so that the reason why it never succeeded isn't lost.
 func Await(test func() error, timeoutDuration time.Duration) error {
-	timeout := time.After(timeoutDuration)
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+	defer cancel()
+
+	if err := AwaitWithContext(ctx, test, 100*time.Millisecond); err != nil {
+		return fmt.Errorf(
+			"timed out after %s: last error: %w", timeoutDuration, err,
+		)
+	}
 
+	return nil
+}
+
+// AwaitWithContext runs the provided `test` function once every `interval` and
+// returns as soon as either:
+//
+// * The function returns nil, indicating success, or
+// * The context is done, e.g. because it was canceled or its deadline passed.
+//
+// The function is always run at least once. When the context is done, the last
+// error returned by the function is returned.
+func AwaitWithContext(
+	ctx context.Context, test func() error, interval time.Duration,
+) error {
 	for {
 		err := test()
 
@@ -18,10 +46,103 @@ func Await(test func() error, timeoutDuration time.Duration) error {
 		}
 
 		select {
-		case <-timeout:
+		case <-ctx.Done():
 			return err
-		default:
-			time.Sleep(100 * time.Millisecond)
+		case <-time.After(interval):
 		}
 	}
 }
+
+// AwaitResult is like Await, except that `fn` produces a value, which is
+// returned once `fn` succeeds. This saves the caller from having to capture the
+// value in a closure.
+//
+// If we exceed the provided timeout, the zero value of T is returned, along with
+// the timeout error. (See Await.)
+func AwaitResult[T any](
+	fn func() (T, error), timeoutDuration time.Duration,
+) (T, error) {
+	var result T
+
+	err := Await(
+		func() error {
+			value, err := fn()
+			if err != nil {
+				return err
+			}
+
+			result = value
+			return nil
+		},
+		timeoutDuration,
+	)
+
+	return result, err
+}
+
+// AwaitWithBackoff is like Await, except that the interval between attempts
+// starts at `initialInterval` and doubles after each failed attempt, up to
+// `maxInterval`. This avoids running `test` more often than necessary when
+// success isn't expected to come quickly.
+//
+// As with Await, the last error is returned once we've exceeded the provided
+// timeout. We never sleep past the timeout, so the final attempt happens right
+// around the time that the timeout is reached.
+func AwaitWithBackoff(
+	test func() error,
+	timeoutDuration, initialInterval, maxInterval time.Duration,
+) error {
+	deadline := time.Now().Add(timeoutDuration)
+	interval := initialInterval
+
+	for {
+		err := test()
+
+		if err == nil {
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return err
+		}
+
+		if interval > remaining {
+			interval = remaining
+		}
+
+		time.Sleep(interval)
+
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
+// AwaitResultWithBackoff is like AwaitWithBackoff, except that `fn` produces a
+// value, which is returned once `fn` succeeds. (See AwaitResult.)
+//
+// If we exceed the provided timeout, the zero value of T is returned, along with
+// the last error returned by `fn`.
+func AwaitResultWithBackoff[T any](
+	fn func() (T, error),
+	timeoutDuration, initialInterval, maxInterval time.Duration,
+) (T, error) {
+	var result T
+
+	err := AwaitWithBackoff(
+		func() error {
+			value, err := fn()
+			if err != nil {
+				return err
+			}
+
+			result = value
+			return nil
+		},
+		timeoutDuration, initialInterval, maxInterval,
+	)
+
+	return result, err
+}
@@ -0,0 +1,170 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAwaitResult(t *testing.T) {
+	attempts := 0
+
+	result, err := AwaitResult(
+		func() (string, error) {
+			attempts++
+			if attempts < 3 {
+				return "", errors.New("not yet")
+			}
+
+			return "ready", nil
+		},
+		time.Second,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != "ready" {
+		t.Errorf("expected %q, got %q", "ready", result)
+	}
+
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestAwaitResultTimeout(t *testing.T) {
+	errNotReady := errors.New("not ready")
+
+	result, err := AwaitResult(
+		func() (int, error) { return 42, errNotReady },
+		250*time.Millisecond,
+	)
+
+	if !errors.Is(err, errNotReady) {
+		t.Errorf("expected the last error to be wrapped, got %v", err)
+	}
+
+	if result != 0 {
+		t.Errorf("expected the zero value, got %d", result)
+	}
+}
+
+func TestAwaitTimeoutIncludesLastError(t *testing.T) {
+	attempts := 0
+
+	err := Await(
+		func() error {
+			attempts++
+			return fmt.Errorf("permission denied (attempt %d)", attempts)
+		},
+		250*time.Millisecond,
+	)
+	if err == nil {
+		t.Fatal("expected a timeout error")
+	}
+
+	expected := fmt.Sprintf(
+		"timed out after 250ms: last error: permission denied (attempt %d)",
+		attempts,
+	)
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestAwaitWithContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	errNotReady := errors.New("not ready")
+
+	start := time.Now()
+	err := AwaitWithContext(
+		ctx, func() error { return errNotReady }, 10*time.Second,
+	)
+
+	if err != errNotReady {
+		t.Errorf("expected the last error, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected to return soon after cancellation, took %s", elapsed)
+	}
+}
+
+func TestAwaitWithContextInterval(t *testing.T) {
+	attemptsWithInterval := func(interval time.Duration) int {
+		ctx, cancel := context.WithTimeout(
+			context.Background(), 300*time.Millisecond,
+		)
+		defer cancel()
+
+		attempts := 0
+		_ = AwaitWithContext(
+			ctx,
+			func() error {
+				attempts++
+				return errors.New("not ready")
+			},
+			interval,
+		)
+
+		return attempts
+	}
+
+	slow := attemptsWithInterval(100 * time.Millisecond)
+	fast := attemptsWithInterval(10 * time.Millisecond)
+
+	if slow < 2 || slow > 4 {
+		t.Errorf("expected 2-4 attempts at a 100ms interval, got %d", slow)
+	}
+
+	if fast < 3*slow {
+		t.Errorf(
+			"expected many more attempts at a 10ms interval than at a 100ms "+
+				"interval, got %d and %d",
+			fast, slow,
+		)
+	}
+}
+
+func TestAwaitResultWithBackoff(t *testing.T) {
+	attempts := 0
+
+	result, err := AwaitResultWithBackoff(
+		func() (string, error) {
+			attempts++
+			if attempts < 3 {
+				return "", errors.New("not yet")
+			}
+
+			return "ready", nil
+		},
+		time.Second, time.Millisecond, 10*time.Millisecond,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != "ready" {
+		t.Errorf("expected %q, got %q", "ready", result)
+	}
+
+	errNotReady := errors.New("not ready")
+
+	number, err := AwaitResultWithBackoff(
+		func() (int, error) { return 42, errNotReady },
+		50*time.Millisecond, time.Millisecond, 10*time.Millisecond,
+	)
+
+	if err != errNotReady {
+		t.Errorf("expected the last error, got %v", err)
+	}
+
+	if number != 0 {
+		t.Errorf("expected the zero value, got %d", number)
+	}
+}
@@ -453,6 +453,14 @@ class MidiEngine {
     )
   }
 
+  // `bend` is a 14-bit value (0-16383), where 8192 means no bend. It's sent as
+  // two 7-bit data bytes, least significant first.
+  fun pitchBend(offset : Int, channel : Int, bend : Int) {
+    scheduleShortMsg(
+      offset, ShortMessage.PITCH_BEND, channel, bend and 0x7F, bend shr 7
+    )
+  }
+
   // Schedules an event to occur at the desired offset.
   //
   // Returns a CountDownLatch that will count down from 1 to 0 when the event is
@@ -514,6 +522,25 @@ class MidiEngine {
     withChannel(channelNumber) { it.setMute(false) }
   }
 
+  // The latency of the synthesizer's audio output, in microseconds.
+  fun latencyMicros() : Long {
+    return synthesizer.getLatency()
+  }
+
+  // Replaces the synthesizer's instruments with the ones in the soundfont
+  // (.sf2) file at the provided path. Instruments that aren't in the soundfont
+  // fall back to the synthesizer's default soundbank.
+  fun loadSoundfont(filepath : String) {
+    log.info { "Loading soundfont: ${filepath}" }
+
+    try {
+      val soundbank = MidiSystem.getSoundbank(File(filepath))
+      synthesizer.loadAllInstruments(soundbank)
+    } catch (e : Exception) {
+      log.error(e) { "Failed to load soundfont: ${filepath}" }
+    }
+  }
+
   fun export(filepath : String) {
     // We make a copy of the sequence so that we can shift the tick position of
     // each event in the sequence back such that the first event starts at tick
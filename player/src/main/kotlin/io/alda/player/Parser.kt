@@ -117,6 +117,20 @@ class MidiPanningEvent(
   override fun endOffset() = 0
 }
 
+class MidiPitchBendEvent(
+  val offset : Int, val bend : Int
+) : Event, Schedulable {
+  override fun addOffset(o : Int) : MidiPitchBendEvent {
+    return MidiPitchBendEvent(offset + o, bend)
+  }
+
+  override fun schedule(channel : Int) {
+    midi().pitchBend(offset, channel, bend)
+  }
+
+  override fun endOffset() = 0
+}
+
 abstract class PatternEventBase(
   open val offset : Int, open val patternName : String
 ) {
@@ -165,6 +179,34 @@ class MidiExportEvent(val filepath : String) : Event {
   override fun endOffset() = 0
 }
 
+class SoundfontEvent(val filepath : String) : Event {
+  override fun addOffset(o : Int) : SoundfontEvent {
+    return SoundfontEvent(filepath)
+  }
+
+  override fun endOffset() = 0
+}
+
+class LatencyRequestEvent(
+  val replyHost : String?, val replyPort : Int
+) : Event {
+  override fun addOffset(o : Int) : LatencyRequestEvent {
+    return LatencyRequestEvent(replyHost, replyPort)
+  }
+
+  override fun endOffset() = 0
+}
+
+class ClockRequestEvent(
+  val replyHost : String?, val replyPort : Int
+) : Event {
+  override fun addOffset(o : Int) : ClockRequestEvent {
+    return ClockRequestEvent(replyHost, replyPort)
+  }
+
+  override fun endOffset() = 0
+}
+
 class Updates() {
   var systemActions  = mutableSetOf<SystemAction>()
   var trackActions   = mutableMapOf<Int, Set<TrackAction>>()
@@ -286,6 +328,23 @@ class Updates() {
           systemEvents.add(MidiExportEvent(filepath))
         }
 
+        Regex("/system/soundfont").matches(address) -> {
+          val filepath = args.get(0) as String
+          systemEvents.add(SoundfontEvent(filepath))
+        }
+
+        Regex("/system/latency").matches(address) -> {
+          val replyPort = args.get(0) as Int
+          val replyHost = args.getOrNull(1) as String?
+          systemEvents.add(LatencyRequestEvent(replyHost, replyPort))
+        }
+
+        Regex("/system/clock").matches(address) -> {
+          val replyPort = args.get(0) as Int
+          val replyHost = args.getOrNull(1) as String?
+          systemEvents.add(ClockRequestEvent(replyHost, replyPort))
+        }
+
         Regex("/track/\\d+/unmute").matches(address) -> {
           addTrackAction(trackNumber(address), TrackAction.UNMUTE)
         }
@@ -336,6 +395,12 @@ class Updates() {
           addTrackEvent(trackNumber(address), MidiPanningEvent(offset, panning))
         }
 
+        Regex("/track/\\d+/midi/pitch-bend").matches(address) -> {
+          val offset = args.get(0) as Int
+          val bend   = args.get(1) as Int
+          addTrackEvent(trackNumber(address), MidiPitchBendEvent(offset, bend))
+        }
+
         Regex("/track/\\d+/pattern").matches(address) -> {
           val offset      = args.get(0) as Int
           val patternName = args.get(1) as String
@@ -396,6 +461,14 @@ class Updates() {
           )
         }
 
+        Regex("/pattern/[^/]+/midi/pitch-bend").matches(address) -> {
+          val offset = args.get(0) as Int
+          val bend   = args.get(1) as Int
+          addPatternEvent(
+            patternName(address), MidiPitchBendEvent(offset, bend)
+          )
+        }
+
         Regex("/pattern/[^/]+/pattern").matches(address) -> {
           val offset      = args.get(0) as Int
           val patternName = args.get(1) as String
@@ -442,7 +442,31 @@ private fun applyUpdates(updates : Updates) {
     }
   }
 
-  // PHASE 2: update tempo and patterns
+  updates.systemEvents.filter { it is LatencyRequestEvent }.forEach {
+    val latencyRequestEvent = it as LatencyRequestEvent
+    reply(
+      latencyRequestEvent.replyHost,
+      latencyRequestEvent.replyPort,
+      OSCMessage(
+        "/system/latency/reply", listOf(midi().latencyMicros().toInt())
+      )
+    )
+  }
+
+  updates.systemEvents.filter { it is ClockRequestEvent }.forEach {
+    val clockRequestEvent = it as ClockRequestEvent
+    reply(
+      clockRequestEvent.replyHost,
+      clockRequestEvent.replyPort,
+      OSCMessage("/system/clock/reply", listOf(System.currentTimeMillis()))
+    )
+  }
+
+  // PHASE 2: update soundfont, tempo and patterns
+
+  updates.systemEvents.filter { it is SoundfontEvent }.forEach {
+    midi().loadSoundfont((it as SoundfontEvent).filepath)
+  }
 
   updates.systemEvents.filter { it is TempoEvent }.forEach {
     val tempoEvent = it as TempoEvent
@@ -9,6 +9,9 @@ import com.illposed.osc.OSCPacketListener
 import com.illposed.osc.transport.NetworkProtocol
 import com.illposed.osc.transport.OSCPortIn
 import com.illposed.osc.transport.OSCPortInBuilder
+import com.illposed.osc.transport.OSCPortOutBuilder
+import java.net.InetAddress
+import java.net.InetSocketAddress
 import mu.KotlinLogging
 
 private val log = KotlinLogging.logger {}
@@ -37,3 +40,29 @@ fun receiver(port : Int) : OSCPortIn {
   }).build()
 }
 
+// Sends a message to a client that asked the player for information (e.g. the
+// latency of its audio output) and is listening for the reply on the provided
+// host and port.
+//
+// Older clients don't include a host in their requests, in which case they're
+// running on the same host as the player.
+fun reply(host : String?, port : Int, msg : OSCMessage) {
+  val address = if (host == null) {
+    InetAddress.getLoopbackAddress()
+  } else {
+    InetAddress.getByName(host)
+  }
+
+  val portOut = OSCPortOutBuilder()
+    .setRemoteSocketAddress(InetSocketAddress(address, port))
+    .setNetworkProtocol(NetworkProtocol.TCP)
+    .build()
+
+  try {
+    portOut.send(msg)
+  } catch (e : Exception) {
+    log.error(e) { "Failed to send reply to ${address}:${port}" }
+  } finally {
+    portOut.close()
+  }
+}